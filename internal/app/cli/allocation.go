@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// allocationCmd represents the allocation command
+var allocationCmd = &cobra.Command{
+	Use:   "allocation",
+	Short: "Portfolio weights by sector, country, currency and asset class",
+	Long: `Weigh the positions held at each year end by asset class, sector, country,
+currency and domicile, and warn about concentrations.
+
+Sector, country, asset class, TER and domicile come from a security metadata file
+keyed by ISIN, given with --metadata. It is YAML:
+
+  version: 1
+  securities:
+    IE00B4L5Y983:
+      name: iShares Core MSCI World
+      sector: Diversified
+      country: Global
+      asset_class: Equity ETF
+      ter: 0.20
+      domicile: IE
+
+or CSV with a header naming the columns: isin,sector,country,asset_class,ter,domicile
+(name and currency are optional). Positions without metadata have an unknown
+country, since a fund's ISIN prefix is where it is registered rather than where
+it invests, and take their domicile from the ISIN prefix and their currency from
+their trades.
+
+Weights are percentages of the positions' market value, excluding cash. A position
+above --max-position or a sector or country above --max-group is reported.
+
+Examples:
+  # Allocation at every year end
+  t212-taxes allocation --dir ./exports --metadata ./securities.yaml --prices ./prices.csv
+
+  # 2024 only, warning about any position above 10%
+  t212-taxes allocation --dir ./exports --metadata ./securities.csv --year 2024 --max-position 10`,
+	Run: generateAllocationReport,
+}
+
+// generateAllocationReport handles the allocation command
+func generateAllocationReport(cmd *cobra.Command, args []string) {
+	finCalc := calculator.NewFinancialCalculator(viper.GetString("currency"))
+	if prices := priceProvider(cmd); prices != nil {
+		finCalc.SetPriceProvider(prices)
+	}
+
+	limits := calculator.DefaultConcentrationLimits()
+	limits.Position, _ = cmd.Flags().GetFloat64("max-position")
+	limits.Group, _ = cmd.Flags().GetFloat64("max-group")
+
+	result := parseTransactions(cmd)
+	report := finCalc.CalculateAllocation(result.Transactions, securityMetadata(cmd), limits)
+	if year, _ := cmd.Flags().GetInt("year"); year != 0 {
+		var years []calculator.YearAllocation
+		for _, allocation := range report.Years {
+			if allocation.Year == year {
+				years = append(years, allocation)
+			}
+		}
+		report.Years = years
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding allocation report: %v", err)
+		}
+	} else {
+		printAllocationReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Allocation report saved to %s\n", outputFile)
+	}
+}
+
+// securityMetadata returns the metadata in the --metadata file, or nil when the flag is not set
+func securityMetadata(cmd *cobra.Command) *calculator.SecurityMetadataStore {
+	metadataFile, _ := cmd.Flags().GetString("metadata")
+	if metadataFile == "" {
+		return nil
+	}
+	store, err := calculator.LoadSecurityMetadata(metadataFile)
+	if err != nil {
+		log.Fatalf("Error loading security metadata: %v", err)
+	}
+	return store
+}
+
+// printAllocationReport prints the weights per dimension at each year end
+func printAllocationReport(out io.Writer, report *calculator.AllocationReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintln(out, "        🧭 ASSET ALLOCATION")
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "Note: %s\n", report.PriceNote)
+
+	if len(report.Years) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo positions found.")
+		return
+	}
+
+	for _, allocation := range report.Years {
+		_, _ = fmt.Fprintf(out, "\n📅 %s: %.2f %s in %d positions", allocation.AsOfDate.Format("2006-01-02"),
+			allocation.TotalValue, report.Currency, len(allocation.Positions))
+		if allocation.WeightedTER != nil {
+			_, _ = fmt.Fprintf(out, ", weighted TER %.2f%%", *allocation.WeightedTER)
+		}
+		_, _ = fmt.Fprintln(out)
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+
+		for _, breakdown := range allocation.Breakdowns {
+			_, _ = fmt.Fprintf(out, "%s\n", strings.ToUpper(strings.ReplaceAll(string(breakdown.Dimension), "_", " ")))
+			for _, weight := range breakdown.Weights {
+				_, _ = fmt.Fprintf(out, "  %-24s %7.2f%% %14.2f %4d\n", weight.Group, weight.Weight, weight.Value, weight.Positions)
+			}
+		}
+
+		for _, warning := range allocation.Warnings {
+			_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+		}
+	}
+}
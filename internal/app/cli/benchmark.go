@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// benchmarkCmd represents the benchmark command
+var benchmarkCmd = &cobra.Command{
+	Use:   "benchmark",
+	Short: "Compare portfolio returns with a benchmark",
+	Long: `Compare the portfolio's time-weighted returns with a benchmark such as VWCE or
+the S&P 500, per calendar year and overall.
+
+The benchmark's closing prices, in your base currency, come from a CSV file with
+date,close rows, or a price file with date,security,price rows and --benchmark-symbol
+to pick the security. Every deposit and withdrawal is replayed as if invested in
+the benchmark at its close that day, so both sides see the same cash flows.
+
+Reported per year:
+  • Portfolio and benchmark return, and their cumulative return since the start
+  • Tracking difference: portfolio return less benchmark return
+  • Beta of the daily returns and alpha: portfolio return less beta times the
+    benchmark return, with no risk-free rate
+  • What the same deposits would be worth in the benchmark
+
+Examples:
+  # Compare with VWCE closes
+  t212-taxes benchmark --dir ./exports --prices ./prices.csv --benchmark ./vwce.csv
+
+  # Pick the S&P 500 tracker from a price file
+  t212-taxes benchmark --dir ./exports --prices ./prices.csv --benchmark ./prices.csv --benchmark-symbol CSPX`,
+	Run: generateBenchmarkReport,
+}
+
+// generateBenchmarkReport handles the benchmark command
+func generateBenchmarkReport(cmd *cobra.Command, args []string) {
+	benchmark := loadBenchmark(cmd)
+	if benchmark == nil {
+		log.Fatal("--benchmark is required")
+	}
+
+	calc := calculator.NewFinancialCalculator(viper.GetString("currency"))
+	if prices := priceProvider(cmd); prices != nil {
+		calc.SetPriceProvider(prices)
+	}
+
+	result := parseTransactions(cmd)
+	report, err := calc.CompareBenchmark(result.Transactions, benchmark, seriesDate(cmd, "to"))
+	if err != nil {
+		log.Fatalf("Error comparing with benchmark: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding benchmark report: %v", err)
+		}
+	} else {
+		printBenchmarkReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Benchmark report saved to %s\n", outputFile)
+	}
+}
+
+// loadBenchmark returns the benchmark in the --benchmark file, or nil when the flag is not set
+func loadBenchmark(cmd *cobra.Command) *calculator.Benchmark {
+	benchmarkFile, _ := cmd.Flags().GetString("benchmark")
+	if benchmarkFile == "" {
+		return nil
+	}
+	symbol, _ := cmd.Flags().GetString("benchmark-symbol")
+	benchmark, err := calculator.LoadBenchmark(benchmarkFile, symbol)
+	if err != nil {
+		log.Fatalf("Error loading benchmark: %v", err)
+	}
+	return benchmark
+}
+
+// printBenchmarkReport prints the portfolio and benchmark returns side by side per year and overall
+func printBenchmarkReport(out io.Writer, report *calculator.BenchmarkReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "        🏁 PORTFOLIO VS %s\n", report.Benchmark)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "Note: %s\n", report.PriceNote)
+
+	if len(report.Years) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo transactions found.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n%-8s %10s %10s %11s %11s %10s %7s %9s %13s %13s\n",
+		"Period", "Portfolio", "Benchmark", "Cum. Port.", "Cum. Bench.", "Tracking", "Beta", "Alpha", "Value", "Bench. Value")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	for _, comparison := range report.Years {
+		printBenchmarkComparison(out, fmt.Sprintf("%d", comparison.Year), comparison)
+	}
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	printBenchmarkComparison(out, "Overall", report.Overall)
+
+	_, _ = fmt.Fprintf(out, "\nMoney-weighted (annualised): portfolio %s, benchmark %s\n",
+		formatRate(report.Overall.PortfolioMoneyWeighted), formatRate(report.Overall.BenchmarkMoneyWeighted))
+	_, _ = fmt.Fprintf(out, "All amounts in %s; tracking is portfolio less benchmark return\n", report.Currency)
+}
+
+// printBenchmarkComparison prints one row of the benchmark table
+func printBenchmarkComparison(out io.Writer, label string, comparison calculator.BenchmarkComparison) {
+	_, _ = fmt.Fprintf(out, "%-8s %9.2f%% %9.2f%% %10.2f%% %10.2f%% %+9.2f%% %7.2f %+8.2f%% %13.2f %13.2f\n",
+		label, comparison.PortfolioReturn, comparison.BenchmarkReturn, comparison.PortfolioCumulative,
+		comparison.BenchmarkCumulative, comparison.TrackingDifference, comparison.Beta, comparison.Alpha,
+		comparison.PortfolioValue, comparison.BenchmarkValue)
+}
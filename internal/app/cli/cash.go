@@ -0,0 +1,194 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// cashCmd represents the cash command
+var cashCmd = &cobra.Command{
+	Use:   "cash",
+	Short: "Cash balance per currency, reconciled with the statement",
+	Long: `Replay every row of the exports into a cash ledger per currency: deposits,
+withdrawals, buys, sells, dividends, interest, fees, card spending and currency
+conversions. Rows of unknown types are booked by the sign of their Total and
+counted in the report.
+
+The balances at --as-of are compared with the balances on your Trading 212
+statement given with --statement, and the cash held at every year end is listed
+for Box 3 and foreign-asset declarations. Foreign currency balances are valued
+with --fx-rates (ECB style: foreign currency per unit of base currency), or
+else, when --currency is the account currency, the last rate Trading 212
+applied. Balances without a rate are shown unconverted.
+
+Examples:
+  # Balances today and at every year end
+  t212-taxes cash --dir ./exports
+
+  # Reconcile with the statement balance on 31 December 2024
+  t212-taxes cash --dir ./exports --as-of 2024-12-31 --statement EUR=1234.56,USD=10.20
+
+  # Every movement as CSV
+  t212-taxes cash --dir ./exports --format csv --output cash.csv`,
+	Run: generateCashReport,
+}
+
+// generateCashReport handles the cash command
+func generateCashReport(cmd *cobra.Command, args []string) {
+	currency := viper.GetString("currency")
+	cashCalc := calculator.NewCashCalculator(currency)
+	if ratesFile, _ := cmd.Flags().GetString("fx-rates"); ratesFile != "" {
+		rates, err := calculator.LoadRateTable(ratesFile, currency, calculator.FXSource{
+			Name:   ratesFile,
+			Quote:  calculator.QuoteForeignPerBase,
+			Lookup: calculator.LookupSameDay,
+		})
+		if err != nil {
+			log.Fatalf("Error loading exchange rates: %v", err)
+		}
+		cashCalc.SetFXRateProvider(rates)
+	}
+
+	result := parseTransactions(cmd)
+	report := cashCalc.CalculateCashReport(result.Transactions, seriesDate(cmd, "as-of"), statementBalances(cmd))
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding cash report: %v", err)
+		}
+	case CSVFormat:
+		if err := writeCashCSV(out, report); err != nil {
+			log.Fatalf("Error writing cash ledger CSV: %v", err)
+		}
+	default:
+		printCashReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Cash report saved to %s\n", outputFile)
+	}
+}
+
+// statementBalances returns the --statement balances keyed by upper-case currency
+func statementBalances(cmd *cobra.Command) map[string]float64 {
+	flags, _ := cmd.Flags().GetStringToString("statement")
+	balances := make(map[string]float64, len(flags))
+	for currency, value := range flags {
+		balance, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid --statement balance for %s: %v", currency, err)
+		}
+		balances[strings.ToUpper(currency)] = balance
+	}
+	return balances
+}
+
+// writeCashCSV writes one row per ledger movement
+func writeCashCSV(out io.Writer, report *calculator.CashReport) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"date", "kind", "action", "id", "ticker", "currency", "amount", "balance"}); err != nil {
+		return err
+	}
+
+	for _, entry := range report.Entries {
+		if err := writer.Write([]string{
+			entry.Date.Format("2006-01-02 15:04:05"),
+			string(entry.Kind),
+			entry.Action,
+			entry.ID,
+			entry.Ticker,
+			entry.Currency,
+			strconv.FormatFloat(entry.Amount, 'f', 2, 64),
+			strconv.FormatFloat(entry.Balance, 'f', 2, 64),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// printCashReport prints the balances, the reconciliation and the year-end cash
+func printCashReport(out io.Writer, report *calculator.CashReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        💶 CASH BALANCES (as of %s)\n", report.AsOf.Format("2006-01-02"))
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	if len(report.Entries) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo cash movements found.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n%-10s %16s %16s\n", "Currency", "Balance", "Value "+report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, balance := range report.Balances {
+		printCashBalance(out, balance)
+	}
+	_, _ = fmt.Fprintf(out, "%-10s %16s %16.2f\n", "Total", "", report.BaseValue)
+
+	if len(report.Reconciliation) > 0 {
+		_, _ = fmt.Fprintln(out, "\n🔍 Statement Reconciliation")
+		_, _ = fmt.Fprintf(out, "%-10s %16s %16s %14s\n", "Currency", "Ledger", "Statement", "Difference")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, row := range report.Reconciliation {
+			status := "✅"
+			if !row.Matched {
+				status = "⚠️"
+			}
+			_, _ = fmt.Fprintf(out, "%-10s %16.2f %16.2f %+14.2f %s\n", row.Currency, row.Ledger, row.Statement, row.Difference, status)
+		}
+	}
+
+	_, _ = fmt.Fprintln(out, "\n📅 Year-End Cash (31 December)")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, yearEnd := range report.YearEnd {
+		_, _ = fmt.Fprintf(out, "%d: %.2f %s\n", yearEnd.Year, yearEnd.BaseValue, report.Currency)
+		for _, balance := range yearEnd.Balances {
+			_, _ = fmt.Fprint(out, "  ")
+			printCashBalance(out, balance)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "\nMovements: %d • rows without cash: %d • rows of unknown type: %d\n",
+		len(report.Entries), report.Skipped, report.Unclassified)
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printCashBalance prints one currency balance and its value in the base currency
+func printCashBalance(out io.Writer, balance calculator.CashBalance) {
+	value := fmt.Sprintf("%16.2f", balance.BaseValue)
+	if !balance.Converted {
+		value = fmt.Sprintf("%16s", "no rate")
+	}
+	_, _ = fmt.Fprintf(out, "%-10s %16.2f %s\n", balance.Currency, balance.Balance, value)
+}
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -41,6 +42,7 @@ const (
 	DefaultMaxHoldings = 10
 	JSONFormat         = "json"
 	TableFormat        = "table"
+	CSVFormat          = "csv"
 	SeparatorWidth80   = 80
 	SeparatorWidth60   = 60
 	SeparatorWidth50   = 50
@@ -179,6 +181,19 @@ func init() {
 	RootCmd.AddCommand(validateCmd)
 	RootCmd.AddCommand(incomeCmd)
 	RootCmd.AddCommand(portfolioCmd)
+	RootCmd.AddCommand(taxCmd)
+	RootCmd.AddCommand(reclaimCmd)
+	RootCmd.AddCommand(reconcileCmd)
+	RootCmd.AddCommand(simulateCmd)
+	RootCmd.AddCommand(harvestCmd)
+	RootCmd.AddCommand(planCmd)
+	RootCmd.AddCommand(seriesCmd)
+	RootCmd.AddCommand(returnsCmd)
+	RootCmd.AddCommand(benchmarkCmd)
+	RootCmd.AddCommand(cashCmd)
+	RootCmd.AddCommand(fxCmd)
+	RootCmd.AddCommand(historyCmd)
+	RootCmd.AddCommand(allocationCmd)
 	RootCmd.AddCommand(versionCmd)
 
 	// Global flags
@@ -191,10 +206,16 @@ func init() {
 	processCmd.Flags().String("files", "", "Comma-separated list of CSV files")
 	processCmd.Flags().String("output", "", "Output file for results (JSON format)")
 	processCmd.Flags().String("format", "table", "Output format (table, json)")
+	processCmd.Flags().String("jurisdiction", "", "Match lots and carry capital losses forward under this jurisdiction's rules")
 
 	// Analyze command flags
 	analyzeCmd.Flags().String("dir", "", "Directory containing CSV files")
 	analyzeCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	analyzeCmd.Flags().String("jurisdiction", "", "Match lots and carry capital losses forward under this jurisdiction's rules")
+	analyzeCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	analyzeCmd.Flags().String("benchmark", "", "CSV file with benchmark closes (date,close, or date,security,price)")
+	analyzeCmd.Flags().String("benchmark-symbol", "", "Security to use from a benchmark file with several")
+	analyzeCmd.Flags().String("metadata", "", "YAML or CSV file with security metadata by ISIN for the allocation view")
 
 	// Validate command flags
 	validateCmd.Flags().String("dir", "", "Directory containing CSV files")
@@ -214,6 +235,161 @@ func init() {
 	portfolioCmd.Flags().String("format", TableFormat, "Output format (table, json)")
 	portfolioCmd.Flags().Int("max-holdings", DefaultMaxHoldings, "Maximum number of holdings to display per year")
 	portfolioCmd.Flags().Bool("show-all", false, "Show all positions (ignores max-holdings limit)")
+	portfolioCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	portfolioCmd.Flags().String("as-of", "", "Value the portfolio at the close of this date, YYYY-MM-DD, instead of each year end")
+
+	// Tax command flags
+	taxCmd.Flags().String("dir", "", "Directory containing CSV files")
+	taxCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	taxCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction code (US, UK, BG, LT, DE, PL, IE, NL, ES, PT)")
+	taxCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	taxCmd.Flags().String("treaty-rates", "", "YAML treaty-rate table replacing the built-in one for foreign tax credits")
+	taxCmd.Flags().Int("year", 0, "Tax year, labelled by the calendar year it starts in (default: the tax year of the latest transaction)")
+	taxCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	taxCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	taxCmd.Flags().String("csv-dir", "", "Directory to write declaration annex CSV files (BG, US)")
+	taxCmd.Flags().Float64("church-tax", 0, "Church tax rate, e.g. 0.08 or 0.09 (DE)")
+	taxCmd.Flags().Bool("joint", false, "Joint assessment with doubled saver's allowance (DE)")
+	taxCmd.Flags().Float64("share-losses", 0, "Share losses brought forward into the year, overriding those from earlier exports (DE)")
+	taxCmd.Flags().Float64("other-losses", 0, "Other capital losses brought forward into the year, overriding those from earlier exports (DE)")
+	taxCmd.Flags().Float64("cash", 0, "Bank cash outside Trading 212 on 1 January (NL); Trading 212 cash comes from the exports")
+	taxCmd.Flags().Float64("debts", 0, "Box 3 debts on 1 January (NL)")
+	taxCmd.Flags().Bool("fiscal-partner", false, "Double the tax-free allowance for fiscal partners (NL)")
+	taxCmd.Flags().Float64("other-income", 0, "Other taxable income for bracketed rates (UK, US, PT)")
+	taxCmd.Flags().Bool("aggregate-income", false, "Tax investment income at the progressive income rates (PT englobamento)")
+	taxCmd.Flags().StringToString("instrument", nil, "Instrument classification overrides as ISIN=type (DE, IE)")
+	taxCmd.Flags().String("output", "", "Output file for results")
+	taxCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Reclaim command flags
+	reclaimCmd.Flags().String("dir", "", "Directory containing CSV files")
+	reclaimCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	reclaimCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction code of your country of residence")
+	reclaimCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	reclaimCmd.Flags().String("treaty-rates", "", "YAML treaty-rate table replacing the built-in one")
+	reclaimCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	reclaimCmd.Flags().Int("year", 0, "Only include dividends paid in this year (default: all years)")
+	reclaimCmd.Flags().String("as-of", "", "Date to check deadlines against, as YYYY-MM-DD (default: today)")
+	reclaimCmd.Flags().String("output", "", "Output file for results")
+	reclaimCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// Reconcile command flags
+	reconcileCmd.Flags().String("dir", "", "Directory containing CSV files")
+	reconcileCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	reconcileCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction whose matching method and FX source to use")
+	reconcileCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	reconcileCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	reconcileCmd.Flags().Int("year", 0, "Only include sells in this tax year (default: all years)")
+	reconcileCmd.Flags().Float64("tolerance", calculator.GainDiscrepancyTolerance, "Flag sells whose difference exceeds this amount")
+	reconcileCmd.Flags().String("output", "", "Output file for results")
+	reconcileCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// Simulate command flags
+	simulateCmd.Flags().String("dir", "", "Directory containing CSV files")
+	simulateCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	simulateCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction whose rules to apply")
+	simulateCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	simulateCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	simulateCmd.Flags().StringArray("sell", nil, "Hypothetical sell as SECURITY:SHARES@PRICE[:YYYY-MM-DD] (repeatable)")
+	simulateCmd.Flags().String("date", "", "Date of sells given without one, YYYY-MM-DD (default: today)")
+	simulateCmd.Flags().Float64("other-income", 0, "Other taxable income for bracketed rates (UK, US, PT)")
+	simulateCmd.Flags().Bool("aggregate-income", false, "Tax investment income at the progressive income rates (PT englobamento)")
+	simulateCmd.Flags().String("output", "", "Output file for results")
+	simulateCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Harvest command flags
+	harvestCmd.Flags().String("dir", "", "Directory containing CSV files")
+	harvestCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	harvestCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction whose rules to apply")
+	harvestCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	harvestCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	harvestCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	harvestCmd.Flags().StringToString("price", nil, "Current price per share as TICKER=price or ISIN=price, in the trading currency")
+	harvestCmd.Flags().String("as-of", "", "Date to value positions on, YYYY-MM-DD (default: today)")
+	harvestCmd.Flags().Float64("other-income", 0, "Other taxable income for bracketed rates (UK, US, PT)")
+	harvestCmd.Flags().Bool("aggregate-income", false, "Tax investment income at the progressive income rates (PT englobamento)")
+	harvestCmd.Flags().String("output", "", "Output file for results")
+	harvestCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Plan command flags
+	planCmd.Flags().String("dir", "", "Directory containing CSV files")
+	planCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	planCmd.Flags().String("jurisdiction", "LT", "Tax jurisdiction whose allowance and matching rules to apply")
+	planCmd.Flags().StringSlice("jurisdiction-file", nil, "YAML jurisdiction definitions, as files or directories, added to the built-in ones")
+	planCmd.Flags().String("fx-rates", "", "CSV file with official exchange rates (date,currency,rate)")
+	planCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	planCmd.Flags().StringToString("price", nil, "Current price per share as TICKER=price or ISIN=price, in the trading currency")
+	planCmd.Flags().String("as-of", "", "Date of the planned sells, YYYY-MM-DD (default: today)")
+	planCmd.Flags().Float64("buffer", 0, "Amount to stay below the allowance by")
+	planCmd.Flags().Float64("other-income", 0, "Other taxable income for bracketed rates (UK, US, PT)")
+	planCmd.Flags().Bool("aggregate-income", false, "Tax investment income at the progressive income rates (PT englobamento)")
+	planCmd.Flags().String("output", "", "Output file for results")
+	planCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Series command flags
+	seriesCmd.Flags().String("dir", "", "Directory containing CSV files")
+	seriesCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	seriesCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	seriesCmd.Flags().String("interval", string(calculator.SeriesMonthly), "Snapshot interval (daily, monthly)")
+	seriesCmd.Flags().String("from", "", "First date, YYYY-MM-DD (default: first transaction)")
+	seriesCmd.Flags().String("to", "", "Last date, YYYY-MM-DD (default: last transaction)")
+	seriesCmd.Flags().String("output", "", "Output file for results")
+	seriesCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// Returns command flags
+	returnsCmd.Flags().String("dir", "", "Directory containing CSV files")
+	returnsCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	returnsCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	returnsCmd.Flags().String("to", "", "Last date, YYYY-MM-DD (default: last transaction)")
+	returnsCmd.Flags().Bool("positions", false, "Include returns per position")
+	returnsCmd.Flags().String("output", "", "Output file for results")
+	returnsCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Benchmark command flags
+	benchmarkCmd.Flags().String("dir", "", "Directory containing CSV files")
+	benchmarkCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	benchmarkCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	benchmarkCmd.Flags().String("benchmark", "", "CSV file with benchmark closes (date,close, or date,security,price)")
+	benchmarkCmd.Flags().String("benchmark-symbol", "", "Security to use from a benchmark file with several")
+	benchmarkCmd.Flags().String("to", "", "Last date, YYYY-MM-DD (default: last transaction)")
+	benchmarkCmd.Flags().String("output", "", "Output file for results")
+	benchmarkCmd.Flags().String("format", TableFormat, "Output format (table, json)")
+
+	// Cash command flags
+	cashCmd.Flags().String("dir", "", "Directory containing CSV files")
+	cashCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	cashCmd.Flags().String("fx-rates", "", "CSV file with exchange rates (date,currency,rate) as foreign currency per base unit")
+	cashCmd.Flags().String("as-of", "", "Balance date, YYYY-MM-DD (default: last transaction)")
+	cashCmd.Flags().StringToString("statement", nil, "Statement balances on the as-of date as CURRENCY=balance")
+	cashCmd.Flags().String("output", "", "Output file for results")
+	cashCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// FX command flags
+	fxCmd.Flags().String("dir", "", "Directory containing CSV files")
+	fxCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	fxCmd.Flags().String("fx-rates", "", "CSV file with exchange rates (date,currency,rate) as foreign currency per base unit")
+	fxCmd.Flags().Int("year", 0, "Only show this year's disposals (default: all years)")
+	fxCmd.Flags().String("output", "", "Output file for results")
+	fxCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// History command flags
+	historyCmd.Flags().String("dir", "", "Directory containing CSV files")
+	historyCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	historyCmd.Flags().String("security", "", "ISIN or ticker of the position")
+	historyCmd.Flags().String("jurisdiction", "", "Match sells with this jurisdiction's method (default: FIFO)")
+	historyCmd.Flags().String("output", "", "Output file for results")
+	historyCmd.Flags().String("format", TableFormat, "Output format (table, json, csv)")
+
+	// Allocation command flags
+	allocationCmd.Flags().String("dir", "", "Directory containing CSV files")
+	allocationCmd.Flags().String("files", "", "Comma-separated list of CSV files")
+	allocationCmd.Flags().String("metadata", "", "YAML or CSV file with sector, country, asset class, TER and domicile by ISIN")
+	allocationCmd.Flags().String("prices", "", "CSV or JSON file with closing prices (date,security,price,currency) by ISIN or ticker")
+	allocationCmd.Flags().Int("year", 0, "Only show this year end (default: all years)")
+	allocationCmd.Flags().Float64("max-position", calculator.DefaultMaxPositionWeight, "Warn about positions above this weight in percent (0 disables)")
+	allocationCmd.Flags().Float64("max-group", calculator.DefaultMaxGroupWeight, "Warn about sectors and countries above this weight in percent (0 disables)")
+	allocationCmd.Flags().String("output", "", "Output file for results")
+	allocationCmd.Flags().String("format", TableFormat, "Output format (table, json)")
 
 	// Version command flags
 	versionCmd.Flags().String("format", TableFormat, "Output format (table, json)")
@@ -222,6 +398,8 @@ func init() {
 	_ = viper.BindPFlag("currency", RootCmd.PersistentFlags().Lookup("currency"))
 	_ = viper.BindPFlag("verbose", RootCmd.PersistentFlags().Lookup("verbose"))
 	_ = viper.BindPFlag("config", RootCmd.PersistentFlags().Lookup("config"))
+	_ = viper.BindPFlag("profile.other_income", taxCmd.Flags().Lookup("other-income"))
+	_ = viper.BindPFlag("profile.aggregate_income", taxCmd.Flags().Lookup("aggregate-income"))
 }
 
 // processFiles handles the process command
@@ -239,6 +417,7 @@ func processFiles(cmd *cobra.Command, args []string) {
 	csvParser := parser.NewCSVParser()
 	currency := viper.GetString("currency")
 	finCalc := calculator.NewFinancialCalculator(currency)
+	applyJurisdictionRules(cmd, finCalc)
 
 	// Parse files
 	fmt.Printf("Processing %d CSV files...\n", len(files))
@@ -285,6 +464,10 @@ func analyzeFiles(cmd *cobra.Command, args []string) {
 	csvParser := parser.NewCSVParser()
 	currency := viper.GetString("currency")
 	finCalc := calculator.NewFinancialCalculator(currency)
+	applyJurisdictionRules(cmd, finCalc)
+	if prices := priceProvider(cmd); prices != nil {
+		finCalc.SetPriceProvider(prices)
+	}
 
 	// Parse files
 	result, err := csvParser.ParseMultipleFiles(files)
@@ -317,6 +500,17 @@ func analyzeFiles(cmd *cobra.Command, args []string) {
 
 	// Show TUI with all available data
 	app := tui.NewAppWithAllData(yearlyReports, overallReport, result.Transactions, portfolioReport, incomeReport)
+	app.SetReturnsReport(finCalc.CalculateReturns(result.Transactions, time.Time{}))
+	if benchmark := loadBenchmark(cmd); benchmark != nil {
+		benchmarkReport, err := finCalc.CompareBenchmark(result.Transactions, benchmark, time.Time{})
+		if err != nil {
+			log.Printf("Warning: Could not compare with benchmark: %v", err)
+		} else {
+			app.SetBenchmarkReport(benchmarkReport)
+		}
+	}
+	app.SetAllocationReport(finCalc.CalculateAllocation(result.Transactions, securityMetadata(cmd),
+		calculator.DefaultConcentrationLimits()))
 	if err := app.Run(); err != nil {
 		log.Fatalf("Failed to start TUI: %v", err)
 	}
@@ -374,6 +568,25 @@ func validateFiles(cmd *cobra.Command, args []string) {
 	}
 }
 
+// applyJurisdictionRules applies the lot matching method and loss carry-forward rules of the
+// --jurisdiction flag to yearly reports
+func applyJurisdictionRules(cmd *cobra.Command, finCalc *calculator.FinancialCalculator) {
+	code, _ := cmd.Flags().GetString("jurisdiction")
+	if code == "" {
+		return
+	}
+
+	jurisdiction, exists := calculator.NewTaxCalculator().GetJurisdiction(strings.ToUpper(code))
+	if !exists {
+		log.Fatalf("Unsupported jurisdiction %q", code)
+	}
+	finCalc.SetMatchingMethod(jurisdiction.MatchingMethod)
+	if jurisdiction.RepurchaseRule != nil && jurisdiction.MatchingMethod == calculator.MatchingFourWeek {
+		finCalc.SetFourWeekDays(jurisdiction.RepurchaseRule.Days)
+	}
+	finCalc.SetLossCarryForward(jurisdiction.LossCarryForward)
+}
+
 // getCSVFiles gets CSV files from command flags
 func getCSVFiles(cmd *cobra.Command) ([]string, error) {
 	dir, _ := cmd.Flags().GetString("dir")
@@ -459,6 +672,20 @@ func saveReportsToFile(yearlyReports []types.YearlyReport, overallReport *types.
 			_, _ = fmt.Fprintf(file, "  Deposits: %.2f %s\n", report.TotalDeposits, report.Currency)
 			_, _ = fmt.Fprintf(file, "  Transactions: %d\n", report.TotalTransactions)
 			_, _ = fmt.Fprintf(file, "  Capital Gains: %.2f %s\n", report.CapitalGains, report.Currency)
+			_, _ = fmt.Fprintf(file, "  Trading 212 Result: %.2f %s\n", report.BrokerCapitalGains, report.Currency)
+			for _, discrepancy := range report.GainDiscrepancies {
+				_, _ = fmt.Fprintf(file, "  ⚠️  %s %s: matched %.2f vs Result %.2f (%+.2f)\n",
+					discrepancy.Date.Format("2006-01-02"), discrepancyLabel(discrepancy),
+					discrepancy.GainLoss, discrepancy.BrokerResult, discrepancy.Difference)
+			}
+			if report.SellsWithoutResult > 0 {
+				_, _ = fmt.Fprintf(file, "  Sells without a Result column: %d\n", report.SellsWithoutResult)
+			}
+			if report.LossesBroughtForward > 0 || report.LossesCarriedForward > 0 {
+				_, _ = fmt.Fprintf(file, "  Losses Brought Forward: %.2f %s\n", report.LossesBroughtForward, report.Currency)
+				_, _ = fmt.Fprintf(file, "  Losses Used: %.2f %s\n", report.LossesUsed, report.Currency)
+				_, _ = fmt.Fprintf(file, "  Losses Carried Forward: %.2f %s\n", report.LossesCarriedForward, report.Currency)
+			}
 			_, _ = fmt.Fprintf(file, "  Dividends: %.2f %s\n", report.Dividends, report.Currency)
 			_, _ = fmt.Fprintf(file, "  Total Gains: %.2f %s\n", report.TotalGains, report.Currency)
 			_, _ = fmt.Fprintf(file, "  Percentage Increase: %.2f%%\n\n", report.PercentageIncrease)
@@ -474,6 +701,14 @@ func saveReportsToFile(yearlyReports []types.YearlyReport, overallReport *types.
 	return nil
 }
 
+// discrepancyLabel names the security of a gain discrepancy
+func discrepancyLabel(discrepancy types.GainDiscrepancy) string {
+	if discrepancy.Ticker != "" {
+		return discrepancy.Ticker
+	}
+	return discrepancy.ISIN
+}
+
 // generateIncomeReport handles the income command
 func generateIncomeReport(cmd *cobra.Command, args []string) {
 	files, err := getCSVFiles(cmd)
@@ -687,6 +922,9 @@ func generatePortfolioReport(cmd *cobra.Command, args []string) {
 	// Initialize calculator
 	currency := viper.GetString("currency")
 	finCalc := calculator.NewFinancialCalculator(currency)
+	if prices := priceProvider(cmd); prices != nil {
+		finCalc.SetPriceProvider(prices)
+	}
 
 	// Parse files
 	fmt.Printf("Generating portfolio valuation report for %s...\n", dir)
@@ -699,8 +937,13 @@ func generatePortfolioReport(cmd *cobra.Command, args []string) {
 		log.Fatal("No CSV files found")
 	}
 
-	// Calculate portfolio reports
-	portfolioReport, err := finCalc.CalculatePortfolioReports(files)
+	// Calculate portfolio reports, or the single portfolio at --as-of
+	var portfolioReport *types.PortfolioValuationReport
+	if cmd.Flags().Changed("as-of") {
+		portfolioReport, err = finCalc.CalculatePortfolioAt(files, asOfDate(cmd))
+	} else {
+		portfolioReport, err = finCalc.CalculatePortfolioReports(files)
+	}
 	if err != nil {
 		log.Fatalf("Error calculating portfolio reports: %v", err)
 	}
@@ -749,6 +992,11 @@ func printPortfolioReportTable(report *types.PortfolioValuationReport, maxHoldin
 		fmt.Printf("Total Market Value:     %10.2f %s\n", yearly.TotalMarketValue, yearly.Currency)
 		fmt.Printf("Unrealized P&L:         %10.2f %s (%.2f%%)\n",
 			yearly.TotalUnrealizedGainLoss, yearly.Currency, yearly.TotalUnrealizedGainLossPercent)
+		fmt.Printf("Cash:                   %10.2f %s\n", yearly.Cash, yearly.Currency)
+		if yearly.StalePrices > 0 {
+			fmt.Printf("⚠️  %d position(s) valued at prices more than %d days old (marked !)\n",
+				yearly.StalePrices, calculator.StalePriceDays)
+		}
 
 		// Show yearly activity
 		fmt.Printf("\n💰 %d ACTIVITY\n", yearly.Year)
@@ -767,8 +1015,8 @@ func printPortfolioReportTable(report *types.PortfolioValuationReport, maxHoldin
 				fmt.Printf("\n🏆 TOP HOLDINGS %d\n", yearly.Year)
 			}
 			fmt.Println(strings.Repeat("-", SeparatorWidth100))
-			fmt.Printf("%-8s %-6s %-12s %-12s %-12s %-12s %-8s\n",
-				"Ticker", "Shares", "Avg Cost", "Last Price", "Total Cost", "Market Val", "P&L %")
+			fmt.Printf("%-8s %-6s %-12s %-12s %-12s %-12s %-8s %-14s %5s\n",
+				"Ticker", "Shares", "Avg Cost", "Last Price", "Total Cost", "Market Val", "P&L %", "Price Source", "Age")
 			fmt.Println(strings.Repeat("-", SeparatorWidth100))
 
 			// Display top N holdings
@@ -781,14 +1029,21 @@ func printPortfolioReportTable(report *types.PortfolioValuationReport, maxHoldin
 
 			for i := 0; i < limit; i++ {
 				pos := yearly.Positions[i]
-				fmt.Printf("%-8s %6.2f %12.2f %12.2f %12.2f %12.2f %7.1f%%\n",
+				stale := ""
+				if pos.PriceStale {
+					stale = "!"
+				}
+				fmt.Printf("%-8s %6.2f %12.2f %12.2f %12.2f %12.2f %7.1f%% %-14s %4dd%s\n",
 					pos.Ticker,
 					pos.Shares,
 					pos.AverageCost,
 					pos.LastPrice,
 					pos.TotalCost,
 					pos.MarketValue,
-					pos.UnrealizedGainLossPercent)
+					pos.UnrealizedGainLossPercent,
+					pos.PriceSource,
+					pos.PriceAgeDays,
+					stale)
 			}
 
 			// Show expand/collapse hint
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/spf13/cobra"
 )
@@ -110,7 +111,7 @@ func TestRootCmd(t *testing.T) {
 	}
 
 	// Check that subcommands are registered
-	expectedCommands := []string{"process", "analyze", "validate", "income", "portfolio", "version"}
+	expectedCommands := []string{"process", "analyze", "validate", "income", "portfolio", "tax", "reclaim", "reconcile", "simulate", "harvest", "plan", "series", "returns", "benchmark", "cash", "fx", "history", "allocation", "version"}
 	commands := RootCmd.Commands()
 
 	if len(commands) != len(expectedCommands) {
@@ -139,7 +140,7 @@ func TestProcessCmd(t *testing.T) {
 	}
 
 	// Check that required flags are present
-	expectedFlags := []string{"dir", "files", "output", "format"}
+	expectedFlags := []string{"dir", "files", "output", "format", "jurisdiction"}
 
 	for _, flagName := range expectedFlags {
 		flag := processCmd.Flags().Lookup(flagName)
@@ -159,7 +160,7 @@ func TestAnalyzeCmd(t *testing.T) {
 	}
 
 	// Check that required flags are present
-	expectedFlags := []string{"dir", "files"}
+	expectedFlags := []string{"dir", "files", "jurisdiction", "prices", "benchmark", "benchmark-symbol", "metadata"}
 
 	for _, flagName := range expectedFlags {
 		flag := analyzeCmd.Flags().Lookup(flagName)
@@ -188,3 +189,221 @@ func TestValidateCmd(t *testing.T) {
 		}
 	}
 }
+
+func TestTaxCmd(t *testing.T) {
+	if taxCmd.Use != "tax" {
+		t.Errorf("taxCmd.Use = %s, want 'tax'", taxCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "treaty-rates", "year", "fx-rates", "prices", "csv-dir", "church-tax", "joint", "share-losses", "other-losses", "instrument", "cash", "debts", "fiscal-partner", "other-income", "aggregate-income", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := taxCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("taxCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestReclaimCmd(t *testing.T) {
+	if reclaimCmd.Use != "reclaim" {
+		t.Errorf("reclaimCmd.Use = %s, want 'reclaim'", reclaimCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "treaty-rates", "fx-rates", "year", "as-of", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := reclaimCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("reclaimCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestReconcileCmd(t *testing.T) {
+	if reconcileCmd.Use != "reconcile" {
+		t.Errorf("reconcileCmd.Use = %s, want 'reconcile'", reconcileCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "fx-rates", "year", "tolerance", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := reconcileCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("reconcileCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestSimulateCmd(t *testing.T) {
+	if simulateCmd.Use != "simulate" {
+		t.Errorf("simulateCmd.Use = %s, want 'simulate'", simulateCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "fx-rates", "sell", "date", "other-income", "aggregate-income", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := simulateCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("simulateCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestParseSellSpec(t *testing.T) {
+	today := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	sell, err := parseSellSpec("AAPL:10.5@230.25", today)
+	if err != nil {
+		t.Fatalf("parseSellSpec() error = %v", err)
+	}
+	if sell.Security != "AAPL" || sell.Shares != 10.5 || sell.Price != 230.25 || !sell.Date.Equal(today) {
+		t.Errorf("Unexpected sell: %+v", sell)
+	}
+
+	sell, err = parseSellSpec("IE00B4L5Y983:2@101:2025-04-01", today)
+	if err != nil || !sell.Date.Equal(time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected a sell dated 2025-04-01, got %+v (%v)", sell, err)
+	}
+
+	for _, spec := range []string{"AAPL", "AAPL:10", "AAPL:ten@230", "AAPL:10@230:tomorrow"} {
+		if _, err := parseSellSpec(spec, today); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestHarvestCmd(t *testing.T) {
+	if harvestCmd.Use != "harvest" {
+		t.Errorf("harvestCmd.Use = %s, want 'harvest'", harvestCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "fx-rates", "prices", "price", "as-of", "other-income", "aggregate-income", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := harvestCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("harvestCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestPlanCmd(t *testing.T) {
+	if planCmd.Use != "plan" {
+		t.Errorf("planCmd.Use = %s, want 'plan'", planCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "jurisdiction", "jurisdiction-file", "fx-rates", "prices", "price", "as-of", "buffer", "other-income", "aggregate-income", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := planCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("planCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestSeriesCmd(t *testing.T) {
+	if seriesCmd.Use != "series" {
+		t.Errorf("seriesCmd.Use = %s, want 'series'", seriesCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "prices", "interval", "from", "to", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := seriesCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("seriesCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestReturnsCmd(t *testing.T) {
+	if returnsCmd.Use != "returns" {
+		t.Errorf("returnsCmd.Use = %s, want 'returns'", returnsCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "prices", "to", "positions", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := returnsCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("returnsCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestBenchmarkCmd(t *testing.T) {
+	if benchmarkCmd.Use != "benchmark" {
+		t.Errorf("benchmarkCmd.Use = %s, want 'benchmark'", benchmarkCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "prices", "benchmark", "benchmark-symbol", "to", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := benchmarkCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("benchmarkCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestCashCmd(t *testing.T) {
+	if cashCmd.Use != "cash" {
+		t.Errorf("cashCmd.Use = %s, want 'cash'", cashCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "fx-rates", "as-of", "statement", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := cashCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("cashCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestFxCmd(t *testing.T) {
+	if fxCmd.Use != "fx" {
+		t.Errorf("fxCmd.Use = %s, want 'fx'", fxCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "fx-rates", "year", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := fxCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("fxCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestHistoryCmd(t *testing.T) {
+	if historyCmd.Use != "history" {
+		t.Errorf("historyCmd.Use = %s, want 'history'", historyCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "security", "jurisdiction", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := historyCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("historyCmd missing flag: %s", flagName)
+		}
+	}
+}
+
+func TestAllocationCmd(t *testing.T) {
+	if allocationCmd.Use != "allocation" {
+		t.Errorf("allocationCmd.Use = %s, want 'allocation'", allocationCmd.Use)
+	}
+
+	expectedFlags := []string{"dir", "files", "metadata", "prices", "year", "max-position", "max-group", "output", "format"}
+
+	for _, flagName := range expectedFlags {
+		flag := allocationCmd.Flags().Lookup(flagName)
+		if flag == nil {
+			t.Errorf("allocationCmd missing flag: %s", flagName)
+		}
+	}
+}
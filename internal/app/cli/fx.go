@@ -0,0 +1,179 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// fxCmd represents the fx command
+var fxCmd = &cobra.Command{
+	Use:   "fx",
+	Short: "Realised currency gains and losses on foreign cash",
+	Long: `Track every foreign currency cash balance as lots and report the currency gain
+or loss realised each time foreign cash is converted, spent or withdrawn.
+
+Foreign cash received - from a conversion, a sale, a dividend or interest - opens
+a lot at its value in your base currency. Foreign cash converted back, spent on a
+purchase or withdrawn is matched first in, first out against the lots, and the
+difference between its value on the day and the cost of the lots is the realised
+currency gain or loss. Conversions to or from the base currency use their own
+rate; other movements use --fx-rates (ECB style: foreign currency per unit of
+base currency), else the rate of the latest conversion. Movements without
+either are left out and counted in the warnings.
+
+Currency gains are a separate report line; they are not part of capital gains.
+
+Examples:
+  # Currency gains per year
+  t212-taxes fx --dir ./exports --fx-rates ./ecb.csv
+
+  # Every disposal of 2024 as CSV
+  t212-taxes fx --dir ./exports --year 2024 --format csv --output fx.csv`,
+	Run: generateFXReport,
+}
+
+// generateFXReport handles the fx command
+func generateFXReport(cmd *cobra.Command, args []string) {
+	currency := viper.GetString("currency")
+	cashCalc := calculator.NewCashCalculator(currency)
+	if ratesFile, _ := cmd.Flags().GetString("fx-rates"); ratesFile != "" {
+		rates, err := calculator.LoadRateTable(ratesFile, currency, calculator.FXSource{
+			Name:   ratesFile,
+			Quote:  calculator.QuoteForeignPerBase,
+			Lookup: calculator.LookupSameDay,
+		})
+		if err != nil {
+			log.Fatalf("Error loading exchange rates: %v", err)
+		}
+		cashCalc.SetFXRateProvider(rates)
+	}
+
+	result := parseTransactions(cmd)
+	report := cashCalc.CalculateFXGains(result.Transactions)
+	if year, _ := cmd.Flags().GetInt("year"); year != 0 {
+		filterFXYear(report, year)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding FX report: %v", err)
+		}
+	case CSVFormat:
+		if err := writeFXCSV(out, report); err != nil {
+			log.Fatalf("Error writing FX disposals CSV: %v", err)
+		}
+	default:
+		printFXReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("FX report saved to %s\n", outputFile)
+	}
+}
+
+// filterFXYear keeps the disposals and totals of year only
+func filterFXYear(report *calculator.FXGainsReport, year int) {
+	var disposals []calculator.FXDisposal
+	for _, disposal := range report.Disposals {
+		if disposal.Date.Year() == year {
+			disposals = append(disposals, disposal)
+		}
+	}
+	report.Disposals = disposals
+	report.Years = []calculator.FXYear{report.Year(year)}
+}
+
+// writeFXCSV writes one row per disposal of foreign cash
+func writeFXCSV(out io.Writer, report *calculator.FXGainsReport) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{"date", "currency", "kind", "action", "amount", "proceeds", "cost", "gain_loss", "unmatched"}); err != nil {
+		return err
+	}
+
+	for _, disposal := range report.Disposals {
+		if err := writer.Write([]string{
+			disposal.Date.Format("2006-01-02 15:04:05"),
+			disposal.Currency,
+			string(disposal.Kind),
+			disposal.Action,
+			strconv.FormatFloat(disposal.Amount, 'f', 2, 64),
+			strconv.FormatFloat(disposal.Proceeds, 'f', 2, 64),
+			strconv.FormatFloat(disposal.Cost, 'f', 2, 64),
+			strconv.FormatFloat(disposal.GainLoss, 'f', 2, 64),
+			strconv.FormatFloat(disposal.Unmatched, 'f', 2, 64),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// printFXReport prints the disposals, the yearly totals and the lots still held
+func printFXReport(out io.Writer, report *calculator.FXGainsReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        💱 CURRENCY GAINS ON FOREIGN CASH (%s, %s)\n", report.Currency, report.Method)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	if len(report.Disposals) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo foreign cash was converted, spent or withdrawn.")
+	} else {
+		_, _ = fmt.Fprintf(out, "\n%-10s %-4s %-11s %12s %12s %12s %12s\n",
+			"Date", "Ccy", "Kind", "Amount", "Proceeds", "Cost", "Gain/Loss")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, disposal := range report.Disposals {
+			_, _ = fmt.Fprintf(out, "%-10s %-4s %-11s %12.2f %12.2f %12.2f %+12.2f\n",
+				disposal.Date.Format("2006-01-02"), disposal.Currency, disposal.Kind,
+				disposal.Amount, disposal.Proceeds, disposal.Cost, disposal.GainLoss)
+		}
+
+		_, _ = fmt.Fprintln(out, "\n📅 Per Year")
+		_, _ = fmt.Fprintf(out, "%-6s %10s %12s %12s %12s\n", "Year", "Disposals", "Gains", "Losses", "Net")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, year := range report.Years {
+			_, _ = fmt.Fprintf(out, "%-6d %10d %12.2f %12.2f %+12.2f\n", year.Year, year.Disposals, year.Gains, year.Losses, year.Net)
+		}
+	}
+
+	if len(report.OpenLots) > 0 {
+		_, _ = fmt.Fprintln(out, "\n📦 Lots Held")
+		_, _ = fmt.Fprintf(out, "%-10s %-4s %12s %12s\n", "Acquired", "Ccy", "Amount", "Cost")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, lot := range report.OpenLots {
+			_, _ = fmt.Fprintf(out, "%-10s %-4s %12.2f %12.2f\n", lot.Date.Format("2006-01-02"), lot.Currency, lot.Amount, lot.Cost)
+		}
+	}
+
+	_, _ = fmt.Fprintln(out, "\nCurrency gains are reported separately from capital gains.")
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
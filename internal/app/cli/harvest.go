@@ -0,0 +1,142 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// harvestCmd represents the harvest command
+var harvestCmd = &cobra.Command{
+	Use:   "harvest",
+	Short: "Find losses that could be realised against this year's gains",
+	Long: `List the positions that would realise a loss if sold today, the lots behind
+the loss, and the tax each sale would save against the gains already realised
+in the current tax year. The capital gains allowance still unused is shown, as
+there is no point harvesting losses that only eat into it.
+
+Positions are valued with --price where given, otherwise at their latest trade
+price. Each candidate shows the first day it can be bought back without the
+jurisdiction's repurchase rule applying: the UK 30-day rule, the US wash sale
+rule or the Irish four-week rule.
+
+Examples:
+  # Harvesting candidates under UK rules with current prices
+  t212-taxes harvest --dir ./exports --jurisdiction UK --price VUSA=88.20 --price AAPL=180
+
+  # As JSON for a given date
+  t212-taxes harvest --dir ./exports --jurisdiction IE --as-of 2024-12-15 --format json`,
+	Run: findHarvestOpportunities,
+}
+
+// findHarvestOpportunities handles the harvest command
+func findHarvestOpportunities(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+
+	result := parseTransactions(cmd)
+	report, err := taxCalc.FindHarvestOpportunities(result.Transactions, priceOverrides(cmd), types.ProcessingOptions{
+		Currency:              types.Currency(currency),
+		Jurisdiction:          code,
+		IncludeWithholdingTax: true,
+		Profile:               taxProfile(cmd),
+	}, asOfDate(cmd))
+	if err != nil {
+		log.Fatalf("Error finding harvesting opportunities: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding harvest report: %v", err)
+		}
+	} else {
+		printHarvestReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Harvest report saved to %s\n", outputFile)
+	}
+}
+
+// priceOverrides returns the --price flags keyed by upper-case ticker or ISIN
+func priceOverrides(cmd *cobra.Command) map[string]float64 {
+	flags, _ := cmd.Flags().GetStringToString("price")
+	prices := make(map[string]float64, len(flags))
+	for security, value := range flags {
+		price, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			log.Fatalf("Invalid --price for %s: %v", security, err)
+		}
+		prices[strings.ToUpper(security)] = price
+	}
+	return prices
+}
+
+// printHarvestReport prints this year's gains and allowance, then each harvesting candidate
+func printHarvestReport(out io.Writer, report *calculator.HarvestReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        TAX-LOSS HARVESTING %d (%s, as of %s)\n",
+		report.Year, report.Jurisdiction, report.AsOf.Format("2006-01-02"))
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	_, _ = fmt.Fprintf(out, "\nNet gains so far:  %12.2f %s\n", report.NetGains, report.Currency)
+	_, _ = fmt.Fprintf(out, "Allowance unused:  %12.2f of %.2f\n", report.AllowanceLeft, report.Allowance)
+	_, _ = fmt.Fprintf(out, "Tax due on gains:  %12.2f\n", report.TaxDue)
+	if rule := report.RepurchaseRule; rule != nil {
+		_, _ = fmt.Fprintf(out, "Repurchase rule:   %s (%d days)\n", rule.Name, rule.Days)
+	}
+
+	if len(report.Candidates) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo open positions would realise a loss.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🌾 CANDIDATES (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-8s %-14s %10s %11s %11s %10s %9s %-11s\n",
+		"Ticker", "ISIN", "Shares", "Cost", "Value", "Loss", "Saving", "Buy back")
+	for _, candidate := range report.Candidates {
+		_, _ = fmt.Fprintf(out, "%-8s %-14s %10.4f %11.2f %11.2f %10.2f %9.2f %-11s\n",
+			candidate.Ticker, candidate.ISIN, candidate.Shares, candidate.Cost, candidate.Value,
+			candidate.GainLoss, candidate.TaxSaving, candidate.SafeRepurchase.Format("2006-01-02"))
+		_, _ = fmt.Fprintf(out, "         price %.2f %s (%s %s)\n", candidate.Price, candidate.PriceCurrency,
+			candidate.PriceSource, candidate.PriceDate.Format("2006-01-02"))
+		for _, lot := range candidate.LossLots {
+			_, _ = fmt.Fprintf(out, "         lot %-18s %s %10.4f shares %10.2f\n",
+				lot.LotID, lot.AcquiredAt.Format("2006-01-02"), lot.Shares, lot.Loss)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "\nSelling all candidates: loss %.2f, tax saving %.2f %s\n",
+		report.TotalLoss, report.TotalSaving, report.Currency)
+	if report.AllowanceLeft > 0 {
+		_, _ = fmt.Fprintf(out, "⚠️  %.2f of allowance is unused; losses first reduce gains that would be tax-free\n",
+			report.AllowanceLeft)
+	}
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
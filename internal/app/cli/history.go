@@ -0,0 +1,166 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// historyCmd represents the history command
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Everything that happened with one position",
+	Long: `List every buy, sell, dividend, stock split and fee of one security, given by
+ISIN or ticker, in date order. Each row shows the running number of shares, the
+running cost basis, the average cost per share, the realised gain or loss so far
+and the dividends received so far.
+
+Sells are matched against purchases first in, first out, or with the matching
+method of --jurisdiction, so realised gains agree with the yearly reports. A
+stock split changes the number of shares but not the cost basis.
+
+Examples:
+  # What happened with my Apple position
+  t212-taxes history --dir ./exports --security AAPL
+
+  # The same by ISIN, with UK share pooling, as CSV
+  t212-taxes history --dir ./exports --security US0378331005 --jurisdiction UK --format csv`,
+	Run: generatePositionHistory,
+}
+
+// generatePositionHistory handles the history command
+func generatePositionHistory(cmd *cobra.Command, args []string) {
+	security, _ := cmd.Flags().GetString("security")
+	if security == "" {
+		log.Fatal("--security is required")
+	}
+
+	finCalc := calculator.NewFinancialCalculator(viper.GetString("currency"))
+	applyJurisdictionRules(cmd, finCalc)
+
+	result := parseTransactions(cmd)
+	history, err := finCalc.PositionHistory(result.Transactions, security)
+	if err != nil {
+		log.Fatalf("Error building position history: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(history); err != nil {
+			log.Fatalf("Error encoding position history: %v", err)
+		}
+	case CSVFormat:
+		if err := writeHistoryCSV(out, history); err != nil {
+			log.Fatalf("Error writing position history CSV: %v", err)
+		}
+	default:
+		printPositionHistory(out, history)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Position history saved to %s\n", outputFile)
+	}
+}
+
+// writeHistoryCSV writes one row per event with the running totals
+func writeHistoryCSV(out io.Writer, history *calculator.PositionHistory) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{
+		"date", "kind", "action", "id", "shares", "price_per_share", "price_currency", "amount", "fees",
+		"withholding_tax", "gain_loss", "split_ratio", "running_shares", "running_cost_basis",
+		"average_cost", "realised_gain_loss", "dividends",
+	}); err != nil {
+		return err
+	}
+
+	format := func(value float64, decimals int) string {
+		return strconv.FormatFloat(value, 'f', decimals, 64)
+	}
+	for _, event := range history.Events {
+		if err := writer.Write([]string{
+			event.Date.Format("2006-01-02 15:04:05"),
+			string(event.Kind),
+			event.Action,
+			event.ID,
+			format(event.Shares, 6),
+			format(event.PricePerShare, 4),
+			event.PriceCurrency,
+			format(event.Amount, 2),
+			format(event.Fees, 2),
+			format(event.WithholdingTax, 2),
+			format(event.GainLoss, 2),
+			format(event.SplitRatio, 4),
+			format(event.RunningShares, 6),
+			format(event.RunningCostBasis, 2),
+			format(event.AverageCost, 4),
+			format(event.RealisedGainLoss, 2),
+			format(event.Dividends, 2),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// printPositionHistory prints the events of a position and where it stands after them
+func printPositionHistory(out io.Writer, history *calculator.PositionHistory) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "        📜 POSITION HISTORY: %s (%s, %s)\n", history.Name, history.Ticker, history.ISIN)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth100))
+
+	_, _ = fmt.Fprintf(out, "\n%-10s %-9s %11s %12s %12s %11s %12s %11s %11s\n",
+		"Date", "Event", "Shares", "Amount", "Gain/Loss", "Held", "Cost Basis", "Avg Cost", "Dividends")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	for _, event := range history.Events {
+		kind := string(event.Kind)
+		if event.Kind == calculator.PositionSplit {
+			kind = fmt.Sprintf("split %g", event.SplitRatio)
+		}
+		gainLoss := ""
+		if event.Kind == calculator.PositionSell {
+			gainLoss = fmt.Sprintf("%+.2f", event.GainLoss)
+		}
+		_, _ = fmt.Fprintf(out, "%-10s %-9s %11.4f %12.2f %12s %11.4f %12.2f %11.4f %11.2f\n",
+			event.Date.Format("2006-01-02"), kind, event.Shares, event.Amount, gainLoss,
+			event.RunningShares, event.RunningCostBasis, event.AverageCost, event.Dividends)
+	}
+
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "Shares held:        %14.4f\n", history.Shares)
+	_, _ = fmt.Fprintf(out, "Cost basis:         %14.2f %s\n", history.CostBasis, history.Currency)
+	_, _ = fmt.Fprintf(out, "Average cost:       %14.4f %s\n", history.AverageCost, history.Currency)
+	_, _ = fmt.Fprintf(out, "Realised gain/loss: %14.2f %s (%s)\n", history.RealisedGainLoss, history.Currency, history.Method)
+	_, _ = fmt.Fprintf(out, "Dividends:          %14.2f %s (withheld %.2f)\n", history.Dividends, history.Currency, history.WithholdingTax)
+	_, _ = fmt.Fprintf(out, "Fees:               %14.2f %s\n", history.Fees, history.Currency)
+	_, _ = fmt.Fprintf(out, "Period:             %s to %s\n", history.FirstDate.Format("2006-01-02"), history.LastDate.Format("2006-01-02"))
+	for _, warning := range history.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
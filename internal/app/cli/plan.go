@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// planCmd represents the plan command
+var planCmd = &cobra.Command{
+	Use:   "plan",
+	Short: "Plan sells that use up this year's capital gains allowance",
+	Long: `Suggest which lots to sell before the tax year ends so that the gains realised
+this year land just under the annual exemption, such as the UK's £3,000, the
+Lithuanian €500 or the Irish €1,270. Gains realised inside the exemption are
+tax-free and raise the cost basis of shares bought back.
+
+Shares are taken in the order the jurisdiction's matching method disposes of
+them, starting with the positions holding the largest gains. Each sale shows
+when the shares can be bought back without undoing the gain: in the UK, shares
+bought back within 30 days are matched to the sale instead.
+
+Positions are valued with --price where given, otherwise at their latest trade
+price. Use --buffer to stop short of the exemption and allow for price moves.
+
+Examples:
+  # Use up the UK annual exempt amount with current prices
+  t212-taxes plan --dir ./exports --jurisdiction UK --price VUSA=92.10 --buffer 100
+
+  # Lithuanian plan as JSON
+  t212-taxes plan --dir ./exports --jurisdiction LT --price IWDA=98.40 --format json`,
+	Run: planAllowance,
+}
+
+// planAllowance handles the plan command
+func planAllowance(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+	buffer, _ := cmd.Flags().GetFloat64("buffer")
+
+	result := parseTransactions(cmd)
+	plan, err := taxCalc.PlanAllowance(result.Transactions, priceOverrides(cmd), types.ProcessingOptions{
+		Currency:              types.Currency(currency),
+		Jurisdiction:          code,
+		IncludeWithholdingTax: true,
+		Profile:               taxProfile(cmd),
+	}, asOfDate(cmd), buffer)
+	if err != nil {
+		log.Fatalf("Error planning allowance: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(plan); err != nil {
+			log.Fatalf("Error encoding allowance plan: %v", err)
+		}
+	} else {
+		printAllowancePlan(out, plan)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Allowance plan saved to %s\n", outputFile)
+	}
+}
+
+// printAllowancePlan prints the allowance left and the sells suggested to use it
+func printAllowancePlan(out io.Writer, plan *calculator.AllowancePlan) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        ALLOWANCE PLAN %d (%s, %s, as of %s)\n",
+		plan.Year, plan.Jurisdiction, plan.Method, plan.AsOf.Format("2006-01-02"))
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	_, _ = fmt.Fprintf(out, "\nAllowance:         %12.2f %s\n", plan.Allowance, plan.Currency)
+	_, _ = fmt.Fprintf(out, "Net gains so far:  %12.2f\n", plan.NetGains)
+	_, _ = fmt.Fprintf(out, "Allowance left:    %12.2f\n", plan.AllowanceLeft)
+	if plan.Buffer > 0 {
+		_, _ = fmt.Fprintf(out, "Target:            %12.2f (buffer %.2f)\n", plan.Target, plan.Buffer)
+	}
+	_, _ = fmt.Fprintf(out, "Sell by:           %12s\n", plan.SellBy.Format("2006-01-02"))
+
+	if len(plan.Sales) == 0 {
+		if plan.Target <= 0 {
+			_, _ = fmt.Fprintln(out, "\nNo allowance left to use this tax year.")
+		} else {
+			_, _ = fmt.Fprintln(out, "\nNo open positions hold a gain to realise.")
+		}
+	} else {
+		_, _ = fmt.Fprintf(out, "\n📋 SUGGESTED SELLS (%s)\n", plan.Currency)
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		_, _ = fmt.Fprintf(out, "%-8s %-14s %10s %10s %11s %11s %9s %-11s\n",
+			"Ticker", "ISIN", "Sell", "of", "Proceeds", "Cost", "Gain", "Rebuy from")
+		for _, sale := range plan.Sales {
+			_, _ = fmt.Fprintf(out, "%-8s %-14s %10.4f %10.4f %11.2f %11.2f %9.2f %-11s\n",
+				sale.Ticker, sale.ISIN, sale.Shares, sale.HeldShares, sale.Proceeds, sale.Cost, sale.Gain,
+				sale.RebuyFrom.Format("2006-01-02"))
+			_, _ = fmt.Fprintf(out, "         at %.2f %s (%s)\n", sale.Price, sale.PriceCurrency, sale.PriceSource)
+			for _, match := range sale.Matches {
+				_, _ = fmt.Fprintf(out, "         lot %-18s %s %10.4f shares %10.2f\n",
+					match.LotID, match.AcquiredAt.Format("2006-01-02"), match.Shares, match.GainLoss)
+			}
+		}
+
+		_, _ = fmt.Fprintf(out, "\nPlanned gain:      %12.2f\n", plan.PlannedGain)
+		_, _ = fmt.Fprintf(out, "Allowance after:   %12.2f\n", plan.AllowanceAfter)
+		_, _ = fmt.Fprintf(out, "Extra tax:         %12.2f\n", plan.ExtraTax)
+	}
+
+	if rule := plan.RepurchaseRule; rule != nil && rule.AppliesToGains {
+		_, _ = fmt.Fprintf(out, "\nℹ️  Under the %s, shares bought back within %d days are matched to the sale\n",
+			rule.Name, rule.Days)
+	}
+	for _, warning := range plan.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
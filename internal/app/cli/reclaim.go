@@ -0,0 +1,149 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// reclaimCmd represents the reclaim command
+var reclaimCmd = &cobra.Command{
+	Use:   "reclaim",
+	Short: "Track foreign withholding tax that can be reclaimed",
+	Long: `Find dividends withheld above the treaty rate between the paying company's
+country (the ISIN prefix) and your country of residence, such as 26.375% German
+or 35% Swiss withholding, and total the reclaimable tax per country and year.
+
+Each claim shows the deadline for filing it with the source country's tax
+authority. The CSV format lists one payment per row, with the amounts in the
+currency the dividend was declared in, ready to copy into reclaim forms.
+
+Examples:
+  # Open reclaims for a Lithuanian resident
+  t212-taxes reclaim --dir ./exports --jurisdiction LT
+
+  # 2024 payments as CSV for the reclaim forms
+  t212-taxes reclaim --dir ./exports --jurisdiction DE --year 2024 --format csv --output reclaims_2024.csv`,
+	Run: trackReclaims,
+}
+
+// trackReclaims handles the reclaim command
+func trackReclaims(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+	year, _ := cmd.Flags().GetInt("year")
+
+	result := parseTransactions(cmd)
+	report, err := taxCalc.GenerateReclaimReport(result.Transactions, types.ProcessingOptions{
+		TaxYear:      year,
+		Currency:     types.Currency(currency),
+		Jurisdiction: code,
+	}, asOfDate(cmd))
+	if err != nil {
+		log.Fatalf("Error generating reclaim report: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding reclaim report: %v", err)
+		}
+	case CSVFormat:
+		if err := report.WriteCSV(out); err != nil {
+			log.Fatalf("Error writing reclaim CSV: %v", err)
+		}
+	default:
+		printReclaimReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Reclaim report saved to %s\n", outputFile)
+	}
+}
+
+// printReclaimReport prints reclaimable tax per country and year, then each open claim
+func printReclaimReport(out io.Writer, report *calculator.ReclaimReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        WITHHOLDING TAX RECLAIMS (residence %s, as of %s)\n",
+		report.ResidenceCountry, report.AsOf.Format("2006-01-02"))
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	if len(report.Claims) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo dividends were withheld above the treaty rate.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🌍 RECLAIMABLE BY COUNTRY (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-7s %-5s %7s %12s %12s %-12s %-8s\n",
+		"Country", "Year", "Claims", "Withheld", "Reclaimable", "Deadline", "Status")
+	for _, summary := range report.Summaries {
+		_, _ = fmt.Fprintf(out, "%-7s %-5d %7d %12.2f %12.2f %-12s %-8s\n",
+			summary.Country, summary.Year, summary.Claims, summary.Withheld, summary.Reclaimable,
+			formatDeadline(summary.Deadline), summary.Status)
+	}
+
+	_, _ = fmt.Fprintf(out, "\n⏳ OPEN CLAIMS (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-10s %-7s %-14s %7s %7s %10s %-12s %6s\n",
+		"Paid", "Country", "ISIN", "Rate", "Treaty", "Reclaim", "Deadline", "Days")
+	for _, claim := range report.Claims {
+		if claim.Status != calculator.ReclaimOpen {
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "%-10s %-7s %-14s %6.2f%% %6.2f%% %10.2f %-12s %6d\n",
+			claim.Date.Format("2006-01-02"), claim.SourceCountry, claim.ISIN,
+			claim.WithholdingRate*PercentMultiplier, claim.TreatyRate*PercentMultiplier,
+			claim.Reclaimable, formatDeadline(claim.Deadline), claim.DaysLeft)
+	}
+
+	_, _ = fmt.Fprintf(out, "\nOpen:    %10.2f\n", report.Open)
+	_, _ = fmt.Fprintf(out, "Expired: %10.2f\n", report.Expired)
+	if report.Unknown > 0 {
+		_, _ = fmt.Fprintf(out, "Unknown: %10.2f (no reclaim period known for the source country)\n", report.Unknown)
+	}
+}
+
+// asOfDate returns the --as-of date, defaulting to today
+func asOfDate(cmd *cobra.Command) time.Time {
+	asOfFlag, _ := cmd.Flags().GetString("as-of")
+	if asOfFlag == "" {
+		return time.Now()
+	}
+	asOf, err := time.Parse("2006-01-02", asOfFlag)
+	if err != nil {
+		log.Fatalf("Invalid --as-of date %q: %v", asOfFlag, err)
+	}
+	return asOf
+}
+
+// formatDeadline formats a reclaim deadline, or a dash when none is known
+func formatDeadline(deadline time.Time) string {
+	if deadline.IsZero() {
+		return "-"
+	}
+	return deadline.Format("2006-01-02")
+}
@@ -0,0 +1,137 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// reconcileCmd represents the reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Compare computed gains with Trading 212's Result column",
+	Long: `Compare the gain computed for every sell with Trading 212's own Result.
+
+Trading 212 works out Result with average cost in the instrument's currency,
+converted at its own rate on the day of the sale, before fees. The computed
+gain uses the jurisdiction's matching method and exchange rate source, and
+converts each purchase and sale on its own date. The difference on each sell
+is split into:
+  Method       the matching method against average cost
+  Fees         currency conversion fees included in cost and proceeds
+  FX           per-trade conversion against converting the gain at the sale rate
+  Unexplained  anything left, such as corporate actions or rounding
+
+Sells whose difference exceeds --tolerance are flagged.
+
+Examples:
+  # Reconcile 2024 sells under Lithuanian rules with ECB rates
+  t212-taxes reconcile --dir ./exports --jurisdiction LT --year 2024 --fx-rates ./ecb_rates.csv
+
+  # Every sell as CSV, flagging differences above 5
+  t212-taxes reconcile --dir ./exports --jurisdiction UK --tolerance 5 --format csv --output reconcile.csv`,
+	Run: reconcileGains,
+}
+
+// reconcileGains handles the reconcile command
+func reconcileGains(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+	year, _ := cmd.Flags().GetInt("year")
+	tolerance, _ := cmd.Flags().GetFloat64("tolerance")
+
+	result := parseTransactions(cmd)
+	report, err := taxCalc.Reconcile(result.Transactions, types.ProcessingOptions{
+		TaxYear:      year,
+		Currency:     types.Currency(currency),
+		Jurisdiction: code,
+	}, tolerance)
+	if err != nil {
+		log.Fatalf("Error reconciling gains: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding reconciliation: %v", err)
+		}
+	case CSVFormat:
+		if err := report.WriteCSV(out); err != nil {
+			log.Fatalf("Error writing reconciliation CSV: %v", err)
+		}
+	default:
+		printReconciliation(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Reconciliation saved to %s\n", outputFile)
+	}
+}
+
+// printReconciliation prints each sell's computed gain next to Trading 212's Result
+func printReconciliation(out io.Writer, report *calculator.ReconciliationReport) {
+	period := "all years"
+	if report.Year != 0 {
+		period = fmt.Sprintf("%d", report.Year)
+	}
+
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "        RECONCILIATION %s (%s, %s, tolerance %.2f %s)\n",
+		period, report.Jurisdiction, report.Method, report.Tolerance, report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth100))
+
+	_, _ = fmt.Fprintf(out, "%-2s %-10s %-8s %10s %10s %10s %9s %8s %9s %9s  %s\n",
+		"", "Date", "Security", "Computed", "T212", "Diff", "Method", "Fees", "FX", "Other", "Explanation")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	for _, row := range report.Rows {
+		marker := ""
+		if row.Flagged {
+			marker = "⚠️"
+		}
+		security := row.Ticker
+		if security == "" {
+			security = row.ISIN
+		}
+		if !row.HasResult {
+			_, _ = fmt.Fprintf(out, "%-2s %-10s %-8s %10.2f %10s %10s %9s %8s %9s %9s  %s\n",
+				marker, row.Date.Format("2006-01-02"), security, row.GainLoss, "-", "-", "", "", "", "", row.Explanation)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "%-2s %-10s %-8s %10.2f %10.2f %10.2f %9.2f %8.2f %9.2f %9.2f  %s\n",
+			marker, row.Date.Format("2006-01-02"), security, row.GainLoss, row.BrokerResult, row.Difference,
+			row.MethodEffect, row.FeeEffect, row.FXEffect, row.Unexplained, row.Explanation)
+	}
+
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "%-2s %-10s %-8s %10.2f %10.2f %10.2f %9.2f %8.2f %9.2f %9.2f\n",
+		"", "Total", "", report.GainLoss, report.BrokerResult, report.Difference,
+		report.MethodEffect, report.FeeEffect, report.FXEffect, report.Unexplained)
+
+	_, _ = fmt.Fprintf(out, "\n%d of %d sells differ by more than the tolerance", report.Flagged, len(report.Rows))
+	if report.WithoutResult > 0 {
+		_, _ = fmt.Fprintf(out, "; %d have no Result to compare", report.WithoutResult)
+	}
+	_, _ = fmt.Fprintln(out)
+}
@@ -0,0 +1,134 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+)
+
+// returnsCmd represents the returns command
+var returnsCmd = &cobra.Command{
+	Use:   "returns",
+	Short: "Time-weighted and money-weighted returns",
+	Long: `Measure investment performance per calendar year and over the whole history.
+
+The time-weighted return (TWR) chains the daily returns between cash flows, so
+deposits and withdrawals do not distort it; use it to judge the investments.
+The money-weighted return (MWR) is the annualised internal rate of return
+(XIRR) of the deposits and withdrawals, so it reflects when money was added.
+
+The portfolio is valued with cash at the close of every day. Holdings use
+closing prices from --prices where available, otherwise the last trade price.
+With --positions, each security is measured with its buys as money in and its
+sells and dividends as money out.
+
+Examples:
+  # Returns per year and overall
+  t212-taxes returns --dir ./exports --prices ./prices.csv
+
+  # Include each position, up to the end of 2024
+  t212-taxes returns --dir ./exports --prices ./prices.csv --to 2024-12-31 --positions`,
+	Run: generateReturnsReport,
+}
+
+// generateReturnsReport handles the returns command
+func generateReturnsReport(cmd *cobra.Command, args []string) {
+	withPositions, _ := cmd.Flags().GetBool("positions")
+
+	calc := calculator.NewFinancialCalculator(viper.GetString("currency"))
+	if prices := priceProvider(cmd); prices != nil {
+		calc.SetPriceProvider(prices)
+	}
+
+	result := parseTransactions(cmd)
+	report := calc.CalculateReturns(result.Transactions, seriesDate(cmd, "to"))
+	if !withPositions {
+		report.Positions = nil
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding returns report: %v", err)
+		}
+	} else {
+		printReturnsReport(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Returns report saved to %s\n", outputFile)
+	}
+}
+
+// printReturnsReport prints the yearly and overall returns, then those of each position
+func printReturnsReport(out io.Writer, report *calculator.ReturnsReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintln(out, "        📊 INVESTMENT RETURNS")
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "Note: %s\n", report.PriceNote)
+
+	if len(report.Years) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo transactions found.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n%-8s %14s %14s %14s %10s %10s\n", "Period", "Start Value", "Net Flows", "End Value", "TWR", "MWR")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, period := range report.Years {
+		printPeriodReturn(out, fmt.Sprintf("%d", period.Year), period)
+	}
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	printPeriodReturn(out, "Overall", report.Overall)
+
+	if len(report.Positions) > 0 {
+		_, _ = fmt.Fprintln(out, "\n📦 Positions")
+		_, _ = fmt.Fprintf(out, "%-10s %-8s %14s %14s %14s %10s %10s\n", "Ticker", "Period", "Start Value", "Net Flows", "End Value", "TWR", "MWR")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, position := range report.Positions {
+			for _, period := range position.Years {
+				_, _ = fmt.Fprintf(out, "%-10s ", position.Ticker)
+				printPeriodReturn(out, fmt.Sprintf("%d", period.Year), period)
+			}
+			_, _ = fmt.Fprintf(out, "%-10s ", position.Ticker)
+			printPeriodReturn(out, "Overall", position.Overall)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "\nAll amounts in %s; MWR is annualised\n", report.Currency)
+}
+
+// printPeriodReturn prints one row of the returns table
+func printPeriodReturn(out io.Writer, label string, period calculator.PeriodReturn) {
+	_, _ = fmt.Fprintf(out, "%-8s %14.2f %14.2f %14.2f %9.2f%% %10s\n",
+		label, period.StartValue, period.NetFlows, period.EndValue, period.TimeWeighted, formatRate(period.MoneyWeighted))
+}
+
+// formatRate formats a percentage that may be missing
+func formatRate(rate *float64) string {
+	if rate == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.2f%%", *rate)
+}
@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// seriesCmd represents the series command
+var seriesCmd = &cobra.Command{
+	Use:   "series",
+	Short: "Portfolio value over time",
+	Long: `Replay the transactions to value the portfolio at the close of every day or
+every month end: positions held, cost basis, market value, cash and net
+deposits. Holdings are valued at closing prices from --prices where available,
+otherwise at the last trade price before each date.
+
+The CSV format has one row per date, ready for charting.
+
+Examples:
+  # Month-end values since the first transaction
+  t212-taxes series --dir ./exports --prices ./prices.csv
+
+  # Daily values for 2024 as CSV
+  t212-taxes series --dir ./exports --interval daily --from 2024-01-01 --to 2024-12-31 --format csv --output 2024.csv`,
+	Run: generatePortfolioSeries,
+}
+
+// generatePortfolioSeries handles the series command
+func generatePortfolioSeries(cmd *cobra.Command, args []string) {
+	interval, _ := cmd.Flags().GetString("interval")
+
+	portfolioCalc := calculator.NewPortfolioCalculator(viper.GetString("currency"))
+	if prices := priceProvider(cmd); prices != nil {
+		portfolioCalc.SetPriceProvider(prices)
+	}
+
+	result := parseTransactions(cmd)
+	series, err := portfolioCalc.CalculateTimeSeries(result.Transactions,
+		seriesDate(cmd, "from"), seriesDate(cmd, "to"), calculator.SeriesInterval(strings.ToLower(interval)))
+	if err != nil {
+		log.Fatalf("Error calculating portfolio series: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	switch format {
+	case JSONFormat:
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(series); err != nil {
+			log.Fatalf("Error encoding portfolio series: %v", err)
+		}
+	case CSVFormat:
+		if err := writeSeriesCSV(out, series); err != nil {
+			log.Fatalf("Error writing portfolio series CSV: %v", err)
+		}
+	default:
+		printPortfolioSeries(out, series)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Portfolio series saved to %s\n", outputFile)
+	}
+}
+
+// seriesDate returns the date in the named flag, or the zero time when it is not set
+func seriesDate(cmd *cobra.Command, name string) time.Time {
+	value, _ := cmd.Flags().GetString(name)
+	if value == "" {
+		return time.Time{}
+	}
+	date, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Fatalf("Invalid --%s date %q: %v", name, value, err)
+	}
+	return date
+}
+
+// writeSeriesCSV writes one row per snapshot
+func writeSeriesCSV(out io.Writer, series *types.PortfolioTimeSeries) error {
+	writer := csv.NewWriter(out)
+	if err := writer.Write([]string{
+		"date", "positions", "cost_basis", "market_value", "unrealized_gain_loss",
+		"cash", "total_value", "net_deposits", "stale_prices",
+	}); err != nil {
+		return err
+	}
+
+	amount := func(value float64) string {
+		return strconv.FormatFloat(value, 'f', 2, 64)
+	}
+	for _, snapshot := range series.Snapshots {
+		if err := writer.Write([]string{
+			snapshot.Date.Format("2006-01-02"),
+			strconv.Itoa(snapshot.Positions),
+			amount(snapshot.CostBasis),
+			amount(snapshot.MarketValue),
+			amount(snapshot.UnrealizedGainLoss),
+			amount(snapshot.Cash),
+			amount(snapshot.TotalValue),
+			amount(snapshot.NetDeposits),
+			strconv.Itoa(snapshot.StalePrices),
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// printPortfolioSeries prints one line per snapshot
+func printPortfolioSeries(out io.Writer, series *types.PortfolioTimeSeries) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "        📈 PORTFOLIO SERIES (%s, %s to %s)\n",
+		series.Interval, series.From.Format("2006-01-02"), series.To.Format("2006-01-02"))
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth100))
+	_, _ = fmt.Fprintf(out, "Note: %s\n", series.PriceNote)
+
+	if len(series.Snapshots) == 0 {
+		_, _ = fmt.Fprintln(out, "\nNo transactions found.")
+		return
+	}
+
+	_, _ = fmt.Fprintf(out, "\n%-10s %9s %13s %13s %12s %12s %13s %13s\n",
+		"Date", "Positions", "Cost Basis", "Market Val", "Unrealized", "Cash", "Total", "Net Deposits")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth100))
+	for _, snapshot := range series.Snapshots {
+		stale := ""
+		if snapshot.StalePrices > 0 {
+			stale = fmt.Sprintf(" (%d stale)", snapshot.StalePrices)
+		}
+		_, _ = fmt.Fprintf(out, "%-10s %9d %13.2f %13.2f %12.2f %12.2f %13.2f %13.2f%s\n",
+			snapshot.Date.Format("2006-01-02"), snapshot.Positions, snapshot.CostBasis, snapshot.MarketValue,
+			snapshot.UnrealizedGainLoss, snapshot.Cash, snapshot.TotalValue, snapshot.NetDeposits, stale)
+	}
+	_, _ = fmt.Fprintf(out, "\nAll amounts in %s\n", series.Currency)
+}
@@ -0,0 +1,174 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// simulateCmd represents the simulate command
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Preview the tax impact of hypothetical sells",
+	Long: `Preview the tax on sells you have not made yet. Each --sell is matched against
+the lots you would hold on its date with the jurisdiction's matching method,
+showing the lots used, the gain, the holding period, the capital gains
+allowance left before and after, and the extra tax for the tax year.
+
+Sells are written as SECURITY:SHARES@PRICE, optionally followed by :YYYY-MM-DD.
+SECURITY is a ticker or ISIN from your exports and PRICE is per share in the
+security's trading currency. Sells without a date use --date. Your exports are
+not changed.
+
+Examples:
+  # Sell 10 Apple shares at 230 today
+  t212-taxes simulate --dir ./exports --jurisdiction LT --sell AAPL:10@230
+
+  # Two sells before the UK tax year ends, on top of other income
+  t212-taxes simulate --dir ./exports --jurisdiction UK --other-income 47430 \
+    --sell VUSA:50@92.10:2025-04-01 --sell IE00B4L5Y983:20@101.5:2025-04-01`,
+	Run: simulateSells,
+}
+
+// simulateSells handles the simulate command
+func simulateSells(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+
+	date := time.Now()
+	if dateFlag, _ := cmd.Flags().GetString("date"); dateFlag != "" {
+		parsed, err := time.Parse("2006-01-02", dateFlag)
+		if err != nil {
+			log.Fatalf("Invalid --date %q: %v", dateFlag, err)
+		}
+		date = parsed
+	}
+
+	specs, _ := cmd.Flags().GetStringArray("sell")
+	if len(specs) == 0 {
+		log.Fatal("At least one --sell is required")
+	}
+	sells := make([]calculator.HypotheticalSell, 0, len(specs))
+	for _, spec := range specs {
+		sell, err := parseSellSpec(spec, date)
+		if err != nil {
+			log.Fatalf("Invalid --sell: %v", err)
+		}
+		sells = append(sells, sell)
+	}
+
+	result := parseTransactions(cmd)
+	report, err := taxCalc.Simulate(result.Transactions, sells, types.ProcessingOptions{
+		Currency:              types.Currency(currency),
+		Jurisdiction:          code,
+		IncludeWithholdingTax: true,
+		Profile:               taxProfile(cmd),
+	})
+	if err != nil {
+		log.Fatalf("Error simulating sells: %v", err)
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("Error encoding simulation: %v", err)
+		}
+	} else {
+		printSimulation(out, report)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Simulation saved to %s\n", outputFile)
+	}
+}
+
+// parseSellSpec parses a SECURITY:SHARES@PRICE[:YYYY-MM-DD] sell, dated date when no date is given
+func parseSellSpec(spec string, date time.Time) (calculator.HypotheticalSell, error) {
+	security, rest, found := strings.Cut(spec, ":")
+	if !found || security == "" {
+		return calculator.HypotheticalSell{}, fmt.Errorf("%q is not SECURITY:SHARES@PRICE", spec)
+	}
+	quantity, rest, found := strings.Cut(rest, "@")
+	if !found {
+		return calculator.HypotheticalSell{}, fmt.Errorf("%q is not SECURITY:SHARES@PRICE", spec)
+	}
+	price, dateSpec, hasDate := strings.Cut(rest, ":")
+
+	sell := calculator.HypotheticalSell{Security: security, Date: date}
+	var err error
+	if sell.Shares, err = strconv.ParseFloat(quantity, 64); err != nil {
+		return calculator.HypotheticalSell{}, fmt.Errorf("invalid shares in %q: %w", spec, err)
+	}
+	if sell.Price, err = strconv.ParseFloat(price, 64); err != nil {
+		return calculator.HypotheticalSell{}, fmt.Errorf("invalid price in %q: %w", spec, err)
+	}
+	if hasDate {
+		if sell.Date, err = time.Parse("2006-01-02", dateSpec); err != nil {
+			return calculator.HypotheticalSell{}, fmt.Errorf("invalid date in %q: %w", spec, err)
+		}
+	}
+	return sell, nil
+}
+
+// printSimulation prints the lots each hypothetical sell would use and the tax impact per year
+func printSimulation(out io.Writer, report *calculator.SimulationReport) {
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "        SELL SIMULATION (%s, %s)\n", report.Jurisdiction, report.Method)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	for _, sell := range report.Sells {
+		disposal := sell.Disposal
+		_, _ = fmt.Fprintf(out, "\n📤 %s: %g shares at %.2f %s on %s (tax year %d)\n",
+			sell.Sell.Security, disposal.Shares, disposal.PricePerShare, disposal.PriceCurrency,
+			disposal.Date.Format("2006-01-02"), sell.TaxYear)
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		_, _ = fmt.Fprintf(out, "%-18s %-10s %12s %12s %12s %12s %6s\n",
+			"Lot", "Acquired", "Shares", "Cost", "Proceeds", "Gain/Loss", "Days")
+		for _, match := range disposal.Matches {
+			_, _ = fmt.Fprintf(out, "%-18s %-10s %12.4f %12.2f %12.2f %12.2f %6d\n",
+				match.LotID, match.AcquiredAt.Format("2006-01-02"), match.Shares,
+				match.Cost, match.Proceeds, match.GainLoss, match.HoldingDays)
+		}
+		_, _ = fmt.Fprintf(out, "%-29s %12s %12.2f %12.2f %12.2f\n",
+			"Total ("+report.Currency+")", "", disposal.Cost, disposal.Proceeds, disposal.GainLoss)
+	}
+
+	_, _ = fmt.Fprintf(out, "\n💰 TAX IMPACT (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-5s %12s %12s %12s %12s %12s %8s\n",
+		"Year", "Gains now", "Gains after", "Allow. left", "Allow. after", "Extra tax", "Rate")
+	for _, year := range report.Years {
+		_, _ = fmt.Fprintf(out, "%-5d %12.2f %12.2f %12.2f %12.2f %12.2f %7.2f%%\n",
+			year.Year, year.NetGainsBefore, year.NetGainsAfter, year.AllowanceBefore, year.AllowanceAfter,
+			year.MarginalTax, year.MarginalRate*PercentMultiplier)
+	}
+
+	_, _ = fmt.Fprintf(out, "\nRealised gain/loss: %.2f %s\n", report.GainLoss, report.Currency)
+	_, _ = fmt.Fprintf(out, "Extra tax:          %.2f %s\n", report.MarginalTax, report.Currency)
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
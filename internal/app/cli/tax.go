@@ -0,0 +1,780 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/parser"
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// taxCmd represents the tax command
+var taxCmd = &cobra.Command{
+	Use:   "tax",
+	Short: "Calculate tax figures for a jurisdiction and tax year",
+	Long: `Calculate capital gains and dividend tax for a tax jurisdiction and year.
+
+Realised gains are matched against purchases using the jurisdiction's matching
+method. Official exchange rates can be supplied as a CSV file with
+date,currency,rate rows (quoted as the jurisdiction's central bank publishes
+them); Trading 212's own rates are used where no official rate is available.
+
+Supported jurisdictions: US, UK, BG, LT, DE, PL, IE, NL, ES, PT
+
+Rates, allowances, brackets, the tax-year start, exchange rate source and
+matching rules come from versioned YAML definitions with one entry per tax
+year. Built-in definitions can be replaced, or new jurisdictions added, with
+--jurisdiction-file; other jurisdictions get the generic calculation only.
+
+Foreign withholding tax is credited per dividend up to the treaty rate between
+the source country (the ISIN prefix) and the country of residence, and up to
+the domestic tax on the dividend. Tax withheld above the treaty rate is shown
+as reclaimable. The built-in treaty rates can be replaced with --treaty-rates.
+
+Where a jurisdiction defines brackets, investment income is taxed at the
+marginal rates on top of your other taxable income, set with --other-income
+or profile.other_income in the config file. Dividends fill the brackets
+before gains. Spain taxes the savings base on its own brackets, and Portugal
+applies the progressive scale only with --aggregate-income (englobamento).
+
+Instrument classification can be overridden per ISIN with --instrument:
+  DE: equity, mixed, real_estate, foreign_real_estate, other or none (not a fund)
+  IE: share or exit_tax
+
+Examples:
+  # Lithuanian GPM311 figures for 2024 using ECB rates
+  t212-taxes tax --dir ./exports --jurisdiction LT --year 2024 --fx-rates ./ecb_rates.csv
+
+  # Bulgarian Annex 8 and Annex 5 tables as CSV files for the NRA portal
+  t212-taxes tax --dir ./exports --jurisdiction BG --year 2024 --fx-rates ./bnb_rates.csv --csv-dir ./annexes
+
+  # German Anlage KAP and KAP-INV figures with 9% church tax
+  t212-taxes tax --dir ./exports --jurisdiction DE --year 2024 --church-tax 0.09
+
+  # German figures with share losses brought forward from another broker
+  t212-taxes tax --dir ./exports --jurisdiction DE --year 2024 --share-losses 1200
+
+  # Polish PIT-38 and PIT/ZG figures using NBP table A rates
+  t212-taxes tax --dir ./exports --jurisdiction PL --year 2024 --fx-rates ./nbp_rates.csv
+
+  # Irish CGT and exit tax, treating an Irish-domiciled share as CGT
+  t212-taxes tax --dir ./exports --jurisdiction IE --year 2024 --instrument IE00B4BNMY34=share
+
+  # US Form 8949 with wash sale adjustments, written as CSV
+  t212-taxes tax --dir ./exports --jurisdiction US --year 2024 --csv-dir ./forms
+
+  # Dutch Box 3 worksheet with savings held outside Trading 212
+  t212-taxes tax --dir ./exports --jurisdiction NL --year 2024 --cash 12000 --fiscal-partner
+
+  # UK capital gains at the higher rate with a £60,000 salary
+  t212-taxes tax --dir ./exports --jurisdiction UK --year 2024 --other-income 47430
+
+  # Generic calculation for a jurisdiction defined in a YAML file
+  t212-taxes tax --dir ./exports --jurisdiction-file ./ch.yaml --jurisdiction CH --year 2024
+
+  # Foreign tax credits using your own treaty-rate table
+  t212-taxes tax --dir ./exports --jurisdiction DE --year 2024 --treaty-rates ./treaties.yaml
+
+  # Save the calculation as JSON
+  t212-taxes tax --dir ./exports --jurisdiction LT --year 2024 --format json --output tax_2024.json`,
+	Run: calculateTax,
+}
+
+// TaxCommandResult is the combined output of the tax command
+type TaxCommandResult struct {
+	Jurisdiction     string                             `json:"jurisdiction"`
+	Year             int                                `json:"year"`
+	Currency         string                             `json:"currency"`
+	Calculation      *types.TaxCalculation              `json:"calculation"`
+	LossCarryForward []calculator.LossYearSummary       `json:"loss_carry_forward,omitempty"`
+	ForeignCredits   *calculator.ForeignTaxCreditReport `json:"foreign_credits,omitempty"`
+	LTDeclaration    *calculator.LTDeclaration          `json:"lt_declaration,omitempty"`
+	BGAnnexes        *calculator.BGAnnexReport          `json:"bg_annexes,omitempty"`
+	DEReport         *calculator.DEReport               `json:"de_report,omitempty"`
+	PLReport         *calculator.PLReport               `json:"pl_report,omitempty"`
+	IEReport         *calculator.IEReport               `json:"ie_report,omitempty"`
+	USReport         *calculator.USReport               `json:"us_report,omitempty"`
+	NLReport         *calculator.NLReport               `json:"nl_report,omitempty"`
+	FXGains          *calculator.FXYear                 `json:"fx_gains,omitempty"`
+}
+
+// calculateTax handles the tax command
+func calculateTax(cmd *cobra.Command, args []string) {
+	taxCalc, code, currency := newTaxCalculator(cmd)
+	year, _ := cmd.Flags().GetInt("year")
+
+	result := parseTransactions(cmd)
+	if year == 0 {
+		jurisdiction, _ := taxCalc.GetJurisdiction(code)
+		year = jurisdiction.TaxYearOf(result.Summary.DateRange.To)
+	}
+
+	options := types.ProcessingOptions{
+		TaxYear:               year,
+		Currency:              types.Currency(currency),
+		Jurisdiction:          code,
+		IncludeWithholdingTax: true,
+		Profile:               taxProfile(cmd),
+	}
+
+	calculation, err := taxCalc.Calculate(result.Transactions, options)
+	if err != nil {
+		log.Fatalf("Error calculating tax: %v", err)
+	}
+
+	lossHistory, err := taxCalc.CalculateLossCarryForward(result.Transactions, options)
+	if err != nil {
+		log.Fatalf("Error calculating loss carry-forward: %v", err)
+	}
+
+	foreignCredits, err := taxCalc.CalculateForeignTaxCredits(result.Transactions, options)
+	if err != nil {
+		log.Fatalf("Error calculating foreign tax credits: %v", err)
+	}
+	if len(foreignCredits.Dividends) == 0 {
+		foreignCredits = nil
+	}
+
+	taxResult := &TaxCommandResult{
+		Jurisdiction:     code,
+		Year:             year,
+		Currency:         currency,
+		Calculation:      calculation,
+		LossCarryForward: lossHistory,
+		ForeignCredits:   foreignCredits,
+	}
+	if fxGains := taxCalc.CalculateFXGains(result.Transactions, currency).Year(year); fxGains.Disposals > 0 {
+		taxResult.FXGains = &fxGains
+	}
+
+	switch code {
+	case "LT":
+		taxResult.LTDeclaration, err = taxCalc.GenerateLTDeclaration(result.Transactions, year)
+		if err != nil {
+			log.Fatalf("Error generating GPM311 figures: %v", err)
+		}
+	case "BG":
+		taxResult.BGAnnexes, err = taxCalc.GenerateBGAnnexes(result.Transactions, year)
+		if err != nil {
+			log.Fatalf("Error generating Bulgarian annexes: %v", err)
+		}
+	case "DE":
+		churchTax, _ := cmd.Flags().GetFloat64("church-tax")
+		joint, _ := cmd.Flags().GetBool("joint")
+		fundTypes := make(map[string]calculator.DEFundType)
+		for isin, fundType := range instrumentOverrides(cmd) {
+			if fundType == "none" {
+				fundType = string(calculator.DEFundNone)
+			}
+			fundTypes[isin] = calculator.DEFundType(fundType)
+		}
+		options := calculator.DEOptions{
+			ChurchTaxRate:   churchTax,
+			JointAssessment: joint,
+			FundTypes:       fundTypes,
+		}
+		if cmd.Flags().Changed("share-losses") {
+			shareLosses, _ := cmd.Flags().GetFloat64("share-losses")
+			options.ShareLossCarryForward = &shareLosses
+		}
+		if cmd.Flags().Changed("other-losses") {
+			otherLosses, _ := cmd.Flags().GetFloat64("other-losses")
+			options.OtherLossCarryForward = &otherLosses
+		}
+		taxResult.DEReport, err = taxCalc.GenerateDEReport(result.Transactions, year, options)
+		if err != nil {
+			log.Fatalf("Error generating German tax report: %v", err)
+		}
+	case "PL":
+		taxResult.PLReport, err = taxCalc.GeneratePLReport(result.Transactions, year)
+		if err != nil {
+			log.Fatalf("Error generating PIT-38 figures: %v", err)
+		}
+	case "IE":
+		instruments := make(map[string]calculator.IEInstrumentType)
+		for isin, instrumentType := range instrumentOverrides(cmd) {
+			instruments[isin] = calculator.IEInstrumentType(instrumentType)
+		}
+		taxResult.IEReport, err = taxCalc.GenerateIEReport(result.Transactions, year, calculator.IEOptions{
+			Instruments: instruments,
+		})
+		if err != nil {
+			log.Fatalf("Error generating Irish tax report: %v", err)
+		}
+	case "US":
+		taxResult.USReport, err = taxCalc.GenerateUSReport(result.Transactions, year)
+		if err != nil {
+			log.Fatalf("Error generating Form 8949: %v", err)
+		}
+	case "NL":
+		cash, _ := cmd.Flags().GetFloat64("cash")
+		debts, _ := cmd.Flags().GetFloat64("debts")
+		partner, _ := cmd.Flags().GetBool("fiscal-partner")
+		taxResult.NLReport, err = taxCalc.GenerateNLReport(result.Transactions, year, calculator.NLOptions{
+			Cash:          cash,
+			Debts:         debts,
+			FiscalPartner: partner,
+		})
+		if err != nil {
+			log.Fatalf("Error generating Box 3 worksheet: %v", err)
+		}
+	}
+
+	if csvDir, _ := cmd.Flags().GetString("csv-dir"); csvDir != "" {
+		if err := writeAnnexCSVFiles(taxResult, csvDir); err != nil {
+			log.Fatalf("Error writing annex CSV files: %v", err)
+		}
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	outputFile, _ := cmd.Flags().GetString("output")
+	writeTaxResult(taxResult, format, outputFile)
+}
+
+// newTaxCalculator creates a tax calculator for the --jurisdiction flag, loading any jurisdiction
+// definitions, treaty rates, official exchange rates and closing prices given in the command's flags. It returns
+// the calculator, the upper-case jurisdiction code and the reporting currency.
+func newTaxCalculator(cmd *cobra.Command) (*calculator.TaxCalculator, string, string) {
+	code, _ := cmd.Flags().GetString("jurisdiction")
+	code = strings.ToUpper(code)
+
+	taxCalc := calculator.NewTaxCalculator()
+	if definitions, _ := cmd.Flags().GetStringSlice("jurisdiction-file"); len(definitions) > 0 {
+		if err := taxCalc.LoadJurisdictions(definitions...); err != nil {
+			log.Fatalf("Error loading jurisdiction definitions: %v", err)
+		}
+	}
+	if treatyFile, _ := cmd.Flags().GetString("treaty-rates"); treatyFile != "" {
+		treaties, err := calculator.LoadTreatyTable(treatyFile)
+		if err != nil {
+			log.Fatalf("Error loading treaty rates: %v", err)
+		}
+		taxCalc.SetTreatyTable(treaties)
+	}
+	jurisdiction, exists := taxCalc.GetJurisdiction(code)
+	if !exists {
+		var codes []string
+		for _, supported := range taxCalc.GetSupportedJurisdictions() {
+			codes = append(codes, supported.Code)
+		}
+		log.Fatalf("Unsupported jurisdiction %q (supported: %s)", code, strings.Join(codes, ", "))
+	}
+
+	currency := jurisdiction.Currency
+	if cmd.Flags().Changed("currency") {
+		currency = viper.GetString("currency")
+	}
+
+	if ratesFile, _ := cmd.Flags().GetString("fx-rates"); ratesFile != "" {
+		rates, err := calculator.LoadRateTable(ratesFile, currency, jurisdiction.FXSource)
+		if err != nil {
+			log.Fatalf("Error loading exchange rates: %v", err)
+		}
+		taxCalc.SetFXRateProvider(rates)
+	}
+	if prices := priceProvider(cmd); prices != nil {
+		taxCalc.SetPriceProvider(prices)
+	}
+
+	return taxCalc, code, currency
+}
+
+// priceProvider loads the closing prices in the command's --prices file, or returns nil without one
+func priceProvider(cmd *cobra.Command) calculator.PriceProvider {
+	pricesFile, _ := cmd.Flags().GetString("prices")
+	if pricesFile == "" {
+		return nil
+	}
+	store, err := calculator.LoadPriceStore(pricesFile)
+	if err != nil {
+		log.Fatalf("Error loading prices: %v", err)
+	}
+	return store
+}
+
+// taxProfile returns the taxpayer profile from the config file, overridden by the command's
+// --other-income and --aggregate-income flags
+func taxProfile(cmd *cobra.Command) types.TaxProfile {
+	profile := types.TaxProfile{
+		OtherIncome:     viper.GetFloat64("profile.other_income"),
+		AggregateIncome: viper.GetBool("profile.aggregate_income"),
+	}
+	if cmd.Flags().Changed("other-income") {
+		profile.OtherIncome, _ = cmd.Flags().GetFloat64("other-income")
+	}
+	if cmd.Flags().Changed("aggregate-income") {
+		profile.AggregateIncome, _ = cmd.Flags().GetBool("aggregate-income")
+	}
+	return profile
+}
+
+// instrumentOverrides returns the --instrument classifications keyed by upper-case ISIN
+func instrumentOverrides(cmd *cobra.Command) map[string]string {
+	flags, _ := cmd.Flags().GetStringToString("instrument")
+	overrides := make(map[string]string, len(flags))
+	for isin, instrumentType := range flags {
+		overrides[strings.ToUpper(isin)] = strings.ToLower(instrumentType)
+	}
+	return overrides
+}
+
+// parseTransactions parses the CSV files selected by the command flags
+func parseTransactions(cmd *cobra.Command) *types.ProcessingResult {
+	files, err := getCSVFiles(cmd)
+	if err != nil {
+		log.Fatalf("Error getting CSV files: %v", err)
+	}
+
+	if len(files) == 0 {
+		log.Fatal("No CSV files found")
+	}
+
+	csvParser := parser.NewCSVParser()
+	result, err := csvParser.ParseMultipleFiles(files)
+	if err != nil {
+		log.Fatalf("Error parsing CSV files: %v", err)
+	}
+
+	return result
+}
+
+// writeTaxResult prints or saves the tax result in the requested format
+func writeTaxResult(result *TaxCommandResult, format, outputFile string) {
+	var out io.Writer = os.Stdout
+	if outputFile != "" {
+		file, err := os.Create(outputFile)
+		if err != nil {
+			log.Fatalf("Error creating output file: %v", err)
+		}
+		defer func() { _ = file.Close() }()
+		out = file
+	}
+
+	if format == JSONFormat {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(result); err != nil {
+			log.Fatalf("Error encoding tax result: %v", err)
+		}
+	} else {
+		printTaxResultTable(out, result)
+	}
+
+	if outputFile != "" {
+		fmt.Printf("Tax report saved to %s\n", outputFile)
+	}
+}
+
+// printTaxResultTable prints the tax result in table format
+func printTaxResultTable(out io.Writer, result *TaxCommandResult) {
+	calc := result.Calculation
+
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "              TAX REPORT %d (%s)\n", result.Year, result.Jurisdiction)
+	_, _ = fmt.Fprintln(out, strings.Repeat("=", SeparatorWidth80))
+
+	_, _ = fmt.Fprintf(out, "\n📈 CAPITAL GAINS (%s)\n", result.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Total Gains:            %10.2f\n", calc.TotalGains)
+	_, _ = fmt.Fprintf(out, "Total Losses:           %10.2f\n", calc.TotalLosses)
+	_, _ = fmt.Fprintf(out, "Net Gain/Loss:          %10.2f\n", calc.NetGainLoss)
+
+	_, _ = fmt.Fprintf(out, "\n💰 DIVIDENDS (%s)\n", result.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Dividend Income:        %10.2f\n", calc.DividendIncome)
+	_, _ = fmt.Fprintf(out, "Withholding Tax Paid:   %10.2f\n", calc.WithholdingTaxPaid)
+
+	if result.ForeignCredits != nil {
+		printForeignCredits(out, result.ForeignCredits)
+	}
+	if len(result.LossCarryForward) > 0 {
+		printLossCarryForward(out, result.LossCarryForward, result.Currency)
+	}
+	if result.FXGains != nil {
+		_, _ = fmt.Fprintf(out, "\n💱 CURRENCY GAINS ON FOREIGN CASH (%s)\n", result.Currency)
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+		_, _ = fmt.Fprintf(out, "FX Gains:               %10.2f\n", result.FXGains.Gains)
+		_, _ = fmt.Fprintf(out, "FX Losses:              %10.2f\n", result.FXGains.Losses)
+		_, _ = fmt.Fprintf(out, "Net FX Gain/Loss:       %10.2f\n", result.FXGains.Net)
+		_, _ = fmt.Fprintln(out, "Reported separately; not included in the tax below.")
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🧾 TAX (%s)\n", result.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	if calc.LossesUsed > 0 {
+		_, _ = fmt.Fprintf(out, "Losses Brought Forward: %10.2f\n", calc.LossesBroughtForward)
+		_, _ = fmt.Fprintf(out, "Losses Used:            %10.2f\n", calc.LossesUsed)
+	}
+	_, _ = fmt.Fprintf(out, "Taxable Income:         %10.2f\n", calc.TaxableIncome)
+	_, _ = fmt.Fprintf(out, "Capital Gains Tax:      %10.2f\n", calc.CapitalGainsTax)
+	_, _ = fmt.Fprintf(out, "Dividend Tax:           %10.2f\n", calc.DividendTax)
+	_, _ = fmt.Fprintf(out, "Marginal Rate on Gains: %9.2f%%\n", calc.MarginalRate*PercentMultiplier)
+	_, _ = fmt.Fprintf(out, "Estimated Tax:          %10.2f\n", calc.EstimatedTax)
+
+	if result.LTDeclaration != nil {
+		printLTDeclaration(out, result.LTDeclaration)
+	}
+	if result.BGAnnexes != nil {
+		printBGAnnexes(out, result.BGAnnexes)
+	}
+	if result.DEReport != nil {
+		printDEReport(out, result.DEReport)
+	}
+	if result.PLReport != nil {
+		printPLReport(out, result.PLReport)
+	}
+	if result.IEReport != nil {
+		printIEReport(out, result.IEReport)
+	}
+	if result.USReport != nil {
+		printUSReport(out, result.USReport)
+	}
+	if result.NLReport != nil {
+		printNLReport(out, result.NLReport)
+	}
+
+	_, _ = fmt.Fprintln(out, "\n"+strings.Repeat("=", SeparatorWidth80))
+}
+
+// printLTDeclaration prints the GPM311 annex rows
+func printLTDeclaration(out io.Writer, declaration *calculator.LTDeclaration) {
+	_, _ = fmt.Fprintf(out, "\n🇱🇹 GPM311 FOREIGN INCOME ROWS (%s)\n", declaration.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-6s %-18s %-8s %12s %12s %12s %12s\n",
+		"Code", "Income Type", "Country", "Income", "Deductions", "Tax Abroad", "Creditable")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+
+	for _, row := range declaration.Rows {
+		_, _ = fmt.Fprintf(out, "%-6s %-18s %-8s %12.2f %12.2f %12.2f %12.2f\n",
+			row.IncomeTypeCode, row.IncomeType, row.Country, row.Income, row.Deductions, row.TaxPaidAbroad, row.CreditableTax)
+	}
+
+	_, _ = fmt.Fprintln(out)
+	_, _ = fmt.Fprintf(out, "Net Securities Gain:    %10.2f\n", declaration.NetGain)
+	if declaration.LossesBroughtForward > 0 || declaration.LossesCarriedForward > 0 {
+		_, _ = fmt.Fprintf(out, "Losses Brought Forward: %10.2f\n", declaration.LossesBroughtForward)
+		_, _ = fmt.Fprintf(out, "Losses Used:            %10.2f\n", declaration.LossesUsed)
+		_, _ = fmt.Fprintf(out, "Losses Carried Forward: %10.2f\n", declaration.LossesCarriedForward)
+	}
+	_, _ = fmt.Fprintf(out, "Annual Exemption Used:  %10.2f\n", declaration.Exemption)
+	_, _ = fmt.Fprintf(out, "Taxable Gain:           %10.2f\n", declaration.TaxableGain)
+	_, _ = fmt.Fprintf(out, "Capital Gains Tax:      %10.2f\n", declaration.CapitalGainsTax)
+	_, _ = fmt.Fprintf(out, "Dividend Tax Due:       %10.2f\n", declaration.DividendTax)
+	_, _ = fmt.Fprintf(out, "Total GPM Due:          %10.2f\n", declaration.TotalTax)
+
+	for _, warning := range declaration.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printBGAnnexes prints the Bulgarian annex tables
+func printBGAnnexes(out io.Writer, report *calculator.BGAnnexReport) {
+	_, _ = fmt.Fprintf(out, "\n🇧🇬 ANNEX 8 PART I - SHARES HELD AT %d-12-31 (%s)\n", report.Year, report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-8s %-14s %12s %-12s %14s %14s\n", "Country", "ISIN", "Count", "Acquired", "Cost (ccy)", "Cost")
+	for _, holding := range report.Annex8PartI {
+		_, _ = fmt.Fprintf(out, "%-8s %-14s %12.4f %-12s %10.2f %-3s %14.2f\n",
+			holding.Country, holding.ISIN, holding.Shares, holding.AcquiredAt.Format("2006-01-02"),
+			holding.LocalCost, holding.LocalCurrency, holding.Cost)
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🇧🇬 ANNEX 8 PART IV - DIVIDENDS (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-24s %-8s %12s %12s %12s %10s\n", "Payer", "Country", "Gross", "Tax Abroad", "Credit", "Tax Due")
+	for _, row := range report.Annex8PartIV {
+		_, _ = fmt.Fprintf(out, "%-24.24s %-8s %12.2f %12.2f %12.2f %10.2f\n",
+			row.Payer, row.Country, row.GrossIncome, row.TaxPaidAbroad, row.RecognizedCredit, row.TaxDue)
+	}
+
+	total := report.Annex5Total
+	_, _ = fmt.Fprintf(out, "\n🇧🇬 ANNEX 5 TABLE 2 - CODE %s (%s)\n", total.Code, report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Sales:                  %10d\n", len(report.Annex5))
+	_, _ = fmt.Fprintf(out, "Sale Price:             %10.2f\n", total.SalePrice)
+	_, _ = fmt.Fprintf(out, "Acquisition Price:      %10.2f\n", total.AcquisitionPrice)
+	_, _ = fmt.Fprintf(out, "Profit:                 %10.2f\n", total.Profit)
+	_, _ = fmt.Fprintf(out, "Loss:                   %10.2f\n", total.Loss)
+	_, _ = fmt.Fprintf(out, "Capital Gains Tax:      %10.2f\n", report.CapitalGainsTax)
+	_, _ = fmt.Fprintf(out, "Dividend Tax Due:       %10.2f\n", report.DividendTax)
+
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printDEReport prints the German flat tax computation and form lines
+func printDEReport(out io.Writer, report *calculator.DEReport) {
+	_, _ = fmt.Fprintf(out, "\n🇩🇪 ABGELTUNGSTEUER (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Share Gains:            %10.2f\n", report.ShareGains)
+	_, _ = fmt.Fprintf(out, "Share Losses:           %10.2f\n", report.ShareLosses)
+	_, _ = fmt.Fprintf(out, "Dividends:              %10.2f\n", report.Dividends)
+	_, _ = fmt.Fprintf(out, "Interest:               %10.2f\n", report.Interest)
+	_, _ = fmt.Fprintf(out, "Fund Income (net TF):   %10.2f\n", report.FundIncome)
+	_, _ = fmt.Fprintf(out, "Fund Losses (net TF):   %10.2f\n", report.FundLosses)
+	_, _ = fmt.Fprintf(out, "Net Capital Income:     %10.2f\n", report.NetIncome)
+	_, _ = fmt.Fprintf(out, "Sparer-Pauschbetrag:    %10.2f\n", report.SaverAllowance)
+	_, _ = fmt.Fprintf(out, "Taxable Income:         %10.2f\n", report.TaxableIncome)
+	_, _ = fmt.Fprintf(out, "Foreign Tax Credit:     %10.2f\n", report.CreditableForeignTax)
+	_, _ = fmt.Fprintf(out, "Abgeltungsteuer:        %10.2f\n", report.IncomeTax)
+	_, _ = fmt.Fprintf(out, "Solidaritätszuschlag:   %10.2f\n", report.SolidaritySurcharge)
+	_, _ = fmt.Fprintf(out, "Kirchensteuer:          %10.2f\n", report.ChurchTax)
+	_, _ = fmt.Fprintf(out, "Total Tax:              %10.2f\n", report.TotalTax)
+
+	if len(report.Funds) > 0 {
+		_, _ = fmt.Fprintln(out, "\n🇩🇪 INVESTMENT FUNDS (before Teilfreistellung)")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		_, _ = fmt.Fprintf(out, "%-14s %-10s %12s %14s %12s %12s\n",
+			"ISIN", "Type", "Distrib.", "Vorabpausch.", "Sale G/L", "Exempt")
+		for _, fund := range report.Funds {
+			_, _ = fmt.Fprintf(out, "%-14s %-10.10s %12.2f %14.2f %12.2f %12.2f\n",
+				fund.ISIN, fund.Type, fund.Distributions, fund.Vorabpauschale, fund.SaleGainLoss, fund.PartialExemption)
+		}
+	}
+
+	_, _ = fmt.Fprintln(out, "\n🇩🇪 LOSS POTS")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-8s %16s %12s %12s %16s\n", "Pot", "Brought Fwd", "Arising", "Used", "Carried Fwd")
+	for _, pot := range []struct {
+		name string
+		pot  calculator.DELossPot
+	}{{"Shares", report.SharePot}, {"Other", report.OtherPot}} {
+		_, _ = fmt.Fprintf(out, "%-8s %16.2f %12.2f %12.2f %16.2f\n",
+			pot.name, pot.pot.BroughtForward, pot.pot.Arising, pot.pot.Used, pot.pot.CarriedForward)
+	}
+
+	_, _ = fmt.Fprintln(out, "\n🇩🇪 ANLAGE KAP / KAP-INV")
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, line := range report.Lines {
+		_, _ = fmt.Fprintf(out, "%-8s %4d  %-52.52s %12.2f\n", line.Form, line.Line, line.Description, line.Amount)
+	}
+
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printPLReport prints the PIT-38 fields and PIT/ZG rows
+func printPLReport(out io.Writer, report *calculator.PLReport) {
+	_, _ = fmt.Fprintf(out, "\n🇵🇱 PIT-38 (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, field := range report.Fields {
+		_, _ = fmt.Fprintf(out, "%-2s poz. %-3d %-48s %14.2f\n", field.Section, field.Field, field.Description, field.Amount)
+	}
+	if report.LossCarriedForward > 0 {
+		_, _ = fmt.Fprintf(out, "Loss carried forward: %.2f\n", report.LossCarriedForward)
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🇵🇱 PIT/ZG (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-8s %14s %14s %14s\n", "Country", "Income", "Costs", "Profit")
+	for _, row := range report.PITZG {
+		_, _ = fmt.Fprintf(out, "%-8s %14.2f %14.2f %14.2f\n", row.Country, row.Income, row.Costs, row.Profit)
+	}
+
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printIEReport prints the Irish CGT and exit tax computation
+func printIEReport(out io.Writer, report *calculator.IEReport) {
+	_, _ = fmt.Fprintf(out, "\n🇮🇪 CAPITAL GAINS TAX (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Gains:                  %10.2f\n", report.Gains)
+	_, _ = fmt.Fprintf(out, "Losses:                 %10.2f\n", report.Losses)
+	_, _ = fmt.Fprintf(out, "Restricted Losses:      %10d\n", len(report.RestrictedLosses))
+	_, _ = fmt.Fprintf(out, "Released Losses:        %10.2f\n", report.ReleasedLosses)
+	if report.LossesBroughtForward > 0 || report.LossesCarriedForward > 0 {
+		_, _ = fmt.Fprintf(out, "Losses Brought Forward: %10.2f\n", report.LossesBroughtForward)
+		_, _ = fmt.Fprintf(out, "Losses Used:            %10.2f\n", report.LossesUsed)
+		_, _ = fmt.Fprintf(out, "Losses Carried Forward: %10.2f\n", report.LossesCarriedForward)
+	}
+	_, _ = fmt.Fprintf(out, "Annual Exemption:       %10.2f\n", report.Exemption)
+	_, _ = fmt.Fprintf(out, "Taxable Gain:           %10.2f\n", report.TaxableGain)
+	_, _ = fmt.Fprintf(out, "%-24s%10.2f\n", fmt.Sprintf("CGT at %.4g%%:", report.CapitalGainsTaxRate*PercentMultiplier), report.CapitalGainsTax)
+
+	_, _ = fmt.Fprintf(out, "\n🇮🇪 EXIT TAX (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Fund Gains:             %10.2f\n", report.FundGains)
+	_, _ = fmt.Fprintf(out, "Fund Losses:            %10.2f\n", report.FundLosses)
+	_, _ = fmt.Fprintf(out, "Deemed Disposal Credit: %10.2f\n", report.DeemedDisposalCredit)
+	_, _ = fmt.Fprintf(out, "Tax on Disposals:       %10.2f\n", report.ExitTaxOnDisposals)
+	_, _ = fmt.Fprintf(out, "Fund Distributions:     %10.2f\n", report.FundDistributions)
+	_, _ = fmt.Fprintf(out, "Tax on Distributions:   %10.2f\n", report.ExitTaxOnDistributions)
+	_, _ = fmt.Fprintf(out, "Deemed Disposal Tax:    %10.2f\n", report.DeemedDisposalTax)
+	_, _ = fmt.Fprintf(out, "Total Exit Tax:         %10.2f\n", report.ExitTax)
+	_, _ = fmt.Fprintf(out, "Total Tax:              %10.2f\n", report.TotalTax)
+
+	if len(report.RestrictedLosses) > 0 {
+		_, _ = fmt.Fprintln(out, "\n🇮🇪 LOSSES RESTRICTED BY THE FOUR-WEEK RULE")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		for _, loss := range report.RestrictedLosses {
+			_, _ = fmt.Fprintf(out, "%-14s sold %s, bought back %s: %10.2f\n",
+				loss.ISIN, loss.Date.Format("2006-01-02"), loss.RepurchasedAt.Format("2006-01-02"), loss.Loss)
+		}
+	}
+
+	if len(report.DeemedDisposals) > 0 {
+		_, _ = fmt.Fprintln(out, "\n🇮🇪 EIGHT-YEAR DEEMED DISPOSALS")
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		_, _ = fmt.Fprintf(out, "%-14s %-12s %12s %12s %12s %10s\n", "ISIN", "Date", "Shares", "Cost", "Value", "Tax")
+		for _, deemed := range report.DeemedDisposals {
+			_, _ = fmt.Fprintf(out, "%-14s %-12s %12.4f %12.2f %12.2f %10.2f\n",
+				deemed.ISIN, deemed.Date.Format("2006-01-02"), deemed.Shares, deemed.Cost, deemed.MarketValue, deemed.Tax)
+		}
+	}
+
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printUSReport prints Form 8949 rows and Schedule D totals
+func printUSReport(out io.Writer, report *calculator.USReport) {
+	for _, part := range []struct {
+		title string
+		rows  []calculator.Form8949Row
+	}{{"SHORT-TERM (BOX C)", report.ShortTerm}, {"LONG-TERM (BOX F)", report.LongTerm}} {
+		_, _ = fmt.Fprintf(out, "\n🇺🇸 FORM 8949 %s (%s)\n", part.title, report.Currency)
+		_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+		_, _ = fmt.Fprintf(out, "%-18s %-10s %-10s %10s %10s %-2s %8s %10s\n",
+			"(a) Description", "(b) Acq.", "(c) Sold", "(d) Proc.", "(e) Cost", "f", "(g) Adj", "(h) G/L")
+		for _, row := range part.rows {
+			_, _ = fmt.Fprintf(out, "%-18.18s %-10s %-10s %10.2f %10.2f %-2s %8.2f %10.2f\n",
+				row.Description, row.DateAcquired.Format("01/02/06"), row.DateSold.Format("01/02/06"),
+				row.Proceeds, row.Cost, row.Code, row.Adjustment, row.GainLoss)
+		}
+	}
+
+	_, _ = fmt.Fprintf(out, "\n🇺🇸 SCHEDULE D (%s)\n", report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth40))
+	_, _ = fmt.Fprintf(out, "Line 3  Short-term:     %10.2f\n", report.ShortTermTotal.GainLoss)
+	_, _ = fmt.Fprintf(out, "Line 7  Net short-term: %10.2f\n", report.NetShortTerm)
+	_, _ = fmt.Fprintf(out, "Line 10 Long-term:      %10.2f\n", report.LongTermTotal.GainLoss)
+	_, _ = fmt.Fprintf(out, "Line 15 Net long-term:  %10.2f\n", report.NetLongTerm)
+	_, _ = fmt.Fprintf(out, "Line 16 Total:          %10.2f\n", report.NetGainLoss)
+	if report.LossesBroughtForward > 0 {
+		_, _ = fmt.Fprintf(out, "Carryover from prior:   %10.2f\n", report.LossesBroughtForward)
+		_, _ = fmt.Fprintf(out, "Carryover used:         %10.2f\n", report.LossesUsed)
+	}
+	if report.CapitalLossDeduction > 0 {
+		_, _ = fmt.Fprintf(out, "Line 21 Loss deduction: %10.2f\n", report.CapitalLossDeduction)
+	}
+	if report.LossCarryover > 0 {
+		_, _ = fmt.Fprintf(out, "Loss carryover:         %10.2f\n", report.LossCarryover)
+	}
+	_, _ = fmt.Fprintf(out, "Wash sale disallowed:   %10.2f\n", report.WashSaleDisallowed)
+
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printNLReport prints the Box 3 worksheet
+func printNLReport(out io.Writer, report *calculator.NLReport) {
+	_, _ = fmt.Fprintf(out, "\n🇳🇱 BOX 3 WORKSHEET %d (%s)\n", report.Year, report.Currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	for _, line := range report.Worksheet {
+		_, _ = fmt.Fprintf(out, "%-60s %14.2f\n", line.Description, line.Amount)
+	}
+
+	if report.CounterproofApplies {
+		_, _ = fmt.Fprintln(out, "✅ The actual return is lower than the deemed return; the counterproof reduces the tax")
+	}
+	for _, warning := range report.Warnings {
+		_, _ = fmt.Fprintf(out, "⚠️  %s\n", warning)
+	}
+}
+
+// printLossCarryForward prints the loss ledger up to the report year
+func printLossCarryForward(out io.Writer, history []calculator.LossYearSummary, currency string) {
+	_, _ = fmt.Fprintf(out, "\n📉 LOSS CARRY-FORWARD (%s)\n", currency)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-6s %12s %12s %10s %10s %12s %12s\n",
+		"Year", "Net", "B/f", "Expired", "Used", "Vs income", "C/f")
+	for _, summary := range history {
+		_, _ = fmt.Fprintf(out, "%-6d %12.2f %12.2f %10.2f %10.2f %12.2f %12.2f\n",
+			summary.Year, summary.NetGainLoss, summary.BroughtForward, summary.Expired,
+			summary.Used, summary.OffsetAgainstIncome, summary.CarriedForward)
+	}
+}
+
+// printForeignCredits prints foreign tax credits per source country
+func printForeignCredits(out io.Writer, report *calculator.ForeignTaxCreditReport) {
+	_, _ = fmt.Fprintf(out, "\n🌍 FOREIGN TAX CREDITS (%s, residence %s)\n", report.Currency, report.ResidenceCountry)
+	_, _ = fmt.Fprintln(out, strings.Repeat("-", SeparatorWidth80))
+	_, _ = fmt.Fprintf(out, "%-7s %7s %10s %9s %10s %11s %11s %9s\n",
+		"Country", "Treaty", "Gross", "Withheld", "Credit", "Not credit", "Reclaimable", "Residual")
+	for _, country := range report.Countries {
+		treaty := fmt.Sprintf("%.1f%%", country.TreatyRate*PercentMultiplier)
+		if !country.TreatyKnown {
+			treaty += "*"
+		}
+		_, _ = fmt.Fprintf(out, "%-7s %7s %10.2f %9.2f %10.2f %11.2f %11.2f %9.2f\n",
+			country.Country, treaty, country.GrossAmount, country.Withheld, country.Creditable,
+			country.NonCreditable, country.Reclaimable, country.ResidualTax)
+	}
+	_, _ = fmt.Fprintf(out, "%-7s %7s %10.2f %9.2f %10.2f %11.2f %11.2f %9.2f\n",
+		"Total", "", report.GrossAmount, report.Withheld, report.Creditable,
+		report.NonCreditable, report.Reclaimable, report.ResidualTax)
+
+	for _, country := range report.Countries {
+		if !country.TreatyKnown {
+			_, _ = fmt.Fprintln(out, "* No treaty listed; the default treaty rate or the jurisdiction's credit cap was used")
+			break
+		}
+	}
+}
+
+// writeAnnexCSVFiles writes each generated annex table or form listing to its own CSV file
+func writeAnnexCSVFiles(result *TaxCommandResult, dir string) error {
+	if result.BGAnnexes == nil && result.USReport == nil {
+		return fmt.Errorf("no annex tables for jurisdiction %s", result.Jurisdiction)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	if result.USReport != nil {
+		filename := filepath.Join(dir, fmt.Sprintf("us_form8949_%d.csv", result.Year))
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		err = result.USReport.WriteForm8949CSV(file)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", filename)
+		return nil
+	}
+
+	for _, annex := range []calculator.BGAnnex{calculator.BGAnnex8PartI, calculator.BGAnnex8PartIV, calculator.BGAnnex5} {
+		filename := filepath.Join(dir, fmt.Sprintf("bg_%s_%d.csv", annex, result.Year))
+		file, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+
+		err = result.BGAnnexes.WriteCSV(file, annex)
+		_ = file.Close()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Wrote %s\n", filename)
+	}
+
+	return nil
+}
@@ -20,15 +20,19 @@ const (
 	ViewOverall              = "overall"
 	ViewPortfolio            = "portfolio"
 	ViewIncome               = "income"
+	ViewReturns              = "returns"
+	ViewBenchmark            = "benchmark"
+	ViewAllocation           = "allocation"
 	ViewHelp                 = "help"
 	PaddingRight             = 2
 	MaxPositions             = 10
-	SeparatorWidth           = 85
+	SeparatorWidth           = 92
 	AvailableHeightReduction = 20
 	MinAvailableHeight       = 5
 	CardsMargin              = 4
 	CardsSpacing             = 8
 	MaxCardWidth             = 45
+	AllocationBarPercent     = 2.5 // Weight in percent each block of an allocation bar stands for
 )
 
 var (
@@ -75,7 +79,10 @@ type Model struct {
 	AllTransactions   []types.Transaction             // Added for portfolio calculation
 	PortfolioReport   *types.PortfolioValuationReport // New: Full portfolio valuation data
 	IncomeReport      *types.IncomeReport             // New: Income/dividend data
-	CurrentView       string                          // "yearly", "overall", "portfolio", "income", "help"
+	ReturnsReport     *calculator.ReturnsReport       // Time-weighted and money-weighted returns
+	BenchmarkReport   *calculator.BenchmarkReport     // Portfolio against a benchmark
+	AllocationReport  *calculator.AllocationReport    // Year-end weights by sector, country, currency and more
+	CurrentView       string                          // "yearly", "overall", "portfolio", "income", "returns", "benchmark", "allocation", "help"
 	SelectedYear      int                             // Track which year's portfolio we're viewing
 	CurrentPortfolio  *types.PortfolioSummary         // Current portfolio data
 	PortfolioExpanded bool                            // Track if portfolio positions are expanded
@@ -186,6 +193,21 @@ func NewAppWithAllData(
 	return model
 }
 
+// SetReturnsReport sets the returns shown in the overall and returns views
+func (m *Model) SetReturnsReport(report *calculator.ReturnsReport) {
+	m.ReturnsReport = report
+}
+
+// SetBenchmarkReport sets the benchmark comparison shown in the benchmark view
+func (m *Model) SetBenchmarkReport(report *calculator.BenchmarkReport) {
+	m.BenchmarkReport = report
+}
+
+// SetAllocationReport sets the year-end allocations shown in the allocation view
+func (m *Model) SetAllocationReport(report *calculator.AllocationReport) {
+	m.AllocationReport = report
+}
+
 // Run starts the TUI application
 func (m *Model) Run() error {
 	p := tea.NewProgram(m, tea.WithAltScreen())
@@ -224,6 +246,18 @@ func (m Model) handleKeyMsg(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	case "p":
 		return m.handlePortfolioViewRequest(), nil
+	case "r":
+		if m.ReturnsReport != nil {
+			m.CurrentView = ViewReturns
+		}
+	case "c":
+		if m.BenchmarkReport != nil {
+			m.CurrentView = ViewBenchmark
+		}
+	case "a":
+		if m.AllocationReport != nil {
+			m.CurrentView = ViewAllocation
+		}
 	case "h", "?":
 		m.CurrentView = ViewHelp
 	case "up", "k":
@@ -338,9 +372,20 @@ func (m Model) handleYearlySelection() Model {
 		selectedYear := m.YearlyReports[selectedIndex].Year
 		m.SelectedYear = selectedYear
 
-		// Calculate portfolio for the selected year
-		portfolioCalc := calculator.NewPortfolioCalculator("EUR") // TODO: Make currency configurable
-		m.CurrentPortfolio = portfolioCalc.CalculateEndOfYearPortfolio(m.AllTransactions, selectedYear)
+		// Use the valuation report's portfolio for the selected year, which carries its closing prices
+		m.CurrentPortfolio = nil
+		if m.PortfolioReport != nil {
+			for i := range m.PortfolioReport.YearlyPortfolios {
+				if m.PortfolioReport.YearlyPortfolios[i].Year == selectedYear {
+					m.CurrentPortfolio = &m.PortfolioReport.YearlyPortfolios[i]
+					break
+				}
+			}
+		}
+		if m.CurrentPortfolio == nil {
+			portfolioCalc := calculator.NewPortfolioCalculator("EUR") // TODO: Make currency configurable
+			m.CurrentPortfolio = portfolioCalc.CalculateEndOfYearPortfolio(m.AllTransactions, selectedYear)
+		}
 		m.CurrentView = ViewPortfolio
 		// Reset portfolio navigation
 		m.PortfolioCursor = 0
@@ -415,6 +460,15 @@ func (m Model) View() string {
 	case ViewIncome:
 		title = "💰 Income Report"
 		content = m.renderIncomeView()
+	case ViewReturns:
+		title = "⏱️ Returns"
+		content = m.renderReturnsView()
+	case ViewBenchmark:
+		title = "🏁 Benchmark"
+		content = m.renderBenchmarkView()
+	case ViewAllocation:
+		title = "🧭 Allocation"
+		content = m.renderAllocationView()
 	case ViewHelp:
 		title = "❓ Help"
 		content = m.renderHelpView()
@@ -424,7 +478,13 @@ func (m Model) View() string {
 	}
 
 	// Navigation hints
-	navHints := "y: yearly • o: overall • p: portfolio • i: income • h: help • q: quit"
+	navHints := "y: yearly • o: overall • p: portfolio • i: income • r: returns • h: help • q: quit"
+	if m.BenchmarkReport != nil {
+		navHints = strings.Replace(navHints, "r: returns •", "r: returns • c: benchmark •", 1)
+	}
+	if m.AllocationReport != nil {
+		navHints = strings.Replace(navHints, "h: help", "a: allocation • h: help", 1)
+	}
 	if m.CurrentView == ViewPortfolio {
 		navHints = "b: back to yearly • " + navHints
 	}
@@ -516,6 +576,19 @@ func (m Model) createYearCard(report types.YearlyReport, isSelected bool) string
 	content.WriteString(fmt.Sprintf("📈 Gains: %s\n",
 		currencyStyle.Render(formatCurrency(report.CapitalGains, report.Currency))))
 
+	// Highlight years where lot matching disagrees with Trading 212's Result
+	if len(report.GainDiscrepancies) > 0 {
+		content.WriteString(warningStyle.Render(fmt.Sprintf("⚠️  T212: %s (%d differ)",
+			formatCurrency(report.BrokerCapitalGains, report.Currency), len(report.GainDiscrepancies))))
+		content.WriteString("\n")
+	}
+
+	if report.LossesBroughtForward > 0 || report.LossesCarriedForward > 0 {
+		content.WriteString(fmt.Sprintf("📉 Losses: %s used • %s c/f\n",
+			currencyStyle.Render(formatCurrency(report.LossesUsed, report.Currency)),
+			currencyStyle.Render(formatCurrency(report.LossesCarriedForward, report.Currency))))
+	}
+
 	content.WriteString(fmt.Sprintf("💎 Dividends: %s\n",
 		currencyStyle.Render(formatCurrency(report.Dividends, report.Currency))))
 
@@ -528,6 +601,12 @@ func (m Model) createYearCard(report types.YearlyReport, isSelected bool) string
 	content.WriteString(fmt.Sprintf("🎯 Total: %s\n",
 		currencyStyle.Render(formatCurrency(report.TotalGains, report.Currency))))
 
+	if period, ok := m.yearReturn(report.Year); ok {
+		content.WriteString(fmt.Sprintf("⏱️ TWR: %s • MWR: %s\n",
+			valueStyle.Render(fmt.Sprintf("%.1f%%", period.TimeWeighted)),
+			valueStyle.Render(formatRate(period.MoneyWeighted, 1))))
+	}
+
 	// Transactions count and percentage on last line
 	percentageText := fmt.Sprintf("%.1f%%", report.PercentageIncrease)
 	content.WriteString(fmt.Sprintf("📊 %d txns • %s",
@@ -595,6 +674,11 @@ func (m Model) renderPortfolioSummary(content *strings.Builder, portfolio types.
 		gainLossStyled = valueStyle.Render("➖ " + gainLossText)
 	}
 	content.WriteString(fmt.Sprintf("Unrealized P&L: %s\n", gainLossStyled))
+	if portfolio.StalePrices > 0 {
+		content.WriteString(warningStyle.Render(fmt.Sprintf("⚠️  %d position(s) priced more than %d days before the valuation date (marked !)",
+			portfolio.StalePrices, calculator.StalePriceDays)))
+		content.WriteString("\n")
+	}
 }
 
 func (m Model) renderPortfolioActivity(content *strings.Builder, portfolio types.PortfolioSummary) {
@@ -634,8 +718,8 @@ func (m Model) renderPositionsHeader(content *strings.Builder, portfolio types.P
 	content.WriteString("\n")
 
 	// Table header
-	content.WriteString(fmt.Sprintf("%-8s %8s %10s %12s %12s %10s %8s\n",
-		"Ticker", "Shares", "Last Price", "Total Cost", "Market Val", "P&L", "P&L %"))
+	content.WriteString(fmt.Sprintf("%-8s %8s %10s %12s %12s %10s %8s %-10s %5s\n",
+		"Ticker", "Shares", "Last Price", "Total Cost", "Market Val", "P&L", "P&L %", "Source", "Age"))
 	content.WriteString(strings.Repeat("-", SeparatorWidth))
 	content.WriteString("\n")
 }
@@ -708,14 +792,22 @@ func (m Model) formatPositionRow(pos types.PortfolioPosition) string {
 		plPercentText = "0.0%"
 	}
 
-	return fmt.Sprintf("%-8s %8.1f %10.2f %12.2f %12.2f %10s %8s",
+	// Format price age, marking prices too old for the valuation date
+	ageText := fmt.Sprintf("%dd", pos.PriceAgeDays)
+	if pos.PriceStale {
+		ageText += "!"
+	}
+
+	return fmt.Sprintf("%-8s %8.1f %10.2f %12.2f %12.2f %10s %8s %-10.10s %5s",
 		pos.Ticker,
 		pos.Shares,
 		pos.LastPrice,
 		pos.TotalCost,
 		pos.MarketValue,
 		plText,
-		plPercentText)
+		plPercentText,
+		pos.PriceSource,
+		ageText)
 }
 
 func (m Model) renderPositionsExpandInfo(content *strings.Builder, portfolio types.PortfolioSummary) {
@@ -758,6 +850,39 @@ func (m Model) renderIncomeView() string {
 	return boxStyle.Render(content)
 }
 
+// renderReturnsView renders the time-weighted and money-weighted returns per year, overall and per position
+func (m Model) renderReturnsView() string {
+	if m.ReturnsReport == nil {
+		return boxStyle.Render(warningStyle.Render("No returns data available."))
+	}
+
+	return boxStyle.Render(m.formatReturnsReport(*m.ReturnsReport))
+}
+
+// renderBenchmarkView renders the portfolio's returns against the benchmark
+func (m Model) renderBenchmarkView() string {
+	if m.BenchmarkReport == nil {
+		return boxStyle.Render(warningStyle.Render("No benchmark data available. Run analyze with --benchmark."))
+	}
+
+	return boxStyle.Render(m.formatBenchmarkReport(*m.BenchmarkReport))
+}
+
+// renderAllocationView renders the allocation at the selected year's end, or the latest one
+func (m Model) renderAllocationView() string {
+	if m.AllocationReport == nil || len(m.AllocationReport.Years) == 0 {
+		return boxStyle.Render(warningStyle.Render("No allocation data available."))
+	}
+
+	allocation := m.AllocationReport.Years[len(m.AllocationReport.Years)-1]
+	for _, year := range m.AllocationReport.Years {
+		if year.Year == m.SelectedYear {
+			allocation = year
+		}
+	}
+	return boxStyle.Render(m.formatAllocation(allocation, m.AllocationReport.Currency))
+}
+
 // renderHelpView renders the help view
 func (m Model) renderHelpView() string {
 	help := `Welcome to Trading 212 Tax Calculator!
@@ -775,6 +900,9 @@ financial metrics for tax purposes.
    o - View overall summary
    p - View portfolio (if available)
    i - View income report
+   r - View time-weighted and money-weighted returns
+   c - Compare with a benchmark (analyze --benchmark)
+   a - View allocation by sector, country, currency and asset class
    h - Show this help
    ↑↓←→ or k/j - Navigate grid (in yearly view)
    Enter/Space - Drill down to portfolio (in yearly view)
@@ -823,6 +951,16 @@ financial metrics for tax purposes.
    Your CSV files should follow this naming pattern:
    from_YYYY-MM-DD_to_YYYY-MM-DD_[hash].csv
 
+⏱️ Returns Features:
+   • Time-weighted return (TWR) per year and overall, unaffected by deposit timing
+   • Money-weighted return (annualised XIRR) over deposits and withdrawals
+   • Both measured for each position, with buys as money in and sells and dividends as money out
+
+🏁 Benchmark Features:
+   • Deposits and withdrawals replayed as if invested in the benchmark
+   • Portfolio and benchmark return per year, and cumulative since the start
+   • Tracking difference, beta and alpha per year
+
 💡 Features:
    • Yearly financial breakdowns in grid format
    • Capital gains calculations
@@ -890,7 +1028,17 @@ func (m Model) formatOverallReport(report types.OverallReport) string {
 		percentageStyled = valueStyle.Render(percentageText)
 	}
 
-	content.WriteString(fmt.Sprintf("📊 Overall Performance: %s", percentageStyled))
+	content.WriteString(fmt.Sprintf("📊 Overall Performance: %s\n", percentageStyled))
+
+	// Returns that are not distorted by the size of deposits
+	if m.ReturnsReport != nil {
+		content.WriteString(fmt.Sprintf("⏱️ Time-Weighted Return: %s",
+			valueStyle.Render(fmt.Sprintf("%.2f%%", m.ReturnsReport.Overall.TimeWeighted))))
+		if moneyWeighted := m.ReturnsReport.Overall.MoneyWeighted; moneyWeighted != nil {
+			content.WriteString(fmt.Sprintf("\n💸 Money-Weighted Return (XIRR, annualised): %s",
+				valueStyle.Render(formatRate(moneyWeighted, 2))))
+		}
+	}
 
 	// Investment efficiency
 	if report.TotalDeposits > 0 {
@@ -913,6 +1061,159 @@ func (m Model) formatOverallReport(report types.OverallReport) string {
 	return content.String()
 }
 
+// yearReturn returns the portfolio's returns for year, if returns were calculated
+func (m Model) yearReturn(year int) (calculator.PeriodReturn, bool) {
+	if m.ReturnsReport == nil {
+		return calculator.PeriodReturn{}, false
+	}
+	for _, period := range m.ReturnsReport.Years {
+		if period.Year == year {
+			return period, true
+		}
+	}
+	return calculator.PeriodReturn{}, false
+}
+
+// formatReturnsReport formats the returns report for display
+func (m Model) formatReturnsReport(report calculator.ReturnsReport) string {
+	var content strings.Builder
+
+	content.WriteString(headerStyle.Render("⏱️ Investment Returns"))
+	content.WriteString("\n\n")
+	content.WriteString(fmt.Sprintf("ℹ️  %s\n\n", report.PriceNote))
+
+	if len(report.Years) == 0 {
+		content.WriteString(warningStyle.Render("No transactions found."))
+		return content.String()
+	}
+
+	content.WriteString(fmt.Sprintf("%-10s %14s %14s %14s %10s %10s\n", "Period", "Start Value", "Net Flows", "End Value", "TWR", "MWR"))
+	content.WriteString(strings.Repeat("─", SeparatorWidth) + "\n")
+	for _, period := range report.Years {
+		content.WriteString(formatPeriodReturn(fmt.Sprintf("%d", period.Year), period))
+	}
+	content.WriteString(strings.Repeat("─", SeparatorWidth) + "\n")
+	content.WriteString(valueStyle.Render(formatPeriodReturn("Overall", report.Overall)))
+
+	if len(report.Positions) > 0 {
+		content.WriteString("\n")
+		content.WriteString(headerStyle.Render("📦 Positions (whole history)"))
+		content.WriteString("\n")
+		for _, position := range report.Positions {
+			content.WriteString(formatPeriodReturn(position.Ticker, position.Overall))
+		}
+	}
+
+	content.WriteString(fmt.Sprintf("\nAll amounts in %s; MWR is annualised", report.Currency))
+	return content.String()
+}
+
+// formatBenchmarkReport formats the benchmark comparison for display
+func (m Model) formatBenchmarkReport(report calculator.BenchmarkReport) string {
+	var content strings.Builder
+
+	content.WriteString(headerStyle.Render(fmt.Sprintf("🏁 Portfolio vs %s", report.Benchmark)))
+	content.WriteString("\n\n")
+	content.WriteString(fmt.Sprintf("ℹ️  %s\n\n", report.PriceNote))
+
+	if len(report.Years) == 0 {
+		content.WriteString(warningStyle.Render("No transactions found."))
+		return content.String()
+	}
+
+	content.WriteString(fmt.Sprintf("%-8s %10s %10s %11s %11s %10s %7s %9s\n",
+		"Period", "Portfolio", "Benchmark", "Cum. Port.", "Cum. Bench.", "Tracking", "Beta", "Alpha"))
+	content.WriteString(strings.Repeat("─", SeparatorWidth) + "\n")
+	for _, comparison := range report.Years {
+		row := formatBenchmarkComparison(fmt.Sprintf("%d", comparison.Year), comparison)
+		if comparison.TrackingDifference < 0 {
+			row = errorStyle.Render(row)
+		} else {
+			row = infoStyle.Render(row)
+		}
+		content.WriteString(row + "\n")
+	}
+	content.WriteString(strings.Repeat("─", SeparatorWidth) + "\n")
+	content.WriteString(valueStyle.Render(formatBenchmarkComparison("Overall", report.Overall)) + "\n")
+
+	content.WriteString(fmt.Sprintf("\n💼 Portfolio value: %s • Same deposits in %s: %s\n",
+		currencyStyle.Render(formatCurrency(report.Overall.PortfolioValue, report.Currency)), report.Benchmark,
+		currencyStyle.Render(formatCurrency(report.Overall.BenchmarkValue, report.Currency))))
+	content.WriteString(fmt.Sprintf("💸 Money-weighted (annualised): portfolio %s • benchmark %s",
+		formatRate(report.Overall.PortfolioMoneyWeighted, 2), formatRate(report.Overall.BenchmarkMoneyWeighted, 2)))
+	return content.String()
+}
+
+// formatAllocation formats a year-end allocation with a bar per group
+func (m Model) formatAllocation(allocation calculator.YearAllocation, currency string) string {
+	var content strings.Builder
+
+	content.WriteString(headerStyle.Render(fmt.Sprintf("🧭 Allocation at %s", allocation.AsOfDate.Format("2006-01-02"))))
+	content.WriteString("\n\n")
+	content.WriteString(fmt.Sprintf("💼 Positions: %s in %d holdings", currencyStyle.Render(formatCurrency(allocation.TotalValue, currency)),
+		len(allocation.Positions)))
+	if allocation.WeightedTER != nil {
+		content.WriteString(fmt.Sprintf(" • weighted TER %s", valueStyle.Render(formatRate(allocation.WeightedTER, 2))))
+	}
+	content.WriteString("\n")
+
+	if len(allocation.Positions) == 0 {
+		content.WriteString(warningStyle.Render("\nNo positions held."))
+		return content.String()
+	}
+
+	for _, breakdown := range allocation.Breakdowns {
+		content.WriteString("\n" + valueStyle.Render(allocationTitle(breakdown.Dimension)) + "\n")
+		for _, weight := range breakdown.Weights {
+			content.WriteString(fmt.Sprintf("  %-18s %6.1f%% %s\n", weight.Group, weight.Weight,
+				infoStyle.Render(strings.Repeat("█", int(weight.Weight/AllocationBarPercent)))))
+		}
+	}
+
+	for _, warning := range allocation.Warnings {
+		content.WriteString("\n" + warningStyle.Render("⚠️  "+warning))
+	}
+	return content.String()
+}
+
+// allocationTitle returns the heading of an allocation dimension
+func allocationTitle(dimension calculator.AllocationDimension) string {
+	switch dimension {
+	case calculator.AllocationAssetClass:
+		return "Asset class"
+	case calculator.AllocationSector:
+		return "Sector"
+	case calculator.AllocationCountry:
+		return "Country"
+	case calculator.AllocationCurrency:
+		return "Currency"
+	case calculator.AllocationDomicile:
+		return "Domicile"
+	}
+	return string(dimension)
+}
+
+// formatBenchmarkComparison formats one row of the benchmark table
+func formatBenchmarkComparison(label string, comparison calculator.BenchmarkComparison) string {
+	return fmt.Sprintf("%-8s %9.2f%% %9.2f%% %10.2f%% %10.2f%% %+9.2f%% %7.2f %+8.2f%%",
+		label, comparison.PortfolioReturn, comparison.BenchmarkReturn, comparison.PortfolioCumulative,
+		comparison.BenchmarkCumulative, comparison.TrackingDifference, comparison.Beta, comparison.Alpha)
+}
+
+// formatPeriodReturn formats one row of the returns table
+func formatPeriodReturn(label string, period calculator.PeriodReturn) string {
+	return fmt.Sprintf("%-10s %14.2f %14.2f %14.2f %9.2f%% %10s\n",
+		label, period.StartValue, period.NetFlows, period.EndValue, period.TimeWeighted, formatRate(period.MoneyWeighted, 2))
+}
+
+// formatRate formats a percentage that may be missing with the given decimals
+func formatRate(rate *float64, decimals int) string {
+	if rate == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.*f%%", decimals, *rate)
+}
+
 // formatIncomeReport formats the income report for display
 func (m Model) formatIncomeReport(report types.IncomeReport) string {
 	var content strings.Builder
@@ -1002,6 +1303,23 @@ func PrintReportsTable(yearlyReports []types.YearlyReport, overallReport *types.
 		fmt.Printf("💰 Deposits: %s\n", formatCurrency(report.TotalDeposits, report.Currency))
 		fmt.Printf("💳 Transactions: %d\n", report.TotalTransactions)
 		fmt.Printf("📈 Capital Gains: %s\n", formatCurrency(report.CapitalGains, report.Currency))
+		fmt.Printf("🔍 Trading 212 Result: %s\n", formatCurrency(report.BrokerCapitalGains, report.Currency))
+		for _, discrepancy := range report.GainDiscrepancies {
+			security := discrepancy.Ticker
+			if security == "" {
+				security = discrepancy.ISIN
+			}
+			fmt.Printf("⚠️  %s %s: matched %s vs Result %s\n", discrepancy.Date.Format("2006-01-02"), security,
+				formatCurrency(discrepancy.GainLoss, report.Currency), formatCurrency(discrepancy.BrokerResult, report.Currency))
+		}
+		if report.SellsWithoutResult > 0 {
+			fmt.Printf("ℹ️  Sells without a Result column: %d\n", report.SellsWithoutResult)
+		}
+		if report.LossesBroughtForward > 0 || report.LossesCarriedForward > 0 {
+			fmt.Printf("📉 Losses Brought Forward: %s\n", formatCurrency(report.LossesBroughtForward, report.Currency))
+			fmt.Printf("📉 Losses Used: %s\n", formatCurrency(report.LossesUsed, report.Currency))
+			fmt.Printf("📉 Losses Carried Forward: %s\n", formatCurrency(report.LossesCarriedForward, report.Currency))
+		}
 		fmt.Printf("💎 Dividends: %s\n", formatCurrency(report.Dividends, report.Currency))
 		if report.Interest > 0 {
 			fmt.Printf("🏦 Interest: %s\n", formatCurrency(report.Interest, report.Currency))
@@ -1046,7 +1364,7 @@ func (m *Model) updateGridLayout() {
 
 	// Base item dimensions (minimum required space for a year card)
 	minItemWidth := 35  // Minimum width for year card
-	minItemHeight := 14 // Minimum height for year card (increased for labels)
+	minItemHeight := 15 // Minimum height for year card (increased for labels)
 
 	// Calculate available space (accounting for borders, padding, and navigation help)
 	availableWidth := m.Width - CardsMargin    // Account for margins
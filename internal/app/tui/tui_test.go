@@ -4,6 +4,9 @@ import (
 	"testing"
 	"time"
 
+	tea "github.com/charmbracelet/bubbletea"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/calculator"
 	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
 )
 
@@ -194,6 +197,116 @@ func TestFormatIncomeReport(t *testing.T) {
 	}
 }
 
+func TestCreateYearCardHighlightsDiscrepancies(t *testing.T) {
+	model := NewApp()
+	model.GridLayout.ItemWidth = 40
+	model.GridLayout.ItemHeight = 14
+
+	report := types.YearlyReport{
+		Year:               2024,
+		CapitalGains:       600,
+		BrokerCapitalGains: 250,
+		Currency:           "EUR",
+	}
+	if card := model.createYearCard(report, false); contains(card, "T212") {
+		t.Errorf("Expected no cross-check line without discrepancies, got %s", card)
+	}
+
+	report.GainDiscrepancies = []types.GainDiscrepancy{{ISIN: "IE00B4L5Y983", GainLoss: 500, BrokerResult: 250, Difference: 250}}
+	if card := model.createYearCard(report, false); !contains(card, "T212") || !contains(card, "250.00") {
+		t.Errorf("Expected the Trading 212 result to be highlighted, got %s", card)
+	}
+}
+
+func TestReturnsView(t *testing.T) {
+	model := NewApp()
+	if updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")}); updated.(Model).CurrentView == ViewReturns {
+		t.Error("Expected the returns view to need a returns report")
+	}
+
+	moneyWeighted := 12.5
+	model.SetReturnsReport(&calculator.ReturnsReport{
+		Currency: "EUR",
+		Years:    []calculator.PeriodReturn{{Year: 2024, StartValue: 0, NetFlows: 1000, EndValue: 1100, TimeWeighted: 10}},
+		Overall:  calculator.PeriodReturn{NetFlows: 1000, EndValue: 1100, TimeWeighted: 10, MoneyWeighted: &moneyWeighted},
+		Positions: []calculator.PositionReturns{
+			{Ticker: "IWDA", Overall: calculator.PeriodReturn{NetFlows: 500, EndValue: 550, TimeWeighted: 10}},
+		},
+	})
+
+	updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if updated.(Model).CurrentView != ViewReturns {
+		t.Errorf("Expected returns view, got %s", updated.(Model).CurrentView)
+	}
+
+	content := model.formatReturnsReport(*model.ReturnsReport)
+	for _, expected := range []string{"2024", "Overall", "10.00%", "12.50%", "IWDA", "n/a"} {
+		if !contains(content, expected) {
+			t.Errorf("Expected returns view to contain %q, got %s", expected, content)
+		}
+	}
+}
+
+func TestBenchmarkView(t *testing.T) {
+	model := NewApp()
+	if updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")}); updated.(Model).CurrentView == ViewBenchmark {
+		t.Error("Expected the benchmark view to need a benchmark report")
+	}
+
+	model.SetBenchmarkReport(&calculator.BenchmarkReport{
+		Benchmark: "VWCE",
+		Currency:  "EUR",
+		Years:     []calculator.BenchmarkComparison{{Year: 2024, PortfolioReturn: 20, BenchmarkReturn: 10, TrackingDifference: 10, Beta: 1}},
+		Overall:   calculator.BenchmarkComparison{PortfolioReturn: 20, BenchmarkReturn: 10, TrackingDifference: 10, Beta: 1, BenchmarkValue: 1100},
+	})
+
+	updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("c")})
+	if updated.(Model).CurrentView != ViewBenchmark {
+		t.Errorf("Expected benchmark view, got %s", updated.(Model).CurrentView)
+	}
+
+	content := model.formatBenchmarkReport(*model.BenchmarkReport)
+	for _, expected := range []string{"VWCE", "2024", "+10.00%", "1100.00"} {
+		if !contains(content, expected) {
+			t.Errorf("Expected benchmark view to contain %q, got %s", expected, content)
+		}
+	}
+}
+
+func TestAllocationView(t *testing.T) {
+	model := NewApp()
+	if updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")}); updated.(Model).CurrentView == ViewAllocation {
+		t.Error("Expected the allocation view to need an allocation report")
+	}
+
+	model.SetAllocationReport(&calculator.AllocationReport{
+		Currency: "EUR",
+		Years: []calculator.YearAllocation{{
+			Year:       2024,
+			AsOfDate:   time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC),
+			TotalValue: 1000,
+			Positions:  []calculator.PositionAllocation{{ISIN: "US0378331005", Ticker: "AAPL", Value: 1000, Weight: 100}},
+			Breakdowns: []calculator.AllocationBreakdown{{
+				Dimension: calculator.AllocationSector,
+				Weights:   []calculator.AllocationWeight{{Group: "Technology", Value: 1000, Weight: 100, Positions: 1}},
+			}},
+			Warnings: []string{"AAPL is 100.0% of the portfolio (limit 20%)"},
+		}},
+	})
+
+	updated, _ := model.handleKeyMsg(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a")})
+	if updated.(Model).CurrentView != ViewAllocation {
+		t.Errorf("Expected allocation view, got %s", updated.(Model).CurrentView)
+	}
+
+	content := model.formatAllocation(model.AllocationReport.Years[0], "EUR")
+	for _, expected := range []string{"2024-12-31", "Sector", "Technology", "100.0%", "limit 20%"} {
+		if !contains(content, expected) {
+			t.Errorf("Expected allocation view to contain %q, got %s", expected, content)
+		}
+	}
+}
+
 // Helper function to check if a string contains a substring
 func contains(str, substr string) bool {
 	return len(str) >= len(substr) &&
@@ -0,0 +1,290 @@
+package calculator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// DefaultMaxPositionWeight is the weight in percent above which a single position is reported
+	// as a concentration
+	DefaultMaxPositionWeight = 20.0
+	// DefaultMaxGroupWeight is the weight in percent above which a sector or country is reported as
+	// a concentration
+	DefaultMaxGroupWeight = 40.0
+	// UnknownAllocation groups positions without metadata for a dimension
+	UnknownAllocation = "Unknown"
+)
+
+// AllocationDimension is a way of grouping positions in an allocation report
+type AllocationDimension string
+
+// Allocation dimensions
+const (
+	AllocationSector     AllocationDimension = "sector"
+	AllocationCountry    AllocationDimension = "country"
+	AllocationCurrency   AllocationDimension = "currency"
+	AllocationAssetClass AllocationDimension = "asset_class"
+	AllocationDomicile   AllocationDimension = "domicile"
+)
+
+// AllocationDimensions lists the dimensions of an allocation report in display order
+var AllocationDimensions = []AllocationDimension{
+	AllocationAssetClass, AllocationSector, AllocationCountry, AllocationCurrency, AllocationDomicile,
+}
+
+// ConcentrationLimits are the weights in percent above which a concentration warning is given
+type ConcentrationLimits struct {
+	// Position applies to each position
+	Position float64 `json:"position"`
+	// Group applies to each sector and each country; asset classes, currencies and domiciles are
+	// commonly concentrated and are not checked
+	Group float64 `json:"group"`
+}
+
+// DefaultConcentrationLimits returns the default concentration limits
+func DefaultConcentrationLimits() ConcentrationLimits {
+	return ConcentrationLimits{Position: DefaultMaxPositionWeight, Group: DefaultMaxGroupWeight}
+}
+
+// PositionAllocation is a position's weight and classification at a year end
+type PositionAllocation struct {
+	ISIN       string   `json:"isin"`
+	Ticker     string   `json:"ticker"`
+	Name       string   `json:"name"`
+	Value      float64  `json:"value"`
+	Weight     float64  `json:"weight"`
+	Sector     string   `json:"sector"`
+	Country    string   `json:"country"`
+	AssetClass string   `json:"asset_class"`
+	Currency   string   `json:"currency"`
+	Domicile   string   `json:"domicile"`
+	TER        *float64 `json:"ter,omitempty"`
+	// HasMetadata is false for positions missing from the metadata store, whose country is
+	// unknown and whose domicile is taken from the ISIN prefix
+	HasMetadata bool `json:"has_metadata"`
+}
+
+// group returns the position's group in dimension
+func (p PositionAllocation) group(dimension AllocationDimension) string {
+	switch dimension {
+	case AllocationSector:
+		return p.Sector
+	case AllocationCountry:
+		return p.Country
+	case AllocationCurrency:
+		return p.Currency
+	case AllocationAssetClass:
+		return p.AssetClass
+	case AllocationDomicile:
+		return p.Domicile
+	}
+	return UnknownAllocation
+}
+
+// AllocationWeight is the value and weight of one group of positions
+type AllocationWeight struct {
+	Group     string  `json:"group"`
+	Value     float64 `json:"value"`
+	Weight    float64 `json:"weight"`
+	Positions int     `json:"positions"`
+}
+
+// AllocationBreakdown is the portfolio's weights in one dimension, largest first
+type AllocationBreakdown struct {
+	Dimension AllocationDimension `json:"dimension"`
+	Weights   []AllocationWeight  `json:"weights"`
+}
+
+// YearAllocation is the portfolio's allocation at a year end. Weights are percentages of the
+// market value of the positions, excluding cash.
+type YearAllocation struct {
+	Year       int                   `json:"year"`
+	AsOfDate   time.Time             `json:"as_of_date"`
+	TotalValue float64               `json:"total_value"`
+	Positions  []PositionAllocation  `json:"positions"`
+	Breakdowns []AllocationBreakdown `json:"breakdowns"`
+	// WeightedTER is the value-weighted TER of the positions that have one, in percent
+	WeightedTER *float64 `json:"weighted_ter,omitempty"`
+	// WithoutMetadata counts positions missing from the metadata store
+	WithoutMetadata int      `json:"without_metadata"`
+	Warnings        []string `json:"warnings,omitempty"`
+}
+
+// Breakdown returns the weights in dimension
+func (y *YearAllocation) Breakdown(dimension AllocationDimension) AllocationBreakdown {
+	for _, breakdown := range y.Breakdowns {
+		if breakdown.Dimension == dimension {
+			return breakdown
+		}
+	}
+	return AllocationBreakdown{Dimension: dimension}
+}
+
+// AllocationReport holds the portfolio's allocation at each year end
+type AllocationReport struct {
+	Currency  string              `json:"currency"`
+	Limits    ConcentrationLimits `json:"limits"`
+	Years     []YearAllocation    `json:"years"`
+	PriceNote string              `json:"price_note"`
+}
+
+// CalculateAllocation weighs the positions held at each year end by sector, country, currency,
+// asset class and domicile using metadata, which may be nil, and warns about positions, sectors
+// and countries weighing more than limits
+func (pc *PortfolioCalculator) CalculateAllocation(
+	transactions []types.Transaction,
+	metadata *SecurityMetadataStore,
+	limits ConcentrationLimits,
+) *AllocationReport {
+	report := &AllocationReport{Currency: pc.baseCurrency, Limits: limits, PriceNote: pc.priceNote()}
+	currencies := tradeCurrencies(transactions)
+
+	for _, portfolio := range pc.CalculatePortfolioValuation(transactions).YearlyPortfolios {
+		allocation := YearAllocation{Year: portfolio.Year, AsOfDate: portfolio.AsOfDate}
+		terValue, terWeighted := 0.0, 0.0
+
+		for _, position := range portfolio.Positions {
+			allocation.TotalValue += position.MarketValue
+		}
+		for _, position := range portfolio.Positions {
+			classified := classifyPosition(position, metadata, currencies)
+			classified.Weight = pc.calculatePercentage(position.MarketValue, allocation.TotalValue)
+			if !classified.HasMetadata {
+				allocation.WithoutMetadata++
+			}
+			if classified.TER != nil {
+				terValue += position.MarketValue
+				terWeighted += position.MarketValue * *classified.TER
+			}
+			allocation.Positions = append(allocation.Positions, classified)
+		}
+		if terValue > 0 {
+			weighted := terWeighted / terValue
+			allocation.WeightedTER = &weighted
+		}
+
+		for _, dimension := range AllocationDimensions {
+			allocation.Breakdowns = append(allocation.Breakdowns, breakdownBy(allocation.Positions, dimension))
+		}
+		allocation.Warnings = concentrationWarnings(&allocation, limits)
+		report.Years = append(report.Years, allocation)
+	}
+
+	return report
+}
+
+// tradeCurrencies returns the price currency of the last trade of each security
+func tradeCurrencies(transactions []types.Transaction) map[string]string {
+	currencies := make(map[string]string)
+	for _, tx := range sortedByTime(transactions) {
+		if isTradeAction(tx.Action) && tx.CurrencyPricePerShare != nil && *tx.CurrencyPricePerShare != "" {
+			currencies[SecurityKey(tx)] = strings.ToUpper(*tx.CurrencyPricePerShare)
+		}
+	}
+	return currencies
+}
+
+// classifyPosition looks a position up in metadata, taking the domicile from the ISIN prefix and
+// the currency from its trades where metadata has none. The ISIN prefix says where a fund is
+// registered, not where it invests, so the country is unknown without metadata.
+func classifyPosition(position types.PortfolioPosition, metadata *SecurityMetadataStore, currencies map[string]string) PositionAllocation {
+	classified := PositionAllocation{
+		ISIN:   position.ISIN,
+		Ticker: position.Ticker,
+		Name:   position.Name,
+		Value:  position.MarketValue,
+	}
+
+	info, ok := metadata.Lookup(position.ISIN)
+	classified.HasMetadata = ok
+	classified.Sector = orUnknown(info.Sector)
+	classified.AssetClass = orUnknown(info.AssetClass)
+	classified.TER = info.TER
+	classified.Country = orUnknown(info.Country)
+	classified.Domicile = info.Domicile
+	if classified.Domicile == "" {
+		classified.Domicile = CountryFromISIN(position.ISIN)
+	}
+	classified.Currency = info.Currency
+	if classified.Currency == "" {
+		key := position.ISIN
+		if key == "" {
+			key = position.Ticker
+		}
+		classified.Currency = orUnknown(currencies[key])
+	}
+	if info.Name != "" {
+		classified.Name = info.Name
+	}
+	return classified
+}
+
+// orUnknown returns value, or UnknownAllocation when it is empty
+func orUnknown(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return UnknownAllocation
+	}
+	return value
+}
+
+// breakdownBy totals positions by their group in dimension, largest first
+func breakdownBy(positions []PositionAllocation, dimension AllocationDimension) AllocationBreakdown {
+	groups := make(map[string]*AllocationWeight)
+	for _, position := range positions {
+		name := position.group(dimension)
+		group, ok := groups[name]
+		if !ok {
+			group = &AllocationWeight{Group: name}
+			groups[name] = group
+		}
+		group.Value += position.Value
+		group.Weight += position.Weight
+		group.Positions++
+	}
+
+	breakdown := AllocationBreakdown{Dimension: dimension}
+	for _, group := range groups {
+		breakdown.Weights = append(breakdown.Weights, *group)
+	}
+	sort.Slice(breakdown.Weights, func(i, j int) bool {
+		if breakdown.Weights[i].Value != breakdown.Weights[j].Value {
+			return breakdown.Weights[i].Value > breakdown.Weights[j].Value
+		}
+		return breakdown.Weights[i].Group < breakdown.Weights[j].Group
+	})
+	return breakdown
+}
+
+// concentrationWarnings lists the positions, sectors and countries weighing more than limits, and
+// positions without metadata
+func concentrationWarnings(allocation *YearAllocation, limits ConcentrationLimits) []string {
+	var warnings []string
+	if limits.Position > 0 {
+		for _, position := range allocation.Positions {
+			if position.Weight > limits.Position {
+				warnings = append(warnings, fmt.Sprintf("%s is %.1f%% of the portfolio (limit %.0f%%)",
+					position.Ticker, position.Weight, limits.Position))
+			}
+		}
+	}
+	if limits.Group > 0 {
+		for _, dimension := range []AllocationDimension{AllocationSector, AllocationCountry} {
+			for _, group := range allocation.Breakdown(dimension).Weights {
+				if group.Group != UnknownAllocation && group.Weight > limits.Group {
+					warnings = append(warnings, fmt.Sprintf("%s %s is %.1f%% of the portfolio (limit %.0f%%)",
+						dimension, group.Group, group.Weight, limits.Group))
+				}
+			}
+		}
+	}
+	if allocation.WithoutMetadata > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d position(s) have no metadata; their country is unknown and their domicile comes from the ISIN",
+			allocation.WithoutMetadata))
+	}
+	return warnings
+}
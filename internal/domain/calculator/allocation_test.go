@@ -0,0 +1,90 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestPortfolioCalculator_CalculateAllocation(t *testing.T) {
+	date := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	usd := tradeTx(types.TransactionTypeMarketBuy, date, "US0378331005", 10, 20)
+	usd.CurrencyPricePerShare = stringPtr("USD")
+	usd.ExchangeRate = floatPtr(1)
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, date, "IE00B4L5Y983", 10, 60),
+		usd,
+		tradeTx(types.TransactionTypeMarketBuy, date, "DE0007164600", 10, 20),
+	}
+	for i := range transactions {
+		transactions[i].Total = floatPtr(*transactions[i].Shares * *transactions[i].PricePerShare)
+	}
+
+	metadata := NewSecurityMetadataStore()
+	ter := 0.2
+	for _, info := range []SecurityMetadata{
+		{ISIN: "IE00B4L5Y983", Sector: "Diversified", Country: "Global", AssetClass: "Equity ETF", TER: &ter},
+		{ISIN: "US0378331005", Sector: "Technology", Country: "US", AssetClass: "Equity"},
+	} {
+		if err := metadata.Add(info); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+	}
+
+	report := NewPortfolioCalculator("EUR").CalculateAllocation(transactions, metadata, DefaultConcentrationLimits())
+	if len(report.Years) != 1 {
+		t.Fatalf("Expected one year, got %+v", report.Years)
+	}
+	year := report.Years[0]
+	if abs(year.TotalValue-1000) > 1e-9 || year.WithoutMetadata != 1 {
+		t.Fatalf("Expected 1000 of positions with one unclassified, got %.2f and %d", year.TotalValue, year.WithoutMetadata)
+	}
+
+	sectors := year.Breakdown(AllocationSector).Weights
+	if len(sectors) != 3 || sectors[0].Group != "Diversified" || abs(sectors[0].Weight-60) > 1e-9 {
+		t.Errorf("Expected Diversified first at 60%%, got %+v", sectors)
+	}
+	unknown := false
+	for _, country := range year.Breakdown(AllocationCountry).Weights {
+		unknown = unknown || (country.Group == UnknownAllocation && abs(country.Weight-20) < 1e-9)
+	}
+	if !unknown {
+		t.Errorf("Expected the unclassified position's country to be unknown, got %+v", year.Breakdown(AllocationCountry))
+	}
+	germany := false
+	for _, domicile := range year.Breakdown(AllocationDomicile).Weights {
+		germany = germany || (domicile.Group == "DE" && abs(domicile.Weight-20) < 1e-9)
+	}
+	if !germany {
+		t.Errorf("Expected the unclassified position domiciled in DE from its ISIN, got %+v", year.Breakdown(AllocationDomicile))
+	}
+	currencies := year.Breakdown(AllocationCurrency).Weights
+	if len(currencies) != 2 || currencies[0].Group != "EUR" || abs(currencies[0].Weight-80) > 1e-9 {
+		t.Errorf("Expected 80%% EUR and 20%% USD, got %+v", currencies)
+	}
+	if year.WeightedTER == nil || abs(*year.WeightedTER-0.2) > 1e-9 {
+		t.Errorf("Expected a weighted TER of 0.20%%, got %v", year.WeightedTER)
+	}
+
+	// The world ETF breaches both the position and the sector and country limits
+	if len(year.Warnings) != 4 {
+		t.Errorf("Expected position, sector, country and metadata warnings, got %v", year.Warnings)
+	}
+}
+
+func TestClassifyPosition_WithoutMetadata(t *testing.T) {
+	currencies := map[string]string{"IE00B5BMR087": "USD", "VUSA": "GBP"}
+
+	// An Irish-domiciled S&P 500 fund is not Irish exposure
+	etf := classifyPosition(types.PortfolioPosition{ISIN: "IE00B5BMR087", Ticker: "CSPX"}, nil, currencies)
+	if etf.Country != UnknownAllocation || etf.Domicile != "IE" || etf.Currency != "USD" {
+		t.Errorf("Expected unknown country, IE domicile and USD, got %+v", etf)
+	}
+
+	// Positions without an ISIN take their currency by ticker
+	noISIN := classifyPosition(types.PortfolioPosition{Ticker: "VUSA"}, nil, currencies)
+	if noISIN.Currency != "GBP" {
+		t.Errorf("Expected the currency of the ticker's trades, got %+v", noISIN)
+	}
+}
@@ -0,0 +1,225 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// PlanShareStep is the smallest fraction of a share the allowance planner sells
+	PlanShareStep = 0.0001
+	// PlanMinimumGain is the smallest gain worth a planned sale
+	PlanMinimumGain = 1.0
+)
+
+// PlannedSale is a sell suggested to realise gains within the allowance
+type PlannedSale struct {
+	ISIN          string     `json:"isin,omitempty"`
+	Ticker        string     `json:"ticker,omitempty"`
+	Name          string     `json:"name,omitempty"`
+	Shares        float64    `json:"shares"`
+	HeldShares    float64    `json:"held_shares"`
+	Price         float64    `json:"price"`
+	PriceCurrency string     `json:"price_currency,omitempty"`
+	PriceSource   string     `json:"price_source"`
+	Proceeds      float64    `json:"proceeds"`
+	Cost          float64    `json:"cost"`
+	Gain          float64    `json:"gain"`
+	Matches       []LotMatch `json:"matches"`
+	// RebuyFrom is the first day the shares can be bought back without undoing the gain
+	RebuyFrom time.Time `json:"rebuy_from"`
+}
+
+// AllowancePlan suggests sells that realise gains up to the capital gains allowance left in the
+// current tax year
+type AllowancePlan struct {
+	Jurisdiction   string          `json:"jurisdiction"`
+	Year           int             `json:"year"`
+	AsOf           time.Time       `json:"as_of"`
+	SellBy         time.Time       `json:"sell_by"`
+	Method         MatchingMethod  `json:"method"`
+	Currency       string          `json:"currency"`
+	RepurchaseRule *RepurchaseRule `json:"repurchase_rule,omitempty"`
+	Allowance      float64         `json:"allowance"`
+	NetGains       float64         `json:"net_gains"`
+	AllowanceLeft  float64         `json:"allowance_left"`
+	Buffer         float64         `json:"buffer"`
+	Target         float64         `json:"target"`
+	Sales          []PlannedSale   `json:"sales"`
+	PlannedGain    float64         `json:"planned_gain"`
+	AllowanceAfter float64         `json:"allowance_after"`
+	ExtraTax       float64         `json:"extra_tax"`
+	Warnings       []string        `json:"warnings,omitempty"`
+}
+
+// PlanAllowance suggests which lots to sell on asOf so that the gains realised in its tax year land
+// just under the capital gains allowance, less buffer. Shares are taken in the order the
+// jurisdiction's matching method would dispose of them, starting with the positions holding the
+// largest gains. prices holds current prices per share as for FindHarvestOpportunities.
+func (c *TaxCalculator) PlanAllowance(
+	transactions []types.Transaction,
+	prices map[string]float64,
+	options types.ProcessingOptions,
+	asOf time.Time,
+	buffer float64,
+) (*AllowancePlan, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, 0)
+	if err != nil {
+		return nil, err
+	}
+	options.TaxYear = jurisdiction.TaxYearOf(asOf)
+	jurisdiction = jurisdiction.ForYear(options.TaxYear)
+	currency := c.reportingCurrency(jurisdiction, options)
+	held := transactionsUntil(transactions, asOf)
+
+	calculation, err := c.Calculate(held, options)
+	if err != nil {
+		return nil, err
+	}
+	netGains := calculation.NetGainLoss - calculation.LossesUsed
+	_, yearEnd := jurisdiction.TaxYearBounds(options.TaxYear)
+
+	plan := &AllowancePlan{
+		Jurisdiction:   jurisdiction.Code,
+		Year:           options.TaxYear,
+		AsOf:           asOf,
+		SellBy:         yearEnd.AddDate(0, 0, -1),
+		Method:         jurisdiction.MatchingMethod,
+		Currency:       currency,
+		RepurchaseRule: jurisdiction.RepurchaseRule,
+		Allowance:      jurisdiction.Allowances.CapitalGains,
+		NetGains:       netGains,
+		AllowanceLeft:  math.Max(jurisdiction.Allowances.CapitalGains-math.Max(netGains, 0), 0),
+		Buffer:         buffer,
+	}
+	plan.Target = math.Max(plan.AllowanceLeft-buffer, 0)
+	plan.AllowanceAfter = plan.AllowanceLeft
+	if calculation.LossesCarriedForward > 0 {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf(
+			"%.2f of losses carried forward may absorb the planned gains before the allowance", calculation.LossesCarriedForward))
+	}
+	if plan.Target <= 0 {
+		return plan, nil
+	}
+
+	engine := c.newLotEngine(jurisdiction, currency)
+	positions, keys := openPositions(engine.Process(held))
+	converter := newCurrencyConverter(currency, accountCurrency(held), c.ratesFor(jurisdiction, currency))
+
+	type gainingPosition struct {
+		key       string
+		lots      []*Lot
+		quote     positionQuote
+		basePrice float64
+		gain      float64
+	}
+	var gaining []gainingPosition
+	for _, key := range keys {
+		lots := positions[key]
+		quote, found := c.positionPrice(held, key, lots[0].Ticker, lots[0].ISIN, prices, asOf)
+		if !found {
+			continue
+		}
+		basePrice := converter.convert(quote.price, &quote.currency, asOf, quote.exchangeRate)
+		if jurisdiction.MatchingMethod == MatchingFourWeek {
+			lots = fourWeekOrder(lots, asOf, jurisdiction.RepurchaseRule.Days)
+		}
+
+		position := gainingPosition{key: key, lots: lots, quote: quote, basePrice: basePrice}
+		for _, lot := range lots {
+			position.gain += lot.Remaining*basePrice - lot.RemainingCost()
+		}
+		if position.gain > 0 {
+			gaining = append(gaining, position)
+		}
+	}
+	sort.SliceStable(gaining, func(i, j int) bool {
+		return gaining[i].gain > gaining[j].gain
+	})
+
+	var sells []HypotheticalSell
+	var sales []PlannedSale
+	planned := 0.0
+	for _, position := range gaining {
+		shares, gain := sharesWithinGain(position.lots, position.basePrice, plan.Target-planned)
+		if shares <= 0 || gain < PlanMinimumGain {
+			continue
+		}
+		planned += gain
+
+		first := position.lots[0]
+		sale := PlannedSale{
+			ISIN:          first.ISIN,
+			Ticker:        first.Ticker,
+			Name:          first.Name,
+			Shares:        shares,
+			Price:         position.quote.price,
+			PriceCurrency: position.quote.currency,
+			PriceSource:   position.quote.source,
+			RebuyFrom:     truncateToDay(asOf),
+		}
+		for _, lot := range position.lots {
+			sale.HeldShares += lot.Remaining
+		}
+		if rule := jurisdiction.RepurchaseRule; rule != nil && rule.AppliesToGains {
+			sale.RebuyFrom = rule.SafeRepurchaseDate(asOf)
+		}
+		sales = append(sales, sale)
+		sells = append(sells, HypotheticalSell{Security: position.key, Shares: shares, Price: position.quote.price, Date: asOf})
+	}
+	if len(sells) == 0 {
+		return plan, nil
+	}
+
+	// The simulation gives the gains and tax the sells would actually produce
+	simulation, err := c.Simulate(held, sells, options)
+	if err != nil {
+		return nil, err
+	}
+	for i, sell := range simulation.Sells {
+		sales[i].Proceeds = sell.Disposal.Proceeds
+		sales[i].Cost = sell.Disposal.Cost
+		sales[i].Gain = sell.Disposal.GainLoss
+		sales[i].Matches = sell.Disposal.Matches
+		plan.PlannedGain += sell.Disposal.GainLoss
+	}
+	plan.Sales = sales
+	plan.ExtraTax = simulation.MarginalTax
+	if len(simulation.Years) > 0 {
+		plan.AllowanceAfter = simulation.Years[0].AllowanceAfter
+	}
+	plan.Warnings = append(plan.Warnings, simulation.Warnings...)
+
+	return plan, nil
+}
+
+// sharesWithinGain returns the most shares that can be sold from lots, in order, while the realised
+// gain stays within limit, and the gain they realise. Losing lots met on the way are sold whole.
+func sharesWithinGain(lots []*Lot, price, limit float64) (float64, float64) {
+	bestShares, bestGain := 0.0, 0.0
+	shares, gain := 0.0, 0.0
+	for _, lot := range lots {
+		perShare := price - lot.CostPerShare()
+		lotGain := lot.Remaining * perShare
+		if gain+lotGain <= limit {
+			shares += lot.Remaining
+			gain += lotGain
+			if gain > bestGain {
+				bestShares, bestGain = shares, gain
+			}
+			continue
+		}
+
+		// Only a gaining lot can exceed the limit, so part of it still fits
+		partial := math.Floor((limit-gain)/perShare/PlanShareStep+ShareEpsilon) * PlanShareStep
+		if partial > 0 && gain+partial*perShare > bestGain {
+			bestShares, bestGain = shares+partial, gain+partial*perShare
+		}
+		break
+	}
+	return bestShares, bestGain
+}
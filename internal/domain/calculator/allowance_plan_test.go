@@ -0,0 +1,93 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_PlanAllowance(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, day(1, 10), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, day(3, 10), "US0378331005", 10, 150),
+		tradeTx(types.TransactionTypeMarketBuy, day(2, 1), "US5949181045", 10, 50),
+		tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US5949181045", 2, 150),
+		tradeTx(types.TransactionTypeMarketBuy, day(4, 1), "IE00B4L5Y983", 5, 10),
+	}
+	prices := map[string]float64{"1005": 200, "Y983": 20, "1045": 50}
+	asOf := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	plan, err := calc.PlanAllowance(transactions, prices, types.ProcessingOptions{Jurisdiction: "LT"}, asOf, 0)
+	if err != nil {
+		t.Fatalf("PlanAllowance() error = %v", err)
+	}
+
+	// 200 realised leaves 300 of the 500 allowance, filled by 3 shares of the oldest lot
+	if plan.NetGains != 200 || plan.AllowanceLeft != 300 || plan.Target != 300 {
+		t.Errorf("Expected 300 of the allowance to fill, got %+v", plan)
+	}
+	if len(plan.Sales) != 1 {
+		t.Fatalf("Expected 1 sale, got %+v", plan.Sales)
+	}
+	sale := plan.Sales[0]
+	if sale.ISIN != "US0378331005" || abs(sale.Shares-3) > 1e-9 || abs(sale.Gain-300) > 0.001 || sale.HeldShares != 20 {
+		t.Errorf("Expected 3 of 20 shares realising 300, got %+v", sale)
+	}
+	if len(sale.Matches) != 1 || !sale.Matches[0].AcquiredAt.Equal(day(1, 10)) || !sale.RebuyFrom.Equal(asOf) {
+		t.Errorf("Expected the oldest lot to be matched and an immediate rebuy, got %+v", sale)
+	}
+	if plan.AllowanceAfter > 0.001 || plan.ExtraTax != 0 {
+		t.Errorf("Expected the allowance used up without tax, got %+v", plan)
+	}
+
+	// A buffer stops short of the allowance
+	plan, _ = calc.PlanAllowance(transactions, prices, types.ProcessingOptions{Jurisdiction: "LT"}, asOf, 10)
+	if len(plan.Sales) != 1 || abs(plan.Sales[0].Shares-2.9) > 1e-9 || abs(plan.PlannedGain-290) > 0.001 {
+		t.Errorf("Expected 2.9 shares realising 290, got %+v", plan.Sales)
+	}
+
+	// UK pools the shares and the 30-day rule delays buying them back
+	plan, _ = calc.PlanAllowance(transactions, prices, types.ProcessingOptions{Jurisdiction: "UK"}, asOf, 0)
+	if plan.Year != 2024 || !plan.SellBy.Equal(time.Date(2025, 4, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the 2024/25 tax year ending 5 April 2025, got %d %v", plan.Year, plan.SellBy)
+	}
+	if len(plan.Sales) == 0 || !plan.Sales[0].RebuyFrom.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected the rebuy after the 30-day rule, got %+v", plan.Sales)
+	}
+}
+
+func TestTaxCalculator_PlanAllowanceFourWeekRule(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 11, 20, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 190),
+	}
+	asOf := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	plan, err := calc.PlanAllowance(transactions, map[string]float64{"Y983": 250}, types.ProcessingOptions{Jurisdiction: "IE"}, asOf, 0)
+	if err != nil {
+		t.Fatalf("PlanAllowance() error = %v", err)
+	}
+
+	// Shares bought in the last four weeks go first: 600 from them, then 670 from the older lot
+	if len(plan.Sales) != 1 {
+		t.Fatalf("Expected 1 sale, got %+v", plan.Sales)
+	}
+	sale := plan.Sales[0]
+	if abs(sale.Shares-14.4666) > 1e-9 || sale.Gain > 1270 || sale.Gain < 1269.9 {
+		t.Errorf("Expected 14.4666 shares realising just under 1270, got %+v", sale)
+	}
+	if len(sale.Matches) != 2 || sale.Matches[0].Shares != 10 || sale.Matches[0].HoldingDays != 11 {
+		t.Errorf("Expected the recent lot to be matched first, got %+v", sale.Matches)
+	}
+	if !sale.RebuyFrom.Equal(asOf) {
+		t.Errorf("Expected the four-week rule not to delay rebuying after a gain, got %v", sale.RebuyFrom)
+	}
+}
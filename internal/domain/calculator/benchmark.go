@@ -0,0 +1,274 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// Benchmark is an index or fund the portfolio is compared against, with closing prices in the
+// base currency
+type Benchmark struct {
+	Name   string
+	prices *PriceStore
+}
+
+// NewBenchmark creates a benchmark without prices
+func NewBenchmark(name string) *Benchmark {
+	return &Benchmark{Name: name, prices: NewPriceStore(name)}
+}
+
+// Add records the benchmark's close on date
+func (b *Benchmark) Add(date time.Time, price float64) {
+	b.prices.Add(b.Name, date, price, "")
+}
+
+// Close returns the benchmark's close on date or the last one before it
+func (b *Benchmark) Close(date time.Time) (PriceQuote, bool) {
+	return b.prices.Close(b.Name, date)
+}
+
+// LoadBenchmark loads a benchmark's closing prices from a CSV file; see ParseBenchmarkCSV
+func LoadBenchmark(filename, symbol string) (*Benchmark, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open benchmark file %s: %w", filename, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	name := symbol
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return ParseBenchmarkCSV(file, name, symbol)
+}
+
+// ParseBenchmarkCSV reads date,close rows, or date,security,price rows in the price file format
+// keeping those of symbol. Symbol may be empty when the file holds a single security.
+func ParseBenchmarkCSV(reader io.Reader, name, symbol string) (*Benchmark, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read benchmark file: %w", err)
+	}
+
+	benchmark := NewBenchmark(strings.ToUpper(name))
+	security := strings.ToUpper(symbol)
+
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("benchmark file line %d: expected date,close", i+1)
+		}
+
+		date, err := time.Parse(rateDateLayout, strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("benchmark file line %d: invalid date %q", i+1, record[0])
+		}
+
+		column := 1
+		if len(record) > 2 {
+			rowSecurity := strings.ToUpper(strings.TrimSpace(record[1]))
+			if security == "" {
+				security = rowSecurity
+			}
+			if rowSecurity != security {
+				if symbol == "" {
+					return nil, fmt.Errorf("benchmark file holds more than one security; choose one of %s and %s", security, rowSecurity)
+				}
+				continue
+			}
+			column = 2
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[column]), 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("benchmark file line %d: invalid price %q", i+1, record[column])
+		}
+		benchmark.Add(date, price)
+	}
+
+	if len(benchmark.prices.Securities()) == 0 {
+		return nil, fmt.Errorf("benchmark file has no prices for %s", benchmark.Name)
+	}
+	return benchmark, nil
+}
+
+// BenchmarkComparison compares the portfolio with the benchmark over a period. Returns are
+// time-weighted percentages.
+type BenchmarkComparison struct {
+	// Year is the calendar year of the period, or 0 for the whole history
+	Year            int       `json:"year,omitempty"`
+	From            time.Time `json:"from"`
+	To              time.Time `json:"to"`
+	PortfolioReturn float64   `json:"portfolio_return"`
+	BenchmarkReturn float64   `json:"benchmark_return"`
+	// PortfolioCumulative and BenchmarkCumulative are the returns from the first transaction to the
+	// end of the period
+	PortfolioCumulative float64 `json:"portfolio_cumulative"`
+	BenchmarkCumulative float64 `json:"benchmark_cumulative"`
+	// PortfolioValue is the portfolio with cash at the end of the period, and BenchmarkValue what the
+	// same deposits and withdrawals would be worth invested in the benchmark
+	PortfolioValue         float64  `json:"portfolio_value"`
+	BenchmarkValue         float64  `json:"benchmark_value"`
+	PortfolioMoneyWeighted *float64 `json:"portfolio_money_weighted,omitempty"`
+	BenchmarkMoneyWeighted *float64 `json:"benchmark_money_weighted,omitempty"`
+	// Beta is the sensitivity of the portfolio's daily returns to the benchmark's, or 1 when the
+	// benchmark did not move
+	Beta float64 `json:"beta"`
+	// Alpha is the portfolio return less beta times the benchmark return, with no risk-free rate
+	Alpha float64 `json:"alpha"`
+	// TrackingDifference is the portfolio return less the benchmark return
+	TrackingDifference float64 `json:"tracking_difference"`
+}
+
+// BenchmarkReport compares the portfolio with a benchmark per year and over the whole history
+type BenchmarkReport struct {
+	Benchmark string                `json:"benchmark"`
+	Currency  string                `json:"currency"`
+	From      time.Time             `json:"from"`
+	To        time.Time             `json:"to"`
+	Years     []BenchmarkComparison `json:"years"`
+	Overall   BenchmarkComparison   `json:"overall"`
+	PriceNote string                `json:"price_note"`
+}
+
+// CompareBenchmark replays transactions day by day to the close of to, or of the last transaction
+// when to is zero, and replays the same deposits and withdrawals as if invested in the benchmark
+// at its close on the day. A withdrawal larger than the benchmark holding sells all of it.
+func (pc *PortfolioCalculator) CompareBenchmark(transactions []types.Transaction, benchmark *Benchmark, to time.Time) (*BenchmarkReport, error) {
+	report := &BenchmarkReport{Benchmark: benchmark.Name, Currency: pc.baseCurrency, PriceNote: pc.priceNote()}
+	sorted := sortedByTime(transactions)
+	from, to, ok := returnsPeriod(sorted, to)
+	if !ok {
+		return report, nil
+	}
+	report.From, report.To = from, to
+
+	days := pc.replayDays(sorted, seriesDates(from, to, SeriesDaily))
+	shadow, err := benchmarkDays(benchmark, days.dates, days.portfolio)
+	if err != nil {
+		return nil, err
+	}
+
+	portfolioGrowth, benchmarkGrowth := 1.0, 1.0
+	for _, span := range yearRanges(days.dates) {
+		if measureReturn(days.dates, days.portfolio, span[0], span[1]).empty() {
+			continue
+		}
+		comparison := compareReturns(days.dates, days.portfolio, shadow, span[0], span[1])
+		portfolioGrowth *= 1 + comparison.PortfolioReturn/PercentMultiplier
+		benchmarkGrowth *= 1 + comparison.BenchmarkReturn/PercentMultiplier
+		comparison.Year = days.dates[span[0]].Year()
+		comparison.PortfolioCumulative = (portfolioGrowth - 1) * PercentMultiplier
+		comparison.BenchmarkCumulative = (benchmarkGrowth - 1) * PercentMultiplier
+		report.Years = append(report.Years, comparison)
+	}
+
+	report.Overall = compareReturns(days.dates, days.portfolio, shadow, 0, len(days.dates))
+	report.Overall.PortfolioCumulative = report.Overall.PortfolioReturn
+	report.Overall.BenchmarkCumulative = report.Overall.BenchmarkReturn
+
+	return report, nil
+}
+
+// benchmarkDays invests the portfolio's daily deposits and withdrawals in the benchmark and returns
+// the value of the holding at each day's close
+func benchmarkDays(benchmark *Benchmark, dates []time.Time, portfolio []dailyValue) ([]dailyValue, error) {
+	shadow := make([]dailyValue, len(dates))
+	units := 0.0
+
+	for i, date := range dates {
+		day := dailyValue{in: portfolio[i].in, out: portfolio[i].out}
+		quote, ok := benchmark.Close(date)
+		if !ok {
+			if day.in != 0 || day.out != 0 {
+				return nil, fmt.Errorf("benchmark %s has no price on or before %s", benchmark.Name, date.Format(rateDateLayout))
+			}
+			shadow[i] = day
+			continue
+		}
+
+		units += (day.in - day.out) / quote.Price
+		if units < 0 {
+			units = 0
+		}
+		day.value = units * quote.Price
+		shadow[i] = day
+	}
+
+	return shadow, nil
+}
+
+// compareReturns compares the portfolio with the benchmark over days start to end-1
+func compareReturns(dates []time.Time, portfolio, benchmark []dailyValue, start, end int) BenchmarkComparison {
+	ours := measureReturn(dates, portfolio, start, end)
+	theirs := measureReturn(dates, benchmark, start, end)
+	beta := dailyBeta(portfolio, benchmark, start, end)
+
+	return BenchmarkComparison{
+		From:                   ours.From,
+		To:                     ours.To,
+		PortfolioReturn:        ours.TimeWeighted,
+		BenchmarkReturn:        theirs.TimeWeighted,
+		PortfolioValue:         ours.EndValue,
+		BenchmarkValue:         theirs.EndValue,
+		PortfolioMoneyWeighted: ours.MoneyWeighted,
+		BenchmarkMoneyWeighted: theirs.MoneyWeighted,
+		Beta:                   beta,
+		Alpha:                  ours.TimeWeighted - beta*theirs.TimeWeighted,
+		TrackingDifference:     ours.TimeWeighted - theirs.TimeWeighted,
+	}
+}
+
+// dailyBeta regresses the portfolio's daily returns on the benchmark's over days start to end-1
+func dailyBeta(portfolio, benchmark []dailyValue, start, end int) float64 {
+	var ours, theirs []float64
+	for i := start; i < end; i++ {
+		previousOurs, previousTheirs := 0.0, 0.0
+		if i > 0 {
+			previousOurs, previousTheirs = portfolio[i-1].value, benchmark[i-1].value
+		}
+		our, ok := dailyReturn(previousOurs, portfolio[i])
+		their, theirOK := dailyReturn(previousTheirs, benchmark[i])
+		if ok && theirOK {
+			ours = append(ours, our)
+			theirs = append(theirs, their)
+		}
+	}
+
+	if len(ours) < 2 {
+		return 1
+	}
+	meanOurs, meanTheirs := mean(ours), mean(theirs)
+	covariance, variance := 0.0, 0.0
+	for i := range ours {
+		covariance += (ours[i] - meanOurs) * (theirs[i] - meanTheirs)
+		variance += (theirs[i] - meanTheirs) * (theirs[i] - meanTheirs)
+	}
+	if variance == 0 {
+		return 1
+	}
+	return covariance / variance
+}
+
+// mean returns the average of values, which must not be empty
+func mean(values []float64) float64 {
+	total := 0.0
+	for _, value := range values {
+		total += value
+	}
+	return total / float64(len(values))
+}
@@ -0,0 +1,83 @@
+package calculator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestParseBenchmarkCSV(t *testing.T) {
+	benchmark, err := ParseBenchmarkCSV(strings.NewReader("date,close\n2024-01-02,100\n2024-01-03,101.5\n"), "vwce", "")
+	if err != nil {
+		t.Fatalf("ParseBenchmarkCSV() error = %v", err)
+	}
+	if quote, ok := benchmark.Close(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)); !ok || quote.Price != 101.5 || benchmark.Name != "VWCE" {
+		t.Errorf("Close() = %+v, %v for %s", quote, ok, benchmark.Name)
+	}
+
+	prices := "date,security,price\n2024-01-02,VWCE,100\n2024-01-02,SPY,470\n"
+	benchmark, err = ParseBenchmarkCSV(strings.NewReader(prices), "spy", "spy")
+	if err != nil {
+		t.Fatalf("ParseBenchmarkCSV() error = %v", err)
+	}
+	if quote, ok := benchmark.Close(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)); !ok || quote.Price != 470 {
+		t.Errorf("Expected the SPY close, got %+v", quote)
+	}
+
+	if _, err := ParseBenchmarkCSV(strings.NewReader(prices), "prices", ""); err == nil {
+		t.Error("Expected an error choosing between several securities without a symbol")
+	}
+	if _, err := ParseBenchmarkCSV(strings.NewReader(prices), "qqq", "qqq"); err == nil {
+		t.Error("Expected an error for a symbol without prices")
+	}
+}
+
+func TestPortfolioCalculator_CompareBenchmark(t *testing.T) {
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 100)
+	buy.Total, buy.CurrencyTotal = floatPtr(1000), stringPtr("EUR")
+	transactions := []types.Transaction{
+		{Action: types.TransactionTypeDeposit, Time: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), Total: floatPtr(1000), CurrencyTotal: stringPtr("EUR")},
+		buy,
+	}
+
+	store := NewPriceStore("prices.csv")
+	store.Add("IE00B4L5Y983", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 120, "")
+	calculator := NewPortfolioCalculator("EUR")
+	calculator.SetPriceProvider(store)
+
+	benchmark := NewBenchmark("VWCE")
+	benchmark.Add(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 50)
+	benchmark.Add(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 55)
+
+	report, err := calculator.CompareBenchmark(transactions, benchmark, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("CompareBenchmark() error = %v", err)
+	}
+	if len(report.Years) != 1 {
+		t.Fatalf("Expected 1 year, got %+v", report.Years)
+	}
+
+	year := report.Years[0]
+	if abs(year.PortfolioReturn-20) > 1e-6 || abs(year.BenchmarkReturn-10) > 1e-6 || abs(year.TrackingDifference-10) > 1e-6 {
+		t.Errorf("Unexpected comparison %+v", year)
+	}
+	if abs(year.BenchmarkValue-1100) > 1e-6 || abs(year.PortfolioValue-1200) > 1e-6 {
+		t.Errorf("Expected end values of 1200 and 1100, got %.2f and %.2f", year.PortfolioValue, year.BenchmarkValue)
+	}
+
+	// Both moved only on the last day, the portfolio twice as much, so it is all beta
+	if abs(year.Beta-2) > 1e-6 || abs(year.Alpha) > 1e-6 {
+		t.Errorf("Expected a beta of 2 and no alpha, got %.4f and %.4f", year.Beta, year.Alpha)
+	}
+	if year.PortfolioCumulative != year.PortfolioReturn || report.Overall.BenchmarkCumulative != report.Overall.BenchmarkReturn {
+		t.Errorf("Expected cumulative returns to match over a single year, got %+v", report)
+	}
+
+	late := NewBenchmark("LATE")
+	late.Add(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), 10)
+	if _, err := calculator.CompareBenchmark(transactions, late, time.Time{}); err == nil {
+		t.Error("Expected an error when the benchmark has no price for a deposit")
+	}
+}
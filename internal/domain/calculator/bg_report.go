@@ -0,0 +1,288 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// Bulgarian declaration constants
+const (
+	// BGIncomeCodeDividends is the Annex 8 Part IV income code for dividends
+	BGIncomeCodeDividends = "8141"
+	// BGMethodCodeTaxCredit is the Annex 8 Part IV code for the ordinary tax credit method
+	BGMethodCodeTaxCredit = "1"
+	// BGSaleCodeFinancialAssets is the Annex 5 Table 2 code for sales of financial assets
+	BGSaleCodeFinancialAssets = "508"
+	// BGHoldingTypeShares is the Annex 8 Part I holding type for shares
+	BGHoldingTypeShares = "Акции"
+	// bgDateLayout is the date format used by the NRA portal
+	bgDateLayout = "02.01.2006"
+)
+
+// BGAnnex identifies one of the generated annex tables
+type BGAnnex string
+
+// Generated annex tables
+const (
+	BGAnnex8PartI  BGAnnex = "annex8-part1" // Foreign shares held at year end
+	BGAnnex8PartIV BGAnnex = "annex8-part4" // Foreign dividends per payer and country
+	BGAnnex5       BGAnnex = "annex5"       // Sales of financial assets
+)
+
+// BGHolding is an Annex 8 Part I row for foreign shares held at year end
+type BGHolding struct {
+	Type          string    `json:"type"`
+	ISIN          string    `json:"isin"`
+	Name          string    `json:"name"`
+	Country       string    `json:"country"`
+	Shares        float64   `json:"shares"`
+	AcquiredAt    time.Time `json:"acquired_at"`
+	LocalCost     float64   `json:"local_cost"`
+	LocalCurrency string    `json:"local_currency"`
+	Cost          float64   `json:"cost"`
+}
+
+// BGDividendRow is an Annex 8 Part IV row for dividends from one payer
+type BGDividendRow struct {
+	Payer            string  `json:"payer"`
+	Country          string  `json:"country"`
+	IncomeCode       string  `json:"income_code"`
+	MethodCode       string  `json:"method_code"`
+	GrossIncome      float64 `json:"gross_income"`
+	TaxPaidAbroad    float64 `json:"tax_paid_abroad"`
+	AllowedCredit    float64 `json:"allowed_credit"`
+	RecognizedCredit float64 `json:"recognized_credit"`
+	TaxDue           float64 `json:"tax_due"`
+}
+
+// BGSaleRow is an Annex 5 Table 2 row for a sale of financial assets
+type BGSaleRow struct {
+	Code             string    `json:"code"`
+	ISIN             string    `json:"isin,omitempty"`
+	Name             string    `json:"name,omitempty"`
+	Date             time.Time `json:"date"`
+	SalePrice        float64   `json:"sale_price"`
+	AcquisitionPrice float64   `json:"acquisition_price"`
+	Profit           float64   `json:"profit"`
+	Loss             float64   `json:"loss"`
+}
+
+// BGAnnexReport holds the Bulgarian annual declaration annex tables
+type BGAnnexReport struct {
+	Year            int             `json:"year"`
+	Currency        string          `json:"currency"`
+	Annex8PartI     []BGHolding     `json:"annex8_part1"`
+	Annex8PartIV    []BGDividendRow `json:"annex8_part4"`
+	Annex5          []BGSaleRow     `json:"annex5"`
+	Annex5Total     BGSaleRow       `json:"annex5_total"`
+	CapitalGainsTax float64         `json:"capital_gains_tax"`
+	DividendTax     float64         `json:"dividend_tax"`
+	Warnings        []string        `json:"warnings,omitempty"`
+}
+
+// GenerateBGAnnexes builds Annex 8 Parts I and IV and Annex 5 for a year, converting at BNB rates
+func (c *TaxCalculator) GenerateBGAnnexes(transactions []types.Transaction, year int) (*BGAnnexReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("BG", year)
+	if err != nil {
+		return nil, err
+	}
+
+	currency := jurisdiction.Currency
+	rates := c.ratesFor(jurisdiction, currency)
+
+	report := &BGAnnexReport{
+		Year:     year,
+		Currency: currency,
+	}
+
+	// The lots left open by the year's transactions are the holdings at year end
+	_, endOfYear := taxYearBounds(year)
+	engine := c.newLotEngine(jurisdiction, currency)
+	ledger := engine.Process(filterBefore(transactions, endOfYear))
+	report.Warnings = ledger.Warnings
+
+	report.Annex8PartI = bgHoldings(ledger)
+	report.Annex5, report.Annex5Total = bgSales(ledger.DisposalsInYear(year))
+	report.CapitalGainsTax = math.Max(report.Annex5Total.Profit-report.Annex5Total.Loss, 0) * jurisdiction.CapitalGainsTaxRate
+
+	incomeCalc := NewIncomeCalculator(currency)
+	incomeCalc.SetFXRateProvider(rates)
+	report.Annex8PartIV = bgDividendRows(incomeCalc.extractDividendRecords(transactions), year, jurisdiction)
+	for _, row := range report.Annex8PartIV {
+		report.DividendTax += row.TaxDue
+	}
+
+	return report, nil
+}
+
+// bgHoldings lists the open lots of securities held at year end
+func bgHoldings(ledger *LotLedger) []BGHolding {
+	lots := ledger.OpenLots()
+	holdings := make([]BGHolding, 0, len(lots))
+	for _, lot := range lots {
+		holdings = append(holdings, BGHolding{
+			Type:          BGHoldingTypeShares,
+			ISIN:          lot.ISIN,
+			Name:          lot.Name,
+			Country:       CountryFromISIN(lot.ISIN),
+			Shares:        lot.Remaining,
+			AcquiredAt:    lot.AcquiredAt,
+			LocalCost:     lot.RemainingLocalCost(),
+			LocalCurrency: lot.LocalCurrency,
+			Cost:          lot.RemainingCost(),
+		})
+	}
+
+	sort.SliceStable(holdings, func(i, j int) bool {
+		if holdings[i].Country != holdings[j].Country {
+			return holdings[i].Country < holdings[j].Country
+		}
+		return holdings[i].ISIN < holdings[j].ISIN
+	})
+
+	return holdings
+}
+
+// bgSales converts disposals into Annex 5 rows and their total
+func bgSales(disposals []Disposal) ([]BGSaleRow, BGSaleRow) {
+	rows := make([]BGSaleRow, 0, len(disposals))
+	total := BGSaleRow{Code: BGSaleCodeFinancialAssets}
+
+	for _, disposal := range disposals {
+		row := BGSaleRow{
+			Code:             BGSaleCodeFinancialAssets,
+			ISIN:             disposal.ISIN,
+			Name:             disposal.Name,
+			Date:             disposal.Date,
+			SalePrice:        disposal.Proceeds,
+			AcquisitionPrice: disposal.Cost,
+		}
+		if disposal.GainLoss > 0 {
+			row.Profit = disposal.GainLoss
+		} else {
+			row.Loss = -disposal.GainLoss
+		}
+
+		total.SalePrice += row.SalePrice
+		total.AcquisitionPrice += row.AcquisitionPrice
+		total.Profit += row.Profit
+		total.Loss += row.Loss
+		rows = append(rows, row)
+	}
+
+	return rows, total
+}
+
+// bgDividendRows groups a year's dividends by payer and country with the 5% tax and credit
+func bgDividendRows(records []types.DividendRecord, year int, jurisdiction TaxJurisdiction) []BGDividendRow {
+	grouped := make(map[string]*BGDividendRow)
+
+	for _, record := range records {
+		if record.Date.Year() != year {
+			continue
+		}
+
+		payer := record.Name
+		if payer == "" {
+			payer = record.Ticker
+		}
+		country := CountryFromISIN(record.ISIN)
+
+		key := payer + "/" + country
+		row, exists := grouped[key]
+		if !exists {
+			row = &BGDividendRow{
+				Payer:      payer,
+				Country:    country,
+				IncomeCode: BGIncomeCodeDividends,
+				MethodCode: BGMethodCodeTaxCredit,
+			}
+			grouped[key] = row
+		}
+
+		row.GrossIncome += record.Amount
+		row.TaxPaidAbroad += record.WithholdingTax
+	}
+
+	rows := make([]BGDividendRow, 0, len(grouped))
+	for _, row := range grouped {
+		domesticTax := row.GrossIncome * jurisdiction.DividendTaxRate
+		row.AllowedCredit = domesticTax
+		row.RecognizedCredit = math.Min(row.TaxPaidAbroad, domesticTax)
+		row.TaxDue = domesticTax - row.RecognizedCredit
+		rows = append(rows, *row)
+	}
+
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Country != rows[j].Country {
+			return rows[i].Country < rows[j].Country
+		}
+		return rows[i].Payer < rows[j].Payer
+	})
+
+	return rows
+}
+
+// WriteCSV writes one annex table as CSV for entry into the NRA portal
+func (r *BGAnnexReport) WriteCSV(w io.Writer, annex BGAnnex) error {
+	writer := csv.NewWriter(w)
+
+	var records [][]string
+	switch annex {
+	case BGAnnex8PartI:
+		records = append(records, []string{"No", "Type", "Country", "Count", "Acquisition Date",
+			"Acquisition Price (Currency)", "Currency", "Acquisition Price (" + r.Currency + ")", "ISIN", "Name"})
+		for i, holding := range r.Annex8PartI {
+			records = append(records, []string{
+				fmt.Sprint(i + 1), holding.Type, holding.Country, formatShares(holding.Shares),
+				holding.AcquiredAt.Format(bgDateLayout), formatAmount(holding.LocalCost), holding.LocalCurrency,
+				formatAmount(holding.Cost), holding.ISIN, holding.Name,
+			})
+		}
+	case BGAnnex8PartIV:
+		records = append(records, []string{"No", "Payer", "Country", "Income Code", "Method Code",
+			"Gross Income", "Tax Paid Abroad", "Allowed Credit", "Recognized Credit", "Tax Due"})
+		for i, row := range r.Annex8PartIV {
+			records = append(records, []string{
+				fmt.Sprint(i + 1), row.Payer, row.Country, row.IncomeCode, row.MethodCode,
+				formatAmount(row.GrossIncome), formatAmount(row.TaxPaidAbroad), formatAmount(row.AllowedCredit),
+				formatAmount(row.RecognizedCredit), formatAmount(row.TaxDue),
+			})
+		}
+	case BGAnnex5:
+		records = append(records, []string{"Code", "Date", "ISIN", "Name", "Sale Price", "Acquisition Price", "Profit", "Loss"})
+		for _, row := range append(r.Annex5, r.Annex5Total) {
+			date := ""
+			if !row.Date.IsZero() {
+				date = row.Date.Format(bgDateLayout)
+			}
+			records = append(records, []string{
+				row.Code, date, row.ISIN, row.Name, formatAmount(row.SalePrice),
+				formatAmount(row.AcquisitionPrice), formatAmount(row.Profit), formatAmount(row.Loss),
+			})
+		}
+	default:
+		return fmt.Errorf("unknown annex: %s", annex)
+	}
+
+	if err := writer.WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write %s CSV: %w", annex, err)
+	}
+	return nil
+}
+
+// formatAmount formats a monetary amount for CSV output
+func formatAmount(amount float64) string {
+	return fmt.Sprintf("%.2f", amount)
+}
+
+// formatShares formats a share quantity for CSV output
+func formatShares(shares float64) string {
+	return fmt.Sprintf("%.6f", shares)
+}
@@ -0,0 +1,88 @@
+package calculator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateBGAnnexes(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 3, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100)
+	buy.Name = stringPtr("Apple")
+	buy.Total = floatPtr(1000)
+
+	transactions := []types.Transaction{
+		buy,
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 4, 150),
+		{
+			Action:         types.TransactionTypeDividend,
+			Time:           time.Date(2024, 8, 1, 9, 0, 0, 0, time.UTC),
+			ISIN:           stringPtr("US0378331005"),
+			Name:           stringPtr("Apple"),
+			Result:         floatPtr(10),
+			CurrencyResult: stringPtr("EUR"),
+			WithholdingTax: floatPtr(1.5),
+		},
+	}
+
+	report, err := calc.GenerateBGAnnexes(transactions, 2024)
+	if err != nil {
+		t.Fatalf("GenerateBGAnnexes() error = %v", err)
+	}
+
+	if report.Currency != "BGN" {
+		t.Errorf("Expected BGN currency, got %s", report.Currency)
+	}
+
+	// 6 shares left from the 2023 lot, costed at the fixed EUR peg
+	if len(report.Annex8PartI) != 1 {
+		t.Fatalf("Expected 1 holding, got %d", len(report.Annex8PartI))
+	}
+	holding := report.Annex8PartI[0]
+	if abs(holding.Shares-6) > ShareEpsilon || abs(holding.LocalCost-600) > 0.001 || abs(holding.Cost-600*1.95583) > 0.001 {
+		t.Errorf("Unexpected holding: %+v", holding)
+	}
+
+	// Gain of 4 × 50 EUR in BGN
+	if abs(report.Annex5Total.Profit-200*1.95583) > 0.001 {
+		t.Errorf("Expected profit %.2f, got %.2f", 200*1.95583, report.Annex5Total.Profit)
+	}
+
+	// 5% dividend tax fully covered by 15% withholding
+	if len(report.Annex8PartIV) != 1 {
+		t.Fatalf("Expected 1 dividend row, got %d", len(report.Annex8PartIV))
+	}
+	if report.Annex8PartIV[0].TaxDue != 0 || report.Annex8PartIV[0].Country != "US" {
+		t.Errorf("Unexpected dividend row: %+v", report.Annex8PartIV[0])
+	}
+
+	// The tax summary converts at the same peg
+	calculation, err := calc.Calculate(transactions, types.ProcessingOptions{Jurisdiction: "BG", TaxYear: 2024})
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+	if abs(calculation.NetGainLoss-report.Annex5Total.Profit) > 0.001 || abs(calculation.DividendIncome-10*1.95583) > 0.001 {
+		t.Errorf("Expected the Annex 5 profit %.2f and dividends %.2f, got %+v", report.Annex5Total.Profit, 10*1.95583, calculation)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf, BGAnnex5); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 {
+		t.Errorf("Expected header, sale and total rows, got %d lines", len(lines))
+	}
+	if !strings.HasPrefix(lines[1], "508,10.05.2024") {
+		t.Errorf("Unexpected Annex 5 row: %s", lines[1])
+	}
+
+	if err := report.WriteCSV(&buf, BGAnnex("unknown")); err == nil {
+		t.Error("Expected error for unknown annex")
+	}
+}
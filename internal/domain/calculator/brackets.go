@@ -0,0 +1,82 @@
+package calculator
+
+import (
+	"math"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// BracketBase selects the income investment income is stacked on when applying brackets
+type BracketBase string
+
+const (
+	// BracketBaseOtherIncome stacks investment income on top of the taxpayer's other income
+	BracketBaseOtherIncome BracketBase = "other_income"
+	// BracketBaseInvestmentIncome applies brackets to investment income alone, as a separate
+	// savings base does
+	BracketBaseInvestmentIncome BracketBase = "investment_income"
+)
+
+// BracketTax returns the tax on amount of income stacked on top of base income, charging each
+// slice at the rate of the bracket it falls in
+func BracketTax(brackets []TaxBracket, base, amount float64) float64 {
+	if amount <= 0 {
+		return 0
+	}
+
+	tax := 0.0
+	top := base + amount
+	for i, bracket := range brackets {
+		upper := math.Inf(1)
+		if i+1 < len(brackets) {
+			upper = brackets[i+1].From
+		}
+		from := math.Max(bracket.From, base)
+		to := math.Min(upper, top)
+		if to > from {
+			tax += (to - from) * bracket.Rate
+		}
+	}
+	return tax
+}
+
+// MarginalRate returns the rate of the bracket the last unit of income falls in
+func MarginalRate(brackets []TaxBracket, income float64) float64 {
+	rate := 0.0
+	for i, bracket := range brackets {
+		if i == 0 || income > bracket.From {
+			rate = bracket.Rate
+		}
+	}
+	return rate
+}
+
+// InvestmentTax returns the tax on taxable gains and dividends for a taxpayer profile, and the
+// marginal rate on the last unit of gains. Dividends fill the brackets before gains. Without
+// brackets the flat rates apply.
+func (j TaxJurisdiction) InvestmentTax(gains, dividends float64, profile types.TaxProfile) (float64, float64, float64) {
+	if profile.AggregateIncome && len(j.IncomeBrackets) > 0 {
+		dividendTax := BracketTax(j.IncomeBrackets, profile.OtherIncome, dividends)
+		gainsTax := BracketTax(j.IncomeBrackets, profile.OtherIncome+dividends, gains)
+		return gainsTax, dividendTax, MarginalRate(j.IncomeBrackets, profile.OtherIncome+dividends+gains)
+	}
+
+	base := profile.OtherIncome
+	if j.BracketBase == BracketBaseInvestmentIncome {
+		base = 0
+	}
+
+	dividendTax := dividends * j.DividendTaxRate
+	if len(j.DividendBrackets) > 0 {
+		dividendTax = BracketTax(j.DividendBrackets, base, dividends)
+	}
+
+	gainsTax := gains * j.CapitalGainsTaxRate
+	marginalRate := j.CapitalGainsTaxRate
+	if len(j.CapitalGainsBrackets) > 0 {
+		gainsTax = BracketTax(j.CapitalGainsBrackets, base+dividends, gains)
+		marginalRate = MarginalRate(j.CapitalGainsBrackets, base+dividends+gains)
+	}
+
+	return gainsTax, dividendTax, marginalRate
+}
@@ -0,0 +1,165 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestBracketTax(t *testing.T) {
+	brackets := []TaxBracket{{From: 0, Rate: 0.18}, {From: 37700, Rate: 0.24}}
+
+	tests := []struct {
+		name     string
+		base     float64
+		amount   float64
+		expected float64
+		marginal float64
+	}{
+		{"within basic band", 0, 10000, 1800, 0.18},
+		{"straddling bands", 30000, 10000, 7700*0.18 + 2300*0.24, 0.24},
+		{"above basic band", 50000, 10000, 2400, 0.24},
+		{"up to threshold", 27700, 10000, 1800, 0.18},
+		{"nothing to tax", 30000, 0, 0, 0.18},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tax := BracketTax(brackets, tt.base, tt.amount); abs(tax-tt.expected) > 0.001 {
+				t.Errorf("BracketTax() = %.2f, expected %.2f", tax, tt.expected)
+			}
+			if rate := MarginalRate(brackets, tt.base+tt.amount); rate != tt.marginal {
+				t.Errorf("MarginalRate() = %.2f, expected %.2f", rate, tt.marginal)
+			}
+		})
+	}
+}
+
+func TestTaxJurisdiction_InvestmentTax(t *testing.T) {
+	calc := NewTaxCalculator()
+	lookup := func(code string, year int) TaxJurisdiction {
+		jurisdiction, err := calc.lookupJurisdiction(code, year)
+		if err != nil {
+			t.Fatalf("lookupJurisdiction(%s) error = %v", code, err)
+		}
+		return jurisdiction
+	}
+
+	tests := []struct {
+		name         string
+		jurisdiction TaxJurisdiction
+		gains        float64
+		dividends    float64
+		profile      types.TaxProfile
+		gainsTax     float64
+		dividendTax  float64
+		marginalRate float64
+	}{
+		{
+			name:         "UK higher-rate taxpayer",
+			jurisdiction: lookup("UK", 2024),
+			gains:        10000,
+			dividends:    1000,
+			profile:      types.TaxProfile{OtherIncome: 40000},
+			gainsTax:     2400,
+			dividendTax:  337.5,
+			marginalRate: 0.24,
+		},
+		{
+			name:         "UK dividends fill the basic band before gains",
+			jurisdiction: lookup("UK", 2024),
+			gains:        5000,
+			dividends:    2000,
+			profile:      types.TaxProfile{OtherIncome: 34700},
+			gainsTax:     1000*0.18 + 4000*0.24,
+			dividendTax:  2000 * 0.0875,
+			marginalRate: 0.24,
+		},
+		{
+			name:         "US gains in the 0% bracket",
+			jurisdiction: lookup("US", 2024),
+			gains:        20000,
+			profile:      types.TaxProfile{OtherIncome: 20000},
+			gainsTax:     0,
+			marginalRate: 0,
+		},
+		{
+			name:         "US gains straddling 0% and 15%",
+			jurisdiction: lookup("US", 2024),
+			gains:        20000,
+			profile:      types.TaxProfile{OtherIncome: 40000},
+			gainsTax:     12975 * 0.15,
+			marginalRate: 0.15,
+		},
+		{
+			name:         "ES savings base ignores other income",
+			jurisdiction: lookup("ES", 2024),
+			gains:        8000,
+			dividends:    2000,
+			profile:      types.TaxProfile{OtherIncome: 100000},
+			gainsTax:     4000*0.19 + 4000*0.21,
+			dividendTax:  2000 * 0.19,
+			marginalRate: 0.21,
+		},
+		{
+			name:         "PT special rate",
+			jurisdiction: lookup("PT", 2024),
+			gains:        5000,
+			profile:      types.TaxProfile{OtherIncome: 10000},
+			gainsTax:     1400,
+			marginalRate: 0.28,
+		},
+		{
+			name:         "PT englobamento",
+			jurisdiction: lookup("PT", 2024),
+			gains:        5000,
+			profile:      types.TaxProfile{OtherIncome: 10000, AggregateIncome: true},
+			gainsTax:     1623*0.165 + 3377*0.22,
+			marginalRate: 0.22,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gainsTax, dividendTax, marginalRate := tt.jurisdiction.InvestmentTax(tt.gains, tt.dividends, tt.profile)
+			if abs(gainsTax-tt.gainsTax) > 0.001 || abs(dividendTax-tt.dividendTax) > 0.001 {
+				t.Errorf("InvestmentTax() = %.2f on gains and %.2f on dividends, expected %.2f and %.2f",
+					gainsTax, dividendTax, tt.gainsTax, tt.dividendTax)
+			}
+			if marginalRate != tt.marginalRate {
+				t.Errorf("Expected marginal rate %.3f, got %.3f", tt.marginalRate, marginalRate)
+			}
+		})
+	}
+}
+
+func TestTaxCalculator_CalculateWithProfile(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 100, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 100, 200),
+	}
+
+	options := types.ProcessingOptions{TaxYear: 2024, Currency: "EUR", Jurisdiction: "UK"}
+	basic, err := calc.Calculate(transactions, options)
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+
+	options.Profile = types.TaxProfile{OtherIncome: 60000}
+	higher, err := calc.Calculate(transactions, options)
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+
+	// Gain of 10000 less the 3000 annual exempt amount
+	if abs(basic.EstimatedTax-7000*0.18) > 0.001 || abs(higher.EstimatedTax-7000*0.24) > 0.001 {
+		t.Errorf("Expected tax %.2f without and %.2f with other income, got %.2f and %.2f",
+			7000*0.18, 7000*0.24, basic.EstimatedTax, higher.EstimatedTax)
+	}
+	if higher.MarginalRate != 0.24 || higher.CapitalGainsTax != higher.EstimatedTax {
+		t.Errorf("Expected a 24%% marginal rate on gains only, got %+v", higher)
+	}
+}
@@ -2,22 +2,15 @@
 package calculator
 
 import (
-	"log"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
 )
 
-// Tax rate constants
-const (
-	USTaxRate           = 0.15
-	UKCapitalGains      = 0.10
-	UKDividendRate      = 0.075
-	UKCapitalAllowance  = 6000
-	UKDividendAllowance = 2000
-	BGCapitalGains      = 0.10
-	BGDividendRate      = 0.05
-)
-
 // Calculator handles tax calculations for different jurisdictions
 type Calculator interface {
 	Calculate(transactions []types.Transaction, options types.ProcessingOptions) (*types.TaxCalculation, error)
@@ -28,103 +21,275 @@ type Calculator interface {
 // TaxCalculator implements Calculator
 type TaxCalculator struct {
 	jurisdictions map[string]TaxJurisdiction
+	rates         FXRateProvider
+	prices        PriceProvider
+	treaties      *TreatyTable
 }
 
-// TaxJurisdiction represents tax rules for a jurisdiction
+// TaxJurisdiction represents tax rules for a jurisdiction. The rate, allowance and bracket fields
+// hold the rules of RulesYear, taken from Years.
 type TaxJurisdiction struct {
-	Code                string
-	Name                string
-	CapitalGainsTaxRate float64
-	DividendTaxRate     float64
-	Allowances          TaxAllowances
+	Code                 string
+	Name                 string
+	Country              string
+	Currency             string
+	Version              int
+	TaxYearStartMonth    time.Month
+	TaxYearStartDay      int
+	RulesYear            int
+	CapitalGainsTaxRate  float64
+	DividendTaxRate      float64
+	WithholdingCreditCap float64
+	CapitalGainsBrackets []TaxBracket
+	DividendBrackets     []TaxBracket
+	BracketBase          BracketBase
+	IncomeBrackets       []TaxBracket
+	ExitTaxRate          float64
+	SolidaritySurcharge  float64
+	MatchingMethod       MatchingMethod
+	WashSaleDays         int
+	DeemedDisposalYears  int
+	FXSource             FXSource
+	Allowances           TaxAllowances
+	LossCarryForward     *LossCarryForwardRule
+	RepurchaseRule       *RepurchaseRule
+	Years                map[int]JurisdictionYear
+}
+
+// CreditCap returns the maximum foreign withholding tax rate creditable against dividend tax
+func (j TaxJurisdiction) CreditCap() float64 {
+	if j.WithholdingCreditCap > 0 {
+		return j.WithholdingCreditCap
+	}
+	return j.DividendTaxRate
 }
 
 // TaxAllowances represents tax-free allowances
 type TaxAllowances struct {
-	CapitalGains float64
-	Dividends    float64
+	CapitalGains float64 `yaml:"capital_gains" json:"capital_gains"`
+	Dividends    float64 `yaml:"dividends" json:"dividends"`
 }
 
-// NewTaxCalculator creates a new tax calculator
+// NewTaxCalculator creates a new tax calculator with the built-in jurisdiction definitions
 func NewTaxCalculator() *TaxCalculator {
-	return &TaxCalculator{
-		jurisdictions: map[string]TaxJurisdiction{
-			"US": {
-				Code:                "US",
-				Name:                "United States",
-				CapitalGainsTaxRate: USTaxRate,
-				DividendTaxRate:     USTaxRate,
-				Allowances: TaxAllowances{
-					CapitalGains: 0,
-					Dividends:    0,
-				},
-			},
-			"UK": {
-				Code:                "UK",
-				Name:                "United Kingdom",
-				CapitalGainsTaxRate: UKCapitalGains,
-				DividendTaxRate:     UKDividendRate,
-				Allowances: TaxAllowances{
-					CapitalGains: UKCapitalAllowance,
-					Dividends:    UKDividendAllowance,
-				},
-			},
-			"BG": {
-				Code:                "BG",
-				Name:                "Bulgaria",
-				CapitalGainsTaxRate: BGCapitalGains,
-				DividendTaxRate:     BGDividendRate,
-				Allowances: TaxAllowances{
-					CapitalGains: 0,
-					Dividends:    0,
-				},
-			},
-		},
+	jurisdictions, err := DefaultJurisdictions()
+	if err != nil {
+		panic(err)
+	}
+	treaties, err := DefaultTreatyTable()
+	if err != nil {
+		panic(err)
 	}
+	return &TaxCalculator{jurisdictions: jurisdictions, treaties: treaties}
+}
+
+// SetFXRateProvider sets the official exchange rates used for conversions
+func (c *TaxCalculator) SetFXRateProvider(rates FXRateProvider) {
+	c.rates = rates
+}
+
+// SetPriceProvider sets the closing prices used to value holdings instead of trade prices
+func (c *TaxCalculator) SetPriceProvider(prices PriceProvider) {
+	c.prices = prices
+}
+
+// SetTreatyTable replaces the treaty-rate table used for foreign tax credits
+func (c *TaxCalculator) SetTreatyTable(treaties *TreatyTable) {
+	c.treaties = treaties
 }
 
 // Calculate performs comprehensive tax calculations
 func (c *TaxCalculator) Calculate(transactions []types.Transaction, options types.ProcessingOptions) (*types.TaxCalculation, error) {
-	log.Println("🚧 Tax Calculator is under development")
-	log.Printf("Processing %d transactions for jurisdiction %s", len(transactions), options.Jurisdiction)
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return nil, err
+	}
+
+	gains, losses, err := c.CalculateCapitalGains(transactions, options)
+	if err != nil {
+		return nil, err
+	}
+
+	dividends, withholding, err := c.CalculateDividends(transactions, options)
+	if err != nil {
+		return nil, err
+	}
+
+	netGainLoss := gains - losses
+	carryForward, err := c.lossCarryForwardForYear(transactions, options)
+	if err != nil {
+		return nil, err
+	}
+	taxableGains := math.Max(netGainLoss-carryForward.Used-jurisdiction.Allowances.CapitalGains, 0)
+	taxableDividends := math.Max(dividends-jurisdiction.Allowances.Dividends, 0)
+
+	gainsTax, dividendTax, marginalRate := jurisdiction.InvestmentTax(taxableGains, taxableDividends, options.Profile)
+	if options.IncludeWithholdingTax {
+		credits, err := c.CalculateForeignTaxCredits(transactions, options)
+		if err != nil {
+			return nil, err
+		}
+		dividendTax = math.Max(dividendTax-credits.Creditable, 0)
+	}
 
-	// Hello World implementation - return mock calculation
 	return &types.TaxCalculation{
-		TotalGains:         0,
-		TotalLosses:        0,
-		NetGainLoss:        0,
-		DividendIncome:     0,
-		WithholdingTaxPaid: 0,
-		TaxableIncome:      0,
-		EstimatedTax:       0,
+		TotalGains:           gains,
+		TotalLosses:          losses,
+		NetGainLoss:          netGainLoss,
+		DividendIncome:       dividends,
+		WithholdingTaxPaid:   withholding,
+		LossesBroughtForward: carryForward.BroughtForward,
+		LossesUsed:           carryForward.Used,
+		LossesCarriedForward: carryForward.CarriedForward,
+		TaxableIncome:        taxableGains + taxableDividends,
+		CapitalGainsTax:      gainsTax,
+		DividendTax:          dividendTax,
+		MarginalRate:         marginalRate,
+		EstimatedTax:         gainsTax + dividendTax,
 	}, nil
 }
 
+// lossCarryForwardForYear returns the loss carry-forward summary of options.TaxYear, or an empty
+// summary when no tax year is selected or the jurisdiction does not carry losses forward
+func (c *TaxCalculator) lossCarryForwardForYear(
+	transactions []types.Transaction,
+	options types.ProcessingOptions,
+) (LossYearSummary, error) {
+	if options.TaxYear == 0 {
+		return LossYearSummary{}, nil
+	}
+
+	summaries, err := c.CalculateLossCarryForward(transactions, options)
+	if err != nil {
+		return LossYearSummary{}, err
+	}
+	if len(summaries) == 0 || summaries[len(summaries)-1].Year != options.TaxYear {
+		return LossYearSummary{}, nil
+	}
+	return summaries[len(summaries)-1], nil
+}
+
 // CalculateCapitalGains calculates capital gains and losses
 func (c *TaxCalculator) CalculateCapitalGains(transactions []types.Transaction, options types.ProcessingOptions) (float64, float64, error) {
-	log.Printf("🚧 Capital Gains Calculator is under development - processing %d transactions", len(transactions))
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	currency := c.reportingCurrency(jurisdiction, options)
+	engine := c.newLotEngine(jurisdiction, currency)
+	ledger := engine.Process(transactions)
+
+	totalGains := 0.0
+	totalLosses := 0.0
+	for _, disposal := range ledger.Disposals {
+		if !inTaxYear(jurisdiction, options.TaxYear, disposal.Date) {
+			continue
+		}
+		if disposal.GainLoss > 0 {
+			totalGains += disposal.GainLoss
+		} else {
+			totalLosses += -disposal.GainLoss
+		}
+	}
 
-	return 0.0, 0.0, nil
+	return totalGains, totalLosses, nil
 }
 
 // CalculateDividends calculates dividend income and withholding tax
 func (c *TaxCalculator) CalculateDividends(transactions []types.Transaction, options types.ProcessingOptions) (float64, float64, error) {
-	log.Printf("🚧 Dividend Calculator is under development - processing %d transactions", len(transactions))
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	totalDividends := 0.0
+	totalWithholding := 0.0
+	for _, record := range c.dividendRecords(transactions, jurisdiction, c.reportingCurrency(jurisdiction, options)) {
+		if !inTaxYear(jurisdiction, options.TaxYear, record.Date) {
+			continue
+		}
+		totalDividends += record.Amount
+		totalWithholding += record.WithholdingTax
+	}
+
+	return totalDividends, totalWithholding, nil
+}
+
+// dividendRecords extracts dividend records converted into currency at the official rates of jurisdiction
+func (c *TaxCalculator) dividendRecords(transactions []types.Transaction, jurisdiction TaxJurisdiction, currency string) []types.DividendRecord {
+	incomeCalc := NewIncomeCalculator(currency)
+	incomeCalc.SetFXRateProvider(c.ratesFor(jurisdiction, currency))
+	return incomeCalc.extractDividendRecords(transactions)
+}
+
+// ratesFor returns the calculator's rates for converting into currency under jurisdiction, fixing
+// the currencies its rate source pegs to the jurisdiction's currency
+func (c *TaxCalculator) ratesFor(jurisdiction TaxJurisdiction, currency string) FXRateProvider {
+	if !strings.EqualFold(currency, jurisdiction.Currency) {
+		return c.rates
+	}
+	return jurisdiction.FXSource.WithPegged(c.rates)
+}
+
+// newLotEngine creates a lot engine converting into currency with the jurisdiction's matching
+// method, four-week window and wash sale rule
+func (c *TaxCalculator) newLotEngine(jurisdiction TaxJurisdiction, currency string) *LotEngine {
+	engine := NewLotEngine(currency, jurisdiction.MatchingMethod, c.ratesFor(jurisdiction, currency))
+	if rule := jurisdiction.RepurchaseRule; rule != nil && jurisdiction.MatchingMethod == MatchingFourWeek {
+		engine.SetFourWeekDays(rule.Days)
+	}
+	engine.SetWashSaleDays(jurisdiction.WashSaleDays)
+	return engine
+}
+
+// lookupJurisdiction returns the jurisdiction for code with the rules of year, or an error if it is
+// not supported. Year 0 selects the latest rules.
+func (c *TaxCalculator) lookupJurisdiction(code string, year int) (TaxJurisdiction, error) {
+	jurisdiction, exists := c.jurisdictions[code]
+	if !exists {
+		return TaxJurisdiction{}, fmt.Errorf("unsupported jurisdiction: %s", code)
+	}
+	return jurisdiction.ForYear(year), nil
+}
+
+// reportingCurrency returns the requested currency, defaulting to the jurisdiction's currency
+func (c *TaxCalculator) reportingCurrency(jurisdiction TaxJurisdiction, options types.ProcessingOptions) string {
+	if options.Currency != "" {
+		return string(options.Currency)
+	}
+	return jurisdiction.Currency
+}
+
+// inTaxYear reports whether date falls in the jurisdiction's tax year, or true when year is 0
+func inTaxYear(jurisdiction TaxJurisdiction, year int, date time.Time) bool {
+	if year == 0 {
+		return true
+	}
+	from, to := jurisdiction.TaxYearBounds(year)
+	return !date.Before(from) && date.Before(to)
+}
 
-	return 0.0, 0.0, nil
+// taxYearBounds returns the start and end of a calendar tax year
+func taxYearBounds(year int) (time.Time, time.Time) {
+	start := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(1, 0, 0)
 }
 
-// GetJurisdiction returns tax jurisdiction details
+// GetJurisdiction returns tax jurisdiction details with the latest rules
 func (c *TaxCalculator) GetJurisdiction(code string) (*TaxJurisdiction, bool) {
 	jurisdiction, exists := c.jurisdictions[code]
 	return &jurisdiction, exists
 }
 
-// GetSupportedJurisdictions returns all supported jurisdictions
+// GetSupportedJurisdictions returns all loaded jurisdictions sorted by code
 func (c *TaxCalculator) GetSupportedJurisdictions() []TaxJurisdiction {
 	jurisdictions := make([]TaxJurisdiction, 0, len(c.jurisdictions))
 	for _, jurisdiction := range c.jurisdictions {
 		jurisdictions = append(jurisdictions, jurisdiction)
 	}
+	sort.Slice(jurisdictions, func(i, j int) bool {
+		return jurisdictions[i].Code < jurisdictions[j].Code
+	})
 	return jurisdictions
 }
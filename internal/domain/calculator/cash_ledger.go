@@ -0,0 +1,369 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// CashTolerance is the difference between the ledger and a statement balance below which the two
+// are treated as agreeing
+const CashTolerance = 0.01
+
+// CashKind classifies a movement of cash in the account
+type CashKind string
+
+const (
+	CashDeposit    CashKind = "deposit"
+	CashWithdrawal CashKind = "withdrawal"
+	CashBuy        CashKind = "buy"
+	CashSell       CashKind = "sell"
+	CashIncome     CashKind = "income"
+	CashFee        CashKind = "fee"
+	CashCardDebit  CashKind = "card debit"
+	CashCardCredit CashKind = "card credit"
+	CashConversion CashKind = "conversion"
+	// CashOther is a row of an unknown type whose direction is taken from the sign of its Total
+	CashOther CashKind = "other"
+)
+
+// CashEntry is one movement of cash in one currency
+type CashEntry struct {
+	Date     time.Time `json:"date"`
+	Kind     CashKind  `json:"kind"`
+	Action   string    `json:"action"`
+	ID       string    `json:"id,omitempty"`
+	Ticker   string    `json:"ticker,omitempty"`
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	// Balance is the balance of the currency after the movement
+	Balance float64 `json:"balance"`
+}
+
+// CashLedger is every movement of cash in the account in time order, per currency
+type CashLedger struct {
+	Entries []CashEntry `json:"entries"`
+	// Unclassified counts rows of unknown types booked by the sign of their Total
+	Unclassified int `json:"unclassified"`
+	// Skipped counts rows that move no cash, such as stock splits
+	Skipped int `json:"skipped"`
+	// brokerRates is the last exchange rate Trading 212 applied per instrument currency, in units
+	// of it per unit of accountCurrency
+	brokerRates     map[string]float64
+	accountCurrency string
+}
+
+// CashBalance is the balance of one currency and its value in the base currency
+type CashBalance struct {
+	Currency  string  `json:"currency"`
+	Balance   float64 `json:"balance"`
+	BaseValue float64 `json:"base_value"`
+	// Converted is false when no exchange rate was available and BaseValue is 0
+	Converted bool `json:"converted"`
+}
+
+// YearEndCash is the cash held per currency at the close of 31 December
+type YearEndCash struct {
+	Year      int           `json:"year"`
+	Balances  []CashBalance `json:"balances"`
+	BaseValue float64       `json:"base_value"`
+}
+
+// CashReconciliation compares the ledger balance of a currency with the statement balance
+type CashReconciliation struct {
+	Currency   string  `json:"currency"`
+	Ledger     float64 `json:"ledger"`
+	Statement  float64 `json:"statement"`
+	Difference float64 `json:"difference"`
+	Matched    bool    `json:"matched"`
+}
+
+// CashReport holds the cash ledger, balances on a date, year-end cash and the reconciliation
+// against statement balances
+type CashReport struct {
+	Currency       string               `json:"currency"`
+	AsOf           time.Time            `json:"as_of"`
+	Entries        []CashEntry          `json:"entries,omitempty"`
+	Balances       []CashBalance        `json:"balances"`
+	BaseValue      float64              `json:"base_value"`
+	YearEnd        []YearEndCash        `json:"year_end"`
+	Reconciliation []CashReconciliation `json:"reconciliation,omitempty"`
+	Unclassified   int                  `json:"unclassified"`
+	Skipped        int                  `json:"skipped"`
+	Warnings       []string             `json:"warnings,omitempty"`
+}
+
+// CashCalculator replays transactions into a per-currency cash ledger
+type CashCalculator struct {
+	baseCurrency string
+	rates        FXRateProvider
+}
+
+// NewCashCalculator creates a cash calculator; rows without a currency are taken to be in baseCurrency
+func NewCashCalculator(baseCurrency string) *CashCalculator {
+	return &CashCalculator{baseCurrency: baseCurrency}
+}
+
+// SetFXRateProvider sets official exchange rates used to value foreign currency balances
+func (cc *CashCalculator) SetFXRateProvider(rates FXRateProvider) {
+	cc.rates = rates
+}
+
+// classifyCash returns the kind of cash movement a transaction is, from its action
+func classifyCash(tx types.Transaction) CashKind {
+	action := strings.ToLower(string(tx.Action))
+	switch {
+	case tx.Action == types.TransactionTypeDeposit:
+		return CashDeposit
+	case tx.Action == types.TransactionTypeWithdrawal:
+		return CashWithdrawal
+	case isBuyAction(tx.Action):
+		return CashBuy
+	case isSellAction(tx.Action):
+		return CashSell
+	case strings.Contains(action, "conversion"):
+		return CashConversion
+	case strings.Contains(action, "card debit"):
+		return CashCardDebit
+	case strings.Contains(action, "card credit") || strings.Contains(action, "refund"):
+		return CashCardCredit
+	case strings.Contains(action, "dividend") || strings.Contains(action, "interest") || strings.Contains(action, "cashback"):
+		return CashIncome
+	case strings.Contains(action, "fee") || strings.Contains(action, "cost"):
+		return CashFee
+	}
+	return CashOther
+}
+
+// BuildLedger replays every row of transactions into cash movements per currency
+func (cc *CashCalculator) BuildLedger(transactions []types.Transaction) *CashLedger {
+	ledger := &CashLedger{brokerRates: make(map[string]float64), accountCurrency: accountCurrency(transactions)}
+	balances := make(map[string]float64)
+
+	for _, tx := range sortedByTime(transactions) {
+		if tx.ExchangeRate != nil && *tx.ExchangeRate > 0 && tx.CurrencyPricePerShare != nil {
+			ledger.brokerRates[strings.ToUpper(*tx.CurrencyPricePerShare)] = *tx.ExchangeRate
+		}
+
+		kind := classifyCash(tx)
+		movements := cc.movements(tx, kind)
+		if len(movements) == 0 {
+			ledger.Skipped++
+			continue
+		}
+		if kind == CashOther {
+			ledger.Unclassified++
+		}
+
+		for _, movement := range movements {
+			balances[movement.Currency] += movement.Amount
+			movement.Balance = balances[movement.Currency]
+			ledger.Entries = append(ledger.Entries, movement)
+		}
+	}
+
+	return ledger
+}
+
+// movements returns the cash a transaction moves, one entry per currency. Trading 212 does not
+// sign Total consistently, so the direction comes from the kind of row where it is known.
+func (cc *CashCalculator) movements(tx types.Transaction, kind CashKind) []CashEntry {
+	entry := CashEntry{Date: tx.Time, Kind: kind, Action: string(tx.Action), ID: safeDeref(tx.ID), Ticker: safeDeref(tx.Ticker)}
+
+	if kind == CashConversion {
+		if tx.CurrencyConversionFromAmount == nil || tx.CurrencyConversionToAmount == nil {
+			return nil
+		}
+		from, to := entry, entry
+		from.Currency = cc.currencyOf(tx.CurrencyCurrencyConversionFromAmount)
+		from.Amount = -math.Abs(*tx.CurrencyConversionFromAmount)
+		to.Currency = cc.currencyOf(tx.CurrencyCurrencyConversionToAmount)
+		to.Amount = math.Abs(*tx.CurrencyConversionToAmount)
+		return []CashEntry{from, to}
+	}
+
+	amount, currency := 0.0, tx.CurrencyTotal
+	switch {
+	case tx.Total != nil && *tx.Total != 0:
+		amount = *tx.Total
+	case tx.Result != nil && *tx.Result != 0 && kind == CashIncome:
+		amount, currency = *tx.Result, tx.CurrencyResult
+	default:
+		return nil
+	}
+
+	entry.Currency = cc.currencyOf(currency)
+	switch kind {
+	case CashDeposit, CashSell, CashIncome, CashCardCredit:
+		entry.Amount = math.Abs(amount)
+	case CashWithdrawal, CashBuy, CashFee, CashCardDebit:
+		entry.Amount = -math.Abs(amount)
+	default:
+		entry.Amount = amount
+	}
+	return []CashEntry{entry}
+}
+
+// currencyOf returns a row's currency, or the base currency when the row has none
+func (cc *CashCalculator) currencyOf(currency *string) string {
+	if currency == nil || *currency == "" {
+		return cc.baseCurrency
+	}
+	return strings.ToUpper(*currency)
+}
+
+// BalancesAt returns the balance of each currency at the close of date
+func (l *CashLedger) BalancesAt(date time.Time) map[string]float64 {
+	cutoff := endOfDay(date)
+	balances := make(map[string]float64)
+	for _, entry := range l.Entries {
+		if entry.Date.After(cutoff) {
+			break
+		}
+		balances[entry.Currency] = entry.Balance
+	}
+	return balances
+}
+
+// Years returns the calendar years from the first movement to the last
+func (l *CashLedger) Years() []int {
+	if len(l.Entries) == 0 {
+		return nil
+	}
+	var years []int
+	for year := l.Entries[0].Date.Year(); year <= l.Entries[len(l.Entries)-1].Date.Year(); year++ {
+		years = append(years, year)
+	}
+	return years
+}
+
+// Value converts the ledger's balances at the close of date into the base currency, at the
+// official rate on date or else, when the base currency is the account currency, the last rate
+// Trading 212 applied. Other balances are left unconverted.
+func (cc *CashCalculator) Value(ledger *CashLedger, date time.Time) ([]CashBalance, float64) {
+	balances := ledger.BalancesAt(date)
+	currencies := make([]string, 0, len(balances))
+	for currency := range balances {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	converter := newCurrencyConverter(cc.baseCurrency, ledger.accountCurrency, cc.rates)
+	values := make([]CashBalance, 0, len(currencies))
+	total := 0.0
+	for _, currency := range currencies {
+		balance := CashBalance{Currency: currency, Balance: balances[currency], Converted: true}
+		var brokerRate *float64
+		if rate := ledger.brokerRates[currency]; rate > 0 {
+			brokerRate = &rate
+		}
+		if value, ok := converter.convertOK(balance.Balance, &currency, date, brokerRate); ok {
+			balance.BaseValue = value
+		} else {
+			balance.Converted = false
+		}
+		total += balance.BaseValue
+		values = append(values, balance)
+	}
+	return values, total
+}
+
+// YearEndCash returns the cash held at the close of 31 December of year
+func (cc *CashCalculator) YearEndCash(ledger *CashLedger, year int) YearEndCash {
+	balances, total := cc.Value(ledger, time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC))
+	return YearEndCash{Year: year, Balances: balances, BaseValue: total}
+}
+
+// CalculateCashReport builds the cash ledger, values the balances at the close of asOf, or of the
+// last transaction when asOf is zero, and reconciles them with the statement balances per currency
+func (cc *CashCalculator) CalculateCashReport(
+	transactions []types.Transaction,
+	asOf time.Time,
+	statement map[string]float64,
+) *CashReport {
+	ledger := cc.BuildLedger(transactions)
+	report := &CashReport{
+		Currency:     cc.baseCurrency,
+		Entries:      ledger.Entries,
+		Unclassified: ledger.Unclassified,
+		Skipped:      ledger.Skipped,
+	}
+	if asOf.IsZero() && len(ledger.Entries) > 0 {
+		asOf = ledger.Entries[len(ledger.Entries)-1].Date
+	}
+	report.AsOf = truncateToDay(asOf)
+	report.Balances, report.BaseValue = cc.Value(ledger, report.AsOf)
+
+	for _, year := range ledger.Years() {
+		report.YearEnd = append(report.YearEnd, cc.YearEndCash(ledger, year))
+	}
+
+	report.Reconciliation = reconcileCash(report.Balances, statement)
+	report.Warnings = cashWarnings(report, ledger)
+	return report
+}
+
+// reconcileCash compares ledger balances with statement balances for every currency in either
+func reconcileCash(balances []CashBalance, statement map[string]float64) []CashReconciliation {
+	if len(statement) == 0 {
+		return nil
+	}
+
+	ledger := make(map[string]float64, len(balances))
+	for _, balance := range balances {
+		ledger[balance.Currency] = balance.Balance
+	}
+	currencies := make([]string, 0, len(ledger)+len(statement))
+	for currency := range ledger {
+		currencies = append(currencies, currency)
+	}
+	for currency := range statement {
+		if _, exists := ledger[strings.ToUpper(currency)]; !exists {
+			currencies = append(currencies, strings.ToUpper(currency))
+		}
+	}
+	sort.Strings(currencies)
+
+	statementBalances := make(map[string]float64, len(statement))
+	for currency, balance := range statement {
+		statementBalances[strings.ToUpper(currency)] = balance
+	}
+
+	rows := make([]CashReconciliation, 0, len(currencies))
+	for _, currency := range currencies {
+		row := CashReconciliation{Currency: currency, Ledger: ledger[currency], Statement: statementBalances[currency]}
+		row.Difference = row.Ledger - row.Statement
+		row.Matched = math.Abs(row.Difference) < CashTolerance
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// cashWarnings describes balances that suggest missing history and rows booked on assumptions
+func cashWarnings(report *CashReport, ledger *CashLedger) []string {
+	var warnings []string
+	for _, balance := range report.Balances {
+		if balance.Balance < -CashTolerance {
+			warnings = append(warnings, fmt.Sprintf("%s balance is negative (%.2f); an export or deposit may be missing",
+				balance.Currency, balance.Balance))
+		}
+		if !balance.Converted {
+			warnings = append(warnings, fmt.Sprintf("no exchange rate for %s; its balance is left out of the %s total",
+				balance.Currency, report.Currency))
+		}
+	}
+	if ledger.Unclassified > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d row(s) of unknown type booked by the sign of their Total", ledger.Unclassified))
+	}
+	for _, row := range report.Reconciliation {
+		if !row.Matched {
+			warnings = append(warnings, fmt.Sprintf("%s ledger balance %.2f differs from the statement balance %.2f by %+.2f",
+				row.Currency, row.Ledger, row.Statement, row.Difference))
+		}
+	}
+	return warnings
+}
@@ -0,0 +1,131 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestCashCalculator_CalculateCashReport(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	row := func(action types.TransactionType, date time.Time, total float64, currency string) types.Transaction {
+		return types.Transaction{Action: action, Time: date, Total: floatPtr(total), CurrencyTotal: stringPtr(currency)}
+	}
+	buy := tradeTx(types.TransactionTypeMarketBuy, day(1, 3), "US0378331005", 5, 100)
+	buy.Total, buy.CurrencyTotal = floatPtr(500), stringPtr("EUR")
+
+	transactions := []types.Transaction{
+		row(types.TransactionTypeDeposit, day(1, 2), 1000, "EUR"),
+		buy,
+		{
+			Action:                               "Currency conversion",
+			Time:                                 day(2, 1),
+			CurrencyConversionFromAmount:         floatPtr(200),
+			CurrencyCurrencyConversionFromAmount: stringPtr("EUR"),
+			CurrencyConversionToAmount:           floatPtr(215),
+			CurrencyCurrencyConversionToAmount:   stringPtr("USD"),
+		},
+		row("Card debit", day(3, 1), -50, "EUR"),
+		row("Dividend (Ordinary)", day(4, 1), 3, "USD"),
+		row("Interest on cash", day(5, 1), 1.2, "EUR"),
+		row("Something new", day(6, 1), -5, "EUR"),
+		{Action: "Stock split open", Time: day(7, 1)},
+		row(types.TransactionTypeWithdrawal, time.Date(2025, 1, 10, 10, 0, 0, 0, time.UTC), -100, "EUR"),
+	}
+
+	rates := NewRateTable("EUR", LookupSameDay)
+	rates.Add("USD", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 1.09)
+
+	cash := NewCashCalculator("EUR")
+	cash.SetFXRateProvider(rates)
+	report := cash.CalculateCashReport(transactions, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		map[string]float64{"EUR": 246.2, "usd": 200})
+
+	if len(report.Balances) != 2 {
+		t.Fatalf("Expected EUR and USD balances, got %+v", report.Balances)
+	}
+	eur, usd := report.Balances[0], report.Balances[1]
+	if abs(eur.Balance-246.2) > 1e-9 || abs(usd.Balance-218) > 1e-9 {
+		t.Errorf("Expected 246.20 EUR and 218.00 USD, got %.2f and %.2f", eur.Balance, usd.Balance)
+	}
+	if abs(usd.BaseValue-200) > 1e-9 || abs(report.BaseValue-446.2) > 1e-9 {
+		t.Errorf("Expected USD worth 200 EUR and 446.20 in total, got %.2f and %.2f", usd.BaseValue, report.BaseValue)
+	}
+	if report.Unclassified != 1 || report.Skipped != 1 {
+		t.Errorf("Expected 1 unclassified and 1 skipped row, got %d and %d", report.Unclassified, report.Skipped)
+	}
+
+	if len(report.Reconciliation) != 2 || !report.Reconciliation[0].Matched || report.Reconciliation[1].Matched {
+		t.Errorf("Expected EUR to match the statement and USD not to, got %+v", report.Reconciliation)
+	}
+	if abs(report.Reconciliation[1].Difference-18) > 1e-9 {
+		t.Errorf("Expected a USD difference of 18, got %.2f", report.Reconciliation[1].Difference)
+	}
+
+	// The 2025 withdrawal shows in 2025's year-end cash only
+	if len(report.YearEnd) != 2 || abs(report.YearEnd[0].BaseValue-446.2) > 1e-9 {
+		t.Fatalf("Expected year-end cash for 2024 and 2025, got %+v", report.YearEnd)
+	}
+	if abs(report.YearEnd[1].Balances[0].Balance-146.2) > 1e-9 {
+		t.Errorf("Expected 146.20 EUR at the end of 2025, got %+v", report.YearEnd[1].Balances)
+	}
+}
+
+func TestCashCalculator_ValueBrokerRateOnlyForAccountCurrency(t *testing.T) {
+	date := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	buy := tradeTx(types.TransactionTypeMarketBuy, date, "US0378331005", 1, 108)
+	buy.CurrencyPricePerShare, buy.ExchangeRate = stringPtr("USD"), floatPtr(1.08)
+	transactions := []types.Transaction{
+		{Action: types.TransactionTypeDeposit, Time: date, Total: floatPtr(1000), CurrencyTotal: stringPtr("EUR")},
+		{Action: "Dividend (Ordinary)", Time: date, Total: floatPtr(54), CurrencyTotal: stringPtr("USD")},
+		buy,
+	}
+	yearEnd := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	// The USD per EUR rate values USD cash in EUR, the account currency
+	cash := NewCashCalculator("EUR")
+	balances, _ := cash.Value(cash.BuildLedger(transactions), yearEnd)
+	if usd := balances[1]; !usd.Converted || abs(usd.BaseValue-50) > 1e-9 {
+		t.Errorf("Expected 54 USD worth 50 EUR, got %+v", usd)
+	}
+
+	// but not in BGN, where neither balance can be converted without official rates
+	cash = NewCashCalculator("BGN")
+	balances, total := cash.Value(cash.BuildLedger(transactions), yearEnd)
+	for _, balance := range balances {
+		if balance.Converted || balance.BaseValue != 0 {
+			t.Errorf("Expected %s to be left unconverted, got %+v", balance.Currency, balance)
+		}
+	}
+	if total != 0 {
+		t.Errorf("Expected no converted total, got %.2f", total)
+	}
+}
+
+func TestClassifyCash(t *testing.T) {
+	tests := []struct {
+		action types.TransactionType
+		want   CashKind
+	}{
+		{types.TransactionTypeDeposit, CashDeposit},
+		{types.TransactionTypeWithdrawal, CashWithdrawal},
+		{types.TransactionTypeLimitBuy, CashBuy},
+		{types.TransactionTypeStopSell, CashSell},
+		{"Dividend (Dividend)", CashIncome},
+		{"Spending cashback", CashIncome},
+		{"Card debit", CashCardDebit},
+		{"Card credit", CashCardCredit},
+		{"New card cost", CashFee},
+		{"Currency conversion", CashConversion},
+		{"Stock split close", CashOther},
+	}
+
+	for _, tt := range tests {
+		if got := classifyCash(types.Transaction{Action: tt.action}); got != tt.want {
+			t.Errorf("classifyCash(%q) = %s, want %s", tt.action, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,523 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// German tax constants. The flat tax rate, the solidarity surcharge and the Sparer-Pauschbetrag for a
+// single assessment come from the jurisdiction rules of each year.
+const (
+	// DEJointAssessmentFactor multiplies the Sparer-Pauschbetrag for a joint assessment
+	DEJointAssessmentFactor = 2
+	// DEBasisertragFactor is the share of the Basiszins applied to the fund value for the Basisertrag
+	DEBasisertragFactor = 0.7
+	// MonthsPerYear is used to pro-rate the Vorabpauschale for units bought during the year
+	MonthsPerYear = 12
+)
+
+// DEFundType classifies an investment fund for the Teilfreistellung
+type DEFundType string
+
+// Investment fund types under the InvStG
+const (
+	DEFundNone              DEFundType = ""                    // Not an investment fund
+	DEFundEquity            DEFundType = "equity"              // Aktienfonds
+	DEFundMixed             DEFundType = "mixed"               // Mischfonds
+	DEFundRealEstate        DEFundType = "real_estate"         // Immobilienfonds
+	DEFundForeignRealEstate DEFundType = "foreign_real_estate" // Auslands-Immobilienfonds
+	DEFundOther             DEFundType = "other"               // Sonstige Investmentfonds
+)
+
+// PartialExemption returns the Teilfreistellung rate for the fund type
+func (t DEFundType) PartialExemption() float64 {
+	switch t {
+	case DEFundEquity:
+		return 0.30
+	case DEFundMixed:
+		return 0.15
+	case DEFundRealEstate:
+		return 0.60
+	case DEFundForeignRealEstate:
+		return 0.80
+	default:
+		return 0
+	}
+}
+
+// deKAPINVLines maps a fund type to its Anlage KAP-INV lines for distributions,
+// Vorabpauschale and sale gains
+var deKAPINVLines = map[DEFundType][3]int{
+	DEFundEquity:            {4, 9, 14},
+	DEFundMixed:             {5, 10, 17},
+	DEFundRealEstate:        {6, 11, 20},
+	DEFundForeignRealEstate: {7, 12, 23},
+	DEFundOther:             {8, 13, 26},
+}
+
+// Anlage KAP lines for foreign capital income not subject to German withholding.
+// The numbering follows the 2024 form; check it against the form for the declared year.
+const (
+	DEKAPLineSaverAllowance = 17
+	DEKAPLineForeignIncome  = 19
+	DEKAPLineShareGains     = 20
+	DEKAPLineShareLosses    = 23
+	DEKAPLineForeignTax     = 41
+)
+
+// DEOptions holds the personal settings that affect German capital income tax
+type DEOptions struct {
+	ChurchTaxRate   float64               `json:"church_tax_rate"`
+	JointAssessment bool                  `json:"joint_assessment"`
+	FundTypes       map[string]DEFundType `json:"fund_types,omitempty"`
+	// ShareLossCarryForward and OtherLossCarryForward override the losses brought forward into the
+	// year's pots; when nil they are carried forward from the earlier years' disposals and income
+	ShareLossCarryForward *float64 `json:"share_loss_carry_forward,omitempty"`
+	OtherLossCarryForward *float64 `json:"other_loss_carry_forward,omitempty"`
+}
+
+// DELossPot tracks one Verlustverrechnungstopf for the year
+type DELossPot struct {
+	BroughtForward float64 `json:"brought_forward"`
+	Arising        float64 `json:"arising"`
+	Used           float64 `json:"used"`
+	CarriedForward float64 `json:"carried_forward"`
+}
+
+// DEFundIncome holds the income from one investment fund for the year, before Teilfreistellung
+type DEFundIncome struct {
+	ISIN          string     `json:"isin"`
+	Name          string     `json:"name"`
+	Type          DEFundType `json:"type"`
+	Distributions float64    `json:"distributions"`
+	// Vorabpauschale is the previous year's, which accrues at the start of this year
+	Vorabpauschale   float64 `json:"vorabpauschale"`
+	SaleGainLoss     float64 `json:"sale_gain_loss"`
+	PriorVorabAmount float64 `json:"prior_vorabpauschale"`
+	PartialExemption float64 `json:"partial_exemption"`
+}
+
+// DEFormLine is a single line of Anlage KAP or KAP-INV
+type DEFormLine struct {
+	Form        string  `json:"form"`
+	Line        int     `json:"line"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// DEReport holds the German Abgeltungsteuer computation for a year
+type DEReport struct {
+	Year                 int            `json:"year"`
+	Currency             string         `json:"currency"`
+	Options              DEOptions      `json:"options"`
+	ShareGains           float64        `json:"share_gains"`
+	ShareLosses          float64        `json:"share_losses"`
+	Dividends            float64        `json:"dividends"`
+	Interest             float64        `json:"interest"`
+	Funds                []DEFundIncome `json:"funds"`
+	FundIncome           float64        `json:"fund_income"`
+	FundLosses           float64        `json:"fund_losses"`
+	PartialExemption     float64        `json:"partial_exemption"`
+	SharePot             DELossPot      `json:"share_loss_pot"`
+	OtherPot             DELossPot      `json:"other_loss_pot"`
+	NetIncome            float64        `json:"net_income"`
+	SaverAllowance       float64        `json:"saver_allowance"`
+	TaxableIncome        float64        `json:"taxable_income"`
+	ForeignTaxPaid       float64        `json:"foreign_tax_paid"`
+	CreditableForeignTax float64        `json:"creditable_foreign_tax"`
+	IncomeTax            float64        `json:"income_tax"`
+	SolidaritySurcharge  float64        `json:"solidarity_surcharge"`
+	ChurchTax            float64        `json:"church_tax"`
+	TotalTax             float64        `json:"total_tax"`
+	Lines                []DEFormLine   `json:"lines"`
+	Disposals            []Disposal     `json:"disposals"`
+	Warnings             []string       `json:"warnings,omitempty"`
+}
+
+// GenerateDEReport computes German flat tax on a year's capital income, applying the loss pots,
+// Teilfreistellung, Vorabpauschale and Sparer-Pauschbetrag, and maps it to Anlage KAP and KAP-INV
+func (c *TaxCalculator) GenerateDEReport(transactions []types.Transaction, year int, options DEOptions) (*DEReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("DE", year)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &DEReport{
+		Year:     year,
+		Currency: jurisdiction.Currency,
+		Options:  options,
+	}
+
+	from, to := taxYearBounds(year)
+	engine := c.newLotEngine(jurisdiction, jurisdiction.Currency)
+	ledger := engine.Process(filterBefore(transactions, to))
+	report.Disposals = ledger.DisposalsBetween(from, to)
+	report.Warnings = ledger.Warnings
+
+	converter := newCurrencyConverter(jurisdiction.Currency, accountCurrency(transactions), c.ratesFor(jurisdiction, jurisdiction.Currency))
+	valuer := &deFundValuer{
+		jurisdiction:  jurisdiction,
+		prices:        newTradePriceIndex(transactions, converter, c.prices),
+		distributions: make(map[string]map[int]float64),
+		transactions:  transactions,
+	}
+
+	funds := make(map[string]*DEFundIncome)
+	fundFor := func(key, name string, fundType DEFundType) *DEFundIncome {
+		fund, exists := funds[key]
+		if !exists {
+			fund = &DEFundIncome{ISIN: key, Name: name, Type: fundType}
+			funds[key] = fund
+		}
+		return fund
+	}
+
+	credit := 0.0
+	for _, record := range c.dividendRecords(transactions, jurisdiction, jurisdiction.Currency) {
+		key := record.ISIN
+		if key == "" {
+			key = record.Ticker
+		}
+		fundType := options.fundType(key, record.Name)
+		if fundType != DEFundNone {
+			valuer.addDistribution(key, record.Date.Year(), record.Amount)
+		}
+		if record.Date.Year() != year {
+			continue
+		}
+
+		report.ForeignTaxPaid += record.WithholdingTax
+		credit += math.Min(record.WithholdingTax, record.Amount*jurisdiction.CreditCap())
+		if fundType != DEFundNone {
+			fundFor(key, record.Name, fundType).Distributions += record.Amount
+			continue
+		}
+		report.Dividends += record.Amount
+	}
+
+	incomeCalc := NewIncomeCalculator(jurisdiction.Currency)
+	for _, record := range incomeCalc.extractInterestRecords(transactions) {
+		if record.Date.Year() == year {
+			report.Interest += record.Amount
+		}
+	}
+
+	for _, disposal := range report.Disposals {
+		key := disposalKey(disposal)
+		fundType := options.fundType(key, disposal.Name)
+		if fundType == DEFundNone {
+			if disposal.GainLoss > 0 {
+				report.ShareGains += disposal.GainLoss
+			} else {
+				report.ShareLosses += -disposal.GainLoss
+			}
+			continue
+		}
+
+		// Vorabpauschalen that accrued while the units were held, up to the one for the previous
+		// year, reduce the gain on sale
+		prior := 0.0
+		for _, match := range disposal.Matches {
+			for y := match.AcquiredAt.Year(); y < year; y++ {
+				prior += match.Shares * valuer.perUnit(key, y) * deHoldingFactor(match.AcquiredAt, y)
+			}
+		}
+		fund := fundFor(key, disposal.Name, fundType)
+		fund.SaleGainLoss += disposal.GainLoss - prior
+		fund.PriorVorabAmount += prior
+	}
+
+	// §18(3) InvStG: the Vorabpauschale for the previous year accrues on the first working day of
+	// the year, on the units held then
+	opening := engine.Process(filterBefore(transactions, from))
+	for _, lot := range opening.Lots {
+		if lot.Remaining <= ShareEpsilon {
+			continue
+		}
+		key := lot.ISIN
+		if key == "" {
+			key = lot.Ticker
+		}
+		fundType := options.fundType(key, lot.Name)
+		if fundType == DEFundNone {
+			continue
+		}
+		fundFor(key, lot.Name, fundType).Vorabpauschale += lot.Remaining * valuer.perUnit(key, year-1) * deHoldingFactor(lot.AcquiredAt, year-1)
+	}
+
+	report.Funds = sortedDEFunds(funds)
+	for i := range report.Funds {
+		fund := &report.Funds[i]
+		if _, configured := options.FundTypes[fund.ISIN]; !configured {
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"%s (%s): fund type not set; taxed as an other fund without Teilfreistellung, set it with --instrument %s=equity, mixed, real_estate or foreign_real_estate",
+				fund.ISIN, fund.Name, fund.ISIN))
+		}
+		rate := fund.Type.PartialExemption()
+		income := fund.Distributions + fund.Vorabpauschale
+		fund.PartialExemption = (income + fund.SaleGainLoss) * rate
+		report.PartialExemption += fund.PartialExemption
+
+		report.FundIncome += income * (1 - rate)
+		if fund.SaleGainLoss > 0 {
+			report.FundIncome += fund.SaleGainLoss * (1 - rate)
+		} else {
+			report.FundLosses += -fund.SaleGainLoss * (1 - rate)
+		}
+	}
+	if len(report.Funds) > 0 {
+		report.Warnings = append(report.Warnings, valuer.warnings...)
+	}
+
+	shareBroughtForward, otherBroughtForward, err := c.deLossesBroughtForward(transactions, year, options)
+	if err != nil {
+		return nil, err
+	}
+	report.offsetLosses(shareBroughtForward, otherBroughtForward)
+
+	allowance := jurisdiction.Allowances.CapitalGains
+	if options.JointAssessment {
+		allowance *= DEJointAssessmentFactor
+	}
+	report.SaverAllowance = math.Min(report.NetIncome, allowance)
+	report.TaxableIncome = report.NetIncome - report.SaverAllowance
+
+	// §32d(1) EStG: tax = (e - 4q) / (4 + k), with church tax deductible through k
+	rate := jurisdiction.CapitalGainsTaxRate
+	report.CreditableForeignTax = math.Min(credit, report.TaxableIncome*rate)
+	report.IncomeTax = math.Max(report.TaxableIncome-4*report.CreditableForeignTax, 0) / (1/rate + options.ChurchTaxRate)
+	report.SolidaritySurcharge = report.IncomeTax * jurisdiction.SolidaritySurcharge
+	report.ChurchTax = report.IncomeTax * options.ChurchTaxRate
+	report.TotalTax = report.IncomeTax + report.SolidaritySurcharge + report.ChurchTax
+
+	report.Lines = report.formLines()
+	return report, nil
+}
+
+// deLossesBroughtForward returns the share and other losses brought forward into year: the
+// overrides in options, or else the pots carried forward from the previous year's report, going
+// back to the first year with transactions
+func (c *TaxCalculator) deLossesBroughtForward(transactions []types.Transaction, year int, options DEOptions) (float64, float64, error) {
+	share, other := 0.0, 0.0
+	if options.ShareLossCarryForward == nil || options.OtherLossCarryForward == nil {
+		first := year
+		for _, tx := range transactions {
+			if tx.Time.Year() < first {
+				first = tx.Time.Year()
+			}
+		}
+		if first < year {
+			previousOptions := options
+			previousOptions.ShareLossCarryForward = nil
+			previousOptions.OtherLossCarryForward = nil
+			previous, err := c.GenerateDEReport(transactions, year-1, previousOptions)
+			if err != nil {
+				return 0, 0, err
+			}
+			share, other = previous.SharePot.CarriedForward, previous.OtherPot.CarriedForward
+		}
+	}
+
+	if options.ShareLossCarryForward != nil {
+		share = *options.ShareLossCarryForward
+	}
+	if options.OtherLossCarryForward != nil {
+		other = *options.OtherLossCarryForward
+	}
+	return share, other, nil
+}
+
+// offsetLosses applies the Verlustverrechnungstöpfe: share losses only offset share gains,
+// other losses offset all capital income
+func (r *DEReport) offsetLosses(shareBroughtForward, otherBroughtForward float64) {
+	r.SharePot.BroughtForward = shareBroughtForward
+	r.OtherPot.BroughtForward = otherBroughtForward
+
+	shareNet := r.ShareGains - r.ShareLosses
+	if shareNet < 0 {
+		r.SharePot.Arising = -shareNet
+	}
+	if shareNet > 0 {
+		r.SharePot.Used = math.Min(r.SharePot.BroughtForward, shareNet)
+		shareNet -= r.SharePot.Used
+	}
+
+	otherNet := r.Dividends + r.Interest + r.FundIncome - r.FundLosses
+	if otherNet < 0 {
+		offset := math.Min(-otherNet, math.Max(shareNet, 0))
+		shareNet -= offset
+		otherNet += offset
+		r.OtherPot.Arising = -otherNet
+	}
+
+	positive := math.Max(shareNet, 0) + math.Max(otherNet, 0)
+	r.OtherPot.Used = math.Min(r.OtherPot.BroughtForward, positive)
+	r.NetIncome = positive - r.OtherPot.Used
+
+	r.SharePot.CarriedForward = r.SharePot.BroughtForward - r.SharePot.Used + r.SharePot.Arising
+	r.OtherPot.CarriedForward = r.OtherPot.BroughtForward - r.OtherPot.Used + r.OtherPot.Arising
+}
+
+// formLines maps the report onto Anlage KAP and KAP-INV lines
+func (r *DEReport) formLines() []DEFormLine {
+	lines := []DEFormLine{
+		{"KAP", DEKAPLineSaverAllowance, "Sparer-Pauschbetrag used for income not subject to KapESt", r.SaverAllowance},
+		{"KAP", DEKAPLineForeignIncome, "Foreign capital income (excluding KAP-INV)", r.Dividends + r.Interest + r.ShareGains - r.ShareLosses},
+		{"KAP", DEKAPLineShareGains, "Included gains from sales of shares", r.ShareGains},
+		{"KAP", DEKAPLineShareLosses, "Included losses from sales of shares", r.ShareLosses},
+		{"KAP", DEKAPLineForeignTax, "Creditable foreign taxes", r.CreditableForeignTax},
+	}
+
+	inv := make(map[int]float64)
+	for _, fund := range r.Funds {
+		fundLines := deKAPINVLines[fund.Type]
+		inv[fundLines[0]] += fund.Distributions
+		inv[fundLines[1]] += fund.Vorabpauschale
+		inv[fundLines[2]] += fund.SaleGainLoss
+	}
+
+	descriptions := []string{"Distributions", "Vorabpauschale", "Sale gains/losses"}
+	for _, fundType := range []DEFundType{DEFundEquity, DEFundMixed, DEFundRealEstate, DEFundForeignRealEstate, DEFundOther} {
+		for i, line := range deKAPINVLines[fundType] {
+			if amount, exists := inv[line]; exists {
+				lines = append(lines, DEFormLine{"KAP-INV", line, descriptions[i] + " (" + string(fundType) + " fund)", amount})
+			}
+		}
+	}
+
+	return lines
+}
+
+// fundType returns the configured fund type for a security. An ETF without one is an other fund
+// without Teilfreistellung, as its name does not show whether it holds equities, bonds or cash.
+func (o DEOptions) fundType(key, name string) DEFundType {
+	if fundType, exists := o.FundTypes[key]; exists {
+		return fundType
+	}
+	upper := strings.ToUpper(name)
+	if strings.Contains(upper, "ETF") || strings.Contains(upper, "UCITS") {
+		return DEFundOther
+	}
+	return DEFundNone
+}
+
+// deFundValuer computes the Vorabpauschale per fund unit, valuing funds at closing or trade prices
+type deFundValuer struct {
+	jurisdiction  TaxJurisdiction
+	prices        *tradePriceIndex
+	distributions map[string]map[int]float64
+	transactions  []types.Transaction
+	warnings      []string
+}
+
+// addDistribution records a fund distribution for a year
+func (v *deFundValuer) addDistribution(key string, year int, amount float64) {
+	if v.distributions[key] == nil {
+		v.distributions[key] = make(map[int]float64)
+	}
+	v.distributions[key][year] += amount
+}
+
+// perUnit returns the Vorabpauschale per unit held for the whole of year, before pro-rating
+func (v *deFundValuer) perUnit(key string, year int) float64 {
+	published := v.jurisdiction.Years[year].Basiszins
+	if published == nil {
+		v.warn(fmt.Sprintf("no Basiszins for %d; Vorabpauschale not computed", year))
+		return 0
+	}
+	basiszins := *published
+	if basiszins <= 0 {
+		return 0
+	}
+
+	start, end := taxYearBounds(year)
+	startPrice, _, ok := v.prices.priceAt(key, start)
+	if !ok {
+		// Bought during the year; the first purchase price stands in for the value at year start
+		startPrice, _, ok = v.prices.firstPriceFrom(key, start)
+		if !ok {
+			return 0
+		}
+	}
+	endPrice, _, _ := v.prices.priceAt(key, end)
+
+	distribution := 0.0
+	if units := sharesHeldAt(v.transactions, key, end); units > ShareEpsilon {
+		distribution = v.distributions[key][year] / units
+	}
+
+	if !v.prices.hasCloses() {
+		v.warn("fund values are estimated from trade prices; check them against the fund's published prices")
+	}
+
+	basisertrag := startPrice * basiszins * DEBasisertragFactor
+	increase := endPrice - startPrice + distribution
+	return math.Max(math.Min(basisertrag, increase)-distribution, 0)
+}
+
+// warn records a valuation warning once
+func (v *deFundValuer) warn(message string) {
+	for _, existing := range v.warnings {
+		if existing == message {
+			return
+		}
+	}
+	v.warnings = append(v.warnings, message)
+}
+
+// deHoldingFactor returns the share of a year's Vorabpauschale due for a unit acquired at acquired,
+// reduced by one twelfth for each full month before the month of acquisition
+func deHoldingFactor(acquired time.Time, year int) float64 {
+	switch {
+	case acquired.Year() < year:
+		return 1
+	case acquired.Year() > year:
+		return 0
+	default:
+		return float64(MonthsPerYear-int(acquired.Month())+1) / MonthsPerYear
+	}
+}
+
+// sharesHeldAt returns the shares of a security held just before date
+func sharesHeldAt(transactions []types.Transaction, key string, date time.Time) float64 {
+	shares := 0.0
+	for _, tx := range transactions {
+		if !isTradeAction(tx.Action) || tx.Shares == nil || !tx.Time.Before(date) || SecurityKey(tx) != key {
+			continue
+		}
+		if isBuyAction(tx.Action) {
+			shares += *tx.Shares
+		} else {
+			shares -= *tx.Shares
+		}
+	}
+	return shares
+}
+
+// filterBefore returns the transactions that happened before date
+func filterBefore(transactions []types.Transaction, date time.Time) []types.Transaction {
+	filtered := make([]types.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if tx.Time.Before(date) {
+			filtered = append(filtered, tx)
+		}
+	}
+	return filtered
+}
+
+// sortedDEFunds returns the fund income entries ordered by ISIN
+func sortedDEFunds(funds map[string]*DEFundIncome) []DEFundIncome {
+	sorted := make([]DEFundIncome, 0, len(funds))
+	for _, fund := range funds {
+		sorted = append(sorted, *fund)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ISIN < sorted[j].ISIN
+	})
+	return sorted
+}
@@ -0,0 +1,264 @@
+package calculator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateDEReport(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	etf := func(action types.TransactionType, date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(action, date, "IE00BK5BQT80", shares, price)
+		tx.Name = stringPtr("Vanguard FTSE All-World UCITS ETF")
+		return tx
+	}
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 2, 1, 10, 0, 0, 0, time.UTC), "US5949181045", 5, 100),
+		etf(types.TransactionTypeMarketBuy, time.Date(2023, 1, 15, 10, 0, 0, 0, time.UTC), 100, 100),
+		etf(types.TransactionTypeMarketBuy, time.Date(2023, 12, 20, 10, 0, 0, 0, time.UTC), 1, 110),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 80),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC), "US5949181045", 5, 500),
+		{
+			Action:         types.TransactionTypeDividend,
+			Time:           time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC),
+			ISIN:           stringPtr("US5949181045"),
+			Name:           stringPtr("Microsoft"),
+			Result:         floatPtr(100),
+			CurrencyResult: stringPtr("EUR"),
+			WithholdingTax: floatPtr(15),
+		},
+	}
+
+	options := DEOptions{FundTypes: map[string]DEFundType{"IE00BK5BQT80": DEFundEquity}}
+	report, err := calc.GenerateDEReport(transactions, 2024, options)
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+
+	if report.ShareGains != 2000 || report.ShareLosses != 200 {
+		t.Errorf("Expected share gains 2000 and losses 200, got %.2f and %.2f", report.ShareGains, report.ShareLosses)
+	}
+
+	// The 2023 Vorabpauschale accrues in 2024: Basisertrag 100 × 2.55% × 0.7 per unit, one twelfth
+	// for the unit bought in December
+	expectedVorab := 100*1.785 + 1.785/12
+	if len(report.Funds) != 1 || abs(report.Funds[0].Vorabpauschale-expectedVorab) > 0.001 {
+		t.Fatalf("Expected Vorabpauschale %.4f, got %+v", expectedVorab, report.Funds)
+	}
+	if report.Funds[0].Type != DEFundEquity {
+		t.Errorf("Expected equity fund, got %s", report.Funds[0].Type)
+	}
+
+	expectedNet := 1800 + 100 + expectedVorab*0.7
+	if abs(report.NetIncome-expectedNet) > 0.001 {
+		t.Errorf("Expected net income %.4f, got %.4f", expectedNet, report.NetIncome)
+	}
+	if report.SaverAllowance != 1000 {
+		t.Errorf("Expected full saver's allowance, got %.2f", report.SaverAllowance)
+	}
+
+	expectedTax := (expectedNet - 1000 - 4*15) / 4
+	if abs(report.IncomeTax-expectedTax) > 0.001 {
+		t.Errorf("Expected income tax %.4f, got %.4f", expectedTax, report.IncomeTax)
+	}
+	if abs(report.SolidaritySurcharge-expectedTax*0.055) > 0.001 {
+		t.Errorf("Unexpected solidarity surcharge %.4f", report.SolidaritySurcharge)
+	}
+
+	lines := make(map[string]float64)
+	for _, line := range report.Lines {
+		lines[fmt.Sprintf("%s/%d", line.Form, line.Line)] = line.Amount
+	}
+	if lines["KAP/20"] != 2000 || lines["KAP/23"] != 200 || lines["KAP/41"] != 15 {
+		t.Errorf("Unexpected Anlage KAP lines: %+v", report.Lines)
+	}
+	if abs(lines["KAP-INV/9"]-expectedVorab) > 0.001 {
+		t.Errorf("Expected KAP-INV line 9 to hold the Vorabpauschale, got %+v", report.Lines)
+	}
+}
+
+func TestTaxCalculator_GenerateDEReport_YearRules(t *testing.T) {
+	calc := NewTaxCalculator()
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 400),
+	}
+
+	// The Sparer-Pauschbetrag was 801 until 2022
+	report, err := calc.GenerateDEReport(transactions, 2022, DEOptions{})
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+	if report.SaverAllowance != 801 || abs(report.IncomeTax-(3000-801)*0.25) > 0.001 {
+		t.Errorf("Expected the 2022 allowance of 801 and 25%% tax, got %.2f and %.2f", report.SaverAllowance, report.IncomeTax)
+	}
+
+	report, err = calc.GenerateDEReport(transactions, 2022, DEOptions{JointAssessment: true})
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+	if report.SaverAllowance != 1602 {
+		t.Errorf("Expected a joint allowance of 1602, got %.2f", report.SaverAllowance)
+	}
+}
+
+func TestTaxCalculator_GenerateDEReport_VorabpauschaleFollowingYear(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	buy := func(date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(types.TransactionTypeMarketBuy, date, "IE00B4L5Y983", shares, price)
+		tx.Name = stringPtr("iShares Core MSCI World UCITS ETF")
+		return tx
+	}
+	transactions := []types.Transaction{
+		buy(time.Date(2023, 3, 10, 10, 0, 0, 0, time.UTC), 10, 100),
+		buy(time.Date(2023, 11, 10, 10, 0, 0, 0, time.UTC), 10, 120),
+	}
+
+	vorab := func(year int) float64 {
+		report, err := calc.GenerateDEReport(transactions, year, DEOptions{})
+		if err != nil {
+			t.Fatalf("GenerateDEReport(%d) error = %v", year, err)
+		}
+		total := 0.0
+		for _, fund := range report.Funds {
+			total += fund.Vorabpauschale
+		}
+		return total
+	}
+
+	// Nothing accrues in 2023 for the 2022 Vorabpauschale, as no units were held at the start of 2023
+	if got := vorab(2023); got != 0 {
+		t.Errorf("Expected no Vorabpauschale in 2023, got %.4f", got)
+	}
+
+	// The 2023 Vorabpauschale, at the 2023 Basiszins of 2.55%, accrues at the start of 2024:
+	// 100 × 2.55% × 0.7 per unit for ten months and two months of the year
+	perUnit := 100 * 0.0255 * DEBasisertragFactor
+	expected := 10*perUnit*10/12 + 10*perUnit*2/12
+	if got := vorab(2024); abs(got-expected) > 0.001 {
+		t.Errorf("Expected the 2023 Vorabpauschale of %.4f in 2024, got %.4f", expected, got)
+	}
+}
+
+func TestDEReport_OffsetLosses(t *testing.T) {
+	tests := []struct {
+		name              string
+		report            DEReport
+		shareForward      float64
+		otherForward      float64
+		expectedNet       float64
+		expectedShareLoss float64
+		expectedOtherLoss float64
+	}{
+		{
+			name:              "share losses only offset share gains",
+			report:            DEReport{ShareGains: 100, ShareLosses: 500, Dividends: 300},
+			expectedNet:       300,
+			expectedShareLoss: 400,
+		},
+		{
+			name:        "other losses offset share gains",
+			report:      DEReport{ShareGains: 500, FundLosses: 200},
+			expectedNet: 300,
+		},
+		{
+			name:              "carried forward losses",
+			report:            DEReport{ShareGains: 100, Dividends: 50},
+			shareForward:      150,
+			otherForward:      20,
+			expectedNet:       30,
+			expectedShareLoss: 50,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.report.offsetLosses(tt.shareForward, tt.otherForward)
+			if abs(tt.report.NetIncome-tt.expectedNet) > 0.001 {
+				t.Errorf("NetIncome = %.2f, want %.2f", tt.report.NetIncome, tt.expectedNet)
+			}
+			if abs(tt.report.SharePot.CarriedForward-tt.expectedShareLoss) > 0.001 {
+				t.Errorf("Share pot carried forward = %.2f, want %.2f", tt.report.SharePot.CarriedForward, tt.expectedShareLoss)
+			}
+			if abs(tt.report.OtherPot.CarriedForward-tt.expectedOtherLoss) > 0.001 {
+				t.Errorf("Other pot carried forward = %.2f, want %.2f", tt.report.OtherPot.CarriedForward, tt.expectedOtherLoss)
+			}
+		})
+	}
+}
+
+func TestTaxCalculator_GenerateDEReport_LossesBroughtForward(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC), "US5949181045", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2022, 9, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 50),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC), "US5949181045", 10, 300),
+	}
+
+	// The 2022 share loss of 500 passes through 2023, which has no income, and offsets the 2024 gain
+	report, err := calc.GenerateDEReport(transactions, 2024, DEOptions{})
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+	if report.SharePot.BroughtForward != 500 || report.SharePot.Used != 500 || report.SharePot.CarriedForward != 0 {
+		t.Errorf("Expected 500 share losses brought forward and used, got %+v", report.SharePot)
+	}
+	if abs(report.NetIncome-1500) > 0.001 {
+		t.Errorf("Expected net income 1500, got %.2f", report.NetIncome)
+	}
+
+	shareLosses, otherLosses := 200.0, 100.0
+	report, err = calc.GenerateDEReport(transactions, 2024, DEOptions{
+		ShareLossCarryForward: &shareLosses,
+		OtherLossCarryForward: &otherLosses,
+	})
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+	if report.SharePot.BroughtForward != 200 || report.OtherPot.BroughtForward != 100 {
+		t.Errorf("Expected the overrides to set the pots brought forward, got %+v and %+v", report.SharePot, report.OtherPot)
+	}
+	if abs(report.NetIncome-1700) > 0.001 {
+		t.Errorf("Expected net income 1700, got %.2f", report.NetIncome)
+	}
+}
+
+func TestTaxCalculator_GenerateDEReport_UnknownFundType(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC), "IE00B3F81R35", 10, 100)
+	buy.Name = stringPtr("iShares Core Euro Corporate Bond UCITS ETF")
+	sell := tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC), "IE00B3F81R35", 10, 110)
+	sell.Name = buy.Name
+
+	report, err := calc.GenerateDEReport([]types.Transaction{buy, sell}, 2024, DEOptions{})
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+
+	// A bond ETF must not get the 30% equity fund exemption by its name
+	if len(report.Funds) != 1 || report.Funds[0].Type != DEFundOther || report.PartialExemption != 0 {
+		t.Fatalf("Expected an other fund without Teilfreistellung, got %+v", report.Funds)
+	}
+	if len(report.Warnings) == 0 {
+		t.Error("Expected a warning that the fund type is not set")
+	}
+
+	options := DEOptions{FundTypes: map[string]DEFundType{"IE00B3F81R35": DEFundMixed}}
+	report, err = calc.GenerateDEReport([]types.Transaction{buy, sell}, 2024, options)
+	if err != nil {
+		t.Fatalf("GenerateDEReport() error = %v", err)
+	}
+	if report.Funds[0].Type != DEFundMixed || abs(report.PartialExemption-100*0.15) > 0.001 {
+		t.Errorf("Expected the configured mixed fund type, got %+v", report.Funds)
+	}
+}
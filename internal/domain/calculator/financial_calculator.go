@@ -14,11 +14,18 @@ import (
 // Constants
 const (
 	PercentMultiplier = 100.0
+	// GainDiscrepancyTolerance is the difference between a matched gain and Trading 212's Result,
+	// in the base currency, below which the two are treated as agreeing
+	GainDiscrepancyTolerance = 1.0
 )
 
 // FinancialCalculator handles financial calculations and reporting
 type FinancialCalculator struct {
-	baseCurrency string
+	baseCurrency     string
+	matching         MatchingMethod
+	fourWeekDays     int
+	lossCarryForward *LossCarryForwardRule
+	prices           PriceProvider
 }
 
 // NewFinancialCalculator creates a new financial calculator
@@ -28,7 +35,35 @@ func NewFinancialCalculator(baseCurrency string) *FinancialCalculator {
 	}
 }
 
-// CalculateYearlyReports generates yearly financial reports from transactions
+// SetLossCarryForward carries net capital losses in yearly reports forward under rule; nil disables it
+func (fc *FinancialCalculator) SetLossCarryForward(rule *LossCarryForwardRule) {
+	fc.lossCarryForward = rule
+}
+
+// SetPriceProvider sets the closing prices used for portfolio valuations
+func (fc *FinancialCalculator) SetPriceProvider(prices PriceProvider) {
+	fc.prices = prices
+}
+
+// SetMatchingMethod sets how sells are matched against purchases for realised gains; FIFO by default
+func (fc *FinancialCalculator) SetMatchingMethod(method MatchingMethod) {
+	fc.matching = method
+}
+
+// SetFourWeekDays sets the window of the four-week matching method
+func (fc *FinancialCalculator) SetFourWeekDays(days int) {
+	fc.fourWeekDays = days
+}
+
+// newLotEngine creates a lot engine with the calculator's matching rules and Trading 212's exchange rates
+func (fc *FinancialCalculator) newLotEngine() *LotEngine {
+	engine := NewLotEngine(fc.baseCurrency, fc.matching, nil)
+	engine.SetFourWeekDays(fc.fourWeekDays)
+	return engine
+}
+
+// CalculateYearlyReports generates yearly financial reports from transactions. Capital gains come
+// from matching sells against purchased lots; Trading 212's Result column is kept as a cross-check.
 func (fc *FinancialCalculator) CalculateYearlyReports(transactions []types.Transaction) ([]types.YearlyReport, error) {
 	if len(transactions) == 0 {
 		return []types.YearlyReport{}, nil
@@ -37,9 +72,17 @@ func (fc *FinancialCalculator) CalculateYearlyReports(transactions []types.Trans
 	// Group transactions by year
 	yearlyTransactions := fc.groupTransactionsByYear(transactions)
 
+	ledger := fc.newLotEngine().Process(transactions)
+	yearlyDisposals := make(map[int][]Disposal)
+	for _, disposal := range ledger.Disposals {
+		year := disposal.Date.Year()
+		yearlyDisposals[year] = append(yearlyDisposals[year], disposal)
+	}
+
 	reports := make([]types.YearlyReport, 0, len(yearlyTransactions))
 	for year, yearTransactions := range yearlyTransactions {
 		report := fc.calculateYearlyReport(year, yearTransactions)
+		fc.addRealisedGains(report, yearlyDisposals[year])
 		reports = append(reports, *report)
 	}
 
@@ -48,9 +91,35 @@ func (fc *FinancialCalculator) CalculateYearlyReports(transactions []types.Trans
 		return reports[i].Year < reports[j].Year
 	})
 
+	fc.applyLossCarryForward(reports)
+
 	return reports, nil
 }
 
+// CalculateReturns measures time-weighted and money-weighted returns per year, overall and per
+// position up to the close of to, or of the last transaction when to is zero
+func (fc *FinancialCalculator) CalculateReturns(transactions []types.Transaction, to time.Time) *ReturnsReport {
+	portfolioCalc := NewPortfolioCalculator(fc.baseCurrency)
+	portfolioCalc.SetPriceProvider(fc.prices)
+	return portfolioCalc.CalculateReturns(transactions, to)
+}
+
+// CompareBenchmark compares the portfolio's returns per year and overall with the same deposits
+// and withdrawals invested in benchmark
+func (fc *FinancialCalculator) CompareBenchmark(transactions []types.Transaction, benchmark *Benchmark, to time.Time) (*BenchmarkReport, error) {
+	portfolioCalc := NewPortfolioCalculator(fc.baseCurrency)
+	portfolioCalc.SetPriceProvider(fc.prices)
+	return portfolioCalc.CompareBenchmark(transactions, benchmark, to)
+}
+
+// CalculateAllocation weighs the positions held at each year end by sector, country, currency,
+// asset class and domicile, with concentration warnings
+func (fc *FinancialCalculator) CalculateAllocation(transactions []types.Transaction, metadata *SecurityMetadataStore, limits ConcentrationLimits) *AllocationReport {
+	portfolioCalc := NewPortfolioCalculator(fc.baseCurrency)
+	portfolioCalc.SetPriceProvider(fc.prices)
+	return portfolioCalc.CalculateAllocation(transactions, metadata, limits)
+}
+
 // CalculateOverallReport generates an overall investment summary
 func (fc *FinancialCalculator) CalculateOverallReport(yearlyReports []types.YearlyReport) *types.OverallReport {
 	if len(yearlyReports) == 0 {
@@ -119,13 +188,16 @@ func (fc *FinancialCalculator) calculateYearlyReport(year int, transactions []ty
 			}
 
 		case types.TransactionTypeMarketSell, types.TransactionTypeLimitSell, types.TransactionTypeStopSell:
-			// For sells, we need to calculate capital gains
-			// This is a simplified approach - in reality, we'd need to track purchase prices
-			if transaction.Result != nil {
-				amount := fc.convertToBaseCurrency(*transaction.Result, transaction.CurrencyResult, transaction.ExchangeRate)
-				if amount > 0 {
-					report.CapitalGains += amount
-				}
+			// Gains on sells come from lot matching; the Result column is a cross-check, and the
+			// only figure available for sells without a share quantity
+			if transaction.Result == nil {
+				report.SellsWithoutResult++
+				continue
+			}
+			amount := fc.convertToBaseCurrency(*transaction.Result, transaction.CurrencyResult, transaction.ExchangeRate)
+			report.BrokerCapitalGains += amount
+			if transaction.Shares == nil || *transaction.Shares <= 0 {
+				report.CapitalGains += amount
 			}
 		default:
 			// Check for dividend transactions (handle different formats)
@@ -152,15 +224,60 @@ func (fc *FinancialCalculator) calculateYearlyReport(year int, transactions []ty
 		}
 	}
 
-	// Calculate total gains
+	fc.updateTotals(report)
+
+	return report
+}
+
+// addRealisedGains adds the year's matched disposals to the report and compares each with
+// Trading 212's Result
+func (fc *FinancialCalculator) addRealisedGains(report *types.YearlyReport, disposals []Disposal) {
+	for _, disposal := range disposals {
+		report.CapitalGains += disposal.GainLoss
+		if disposal.BrokerResult == nil {
+			continue
+		}
+
+		difference := disposal.GainLoss - *disposal.BrokerResult
+		report.CapitalGainsDiscrepancy += difference
+		if math.Abs(difference) >= GainDiscrepancyTolerance {
+			report.GainDiscrepancies = append(report.GainDiscrepancies, types.GainDiscrepancy{
+				Date:         disposal.Date,
+				Ticker:       disposal.Ticker,
+				ISIN:         disposal.ISIN,
+				Shares:       disposal.Shares,
+				GainLoss:     disposal.GainLoss,
+				BrokerResult: *disposal.BrokerResult,
+				Difference:   difference,
+			})
+		}
+	}
+	fc.updateTotals(report)
+}
+
+// updateTotals recalculates the report's total gains and percentage increase
+func (fc *FinancialCalculator) updateTotals(report *types.YearlyReport) {
 	report.TotalGains = report.CapitalGains + report.Dividends + report.Interest
 
-	// Calculate percentage increase
 	if report.TotalDeposits > 0 {
 		report.PercentageIncrease = (report.TotalGains / report.TotalDeposits) * PercentMultiplier
 	}
+}
 
-	return report
+// applyLossCarryForward fills in the losses brought forward, used and carried forward in reports
+// sorted by year
+func (fc *FinancialCalculator) applyLossCarryForward(reports []types.YearlyReport) {
+	if fc.lossCarryForward == nil {
+		return
+	}
+
+	ledger := NewLossLedger(*fc.lossCarryForward)
+	for i := range reports {
+		summary := ledger.Apply(LossBucketSecurities, reports[i].Year, reports[i].CapitalGains, 0)
+		reports[i].LossesBroughtForward = summary.BroughtForward
+		reports[i].LossesUsed = summary.Used
+		reports[i].LossesCarriedForward = summary.CarriedForward
+	}
 }
 
 // convertToBaseCurrency converts an amount to the base currency
@@ -336,9 +453,30 @@ func (fc *FinancialCalculator) CalculatePortfolioReports(files []string) (*types
 
 	// Create portfolio calculator
 	portfolioCalc := NewPortfolioCalculator(fc.baseCurrency)
+	portfolioCalc.SetPriceProvider(fc.prices)
 
 	// Calculate portfolio valuation
 	report := portfolioCalc.CalculatePortfolioValuation(result.Transactions)
 
 	return report, nil
 }
+
+// CalculatePortfolioAt calculates a valuation report holding the single portfolio at the close of date
+func (fc *FinancialCalculator) CalculatePortfolioAt(files []string, date time.Time) (*types.PortfolioValuationReport, error) {
+	csvParser := parser.NewCSVParser()
+	result, err := csvParser.ParseMultipleFiles(files)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSV files: %w", err)
+	}
+
+	portfolioCalc := NewPortfolioCalculator(fc.baseCurrency)
+	portfolioCalc.SetPriceProvider(fc.prices)
+
+	return &types.PortfolioValuationReport{
+		YearlyPortfolios: []types.PortfolioSummary{*portfolioCalc.PortfolioAt(result.Transactions, date)},
+		Currency:         fc.baseCurrency,
+		GeneratedAt:      time.Now(),
+		DataSource:       "Trading 212 CSV Export",
+		PriceNote:        portfolioCalc.priceNote(),
+	}, nil
+}
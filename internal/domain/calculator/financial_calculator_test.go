@@ -95,6 +95,53 @@ func TestFinancialCalculator_CalculateYearlyReports(t *testing.T) {
 	}
 }
 
+func TestFinancialCalculator_CalculateYearlyReportsMatchesLots(t *testing.T) {
+	calc := NewFinancialCalculator("EUR")
+
+	// A loss with a matching Result, a gain from an export without the Result column, and a
+	// gain where Trading 212's average-cost Result differs from FIFO matching
+	lossSell := tradeTx(types.TransactionTypeMarketSell, time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 80)
+	lossSell.Result = floatPtr(-200)
+	lossSell.CurrencyResult = stringPtr("EUR")
+	fifoSell := tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 9, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 150)
+	fifoSell.Result = floatPtr(250)
+	fifoSell.CurrencyResult = stringPtr("EUR")
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		lossSell,
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), "GB0002634946", 5, 10),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 2, 10, 10, 0, 0, 0, time.UTC), "GB0002634946", 5, 30),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 150),
+		fifoSell,
+	}
+
+	reports, err := calc.CalculateYearlyReports(transactions)
+	if err != nil {
+		t.Fatalf("CalculateYearlyReports() error = %v", err)
+	}
+
+	if reports[0].CapitalGains != -200 || reports[0].BrokerCapitalGains != -200 || len(reports[0].GainDiscrepancies) != 0 {
+		t.Errorf("Expected a 200 loss agreeing with the broker in 2023, got %+v", reports[0])
+	}
+
+	report := reports[1]
+	if report.CapitalGains != 600 || report.BrokerCapitalGains != 250 || report.SellsWithoutResult != 1 {
+		t.Errorf("Expected matched gains 600 against broker results 250 with one sell lacking a Result, got %+v", report)
+	}
+	if report.CapitalGainsDiscrepancy != 250 || len(report.GainDiscrepancies) != 1 || report.GainDiscrepancies[0].ISIN != "IE00B4L5Y983" {
+		t.Errorf("Expected one discrepancy of 250 on IE00B4L5Y983, got %+v", report.GainDiscrepancies)
+	}
+
+	// Average cost matching agrees with Trading 212
+	calc.SetMatchingMethod(MatchingAverageCost)
+	reports, _ = calc.CalculateYearlyReports(transactions)
+	if len(reports[1].GainDiscrepancies) != 0 || reports[1].CapitalGains != 350 {
+		t.Errorf("Expected average cost gains 350 without discrepancies, got %+v", reports[1])
+	}
+}
+
 func TestFinancialCalculator_CalculateOverallReport(t *testing.T) {
 	calc := NewFinancialCalculator("EUR")
 
@@ -0,0 +1,329 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// MaxFXLookbackDays is how far back a rate table looks for the last published rate
+	MaxFXLookbackDays = 7
+	// rateDateLayout is the date format used in rate files
+	rateDateLayout = "2006-01-02"
+)
+
+// FXQuote describes how a published rate relates the foreign and base currencies
+type FXQuote string
+
+const (
+	// QuoteForeignPerBase means one unit of base currency buys rate units of foreign currency (ECB style)
+	QuoteForeignPerBase FXQuote = "foreign_per_base"
+	// QuoteBasePerForeign means one unit of foreign currency costs rate units of base currency (NBP/BNB style)
+	QuoteBasePerForeign FXQuote = "base_per_foreign"
+)
+
+// FXLookup describes which published rate applies to a transaction date
+type FXLookup string
+
+const (
+	// LookupSameDay uses the rate of the transaction date, or the last one published before it
+	LookupSameDay FXLookup = "same_day"
+	// LookupPreviousBusinessDay uses the last rate published strictly before the transaction date
+	LookupPreviousBusinessDay FXLookup = "previous_business_day"
+)
+
+// FXSource describes where a jurisdiction takes its official exchange rates from
+type FXSource struct {
+	Name   string   `json:"name"`
+	Quote  FXQuote  `json:"quote"`
+	Lookup FXLookup `json:"lookup"`
+	// Pegged holds fixed rates for currencies pegged to the base currency, in the source's quote convention
+	Pegged map[string]float64 `json:"pegged,omitempty"`
+}
+
+// WithPegged returns rates with the source's pegged currencies fixed at their rates
+func (s FXSource) WithPegged(rates FXRateProvider) FXRateProvider {
+	currencies := make([]string, 0, len(s.Pegged))
+	for currency := range s.Pegged {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+
+	for _, currency := range currencies {
+		rate := s.Pegged[currency]
+		if s.Quote == QuoteBasePerForeign {
+			rate = 1 / rate
+		}
+		rates = NewPeggedRates(rates, currency, rate)
+	}
+	return rates
+}
+
+// FXRateProvider supplies exchange rates for converting amounts into a base currency
+type FXRateProvider interface {
+	// Rate returns the units of currency per one unit of base currency applicable on date
+	Rate(currency string, date time.Time) (float64, bool)
+}
+
+// datedRate is a single published rate
+type datedRate struct {
+	date time.Time
+	rate float64
+}
+
+// RateTable is an FXRateProvider backed by a table of published daily rates
+type RateTable struct {
+	baseCurrency string
+	lookup       FXLookup
+	rates        map[string][]datedRate
+}
+
+// NewRateTable creates an empty rate table for the given base currency
+func NewRateTable(baseCurrency string, lookup FXLookup) *RateTable {
+	if lookup == "" {
+		lookup = LookupSameDay
+	}
+	return &RateTable{
+		baseCurrency: baseCurrency,
+		lookup:       lookup,
+		rates:        make(map[string][]datedRate),
+	}
+}
+
+// Add records a rate expressed as units of currency per one unit of base currency
+func (rt *RateTable) Add(currency string, date time.Time, rate float64) {
+	currency = strings.ToUpper(currency)
+	day := truncateToDay(date)
+	rates := rt.rates[currency]
+
+	index := sort.Search(len(rates), func(i int) bool {
+		return !rates[i].date.Before(day)
+	})
+	if index < len(rates) && rates[index].date.Equal(day) {
+		rates[index].rate = rate
+		return
+	}
+
+	rates = append(rates, datedRate{})
+	copy(rates[index+1:], rates[index:])
+	rates[index] = datedRate{date: day, rate: rate}
+	rt.rates[currency] = rates
+}
+
+// Rate returns the applicable rate for currency on date
+func (rt *RateTable) Rate(currency string, date time.Time) (float64, bool) {
+	currency = strings.ToUpper(currency)
+	if currency == rt.baseCurrency {
+		return 1, true
+	}
+
+	rates := rt.rates[currency]
+	if len(rates) == 0 {
+		return 0, false
+	}
+
+	day := truncateToDay(date)
+	cutoff := day
+	if rt.lookup == LookupSameDay {
+		cutoff = day.AddDate(0, 0, 1)
+	}
+
+	// Find the last rate published strictly before cutoff
+	index := sort.Search(len(rates), func(i int) bool {
+		return !rates[i].date.Before(cutoff)
+	}) - 1
+	if index < 0 {
+		return 0, false
+	}
+
+	if day.Sub(rates[index].date) > MaxFXLookbackDays*24*time.Hour {
+		return 0, false
+	}
+
+	return rates[index].rate, true
+}
+
+// Currencies returns the currencies present in the table
+func (rt *RateTable) Currencies() []string {
+	currencies := make([]string, 0, len(rt.rates))
+	for currency := range rt.rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// LoadRateTable loads a rate table from a CSV file with date,currency,rate columns
+func LoadRateTable(filename, baseCurrency string, source FXSource) (*RateTable, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rate file %s: %w", filename, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	return ParseRateTable(file, baseCurrency, source)
+}
+
+// ParseRateTable reads date,currency,rate rows, interpreting rates according to the source quote convention
+func ParseRateTable(reader io.Reader, baseCurrency string, source FXSource) (*RateTable, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rate file: %w", err)
+	}
+
+	table := NewRateTable(baseCurrency, source.Lookup)
+
+	for i, record := range records {
+		if len(record) < 3 {
+			return nil, fmt.Errorf("rate file line %d: expected date,currency,rate", i+1)
+		}
+
+		date, err := time.Parse(rateDateLayout, strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("rate file line %d: invalid date %q", i+1, record[0])
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("rate file line %d: invalid rate %q", i+1, record[2])
+		}
+
+		if source.Quote == QuoteBasePerForeign {
+			rate = 1 / rate
+		}
+
+		table.Add(strings.TrimSpace(record[1]), date, rate)
+	}
+
+	return table, nil
+}
+
+// PeggedRates wraps a rate provider with a fixed rate for one currency
+type PeggedRates struct {
+	rates    FXRateProvider
+	currency string
+	rate     float64
+}
+
+// NewPeggedRates returns a provider that always uses rate for currency and delegates
+// all other currencies to rates, which may be nil
+func NewPeggedRates(rates FXRateProvider, currency string, rate float64) *PeggedRates {
+	return &PeggedRates{
+		rates:    rates,
+		currency: strings.ToUpper(currency),
+		rate:     rate,
+	}
+}
+
+// Rate returns the pegged rate or the underlying provider's rate
+func (pr *PeggedRates) Rate(currency string, date time.Time) (float64, bool) {
+	if strings.ToUpper(currency) == pr.currency {
+		return pr.rate, true
+	}
+	if pr.rates == nil {
+		return 0, false
+	}
+	return pr.rates.Rate(currency, date)
+}
+
+// currencyConverter converts transaction amounts into the base currency, preferring official rates.
+// The rate Trading 212 applied to a transaction is units of the instrument currency per unit of the
+// account currency, so it is only a fallback when the account currency is the base currency.
+type currencyConverter struct {
+	baseCurrency    string
+	accountCurrency string
+	rates           FXRateProvider
+	fallbacks       map[string]int
+	unconverted     map[string]int
+}
+
+// newCurrencyConverter creates a converter; rates may be nil to always use the broker rate, and an
+// empty accountCurrency is taken to be the base currency
+func newCurrencyConverter(baseCurrency, accountCurrency string, rates FXRateProvider) *currencyConverter {
+	if accountCurrency == "" {
+		accountCurrency = baseCurrency
+	}
+	return &currencyConverter{
+		baseCurrency:    baseCurrency,
+		accountCurrency: strings.ToUpper(accountCurrency),
+		rates:           rates,
+		fallbacks:       make(map[string]int),
+		unconverted:     make(map[string]int),
+	}
+}
+
+// convert converts amount in currency into the base currency on date, leaving it unconverted when
+// no applicable rate is known
+func (cc *currencyConverter) convert(amount float64, currency *string, date time.Time, brokerRate *float64) float64 {
+	value, _ := cc.convertOK(amount, currency, date, brokerRate)
+	return value
+}
+
+// convertOK converts amount in currency into the base currency on date. It returns amount and false
+// when there is no official rate and the broker rate does not convert into the base currency.
+func (cc *currencyConverter) convertOK(amount float64, currency *string, date time.Time, brokerRate *float64) (float64, bool) {
+	if amount == 0 || currency == nil || *currency == "" || *currency == cc.baseCurrency {
+		return amount, true
+	}
+
+	if cc.rates != nil {
+		if rate, ok := cc.rates.Rate(*currency, date); ok && rate > 0 {
+			return amount / rate, true
+		}
+	}
+
+	if cc.accountCurrency == cc.baseCurrency && !strings.EqualFold(*currency, cc.accountCurrency) &&
+		brokerRate != nil && *brokerRate > 0 {
+		if cc.rates != nil {
+			cc.fallbacks[*currency]++
+		}
+		return amount / *brokerRate, true
+	}
+
+	cc.unconverted[*currency]++
+	return amount, false
+}
+
+// warnings describes conversions that could not use an official rate
+func (cc *currencyConverter) warnings() []string {
+	warnings := make([]string, 0, len(cc.fallbacks)+len(cc.unconverted))
+	for _, currency := range sortedKeys(cc.fallbacks) {
+		warnings = append(warnings, fmt.Sprintf("no official %s rate for %d conversion(s); used Trading 212 exchange rate",
+			currency, cc.fallbacks[currency]))
+	}
+	for _, currency := range sortedKeys(cc.unconverted) {
+		warnings = append(warnings, fmt.Sprintf("no official %s rate for %d amount(s) and the Trading 212 rate converts into %s, not %s; "+
+			"left unconverted, give --fx-rates", currency, cc.unconverted[currency], cc.accountCurrency, cc.baseCurrency))
+	}
+	return warnings
+}
+
+// accountCurrency returns the currency Trading 212 books totals in, or "" when no row has one
+func accountCurrency(transactions []types.Transaction) string {
+	for _, tx := range transactions {
+		if currency := safeDeref(tx.CurrencyTotal); currency != "" {
+			return strings.ToUpper(currency)
+		}
+	}
+	return ""
+}
+
+// truncateToDay strips the time of day from t
+func truncateToDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
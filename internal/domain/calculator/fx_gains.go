@@ -0,0 +1,263 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// FXLot is foreign currency cash acquired on a date, at a cost in the base currency
+type FXLot struct {
+	Date     time.Time `json:"date"`
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	Cost     float64   `json:"cost"`
+}
+
+// FXDisposal is foreign currency cash spent, withdrawn or converted, matched first in, first out
+// against the lots it came from. Amounts in the base currency use the conversion's own rate
+// where the other side is the base currency, and otherwise the exchange rate on the day.
+type FXDisposal struct {
+	Date     time.Time `json:"date"`
+	Currency string    `json:"currency"`
+	Kind     CashKind  `json:"kind"`
+	Action   string    `json:"action"`
+	Amount   float64   `json:"amount"`
+	Proceeds float64   `json:"proceeds"`
+	Cost     float64   `json:"cost"`
+	GainLoss float64   `json:"gain_loss"`
+	// Unmatched is the part of Amount no lot was found for; it is given a cost equal to its
+	// proceeds, so no gain
+	Unmatched float64 `json:"unmatched,omitempty"`
+}
+
+// FXYear totals the realised currency gains and losses of a calendar year
+type FXYear struct {
+	Year      int     `json:"year"`
+	Gains     float64 `json:"gains"`
+	Losses    float64 `json:"losses"`
+	Net       float64 `json:"net"`
+	Disposals int     `json:"disposals"`
+}
+
+// FXGainsReport holds the realised gains and losses on foreign currency cash, per disposal and
+// per year, and the lots still held
+type FXGainsReport struct {
+	Currency  string         `json:"currency"`
+	Method    MatchingMethod `json:"method"`
+	Disposals []FXDisposal   `json:"disposals"`
+	Years     []FXYear       `json:"years"`
+	OpenLots  []FXLot        `json:"open_lots"`
+	Warnings  []string       `json:"warnings,omitempty"`
+}
+
+// Year returns the totals of year, which are zero when nothing was disposed of
+func (r *FXGainsReport) Year(year int) FXYear {
+	for _, totals := range r.Years {
+		if totals.Year == year {
+			return totals
+		}
+	}
+	return FXYear{Year: year}
+}
+
+// fxValuer values foreign currency amounts in the base currency
+type fxValuer struct {
+	rates FXRateProvider
+	// implied is the rate of the latest conversion to or from the base currency, per currency
+	implied  map[string]float64
+	unvalued map[string]int
+}
+
+// value converts amount of currency on date at the official rate, else the rate of the latest
+// conversion. Trading 212's own rates are into the account currency and are not used.
+func (v *fxValuer) value(amount float64, currency string, date time.Time) (float64, bool) {
+	if v.rates != nil {
+		if rate, ok := v.rates.Rate(currency, date); ok && rate > 0 {
+			return amount / rate, true
+		}
+	}
+	if rate := v.implied[currency]; rate > 0 {
+		return amount / rate, true
+	}
+	v.unvalued[currency]++
+	return 0, false
+}
+
+// CalculateFXGains replays the cash ledger and tracks each foreign currency balance as lots. Cash
+// received in a foreign currency opens a lot at its value in the base currency, and cash spent,
+// withdrawn or converted out of it is matched first in, first out against the lots.
+func (cc *CashCalculator) CalculateFXGains(transactions []types.Transaction) *FXGainsReport {
+	ledger := cc.BuildLedger(transactions)
+	report := &FXGainsReport{Currency: cc.baseCurrency, Method: MatchingFIFO}
+	valuer := &fxValuer{
+		rates:    cc.rates,
+		implied:  make(map[string]float64),
+		unvalued: make(map[string]int),
+	}
+	lots := make(map[string][]FXLot)
+	years := make(map[int]*FXYear)
+	unmatched := make(map[string]int)
+
+	dispose := func(entry CashEntry, proceeds float64) {
+		disposal := matchFXLots(lots, entry, proceeds)
+		if disposal.Unmatched > 0 {
+			unmatched[entry.Currency]++
+		}
+		report.Disposals = append(report.Disposals, disposal)
+
+		year := years[entry.Date.Year()]
+		if year == nil {
+			year = &FXYear{Year: entry.Date.Year()}
+			years[year.Year] = year
+		}
+		year.Disposals++
+		year.Net += disposal.GainLoss
+		if disposal.GainLoss > 0 {
+			year.Gains += disposal.GainLoss
+		} else {
+			year.Losses -= disposal.GainLoss
+		}
+	}
+	acquire := func(entry CashEntry, cost float64) {
+		lots[entry.Currency] = append(lots[entry.Currency], FXLot{
+			Date: entry.Date, Currency: entry.Currency, Amount: entry.Amount, Cost: cost,
+		})
+	}
+
+	entries := ledger.Entries
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+
+		// A conversion is booked as the amount out followed by the amount in
+		if entry.Kind == CashConversion && i+1 < len(entries) && entries[i+1].Kind == CashConversion {
+			from, to := entry, entries[i+1]
+			i++
+			value, ok := cc.conversionValue(valuer, from, to)
+			if !ok {
+				continue
+			}
+			if from.Currency != cc.baseCurrency {
+				dispose(from, value)
+			}
+			if to.Currency != cc.baseCurrency {
+				acquire(to, value)
+			}
+			continue
+		}
+
+		if entry.Currency == cc.baseCurrency {
+			continue
+		}
+		value, ok := valuer.value(math.Abs(entry.Amount), entry.Currency, entry.Date)
+		if !ok {
+			continue
+		}
+		if entry.Amount > 0 {
+			acquire(entry, value)
+		} else {
+			dispose(entry, value)
+		}
+	}
+
+	for _, year := range years {
+		report.Years = append(report.Years, *year)
+	}
+	sort.Slice(report.Years, func(i, j int) bool {
+		return report.Years[i].Year < report.Years[j].Year
+	})
+	for _, currencyLots := range lots {
+		report.OpenLots = append(report.OpenLots, currencyLots...)
+	}
+	sort.SliceStable(report.OpenLots, func(i, j int) bool {
+		return report.OpenLots[i].Date.Before(report.OpenLots[j].Date)
+	})
+	report.Warnings = fxWarnings(valuer.unvalued, unmatched)
+
+	return report
+}
+
+// conversionValue returns the base currency value of a conversion: the base side where there is
+// one, which also sets the rate implied for the foreign side, and otherwise the value of the
+// amount converted
+func (cc *CashCalculator) conversionValue(valuer *fxValuer, from, to CashEntry) (float64, bool) {
+	switch {
+	case from.Currency == cc.baseCurrency:
+		value := math.Abs(from.Amount)
+		if value > 0 {
+			valuer.implied[to.Currency] = to.Amount / value
+		}
+		return value, true
+	case to.Currency == cc.baseCurrency:
+		if to.Amount > 0 {
+			valuer.implied[from.Currency] = math.Abs(from.Amount) / to.Amount
+		}
+		return to.Amount, true
+	}
+	return valuer.value(math.Abs(from.Amount), from.Currency, from.Date)
+}
+
+// matchFXLots takes the amount of a disposal from the currency's oldest lots
+func matchFXLots(lots map[string][]FXLot, entry CashEntry, proceeds float64) FXDisposal {
+	amount := math.Abs(entry.Amount)
+	disposal := FXDisposal{
+		Date: entry.Date, Currency: entry.Currency, Kind: entry.Kind, Action: entry.Action,
+		Amount: amount, Proceeds: proceeds,
+	}
+
+	remaining := amount
+	queue := lots[entry.Currency]
+	for remaining > ShareEpsilon && len(queue) > 0 {
+		lot := &queue[0]
+		take := math.Min(lot.Amount, remaining)
+		cost := lot.Cost * take / lot.Amount
+		disposal.Cost += cost
+		lot.Cost -= cost
+		lot.Amount -= take
+		remaining -= take
+		if lot.Amount <= ShareEpsilon {
+			queue = queue[1:]
+		}
+	}
+	lots[entry.Currency] = queue
+
+	if remaining > ShareEpsilon {
+		disposal.Unmatched = remaining
+		disposal.Cost += proceeds * remaining / amount
+	}
+	disposal.GainLoss = disposal.Proceeds - disposal.Cost
+	return disposal
+}
+
+// fxWarnings describes movements that could not be valued and disposals without enough lots
+func fxWarnings(unvalued, unmatched map[string]int) []string {
+	var warnings []string
+	for _, currency := range sortedKeys(unvalued) {
+		warnings = append(warnings, fmt.Sprintf("no exchange rate for %d %s movement(s); they are left out", unvalued[currency], currency))
+	}
+	for _, currency := range sortedKeys(unmatched) {
+		warnings = append(warnings, fmt.Sprintf("%d %s disposal(s) exceed the lots held; an export may be missing", unmatched[currency], currency))
+	}
+	return warnings
+}
+
+// sortedKeys returns the keys of counts in order
+func sortedKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CalculateFXGains tracks foreign currency cash as lots and realises currency gains and losses in
+// currency, using the official exchange rates where set
+func (c *TaxCalculator) CalculateFXGains(transactions []types.Transaction, currency string) *FXGainsReport {
+	cash := NewCashCalculator(currency)
+	cash.SetFXRateProvider(c.rates)
+	return cash.CalculateFXGains(transactions)
+}
@@ -0,0 +1,122 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestCashCalculator_CalculateFXGains(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	convert := func(date time.Time, fromAmount float64, from string, toAmount float64, to string) types.Transaction {
+		return types.Transaction{
+			Action:                               "Currency conversion",
+			Time:                                 date,
+			CurrencyConversionFromAmount:         floatPtr(fromAmount),
+			CurrencyCurrencyConversionFromAmount: stringPtr(from),
+			CurrencyConversionToAmount:           floatPtr(toAmount),
+			CurrencyCurrencyConversionToAmount:   stringPtr(to),
+		}
+	}
+
+	transactions := []types.Transaction{
+		{Action: types.TransactionTypeDeposit, Time: day(2024, 1, 2), Total: floatPtr(2000), CurrencyTotal: stringPtr("EUR")},
+		convert(day(2024, 1, 5), 1000, "EUR", 1100, "USD"),
+		convert(day(2024, 2, 5), 500, "EUR", 500, "USD"),
+		// 1100 USD costing 1000 EUR and 220 of the 500 USD costing 220 EUR, sold for 1200 EUR
+		convert(day(2024, 3, 5), 1320, "USD", 1200, "EUR"),
+		{Action: "Dividend (Ordinary)", Time: day(2024, 4, 1), Total: floatPtr(10), CurrencyTotal: stringPtr("USD")},
+		// 280 USD costing 280 EUR, the dividend's 10 USD costing 8 EUR and 10 USD without a lot
+		{Action: "Card debit", Time: day(2025, 1, 10), Total: floatPtr(-300), CurrencyTotal: stringPtr("USD")},
+	}
+
+	rates := NewRateTable("EUR", LookupSameDay)
+	rates.Add("USD", day(2024, 4, 1), 1.25)
+	rates.Add("USD", day(2025, 1, 10), 1.0)
+
+	cash := NewCashCalculator("EUR")
+	cash.SetFXRateProvider(rates)
+	report := cash.CalculateFXGains(transactions)
+
+	if len(report.Disposals) != 2 {
+		t.Fatalf("Expected 2 disposals, got %+v", report.Disposals)
+	}
+	sold := report.Disposals[0]
+	if abs(sold.Proceeds-1200) > 1e-9 || abs(sold.Cost-1220) > 1e-9 || abs(sold.GainLoss+20) > 1e-9 {
+		t.Errorf("Expected the conversion back to realise -20 EUR on 1220 EUR cost, got %+v", sold)
+	}
+	spent := report.Disposals[1]
+	if abs(spent.Unmatched-10) > 1e-9 || abs(spent.Cost-298) > 1e-9 || abs(spent.GainLoss-2) > 1e-9 {
+		t.Errorf("Expected the card debit to realise 2 EUR with 10 USD unmatched, got %+v", spent)
+	}
+
+	y2024, y2025 := report.Year(2024), report.Year(2025)
+	if y2024.Disposals != 1 || abs(y2024.Losses-20) > 1e-9 || abs(y2024.Net+20) > 1e-9 {
+		t.Errorf("Expected a 20 EUR loss in 2024, got %+v", y2024)
+	}
+	if y2025.Disposals != 1 || abs(y2025.Gains-2) > 1e-9 || abs(y2025.Net-2) > 1e-9 {
+		t.Errorf("Expected a 2 EUR gain in 2025, got %+v", y2025)
+	}
+	if report.Year(2023).Disposals != 0 {
+		t.Errorf("Expected nothing in 2023, got %+v", report.Year(2023))
+	}
+
+	if len(report.OpenLots) != 0 {
+		t.Errorf("Expected no open lots, got %+v", report.OpenLots)
+	}
+	if len(report.Warnings) != 1 {
+		t.Errorf("Expected a warning about the unmatched disposal, got %v", report.Warnings)
+	}
+}
+
+func TestCashCalculator_CalculateFXGainsOpenLots(t *testing.T) {
+	date := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	transactions := []types.Transaction{
+		{
+			Action:                               "Currency conversion",
+			Time:                                 date,
+			CurrencyConversionFromAmount:         floatPtr(100),
+			CurrencyCurrencyConversionFromAmount: stringPtr("EUR"),
+			CurrencyConversionToAmount:           floatPtr(108),
+			CurrencyCurrencyConversionToAmount:   stringPtr("USD"),
+		},
+		// No official rate, so the buy is valued at the conversion's 1.08
+		{Action: types.TransactionTypeMarketBuy, Time: date.AddDate(0, 0, 1), Total: floatPtr(54), CurrencyTotal: stringPtr("USD")},
+	}
+
+	report := NewCashCalculator("EUR").CalculateFXGains(transactions)
+
+	if len(report.Disposals) != 1 || abs(report.Disposals[0].GainLoss) > 1e-9 {
+		t.Fatalf("Expected one disposal without gain, got %+v", report.Disposals)
+	}
+	if len(report.OpenLots) != 1 || abs(report.OpenLots[0].Amount-54) > 1e-9 || abs(report.OpenLots[0].Cost-50) > 1e-9 {
+		t.Errorf("Expected 54 USD costing 50 EUR left, got %+v", report.OpenLots)
+	}
+	if len(report.Warnings) != 0 {
+		t.Errorf("Expected no warnings, got %v", report.Warnings)
+	}
+}
+
+func TestCashCalculator_CalculateFXGainsIgnoresBrokerRate(t *testing.T) {
+	date := time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC)
+	sell := tradeTx(types.TransactionTypeMarketSell, date, "US0378331005", 1, 108)
+	sell.CurrencyPricePerShare, sell.ExchangeRate = stringPtr("USD"), floatPtr(1.08)
+	sell.Total, sell.CurrencyTotal = floatPtr(108), stringPtr("USD")
+	transactions := []types.Transaction{
+		sell,
+		{Action: "Card debit", Time: date.AddDate(0, 1, 0), Total: floatPtr(-50), CurrencyTotal: stringPtr("USD")},
+	}
+
+	// Without official rates or a conversion into BGN, USD cash has no value in BGN; the USD per
+	// EUR rate of the sale must not be used
+	report := NewCashCalculator("BGN").CalculateFXGains(transactions)
+	if len(report.Disposals) != 0 || len(report.OpenLots) != 0 {
+		t.Errorf("Expected nothing valued, got %+v and %+v", report.Disposals, report.OpenLots)
+	}
+	if len(report.Warnings) == 0 {
+		t.Error("Expected a warning for the unvalued movements")
+	}
+}
@@ -0,0 +1,89 @@
+package calculator
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseRateTable(t *testing.T) {
+	data := `date,currency,rate
+2024-03-01,USD,1.0800
+2024-03-04,USD,1.0850
+`
+	table, err := ParseRateTable(strings.NewReader(data), "EUR", FXSource{Quote: QuoteForeignPerBase, Lookup: LookupSameDay})
+	if err != nil {
+		t.Fatalf("ParseRateTable() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		date   time.Time
+		want   float64
+		wantOK bool
+	}{
+		{"exact date", time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC), 1.085, true},
+		{"weekend uses last published", time.Date(2024, 3, 3, 12, 0, 0, 0, time.UTC), 1.08, true},
+		{"before first rate", time.Date(2024, 2, 1, 12, 0, 0, 0, time.UTC), 0, false},
+		{"too stale", time.Date(2024, 4, 1, 12, 0, 0, 0, time.UTC), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := table.Rate("USD", tt.date)
+			if ok != tt.wantOK || abs(got-tt.want) > 1e-9 {
+				t.Errorf("Rate() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseRateTable_BasePerForeignPreviousDay(t *testing.T) {
+	data := `2024-03-01,USD,4.00
+2024-03-04,USD,4.10
+`
+	table, err := ParseRateTable(strings.NewReader(data), "PLN", FXSource{Quote: QuoteBasePerForeign, Lookup: LookupPreviousBusinessDay})
+	if err != nil {
+		t.Fatalf("ParseRateTable() error = %v", err)
+	}
+
+	// Transaction on 2024-03-04 uses the rate published on 2024-03-01
+	rate, ok := table.Rate("USD", time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC))
+	if !ok || abs(rate-0.25) > 1e-9 {
+		t.Errorf("Rate() = %v, %v, want 0.25, true", rate, ok)
+	}
+
+	if rate, _ := table.Rate("PLN", time.Now()); rate != 1 {
+		t.Errorf("Rate() for base currency = %v, want 1", rate)
+	}
+}
+
+func TestCurrencyConverter_BrokerRateOnlyIntoAccountCurrency(t *testing.T) {
+	date := time.Date(2024, 3, 4, 10, 0, 0, 0, time.UTC)
+	usd, eur := "USD", "EUR"
+	brokerRate := 1.08 // USD per EUR
+
+	converter := newCurrencyConverter("EUR", "EUR", nil)
+	if got, ok := converter.convertOK(108, &usd, date, &brokerRate); !ok || abs(got-100) > 1e-9 {
+		t.Errorf("convertOK() into the account currency = %v, %v, want 100, true", got, ok)
+	}
+
+	// A BGN report for a EUR account cannot use the USD per EUR broker rate
+	converter = newCurrencyConverter("BGN", "EUR", nil)
+	if got, ok := converter.convertOK(108, &usd, date, &brokerRate); ok || got != 108 {
+		t.Errorf("convertOK() of USD into BGN = %v, %v, want 108, false", got, ok)
+	}
+	if got, ok := converter.convertOK(50, &eur, date, &brokerRate); ok || got != 50 {
+		t.Errorf("convertOK() of account currency into BGN = %v, %v, want 50, false", got, ok)
+	}
+	if warnings := converter.warnings(); len(warnings) != 2 {
+		t.Errorf("warnings() = %v, want one per unconverted currency", warnings)
+	}
+
+	// The official rate converts both
+	pegged := NewPeggedRates(nil, "EUR", 1/1.95583)
+	converter = newCurrencyConverter("BGN", "EUR", pegged)
+	if got, ok := converter.convertOK(50, &eur, date, &brokerRate); !ok || abs(got-97.7915) > 1e-9 {
+		t.Errorf("convertOK() at the official rate = %v, %v, want 97.7915, true", got, ok)
+	}
+}
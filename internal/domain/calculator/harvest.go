@@ -0,0 +1,290 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// RepurchaseRule restricts buying back a security soon after selling it
+type RepurchaseRule struct {
+	Name string `yaml:"name" json:"name"`
+	// Days is the number of days after a sale in which buying back the same security affects it
+	Days int `yaml:"days" json:"days"`
+	// AppliesToGains is set when buying back also undoes a gain, as the UK 30-day rule matches the
+	// new shares to the sale; otherwise the rule only restricts losses
+	AppliesToGains bool `yaml:"applies_to_gains" json:"applies_to_gains,omitempty"`
+}
+
+// SafeRepurchaseDate returns the first day the security sold on date can be bought back without
+// the rule applying
+func (r *RepurchaseRule) SafeRepurchaseDate(date time.Time) time.Time {
+	date = truncateToDay(date)
+	if r == nil {
+		return date
+	}
+	return date.AddDate(0, 0, r.Days+1)
+}
+
+// HarvestLot is an open lot worth less than its cost
+type HarvestLot struct {
+	LotID       string    `json:"lot_id"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	Shares      float64   `json:"shares"`
+	Cost        float64   `json:"cost"`
+	Value       float64   `json:"value"`
+	Loss        float64   `json:"loss"`
+	HoldingDays int       `json:"holding_days"`
+}
+
+// HarvestCandidate is a position that would realise a loss if sold in full
+type HarvestCandidate struct {
+	ISIN          string    `json:"isin,omitempty"`
+	Ticker        string    `json:"ticker,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	Shares        float64   `json:"shares"`
+	Price         float64   `json:"price"`
+	PriceCurrency string    `json:"price_currency,omitempty"`
+	PriceSource   string    `json:"price_source"`
+	PriceDate     time.Time `json:"price_date,omitempty"`
+	Cost          float64   `json:"cost"`
+	Value         float64   `json:"value"`
+	// GainLoss is the loss realised by selling the whole position under the matching method
+	GainLoss  float64      `json:"gain_loss"`
+	LossLots  []HarvestLot `json:"loss_lots"`
+	TaxSaving float64      `json:"tax_saving"`
+	// SafeRepurchase is the first day the security can be bought back without the repurchase rule
+	SafeRepurchase time.Time `json:"safe_repurchase"`
+}
+
+// HarvestReport lists open positions whose losses could be realised against this tax year's gains
+type HarvestReport struct {
+	Jurisdiction   string             `json:"jurisdiction"`
+	Year           int                `json:"year"`
+	AsOf           time.Time          `json:"as_of"`
+	Method         MatchingMethod     `json:"method"`
+	Currency       string             `json:"currency"`
+	RepurchaseRule *RepurchaseRule    `json:"repurchase_rule,omitempty"`
+	NetGains       float64            `json:"net_gains"`
+	Allowance      float64            `json:"allowance"`
+	AllowanceLeft  float64            `json:"allowance_left"`
+	TaxDue         float64            `json:"tax_due"`
+	Candidates     []HarvestCandidate `json:"candidates"`
+	TotalLoss      float64            `json:"total_loss"`
+	// TotalSaving is the tax saved by selling every candidate, which can be less than the sum of
+	// the candidates' savings once gains are used up
+	TotalSaving float64  `json:"total_saving"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// FindHarvestOpportunities lists the positions held on asOf that would realise a loss if sold,
+// with the tax each sale would save in the tax year of asOf. prices holds current prices per share
+// in the security's trading currency, keyed by ticker or ISIN; securities without one are valued
+// at their latest trade price.
+func (c *TaxCalculator) FindHarvestOpportunities(
+	transactions []types.Transaction,
+	prices map[string]float64,
+	options types.ProcessingOptions,
+	asOf time.Time,
+) (*HarvestReport, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, 0)
+	if err != nil {
+		return nil, err
+	}
+	options.TaxYear = jurisdiction.TaxYearOf(asOf)
+	jurisdiction = jurisdiction.ForYear(options.TaxYear)
+	currency := c.reportingCurrency(jurisdiction, options)
+
+	held := transactionsUntil(transactions, asOf)
+
+	calculation, err := c.Calculate(held, options)
+	if err != nil {
+		return nil, err
+	}
+	netGains := calculation.NetGainLoss - calculation.LossesUsed
+
+	report := &HarvestReport{
+		Jurisdiction:   jurisdiction.Code,
+		Year:           options.TaxYear,
+		AsOf:           asOf,
+		Method:         jurisdiction.MatchingMethod,
+		Currency:       currency,
+		RepurchaseRule: jurisdiction.RepurchaseRule,
+		NetGains:       netGains,
+		Allowance:      jurisdiction.Allowances.CapitalGains,
+		AllowanceLeft:  math.Max(jurisdiction.Allowances.CapitalGains-math.Max(netGains, 0), 0),
+		TaxDue:         calculation.CapitalGainsTax,
+	}
+
+	engine := c.newLotEngine(jurisdiction, currency)
+	positions, keys := openPositions(engine.Process(held))
+
+	var sells []HypotheticalSell
+	for _, key := range keys {
+		candidate, sell, err := c.harvestCandidate(held, positions[key], key, prices, options, asOf)
+		if err != nil {
+			return nil, err
+		}
+		if candidate == nil {
+			continue
+		}
+		candidate.SafeRepurchase = jurisdiction.RepurchaseRule.SafeRepurchaseDate(asOf)
+		report.Candidates = append(report.Candidates, *candidate)
+		report.TotalLoss += candidate.GainLoss
+		sells = append(sells, sell)
+	}
+
+	if len(sells) > 0 {
+		simulation, err := c.Simulate(held, sells, options)
+		if err != nil {
+			return nil, err
+		}
+		report.TotalSaving = -simulation.MarginalTax
+		report.Warnings = simulation.Warnings
+	}
+
+	return report, nil
+}
+
+// harvestCandidate values a position at its current price and returns it with the sell that would
+// realise its loss, or nil when selling the whole position would not realise a loss
+func (c *TaxCalculator) harvestCandidate(
+	transactions []types.Transaction,
+	lots []*Lot,
+	key string,
+	prices map[string]float64,
+	options types.ProcessingOptions,
+	asOf time.Time,
+) (*HarvestCandidate, HypotheticalSell, error) {
+	first := lots[0]
+	candidate := &HarvestCandidate{ISIN: first.ISIN, Ticker: first.Ticker, Name: first.Name}
+	for _, lot := range lots {
+		candidate.Shares += lot.Remaining
+		candidate.Cost += lot.RemainingCost()
+	}
+
+	quote, found := c.positionPrice(transactions, key, candidate.Ticker, candidate.ISIN, prices, asOf)
+	if !found {
+		return nil, HypotheticalSell{}, nil
+	}
+	candidate.Price, candidate.PriceCurrency = quote.price, quote.currency
+	candidate.PriceSource, candidate.PriceDate = quote.source, quote.date
+
+	sell := HypotheticalSell{Security: key, Shares: candidate.Shares, Price: quote.price, Date: asOf}
+	simulation, err := c.Simulate(transactions, []HypotheticalSell{sell}, options)
+	if err != nil {
+		return nil, HypotheticalSell{}, err
+	}
+	disposal := simulation.Sells[0].Disposal
+	if disposal.GainLoss >= 0 {
+		return nil, HypotheticalSell{}, nil
+	}
+
+	valuePerShare := disposal.GrossProceeds / disposal.Shares
+	candidate.Value = disposal.GrossProceeds
+	candidate.GainLoss = disposal.GainLoss
+	candidate.TaxSaving = -simulation.MarginalTax
+	for _, lot := range lots {
+		value := lot.Remaining * valuePerShare
+		if value >= lot.RemainingCost() {
+			continue
+		}
+		candidate.LossLots = append(candidate.LossLots, HarvestLot{
+			LotID:       lot.ID,
+			AcquiredAt:  lot.AcquiredAt,
+			Shares:      lot.Remaining,
+			Cost:        lot.RemainingCost(),
+			Value:       value,
+			Loss:        value - lot.RemainingCost(),
+			HoldingDays: HoldingDays(lot.AcquiredAt, asOf),
+		})
+	}
+
+	return candidate, sell, nil
+}
+
+// transactionsUntil returns the transactions dated on or before date
+func transactionsUntil(transactions []types.Transaction, date time.Time) []types.Transaction {
+	var until []types.Transaction
+	for _, tx := range transactions {
+		if !tx.Time.After(date) {
+			until = append(until, tx)
+		}
+	}
+	return until
+}
+
+// openPositions groups the ledger's open lots by security, returning the security keys sorted
+func openPositions(ledger *LotLedger) (map[string][]*Lot, []string) {
+	positions := make(map[string][]*Lot)
+	var keys []string
+	for _, lot := range ledger.OpenLots() {
+		key := lot.ISIN
+		if key == "" {
+			key = lot.Ticker
+		}
+		if _, seen := positions[key]; !seen {
+			keys = append(keys, key)
+		}
+		positions[key] = append(positions[key], lot)
+	}
+	sort.Strings(keys)
+	return positions, keys
+}
+
+// positionQuote is the price per share a position is valued at
+type positionQuote struct {
+	price    float64
+	currency string
+	date     time.Time
+	source   string
+	// exchangeRate is the rate Trading 212 applied to the security's latest trade
+	exchangeRate *float64
+}
+
+// positionPrice returns the given price for the security's ticker or ISIN, falling back to its
+// closing price on asOf and then to the price of its latest trade, or false when none is known
+func (c *TaxCalculator) positionPrice(
+	transactions []types.Transaction,
+	key, ticker, isin string,
+	prices map[string]float64,
+	asOf time.Time,
+) (positionQuote, bool) {
+	var latest *types.Transaction
+	for i := range transactions {
+		tx := &transactions[i]
+		if !isTradeAction(tx.Action) || tx.PricePerShare == nil || *tx.PricePerShare <= 0 || SecurityKey(*tx) != key {
+			continue
+		}
+		if latest == nil || !tx.Time.Before(latest.Time) {
+			latest = tx
+		}
+	}
+	if latest == nil {
+		return positionQuote{}, false
+	}
+
+	quote := positionQuote{
+		price:        *latest.PricePerShare,
+		currency:     safeDeref(latest.CurrencyPricePerShare),
+		date:         latest.Time,
+		source:       PriceSourceLastTrade,
+		exchangeRate: latest.ExchangeRate,
+	}
+	for _, security := range []string{ticker, isin} {
+		if given, ok := prices[strings.ToUpper(security)]; ok && security != "" && given > 0 {
+			quote.price, quote.date, quote.source = given, asOf, PriceSourceGiven
+			return quote, true
+		}
+	}
+	if closing, ok := closingPrice(c.prices, isin, ticker, asOf); ok && !closing.Date.Before(truncateToDay(quote.date)) {
+		quote.price, quote.date, quote.source = closing.Price, closing.Date, closing.Source
+		if closing.Currency != "" && closing.Currency != quote.currency {
+			quote.currency, quote.exchangeRate = closing.Currency, nil
+		}
+	}
+	return quote, true
+}
@@ -0,0 +1,86 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_FindHarvestOpportunities(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, day(1, 10), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, day(2, 1), "US5949181045", 10, 50),
+		tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US5949181045", 10, 150),
+		tradeTx(types.TransactionTypeMarketBuy, day(3, 5), "IE00B4L5Y983", 5, 10),
+		tradeTx(types.TransactionTypeMarketBuy, day(4, 1), "DE0007164600", 10, 20),
+		tradeTx(types.TransactionTypeMarketBuy, day(5, 1), "DE0007164600", 1, 15),
+		// After the as-of date and ignored
+		tradeTx(types.TransactionTypeMarketSell, day(7, 1), "US0378331005", 10, 60),
+	}
+
+	asOf := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	report, err := calc.FindHarvestOpportunities(transactions, map[string]float64{"1005": 60},
+		types.ProcessingOptions{Jurisdiction: "LT"}, asOf)
+	if err != nil {
+		t.Fatalf("FindHarvestOpportunities() error = %v", err)
+	}
+
+	// 1000 realised uses up the 500 allowance and leaves 75 of tax
+	if report.Year != 2024 || report.NetGains != 1000 || report.AllowanceLeft != 0 || abs(report.TaxDue-75) > 0.001 {
+		t.Errorf("Expected 1000 of gains, no allowance left and 75 of tax, got %+v", report)
+	}
+	if len(report.Candidates) != 2 {
+		t.Fatalf("Expected 2 candidates, got %+v", report.Candidates)
+	}
+
+	// DE0007164600 is valued at its last trade price of 15, below the first lot's cost of 20
+	siemens := report.Candidates[0]
+	if siemens.ISIN != "DE0007164600" || siemens.PriceSource != PriceSourceLastTrade || abs(siemens.GainLoss+50) > 0.001 {
+		t.Errorf("Expected a loss of 50 at the last trade price, got %+v", siemens)
+	}
+	if len(siemens.LossLots) != 1 || abs(siemens.LossLots[0].Loss+50) > 0.001 || abs(siemens.TaxSaving-7.5) > 0.001 {
+		t.Errorf("Expected one lot losing 50 saving 7.50, got %+v", siemens)
+	}
+
+	apple := report.Candidates[1]
+	if apple.PriceSource != PriceSourceGiven || abs(apple.GainLoss+400) > 0.001 || abs(apple.TaxSaving-60) > 0.001 {
+		t.Errorf("Expected a loss of 400 saving 60 at the given price, got %+v", apple)
+	}
+
+	if abs(report.TotalLoss+450) > 0.001 || abs(report.TotalSaving-67.5) > 0.001 {
+		t.Errorf("Expected a total loss of 450 saving 67.50, got %+v", report)
+	}
+
+	// Lithuania does not restrict buying back
+	if report.RepurchaseRule != nil || !apple.SafeRepurchase.Equal(asOf) {
+		t.Errorf("Expected the shares to be safe to buy back at once, got %v", apple.SafeRepurchase)
+	}
+}
+
+func TestRepurchaseRule_SafeRepurchaseDate(t *testing.T) {
+	calc := NewTaxCalculator()
+	sold := time.Date(2024, 6, 1, 15, 0, 0, 0, time.UTC)
+
+	expected := map[string]time.Time{
+		"UK": time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC),
+		"US": time.Date(2024, 7, 2, 0, 0, 0, 0, time.UTC),
+		"IE": time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC),
+		"DE": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+	for code, want := range expected {
+		jurisdiction, _ := calc.GetJurisdiction(code)
+		if got := jurisdiction.RepurchaseRule.SafeRepurchaseDate(sold); !got.Equal(want) {
+			t.Errorf("%s: expected safe repurchase on %v, got %v", code, want, got)
+		}
+	}
+
+	if _, err := ParseJurisdiction([]byte("version: 1\ncode: XX\ncurrency: EUR\nrepurchase_rule: {days: 0}\nyears:\n  2024: {}\n")); err == nil {
+		t.Error("Expected error for a repurchase rule without days")
+	}
+}
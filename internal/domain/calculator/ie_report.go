@@ -0,0 +1,359 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// IEInstrumentType selects the Irish tax regime of an instrument
+type IEInstrumentType string
+
+// Irish instrument regimes
+const (
+	IEInstrumentShare IEInstrumentType = "share"    // Chargeable to CGT
+	IEInstrumentFund  IEInstrumentType = "exit_tax" // EU/EEA-domiciled fund subject to exit tax
+)
+
+// ieFundDomiciles are the EU/EEA ISIN prefixes whose funds fall under the exit tax regime
+var ieFundDomiciles = map[string]bool{
+	"AT": true, "BE": true, "BG": true, "CY": true, "CZ": true, "DE": true, "DK": true, "EE": true,
+	"ES": true, "FI": true, "FR": true, "GR": true, "HR": true, "HU": true, "IE": true, "IS": true,
+	"IT": true, "LI": true, "LT": true, "LU": true, "LV": true, "MT": true, "NL": true, "NO": true,
+	"PL": true, "PT": true, "RO": true, "SE": true, "SI": true, "SK": true,
+}
+
+// IEOptions holds instrument classification overrides keyed by ISIN
+type IEOptions struct {
+	Instruments map[string]IEInstrumentType `json:"instruments,omitempty"`
+}
+
+// ClassifyInstrument returns the override for isin, or treats ETFs with an EU/EEA ISIN prefix
+// as exit tax funds and everything else as shares
+func (o IEOptions) ClassifyInstrument(isin, name string) IEInstrumentType {
+	if instrumentType, exists := o.Instruments[isin]; exists {
+		return instrumentType
+	}
+	upper := strings.ToUpper(name)
+	if ieFundDomiciles[CountryFromISIN(isin)] && (strings.Contains(upper, "ETF") || strings.Contains(upper, "UCITS")) {
+		return IEInstrumentFund
+	}
+	return IEInstrumentShare
+}
+
+// IERestrictedLoss is a loss restricted by the four-week rule because the shares were bought back
+type IERestrictedLoss struct {
+	ISIN          string    `json:"isin"`
+	Date          time.Time `json:"date"`
+	Loss          float64   `json:"loss"`
+	RepurchasedAt time.Time `json:"repurchased_at"`
+}
+
+// IEDeemedDisposal is an eight-year deemed disposal of fund units
+type IEDeemedDisposal struct {
+	LotID       string    `json:"lot_id"`
+	ISIN        string    `json:"isin"`
+	Name        string    `json:"name"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	Date        time.Time `json:"date"`
+	Shares      float64   `json:"shares"`
+	Cost        float64   `json:"cost"`
+	MarketValue float64   `json:"market_value"`
+	Gain        float64   `json:"gain"`
+	PriorTax    float64   `json:"prior_tax"`
+	Tax         float64   `json:"tax"`
+}
+
+// IEReport holds the Irish CGT and exit tax computation for a year
+type IEReport struct {
+	Year                   int                `json:"year"`
+	Currency               string             `json:"currency"`
+	Gains                  float64            `json:"gains"`
+	Losses                 float64            `json:"losses"`
+	RestrictedLosses       []IERestrictedLoss `json:"restricted_losses,omitempty"`
+	ReleasedLosses         float64            `json:"released_losses"`
+	NetGain                float64            `json:"net_gain"`
+	LossesBroughtForward   float64            `json:"losses_brought_forward"`
+	LossesUsed             float64            `json:"losses_used"`
+	LossesCarriedForward   float64            `json:"losses_carried_forward"`
+	Exemption              float64            `json:"exemption"`
+	TaxableGain            float64            `json:"taxable_gain"`
+	CapitalGainsTaxRate    float64            `json:"capital_gains_tax_rate"`
+	CapitalGainsTax        float64            `json:"capital_gains_tax"`
+	FundGains              float64            `json:"fund_gains"`
+	FundLosses             float64            `json:"fund_losses"`
+	DeemedDisposalCredit   float64            `json:"deemed_disposal_credit"`
+	ExitTaxOnDisposals     float64            `json:"exit_tax_on_disposals"`
+	FundDistributions      float64            `json:"fund_distributions"`
+	ExitTaxOnDistributions float64            `json:"exit_tax_on_distributions"`
+	DeemedDisposals        []IEDeemedDisposal `json:"deemed_disposals,omitempty"`
+	DeemedDisposalTax      float64            `json:"deemed_disposal_tax"`
+	ExitTax                float64            `json:"exit_tax"`
+	ShareDividends         float64            `json:"share_dividends"`
+	TotalTax               float64            `json:"total_tax"`
+	ShareDisposals         []Disposal         `json:"share_disposals"`
+	FundDisposals          []Disposal         `json:"fund_disposals"`
+	Warnings               []string           `json:"warnings,omitempty"`
+}
+
+// GenerateIEReport computes Irish CGT on shares, applying the four-week rule and annual exemption,
+// and exit tax on EU/EEA funds including eight-year deemed disposals
+func (c *TaxCalculator) GenerateIEReport(transactions []types.Transaction, year int, options IEOptions) (*IEReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("IE", year)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IEReport{
+		Year:     year,
+		Currency: jurisdiction.Currency,
+	}
+
+	from, to := taxYearBounds(year)
+	engine := c.newLotEngine(jurisdiction, jurisdiction.Currency)
+	ledger := engine.Process(filterBefore(transactions, to))
+	report.Warnings = ledger.Warnings
+
+	prices := newTradePriceIndex(transactions, newCurrencyConverter(jurisdiction.Currency, accountCurrency(transactions), c.ratesFor(jurisdiction, jurisdiction.Currency)), c.prices)
+	classify := options.ClassifyInstrument
+
+	// Losses restricted by the four-week rule only offset later gains on the same shares
+	restricted := make(map[string]float64)
+	nets := make(map[int]float64)
+	for _, disposal := range ledger.Disposals {
+		key := disposalKey(disposal)
+		inYear := !disposal.Date.Before(from)
+
+		if classify(disposal.ISIN, disposal.Name) == IEInstrumentFund {
+			if inYear {
+				report.FundDisposals = append(report.FundDisposals, disposal)
+				report.addFundDisposal(ledger, disposal, prices, key, jurisdiction)
+			}
+			continue
+		}
+
+		gain := disposal.GainLoss
+		if gain > 0 && restricted[key] > 0 {
+			release := math.Min(restricted[key], gain)
+			restricted[key] -= release
+			gain -= release
+			if inYear {
+				report.ReleasedLosses += release
+			}
+		}
+		if gain < 0 {
+			if repurchase, found := repurchaseWithin(transactions, key, disposal.Date, jurisdiction.RepurchaseRule.Days); found {
+				restricted[key] += -gain
+				if inYear {
+					report.RestrictedLosses = append(report.RestrictedLosses, IERestrictedLoss{
+						ISIN:          key,
+						Date:          disposal.Date,
+						Loss:          -gain,
+						RepurchasedAt: repurchase,
+					})
+				}
+				gain = 0
+			}
+		}
+
+		nets[jurisdiction.TaxYearOf(disposal.Date)] += gain
+		if !inYear {
+			continue
+		}
+		report.ShareDisposals = append(report.ShareDisposals, disposal)
+		if gain > 0 {
+			report.Gains += gain
+		} else {
+			report.Losses += -gain
+		}
+	}
+
+	report.addDeemedDisposals(ledger, prices, year, classify, jurisdiction)
+
+	for _, record := range c.dividendRecords(transactions, jurisdiction, jurisdiction.Currency) {
+		if record.Date.Year() != year {
+			continue
+		}
+		if classify(record.ISIN, record.Name) == IEInstrumentFund {
+			report.FundDistributions += record.Amount
+		} else {
+			report.ShareDividends += record.Amount
+		}
+	}
+	report.ExitTaxOnDistributions = report.FundDistributions * jurisdiction.ExitTaxRate
+
+	report.NetGain = report.Gains - report.Losses
+
+	// Share losses of earlier years reduce the gain before the annual exemption
+	losses := lossesForYear(jurisdiction, nets, year)
+	report.LossesBroughtForward = losses.BroughtForward
+	report.LossesUsed = losses.Used
+	report.LossesCarriedForward = losses.CarriedForward
+
+	netAfterLosses := report.NetGain - report.LossesUsed
+	report.Exemption = math.Min(math.Max(netAfterLosses, 0), jurisdiction.Allowances.CapitalGains)
+	report.TaxableGain = math.Max(netAfterLosses-report.Exemption, 0)
+	report.CapitalGainsTaxRate = jurisdiction.CapitalGainsTaxRate
+	report.CapitalGainsTax = report.TaxableGain * report.CapitalGainsTaxRate
+
+	report.ExitTax = report.ExitTaxOnDisposals + report.ExitTaxOnDistributions + report.DeemedDisposalTax
+	report.TotalTax = report.CapitalGainsTax + report.ExitTax
+
+	if report.ShareDividends > 0 {
+		report.Warnings = append(report.Warnings, "dividends from shares are taxed at marginal income tax rates and are not included in the total")
+	}
+	if len(report.DeemedDisposals) > 0 || report.DeemedDisposalCredit > 0 {
+		report.Warnings = append(report.Warnings, "deemed disposal values are estimated from trade prices; check them against the fund's published prices")
+	}
+
+	return report, nil
+}
+
+// addFundDisposal applies exit tax to a fund disposal, crediting tax paid on earlier deemed disposals.
+// Losses on funds cannot be offset against other gains.
+func (r *IEReport) addFundDisposal(ledger *LotLedger, disposal Disposal, prices *tradePriceIndex, key string, jurisdiction TaxJurisdiction) {
+	if disposal.GainLoss > 0 {
+		r.FundGains += disposal.GainLoss
+	} else {
+		r.FundLosses += -disposal.GainLoss
+	}
+
+	for _, match := range disposal.Matches {
+		credit := 0.0
+		if lot := ledger.Lot(match.LotID); lot != nil {
+			credit = match.Shares * ieDeemedTaxPerShare(prices, key, lot, disposal.Date, jurisdiction)
+		}
+		tax := math.Max(match.GainLoss, 0) * jurisdiction.ExitTaxRate
+		r.DeemedDisposalCredit += math.Min(credit, tax)
+		if credit > tax {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("%s: deemed disposal tax of %.2f exceeds exit tax on disposal; claim the excess %.2f %s as a refund",
+				key, credit, credit-tax, jurisdiction.Currency))
+		}
+		r.ExitTaxOnDisposals += math.Max(tax-credit, 0)
+	}
+}
+
+// addDeemedDisposals records the eighth-anniversary deemed disposals of fund lots falling in year,
+// taxed at the exit tax rate
+func (r *IEReport) addDeemedDisposals(ledger *LotLedger, prices *tradePriceIndex, year int, classify func(isin, name string) IEInstrumentType, jurisdiction TaxJurisdiction) {
+	interval, rate := jurisdiction.DeemedDisposalYears, jurisdiction.ExitTaxRate
+	if interval <= 0 {
+		return
+	}
+	for _, lot := range ledger.Lots {
+		if classify(lot.ISIN, lot.Name) != IEInstrumentFund {
+			continue
+		}
+		key := lot.ISIN
+		if key == "" {
+			key = lot.Ticker
+		}
+
+		for anniversary := lot.AcquiredAt.AddDate(interval, 0, 0); anniversary.Year() <= year; anniversary = anniversary.AddDate(interval, 0, 0) {
+			if anniversary.Year() < year {
+				continue
+			}
+
+			shares := sharesInLotAt(ledger, lot, anniversary)
+			if shares <= ShareEpsilon {
+				break
+			}
+
+			price, _, ok := prices.priceAt(key, anniversary.AddDate(0, 0, 1))
+			if !ok {
+				r.Warnings = append(r.Warnings, fmt.Sprintf("%s: no price for deemed disposal on %s", lot.ID, anniversary.Format(rateDateLayout)))
+				break
+			}
+
+			cost := shares * lot.CostPerShare() * lot.SplitRatio(anniversary, time.Time{})
+			value := shares * price
+			priorTax := shares * ieDeemedTaxPerShare(prices, key, lot, anniversary, jurisdiction)
+			tax := math.Max(math.Max(value-cost, 0)*rate-priorTax, 0)
+
+			r.DeemedDisposals = append(r.DeemedDisposals, IEDeemedDisposal{
+				LotID:       lot.ID,
+				ISIN:        lot.ISIN,
+				Name:        lot.Name,
+				AcquiredAt:  lot.AcquiredAt,
+				Date:        anniversary,
+				Shares:      shares,
+				Cost:        cost,
+				MarketValue: value,
+				Gain:        value - cost,
+				PriorTax:    priorTax,
+				Tax:         tax,
+			})
+			r.DeemedDisposalTax += tax
+		}
+	}
+
+	sort.SliceStable(r.DeemedDisposals, func(i, j int) bool {
+		return r.DeemedDisposals[i].Date.Before(r.DeemedDisposals[j].Date)
+	})
+}
+
+// ieDeemedTaxPerShare returns the exit tax per share of lot held at date already paid on the
+// jurisdiction's deemed disposals before date. Each deemed disposal taxes the gain since acquisition
+// less the tax paid on earlier ones, so the cumulative tax is the largest tax due at any anniversary.
+func ieDeemedTaxPerShare(prices *tradePriceIndex, key string, lot *Lot, before time.Time, jurisdiction TaxJurisdiction) float64 {
+	interval := jurisdiction.DeemedDisposalYears
+	if interval <= 0 {
+		return 0
+	}
+
+	paid := 0.0
+	for anniversary := lot.AcquiredAt.AddDate(interval, 0, 0); anniversary.Before(before); anniversary = anniversary.AddDate(interval, 0, 0) {
+		if price, _, ok := prices.priceAt(key, anniversary.AddDate(0, 0, 1)); ok {
+			// Prices and cost at the anniversary are per share before any later splits
+			costPerShare := lot.CostPerShare() * lot.SplitRatio(anniversary, time.Time{})
+			tax := math.Max(price-costPerShare, 0) * jurisdiction.ExitTaxRate / lot.SplitRatio(anniversary, before)
+			paid = math.Max(paid, tax)
+		}
+	}
+	return paid
+}
+
+// sharesInLotAt returns the shares of lot still held at date, counted before the splits from date on.
+// Matches are counted in shares at their disposal, so splits between a disposal and date rescale them.
+func sharesInLotAt(ledger *LotLedger, lot *Lot, date time.Time) float64 {
+	shares := lot.Shares / lot.SplitRatio(date, time.Time{})
+	for _, disposal := range ledger.Disposals {
+		if !disposal.Date.Before(date) {
+			continue
+		}
+		for _, match := range disposal.Matches {
+			if match.LotID == lot.ID {
+				shares -= match.Shares * lot.SplitRatio(disposal.Date, date)
+			}
+		}
+	}
+	return shares
+}
+
+// repurchaseWithin returns the first purchase of key within days after date
+func repurchaseWithin(transactions []types.Transaction, key string, date time.Time, days int) (time.Time, bool) {
+	var first time.Time
+	found := false
+	for _, tx := range transactions {
+		if !isBuyAction(tx.Action) || SecurityKey(tx) != key || !tx.Time.After(date) || HoldingDays(date, tx.Time) > days {
+			continue
+		}
+		if !found || tx.Time.Before(first) {
+			first = tx.Time
+			found = true
+		}
+	}
+	return first, found
+}
+
+// disposalKey identifies the security of a disposal the same way SecurityKey does for transactions
+func disposalKey(disposal Disposal) string {
+	if disposal.ISIN != "" {
+		return disposal.ISIN
+	}
+	return disposal.Ticker
+}
@@ -0,0 +1,126 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateIEReport(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	etf := func(action types.TransactionType, date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(action, date, "IE00BK5BQT80", shares, price)
+		tx.Name = stringPtr("Vanguard FTSE All-World UCITS ETF")
+		return tx
+	}
+
+	transactions := []types.Transaction{
+		// Shares bought in the four weeks before a sale are matched first
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 1, 10), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 3, 1), "US0378331005", 10, 50),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 3, 15), "US0378331005", 10, 60),
+		// A loss followed by a repurchase within four weeks only offsets the later gain
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 1, 10), "US5949181045", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 5, 1), "US5949181045", 10, 80),
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 5, 10), "US5949181045", 10, 81),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 9, 1), "US5949181045", 10, 120),
+		tradeTx(types.TransactionTypeMarketBuy, day(2023, 1, 10), "US0231351067", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 2, 1), "US0231351067", 10, 400),
+		// Fund units reach their eighth anniversary in 2024
+		etf(types.TransactionTypeMarketBuy, day(2016, 6, 1), 10, 50),
+		etf(types.TransactionTypeMarketBuy, day(2024, 5, 20), 1, 100),
+	}
+
+	report, err := calc.GenerateIEReport(transactions, 2024, IEOptions{})
+	if err != nil {
+		t.Fatalf("GenerateIEReport() error = %v", err)
+	}
+
+	if abs(report.Gains-3290) > 0.001 || report.Losses != 0 {
+		t.Errorf("Expected gains 3290 and no losses, got %.2f and %.2f", report.Gains, report.Losses)
+	}
+	if len(report.RestrictedLosses) != 1 || abs(report.RestrictedLosses[0].Loss-200) > 0.001 {
+		t.Errorf("Expected one restricted loss of 200, got %+v", report.RestrictedLosses)
+	}
+	if abs(report.ReleasedLosses-200) > 0.001 {
+		t.Errorf("Expected 200 of released losses, got %.2f", report.ReleasedLosses)
+	}
+	// 33% CGT after the 1,270 exemption, and 41% exit tax on funds
+	if report.Exemption != 1270 || report.CapitalGainsTaxRate != 0.33 || abs(report.CapitalGainsTax-2020*0.33) > 0.001 {
+		t.Errorf("Expected exemption 1270 and CGT %.2f, got %.2f and %.2f",
+			2020*0.33, report.Exemption, report.CapitalGainsTax)
+	}
+
+	if len(report.DeemedDisposals) != 1 {
+		t.Fatalf("Expected 1 deemed disposal, got %d", len(report.DeemedDisposals))
+	}
+	deemed := report.DeemedDisposals[0]
+	if abs(deemed.Gain-500) > 0.001 || abs(deemed.Tax-500*0.41) > 0.001 {
+		t.Errorf("Unexpected deemed disposal: %+v", deemed)
+	}
+	if abs(report.TotalTax-(2020*0.33+500*0.41)) > 0.001 {
+		t.Errorf("Unexpected total tax %.2f", report.TotalTax)
+	}
+}
+
+func TestTaxCalculator_GenerateIEReport_DeemedDisposalAfterSplit(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	etf := func(action types.TransactionType, date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(action, date, "IE00BK5BQT80", shares, price)
+		tx.Name = stringPtr("Vanguard FTSE All-World UCITS ETF")
+		return tx
+	}
+
+	transactions := []types.Transaction{
+		etf(types.TransactionTypeMarketBuy, day(2016, 6, 1), 10, 50),
+		etf(types.TransactionTypeMarketSell, day(2018, 3, 1), 4, 60),
+		// A 2:1 split of the 6 units left
+		etf(types.TransactionTypeSplitClose, day(2020, 7, 1), 6, 80),
+		etf(types.TransactionTypeSplitOpen, day(2020, 7, 1), 12, 40),
+		etf(types.TransactionTypeMarketBuy, day(2024, 5, 20), 1, 100),
+	}
+
+	report, err := calc.GenerateIEReport(transactions, 2024, IEOptions{})
+	if err != nil {
+		t.Fatalf("GenerateIEReport() error = %v", err)
+	}
+
+	if len(report.DeemedDisposals) != 1 {
+		t.Fatalf("Expected 1 deemed disposal, got %+v", report.DeemedDisposals)
+	}
+	// The 4 units sold before the split are 8 units after it, leaving 12 at 25 each
+	deemed := report.DeemedDisposals[0]
+	if abs(deemed.Shares-12) > 0.001 || abs(deemed.Cost-300) > 0.001 || abs(deemed.Gain-900) > 0.001 {
+		t.Errorf("Expected 12 units with cost 300 and gain 900, got %+v", deemed)
+	}
+}
+
+func TestIEOptions_ClassifyInstrument(t *testing.T) {
+	options := IEOptions{Instruments: map[string]IEInstrumentType{"IE00B3XXRP09": IEInstrumentShare}}
+
+	tests := []struct {
+		isin     string
+		name     string
+		expected IEInstrumentType
+	}{
+		{"IE00BK5BQT80", "Vanguard FTSE All-World UCITS ETF", IEInstrumentFund},
+		{"US78462F1030", "SPDR S&P 500 ETF Trust", IEInstrumentShare},
+		{"IE00B4BNMY34", "Accenture", IEInstrumentShare},
+		{"IE00B3XXRP09", "Vanguard S&P 500 UCITS ETF", IEInstrumentShare},
+	}
+
+	for _, tt := range tests {
+		if got := options.ClassifyInstrument(tt.isin, tt.name); got != tt.expected {
+			t.Errorf("ClassifyInstrument(%s) = %s, want %s", tt.isin, got, tt.expected)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package calculator
 import (
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
 )
@@ -17,6 +18,7 @@ const (
 // IncomeCalculator handles dividend and interest calculations and reporting
 type IncomeCalculator struct {
 	baseCurrency string
+	rates        FXRateProvider
 }
 
 // NewIncomeCalculator creates a new income calculator
@@ -26,6 +28,11 @@ func NewIncomeCalculator(baseCurrency string) *IncomeCalculator {
 	}
 }
 
+// SetFXRateProvider sets official exchange rates used instead of Trading 212's rates
+func (ic *IncomeCalculator) SetFXRateProvider(rates FXRateProvider) {
+	ic.rates = rates
+}
+
 // CalculateIncomeReport generates comprehensive income report from transactions
 func (ic *IncomeCalculator) CalculateIncomeReport(transactions []types.Transaction) (*types.IncomeReport, error) {
 	if len(transactions) == 0 {
@@ -118,14 +125,25 @@ func (ic *IncomeCalculator) extractDividendRecords(transactions []types.Transact
 			record.Name = *tx.Name
 		}
 
+		// Get the gross dividend per share in the paying security's currency
+		if tx.Shares != nil {
+			record.Shares = *tx.Shares
+		}
+		if tx.PricePerShare != nil {
+			record.PricePerShare = *tx.PricePerShare
+		}
+		if tx.CurrencyPricePerShare != nil {
+			record.PriceCurrency = *tx.CurrencyPricePerShare
+		}
+
 		// Calculate net amount
 		record.NetAmount = record.Amount - record.WithholdingTax
 
 		// Convert to base currency if needed
-		if record.Currency != ic.baseCurrency && record.ExchangeRate > 0 {
-					record.Amount /= record.ExchangeRate
-		record.WithholdingTax /= record.ExchangeRate
-		record.NetAmount /= record.ExchangeRate
+		if rate := ic.conversionRate(record.Currency, safeDeref(tx.CurrencyTotal), record.Date, record.ExchangeRate); rate > 0 {
+			record.Amount /= rate
+			record.WithholdingTax /= rate
+			record.NetAmount /= rate
 			record.Currency = ic.baseCurrency
 		}
 
@@ -135,6 +153,24 @@ func (ic *IncomeCalculator) extractDividendRecords(transactions []types.Transact
 	return records
 }
 
+// conversionRate returns the rate converting currency into the base currency, or 0 if no conversion
+// applies. The broker rate converts the instrument currency into account, so it is only used when
+// account, if known, is the base currency and currency is not account.
+func (ic *IncomeCalculator) conversionRate(currency, account string, date time.Time, brokerRate float64) float64 {
+	if currency == ic.baseCurrency {
+		return 0
+	}
+	if ic.rates != nil {
+		if rate, ok := ic.rates.Rate(currency, date); ok {
+			return rate
+		}
+	}
+	if account != "" && (account != ic.baseCurrency || currency == account) {
+		return 0
+	}
+	return brokerRate
+}
+
 // extractInterestRecords extracts and processes interest transactions
 func (ic *IncomeCalculator) extractInterestRecords(transactions []types.Transaction) []types.InterestRecord {
 	// Pre-allocate slice with estimated capacity (assume ~5% of transactions are interest)
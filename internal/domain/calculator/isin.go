@@ -0,0 +1,32 @@
+package calculator
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// ISINLength is the length of a valid ISIN
+	ISINLength = 12
+	// ISINCountryPrefixLength is the length of the ISIN country prefix
+	ISINCountryPrefixLength = 2
+	// UnknownCountry is used when a country cannot be derived
+	UnknownCountry = "XX"
+)
+
+// CountryFromISIN returns the ISO 3166 country prefix of an ISIN
+func CountryFromISIN(isin string) string {
+	isin = strings.ToUpper(strings.TrimSpace(isin))
+	if len(isin) != ISINLength {
+		return UnknownCountry
+	}
+
+	prefix := isin[:ISINCountryPrefixLength]
+	for _, r := range prefix {
+		if !unicode.IsLetter(r) {
+			return UnknownCountry
+		}
+	}
+
+	return prefix
+}
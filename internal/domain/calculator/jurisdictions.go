@@ -0,0 +1,276 @@
+package calculator
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// JurisdictionSchemaVersion is the version of the jurisdiction definition format this build reads
+const JurisdictionSchemaVersion = 1
+
+// defaultJurisdictionFiles holds the built-in jurisdiction definitions
+//
+//go:embed jurisdictions/*.yaml
+var defaultJurisdictionFiles embed.FS
+
+// TaxBracket is a rate applying to income from a threshold up to the next bracket
+type TaxBracket struct {
+	From float64 `yaml:"from" json:"from"`
+	Rate float64 `yaml:"rate" json:"rate"`
+}
+
+// JurisdictionYear holds the rates and allowances of a jurisdiction for one tax year
+type JurisdictionYear struct {
+	CapitalGainsTaxRate  float64       `yaml:"capital_gains_rate" json:"capital_gains_rate"`
+	DividendTaxRate      float64       `yaml:"dividend_rate" json:"dividend_rate"`
+	WithholdingCreditCap float64       `yaml:"withholding_credit_cap" json:"withholding_credit_cap,omitempty"`
+	Allowances           TaxAllowances `yaml:"allowances" json:"allowances"`
+	CapitalGainsBrackets []TaxBracket  `yaml:"capital_gains_brackets" json:"capital_gains_brackets,omitempty"`
+	DividendBrackets     []TaxBracket  `yaml:"dividend_brackets" json:"dividend_brackets,omitempty"`
+	BracketBase          BracketBase   `yaml:"bracket_base" json:"bracket_base,omitempty"`
+	IncomeBrackets       []TaxBracket  `yaml:"income_brackets" json:"income_brackets,omitempty"`
+	ExitTaxRate          float64       `yaml:"exit_tax_rate" json:"exit_tax_rate,omitempty"`
+	SolidaritySurcharge  float64       `yaml:"solidarity_surcharge" json:"solidarity_surcharge,omitempty"`
+	Basiszins            *float64      `yaml:"basiszins" json:"basiszins,omitempty"`
+	Box3                 *NLBox3Year   `yaml:"box3" json:"box3,omitempty"`
+}
+
+// jurisdictionFile is the YAML layout of a jurisdiction definition
+type jurisdictionFile struct {
+	Version      int                      `yaml:"version"`
+	Code         string                   `yaml:"code"`
+	Name         string                   `yaml:"name"`
+	Country      string                   `yaml:"country"`
+	Currency     string                   `yaml:"currency"`
+	TaxYearStart string                   `yaml:"tax_year_start"`
+	Matching     MatchingMethod           `yaml:"matching"`
+	WashSaleDays int                      `yaml:"wash_sale_days"`
+	FXSource     FXSource                 `yaml:"fx_source"`
+	Years        map[int]JurisdictionYear `yaml:"years"`
+
+	LossCarryForward    *LossCarryForwardRule `yaml:"loss_carry_forward"`
+	RepurchaseRule      *RepurchaseRule       `yaml:"repurchase_rule"`
+	DeemedDisposalYears int                   `yaml:"deemed_disposal_years"`
+}
+
+// ParseJurisdiction reads a jurisdiction definition from YAML. The returned jurisdiction carries
+// the rules of its latest year; use ForYear for another year.
+func ParseJurisdiction(data []byte) (TaxJurisdiction, error) {
+	var file jurisdictionFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return TaxJurisdiction{}, fmt.Errorf("failed to parse jurisdiction definition: %w", err)
+	}
+
+	if file.Version != JurisdictionSchemaVersion {
+		return TaxJurisdiction{}, fmt.Errorf("unsupported jurisdiction definition version %d", file.Version)
+	}
+	if file.Code == "" || file.Currency == "" {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction definition needs a code and currency")
+	}
+	if len(file.Years) == 0 {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s defines no tax years", file.Code)
+	}
+
+	switch file.Matching {
+	case MatchingFIFO, MatchingAverageCost, MatchingFourWeek:
+	case "":
+		file.Matching = MatchingFIFO
+	default:
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: unknown matching method %q", file.Code, file.Matching)
+	}
+
+	switch file.FXSource.Quote {
+	case "", QuoteForeignPerBase, QuoteBasePerForeign:
+	default:
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: unknown FX quote %q", file.Code, file.FXSource.Quote)
+	}
+	switch file.FXSource.Lookup {
+	case "", LookupSameDay, LookupPreviousBusinessDay:
+	default:
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: unknown FX lookup %q", file.Code, file.FXSource.Lookup)
+	}
+	for currency, rate := range file.FXSource.Pegged {
+		if rate <= 0 {
+			return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: invalid pegged rate for %s", file.Code, currency)
+		}
+	}
+
+	yearStart := time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)
+	if file.TaxYearStart != "" {
+		parsed, err := time.Parse("01-02", file.TaxYearStart)
+		if err != nil {
+			return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: invalid tax year start %q: %w", file.Code, file.TaxYearStart, err)
+		}
+		yearStart = parsed
+	}
+
+	if rule := file.LossCarryForward; rule != nil && (rule.Years < 0 || rule.MaxUseShare < 0 || rule.MaxUseShare > 1) {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: invalid loss carry-forward rule", file.Code)
+	}
+
+	if rule := file.RepurchaseRule; rule != nil && rule.Days <= 0 {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: invalid repurchase rule", file.Code)
+	}
+	if file.Matching == MatchingFourWeek && file.RepurchaseRule == nil {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: four-week matching needs a repurchase rule", file.Code)
+	}
+	if file.DeemedDisposalYears < 0 {
+		return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: invalid deemed disposal interval", file.Code)
+	}
+
+	for year, rules := range file.Years {
+		switch rules.BracketBase {
+		case "", BracketBaseOtherIncome, BracketBaseInvestmentIncome:
+		default:
+			return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: unknown bracket base %q for %d", file.Code, rules.BracketBase, year)
+		}
+		for _, brackets := range [][]TaxBracket{rules.CapitalGainsBrackets, rules.DividendBrackets, rules.IncomeBrackets} {
+			if !sort.SliceIsSorted(brackets, func(i, j int) bool { return brackets[i].From < brackets[j].From }) {
+				return TaxJurisdiction{}, fmt.Errorf("jurisdiction %s: brackets for %d are not in ascending order", file.Code, year)
+			}
+		}
+	}
+
+	if file.Country == "" {
+		file.Country = file.Code
+	}
+
+	jurisdiction := TaxJurisdiction{
+		Code:                file.Code,
+		Name:                file.Name,
+		Country:             file.Country,
+		Currency:            file.Currency,
+		Version:             file.Version,
+		TaxYearStartMonth:   yearStart.Month(),
+		TaxYearStartDay:     yearStart.Day(),
+		MatchingMethod:      file.Matching,
+		WashSaleDays:        file.WashSaleDays,
+		DeemedDisposalYears: file.DeemedDisposalYears,
+		FXSource:            file.FXSource,
+		LossCarryForward:    file.LossCarryForward,
+		RepurchaseRule:      file.RepurchaseRule,
+		Years:               file.Years,
+	}
+	return jurisdiction.ForYear(0), nil
+}
+
+// LoadJurisdictionFile reads a jurisdiction definition from a YAML file
+func LoadJurisdictionFile(path string) (TaxJurisdiction, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return TaxJurisdiction{}, fmt.Errorf("failed to read jurisdiction file: %w", err)
+	}
+	jurisdiction, err := ParseJurisdiction(data)
+	if err != nil {
+		return TaxJurisdiction{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return jurisdiction, nil
+}
+
+// DefaultJurisdictions returns the built-in jurisdiction definitions keyed by code
+func DefaultJurisdictions() (map[string]TaxJurisdiction, error) {
+	paths, err := fs.Glob(defaultJurisdictionFiles, "jurisdictions/*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list built-in jurisdictions: %w", err)
+	}
+
+	jurisdictions := make(map[string]TaxJurisdiction, len(paths))
+	for _, path := range paths {
+		data, err := defaultJurisdictionFiles.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read built-in jurisdiction %s: %w", path, err)
+		}
+		jurisdiction, err := ParseJurisdiction(data)
+		if err != nil {
+			return nil, fmt.Errorf("built-in jurisdiction %s: %w", path, err)
+		}
+		jurisdictions[jurisdiction.Code] = jurisdiction
+	}
+	return jurisdictions, nil
+}
+
+// LoadJurisdictions adds jurisdiction definitions from YAML files, replacing any loaded jurisdiction
+// with the same code. A directory loads every .yaml and .yml file in it.
+func (c *TaxCalculator) LoadJurisdictions(paths ...string) error {
+	for _, path := range paths {
+		files := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return fmt.Errorf("failed to read jurisdiction path: %w", err)
+		} else if info.IsDir() {
+			yamlFiles, _ := filepath.Glob(filepath.Join(path, "*.yaml"))
+			ymlFiles, _ := filepath.Glob(filepath.Join(path, "*.yml"))
+			files = append(yamlFiles, ymlFiles...)
+		}
+
+		for _, file := range files {
+			jurisdiction, err := LoadJurisdictionFile(file)
+			if err != nil {
+				return err
+			}
+			c.jurisdictions[jurisdiction.Code] = jurisdiction
+		}
+	}
+	return nil
+}
+
+// ForYear returns the jurisdiction with the rules of the given tax year: those defined for the
+// year itself, else the latest earlier year, else the earliest year defined. Year 0 selects the
+// latest year defined.
+func (j TaxJurisdiction) ForYear(year int) TaxJurisdiction {
+	if len(j.Years) == 0 {
+		return j
+	}
+
+	years := make([]int, 0, len(j.Years))
+	for defined := range j.Years {
+		years = append(years, defined)
+	}
+	sort.Ints(years)
+
+	selected := years[0]
+	for _, defined := range years {
+		if year == 0 || defined <= year {
+			selected = defined
+		}
+	}
+
+	rules := j.Years[selected]
+	j.RulesYear = selected
+	j.CapitalGainsTaxRate = rules.CapitalGainsTaxRate
+	j.DividendTaxRate = rules.DividendTaxRate
+	j.WithholdingCreditCap = rules.WithholdingCreditCap
+	j.Allowances = rules.Allowances
+	j.CapitalGainsBrackets = rules.CapitalGainsBrackets
+	j.DividendBrackets = rules.DividendBrackets
+	j.BracketBase = rules.BracketBase
+	j.IncomeBrackets = rules.IncomeBrackets
+	j.ExitTaxRate = rules.ExitTaxRate
+	j.SolidaritySurcharge = rules.SolidaritySurcharge
+	return j
+}
+
+// TaxYearOf returns the tax year date falls in, labelled by the calendar year it starts in
+func (j TaxJurisdiction) TaxYearOf(date time.Time) int {
+	year := date.Year()
+	if start, _ := j.TaxYearBounds(year); date.Before(start) {
+		return year - 1
+	}
+	return year
+}
+
+// TaxYearBounds returns the start and end of the tax year starting in year
+func (j TaxJurisdiction) TaxYearBounds(year int) (time.Time, time.Time) {
+	month, day := j.TaxYearStartMonth, j.TaxYearStartDay
+	if month == 0 {
+		month, day = time.January, 1
+	}
+	start := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return start, start.AddDate(1, 0, 0)
+}
@@ -0,0 +1,170 @@
+package calculator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestDefaultJurisdictions(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	supported := calc.GetSupportedJurisdictions()
+	codes := make([]string, 0, len(supported))
+	for _, jurisdiction := range supported {
+		codes = append(codes, jurisdiction.Code)
+	}
+	expected := []string{"BG", "DE", "ES", "IE", "LT", "NL", "PL", "PT", "UK", "US"}
+	if len(codes) != len(expected) {
+		t.Fatalf("Expected jurisdictions %v, got %v", expected, codes)
+	}
+	for i := range expected {
+		if codes[i] != expected[i] {
+			t.Errorf("Expected jurisdictions %v, got %v", expected, codes)
+			break
+		}
+	}
+
+	pl, _ := calc.GetJurisdiction("PL")
+	if pl.FXSource.Name != "NBP" || pl.FXSource.Lookup != LookupPreviousBusinessDay || pl.CapitalGainsTaxRate != 0.19 {
+		t.Errorf("Expected PL to use NBP D-1 rates at 19%%, got %+v", pl)
+	}
+	if us, _ := calc.GetJurisdiction("US"); us.WashSaleDays != 30 {
+		t.Errorf("Expected US wash sale window 30, got %d", us.WashSaleDays)
+	}
+
+	bg, _ := calc.GetJurisdiction("BG")
+	if pegged := bg.FXSource.WithPegged(nil); pegged == nil {
+		t.Error("Expected BG to peg the euro")
+	} else if rate, ok := pegged.Rate("EUR", time.Now()); !ok || abs(rate-1/1.95583) > 1e-12 {
+		t.Errorf("Expected the euro pegged at 1.95583 BGN, got %v, %v", rate, ok)
+	}
+	if ie, _ := calc.lookupJurisdiction("IE", 2024); ie.ExitTaxRate != 0.41 || ie.DeemedDisposalYears != 8 || ie.RepurchaseRule.Days != 28 {
+		t.Errorf("Expected IE exit tax at 41%% every 8 years and a 28-day four-week rule, got %+v", ie)
+	}
+	if de, _ := calc.lookupJurisdiction("DE", 2024); de.SolidaritySurcharge != 0.055 {
+		t.Errorf("Expected the DE solidarity surcharge of 5.5%%, got %.3f", de.SolidaritySurcharge)
+	}
+	if de, _ := calc.GetJurisdiction("DE"); de.Years[2023].Basiszins == nil || *de.Years[2023].Basiszins != 0.0255 {
+		t.Errorf("Expected the 2023 DE Basiszins of 2.55%%, got %v", de.Years[2023].Basiszins)
+	}
+	if nl, _ := calc.GetJurisdiction("NL"); nl.Years[2024].Box3 == nil || nl.Years[2024].Box3.TaxRate != 0.36 {
+		t.Errorf("Expected 2024 NL Box 3 parameters at 36%%, got %+v", nl.Years[2024].Box3)
+	}
+}
+
+func TestTaxJurisdiction_ForYear(t *testing.T) {
+	uk, _ := NewTaxCalculator().GetJurisdiction("UK")
+
+	tests := []struct {
+		year      int
+		rulesYear int
+		allowance float64
+	}{
+		{2023, 2023, 6000},
+		{2024, 2024, 3000},
+		{2030, 2025, 3000},
+		{2015, 2022, 12300},
+		{0, 2025, 3000},
+	}
+
+	for _, tt := range tests {
+		rules := uk.ForYear(tt.year)
+		if rules.RulesYear != tt.rulesYear || rules.Allowances.CapitalGains != tt.allowance {
+			t.Errorf("ForYear(%d) = rules of %d with allowance %.0f, expected %d with %.0f",
+				tt.year, rules.RulesYear, rules.Allowances.CapitalGains, tt.rulesYear, tt.allowance)
+		}
+	}
+
+	if brackets := uk.ForYear(2024).CapitalGainsBrackets; len(brackets) != 2 || brackets[1].Rate != 0.24 {
+		t.Errorf("Expected two 2024 capital gains brackets up to 24%%, got %+v", brackets)
+	}
+
+	from, to := uk.TaxYearBounds(2024)
+	if !from.Equal(time.Date(2024, 4, 6, 0, 0, 0, 0, time.UTC)) || !to.Equal(time.Date(2025, 4, 6, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected UK 2024 tax year from 6 April 2024 to 6 April 2025, got %v to %v", from, to)
+	}
+}
+
+func TestParseJurisdiction_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unsupported version", "version: 2\ncode: XX\ncurrency: EUR\nyears:\n  2024:\n    capital_gains_rate: 0.1\n"},
+		{"missing currency", "version: 1\ncode: XX\nyears:\n  2024:\n    capital_gains_rate: 0.1\n"},
+		{"no years", "version: 1\ncode: XX\ncurrency: EUR\n"},
+		{"unknown matching", "version: 1\ncode: XX\ncurrency: EUR\nmatching: LIFO\nyears:\n  2024:\n    capital_gains_rate: 0.1\n"},
+		{"invalid year start", "version: 1\ncode: XX\ncurrency: EUR\ntax_year_start: April\nyears:\n  2024:\n    capital_gains_rate: 0.1\n"},
+		{"unsorted brackets", "version: 1\ncode: XX\ncurrency: EUR\nyears:\n  2024:\n    capital_gains_brackets:\n      - {from: 100, rate: 0.2}\n      - {from: 0, rate: 0.1}\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseJurisdiction([]byte(tt.data)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestTaxCalculator_LoadJurisdictions(t *testing.T) {
+	dir := t.TempDir()
+	definition := `version: 1
+code: CH
+name: Switzerland
+currency: CHF
+matching: FIFO
+years:
+  2024:
+    capital_gains_rate: 0
+    dividend_rate: 0.35
+    withholding_credit_cap: 0.15
+`
+	if err := os.WriteFile(filepath.Join(dir, "ch.yaml"), []byte(definition), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	calc := NewTaxCalculator()
+	if err := calc.LoadJurisdictions(dir); err != nil {
+		t.Fatalf("LoadJurisdictions() error = %v", err)
+	}
+
+	ch, exists := calc.GetJurisdiction("CH")
+	if !exists || ch.DividendTaxRate != 0.35 || ch.CreditCap() != 0.15 {
+		t.Errorf("Expected CH at 35%% with a 15%% credit cap, got %+v", ch)
+	}
+	if len(calc.GetSupportedJurisdictions()) != 11 {
+		t.Errorf("Expected 11 jurisdictions after loading CH, got %d", len(calc.GetSupportedJurisdictions()))
+	}
+
+	if err := calc.LoadJurisdictions(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestTaxCalculator_CalculateUsesTaxYearStart(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	// The first sale falls in the UK 2023 tax year, the second in 2024
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "GB0002634946", 20, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 4, 5, 10, 0, 0, 0, time.UTC), "GB0002634946", 10, 150),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 4, 6, 10, 0, 0, 0, time.UTC), "GB0002634946", 10, 200),
+	}
+
+	gains, _, err := calc.CalculateCapitalGains(transactions, types.ProcessingOptions{
+		Jurisdiction: "UK",
+		Currency:     "EUR",
+		TaxYear:      2024,
+	})
+	if err != nil {
+		t.Fatalf("CalculateCapitalGains() error = %v", err)
+	}
+	if abs(gains-1000) > 0.001 {
+		t.Errorf("Expected gain 1000 in the 2024/25 tax year, got %.2f", gains)
+	}
+}
@@ -0,0 +1,193 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// LossBucketSecurities is the loss bucket for gains and losses on shares and funds
+const LossBucketSecurities = "securities"
+
+// LossCarryForwardRule describes how net capital losses carry forward to later tax years
+type LossCarryForwardRule struct {
+	// Years is how many later tax years a loss can be used in; 0 means indefinitely
+	Years int `yaml:"years" json:"years"`
+	// MaxUseShare caps the share of a year's loss that can be used in any one later year; 0 means no cap
+	MaxUseShare float64 `yaml:"max_use_share" json:"max_use_share,omitempty"`
+	// PreserveAllowance uses brought-forward losses only to bring gains down to the annual allowance
+	PreserveAllowance bool `yaml:"preserve_allowance" json:"preserve_allowance,omitempty"`
+	// OrdinaryIncomeOffset is the net loss deductible against other income each year
+	OrdinaryIncomeOffset float64 `yaml:"ordinary_income_offset" json:"ordinary_income_offset,omitempty"`
+}
+
+// LossYearSummary shows how losses in a bucket were brought forward, used and carried on in a year
+type LossYearSummary struct {
+	Year                int     `json:"year"`
+	Bucket              string  `json:"bucket"`
+	NetGainLoss         float64 `json:"net_gain_loss"`
+	BroughtForward      float64 `json:"brought_forward"`
+	Expired             float64 `json:"expired"`
+	Used                float64 `json:"used"`
+	OffsetAgainstIncome float64 `json:"offset_against_income"`
+	Added               float64 `json:"added"`
+	CarriedForward      float64 `json:"carried_forward"`
+	NetAfterLosses      float64 `json:"net_after_losses"`
+}
+
+// lossEntry is the unused part of one year's net loss
+type lossEntry struct {
+	year      int
+	amount    float64
+	remaining float64
+}
+
+// LossLedger tracks unused losses by bucket and year of origin. Years must be applied in order.
+type LossLedger struct {
+	rule    LossCarryForwardRule
+	entries map[string][]*lossEntry
+}
+
+// NewLossLedger creates an empty loss ledger applying rule
+func NewLossLedger(rule LossCarryForwardRule) *LossLedger {
+	return &LossLedger{
+		rule:    rule,
+		entries: make(map[string][]*lossEntry),
+	}
+}
+
+// Apply records a year's net gain or loss in a bucket. Losses past their expiry are dropped, a
+// gain above protected is reduced by the oldest losses first, and a net loss is carried forward
+// after any offset against other income.
+func (l *LossLedger) Apply(bucket string, year int, net, protected float64) LossYearSummary {
+	summary := LossYearSummary{Year: year, Bucket: bucket, NetGainLoss: net}
+
+	var entries []*lossEntry
+	for _, entry := range l.entries[bucket] {
+		if l.rule.Years > 0 && year-entry.year > l.rule.Years {
+			summary.Expired += entry.remaining
+			continue
+		}
+		summary.BroughtForward += entry.remaining
+		entries = append(entries, entry)
+	}
+
+	if net > 0 {
+		usable := math.Max(net-protected, 0)
+		for _, entry := range entries {
+			available := entry.remaining
+			if l.rule.MaxUseShare > 0 {
+				available = math.Min(available, entry.amount*l.rule.MaxUseShare)
+			}
+			used := math.Min(available, usable)
+			entry.remaining -= used
+			usable -= used
+			summary.Used += used
+		}
+	} else if net < 0 {
+		summary.Added = -net
+		entries = append(entries, &lossEntry{year: year, amount: -net, remaining: -net})
+	}
+
+	// Losses left once the year's gains are used up are offset against other income, newest first
+	if l.rule.OrdinaryIncomeOffset > 0 && net-summary.Used <= ShareEpsilon {
+		offset := l.rule.OrdinaryIncomeOffset
+		for i := len(entries) - 1; i >= 0 && offset > 0; i-- {
+			used := math.Min(entries[i].remaining, offset)
+			entries[i].remaining -= used
+			offset -= used
+			summary.OffsetAgainstIncome += used
+		}
+	}
+
+	remaining := entries[:0]
+	for _, entry := range entries {
+		if entry.remaining > ShareEpsilon {
+			summary.CarriedForward += entry.remaining
+			remaining = append(remaining, entry)
+		}
+	}
+	l.entries[bucket] = remaining
+
+	summary.NetAfterLosses = net - summary.Used
+	return summary
+}
+
+// lossesForYear runs the net gain or loss of each tax year up to year through the jurisdiction's
+// loss carry-forward rule and returns year's summary. Years missing from nets had no disposals.
+// Without a carry-forward rule the summary only holds year's net.
+func lossesForYear(jurisdiction TaxJurisdiction, nets map[int]float64, year int) LossYearSummary {
+	if jurisdiction.LossCarryForward == nil {
+		return LossYearSummary{Year: year, Bucket: LossBucketSecurities, NetGainLoss: nets[year], NetAfterLosses: nets[year]}
+	}
+
+	first := year
+	for netYear := range nets {
+		if netYear < first {
+			first = netYear
+		}
+	}
+
+	losses := NewLossLedger(*jurisdiction.LossCarryForward)
+	var summary LossYearSummary
+	for y := first; y <= year; y++ {
+		protected := 0.0
+		if jurisdiction.LossCarryForward.PreserveAllowance {
+			protected = jurisdiction.ForYear(y).Allowances.CapitalGains
+		}
+		summary = losses.Apply(LossBucketSecurities, y, nets[y], protected)
+	}
+	return summary
+}
+
+// CalculateLossCarryForward applies the jurisdiction's loss carry-forward rules to each tax year's net
+// gain or loss, from the first year with disposals up to options.TaxYear, or the last year with
+// disposals when it is 0. It returns nil when the jurisdiction does not carry losses forward.
+func (c *TaxCalculator) CalculateLossCarryForward(
+	transactions []types.Transaction,
+	options types.ProcessingOptions,
+) ([]LossYearSummary, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return nil, err
+	}
+	if jurisdiction.LossCarryForward == nil {
+		return nil, nil
+	}
+
+	currency := c.reportingCurrency(jurisdiction, options)
+	engine := c.newLotEngine(jurisdiction, currency)
+	ledger := engine.Process(transactions)
+
+	nets := make(map[int]float64)
+	for _, disposal := range ledger.Disposals {
+		nets[jurisdiction.TaxYearOf(disposal.Date)] += disposal.GainLoss
+	}
+	if len(nets) == 0 {
+		return nil, nil
+	}
+
+	years := make([]int, 0, len(nets))
+	for year := range nets {
+		years = append(years, year)
+	}
+	sort.Ints(years)
+
+	last := years[len(years)-1]
+	if options.TaxYear != 0 {
+		last = options.TaxYear
+	}
+
+	losses := NewLossLedger(*jurisdiction.LossCarryForward)
+	var summaries []LossYearSummary
+	for year := years[0]; year <= last; year++ {
+		protected := 0.0
+		if jurisdiction.LossCarryForward.PreserveAllowance {
+			protected = jurisdiction.ForYear(year).Allowances.CapitalGains
+		}
+		summaries = append(summaries, losses.Apply(LossBucketSecurities, year, nets[year], protected))
+	}
+
+	return summaries, nil
+}
@@ -0,0 +1,233 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestLossLedger_Apply(t *testing.T) {
+	type step struct {
+		year           int
+		net            float64
+		protected      float64
+		broughtForward float64
+		expired        float64
+		used           float64
+		offset         float64
+		carriedForward float64
+	}
+
+	tests := []struct {
+		name  string
+		rule  LossCarryForwardRule
+		steps []step
+	}{
+		{
+			name: "UK losses only used down to the annual exempt amount",
+			rule: LossCarryForwardRule{PreserveAllowance: true},
+			steps: []step{
+				{year: 2022, net: -5000, carriedForward: 5000},
+				{year: 2023, net: 8000, protected: 6000, broughtForward: 5000, used: 2000, carriedForward: 3000},
+				{year: 2024, net: 1000, protected: 3000, broughtForward: 3000, carriedForward: 3000},
+			},
+		},
+		{
+			name: "PL half of a loss per year",
+			rule: LossCarryForwardRule{Years: 5, MaxUseShare: 0.5},
+			steps: []step{
+				{year: 2020, net: -10000, carriedForward: 10000},
+				{year: 2021, net: 8000, broughtForward: 10000, used: 5000, carriedForward: 5000},
+				{year: 2022, net: 8000, broughtForward: 5000, used: 5000},
+			},
+		},
+		{
+			name: "LT losses expire after five years",
+			rule: LossCarryForwardRule{Years: 5},
+			steps: []step{
+				{year: 2018, net: -1000, carriedForward: 1000},
+				{year: 2019, net: -500, broughtForward: 1000, carriedForward: 1500},
+				{year: 2024, net: 300, expired: 1000, broughtForward: 500, used: 300, carriedForward: 200},
+			},
+		},
+		{
+			name: "US offset against other income",
+			rule: LossCarryForwardRule{OrdinaryIncomeOffset: 3000},
+			steps: []step{
+				{year: 2023, net: -5000, offset: 3000, carriedForward: 2000},
+				{year: 2024, net: 0, broughtForward: 2000, offset: 2000},
+			},
+		},
+		{
+			name: "US offset when the carryover exceeds the year's gain",
+			rule: LossCarryForwardRule{OrdinaryIncomeOffset: 3000},
+			steps: []step{
+				{year: 2022, net: -11000, offset: 3000, carriedForward: 8000},
+				{year: 2023, net: 1000, broughtForward: 8000, used: 1000, offset: 3000, carriedForward: 4000},
+				{year: 2024, net: 5000, broughtForward: 4000, used: 4000},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ledger := NewLossLedger(tt.rule)
+			for _, s := range tt.steps {
+				summary := ledger.Apply(LossBucketSecurities, s.year, s.net, s.protected)
+				if abs(summary.BroughtForward-s.broughtForward) > 0.001 || abs(summary.Expired-s.expired) > 0.001 ||
+					abs(summary.Used-s.used) > 0.001 || abs(summary.OffsetAgainstIncome-s.offset) > 0.001 ||
+					abs(summary.CarriedForward-s.carriedForward) > 0.001 {
+					t.Errorf("%d: got b/f %.2f, expired %.2f, used %.2f, offset %.2f, c/f %.2f; expected %.2f, %.2f, %.2f, %.2f, %.2f",
+						s.year, summary.BroughtForward, summary.Expired, summary.Used, summary.OffsetAgainstIncome, summary.CarriedForward,
+						s.broughtForward, s.expired, s.used, s.offset, s.carriedForward)
+				}
+			}
+		})
+	}
+}
+
+func TestLossLedger_BucketsAreSeparate(t *testing.T) {
+	ledger := NewLossLedger(LossCarryForwardRule{})
+	ledger.Apply("shares", 2023, -1000, 0)
+
+	if summary := ledger.Apply("other", 2024, 500, 0); summary.Used != 0 || summary.BroughtForward != 0 {
+		t.Errorf("Expected no losses in another bucket, got %+v", summary)
+	}
+	if summary := ledger.Apply("shares", 2024, 500, 0); summary.Used != 500 || summary.CarriedForward != 500 {
+		t.Errorf("Expected 500 used and 500 carried forward, got %+v", summary)
+	}
+}
+
+func TestTaxCalculator_CalculateWithLossCarryForward(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2022, 3, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 200),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 250),
+	}
+
+	options := types.ProcessingOptions{TaxYear: 2024, Currency: "EUR", Jurisdiction: "LT"}
+	history, err := calc.CalculateLossCarryForward(transactions, options)
+	if err != nil {
+		t.Fatalf("CalculateLossCarryForward() error = %v", err)
+	}
+	if len(history) != 2 || history[0].Added != 1000 || history[1].Used != 1000 {
+		t.Fatalf("Expected a 1000 loss in 2023 used in 2024, got %+v", history)
+	}
+
+	calculation, err := calc.Calculate(transactions, options)
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+
+	// Gain 1500 less 1000 brought forward and the 500 exemption
+	if calculation.LossesBroughtForward != 1000 || calculation.LossesUsed != 1000 || calculation.LossesCarriedForward != 0 {
+		t.Errorf("Expected 1000 brought forward and used, got %+v", calculation)
+	}
+	if calculation.TaxableIncome != 0 || calculation.EstimatedTax != 0 {
+		t.Errorf("Expected no tax after losses and exemption, got %.2f", calculation.EstimatedTax)
+	}
+
+	// Bulgaria does not carry losses forward
+	options.Jurisdiction = "BG"
+	if history, _ := calc.CalculateLossCarryForward(transactions, options); history != nil {
+		t.Errorf("Expected no loss history for BG, got %+v", history)
+	}
+}
+
+func TestTaxCalculator_ReportsApplyLossCarryForward(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	trades := func(currency string) []types.Transaction {
+		transactions := []types.Transaction{
+			// A 5000 loss in 2023 and a 3000 gain in 2024
+			tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 100, 100),
+			tradeTx(types.TransactionTypeMarketSell, time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 100, 50),
+			tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), "US5949181045", 100, 100),
+			tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), "US5949181045", 100, 130),
+		}
+		for i := range transactions {
+			transactions[i].CurrencyPricePerShare = stringPtr(currency)
+		}
+		return transactions
+	}
+
+	type losses struct{ broughtForward, used, carriedForward float64 }
+	tests := []struct {
+		code     string
+		currency string
+		report   func([]types.Transaction) (losses, error)
+		expected losses
+	}{
+		{"LT", "EUR", func(transactions []types.Transaction) (losses, error) {
+			declaration, err := calc.GenerateLTDeclaration(transactions, 2024)
+			if err != nil {
+				return losses{}, err
+			}
+			return losses{declaration.LossesBroughtForward, declaration.LossesUsed, declaration.LossesCarriedForward}, nil
+		}, losses{5000, 3000, 2000}},
+		{"IE", "EUR", func(transactions []types.Transaction) (losses, error) {
+			report, err := calc.GenerateIEReport(transactions, 2024, IEOptions{})
+			if err != nil {
+				return losses{}, err
+			}
+			return losses{report.LossesBroughtForward, report.LossesUsed, report.LossesCarriedForward}, nil
+		}, losses{5000, 3000, 2000}},
+		// 3000 of the 2023 loss was deducted from other income, leaving 2000 to carry over
+		{"US", "USD", func(transactions []types.Transaction) (losses, error) {
+			report, err := calc.GenerateUSReport(transactions, 2024)
+			if err != nil {
+				return losses{}, err
+			}
+			return losses{report.LossesBroughtForward, report.LossesUsed, report.LossCarryover}, nil
+		}, losses{2000, 2000, 0}},
+	}
+
+	for _, tt := range tests {
+		transactions := trades(tt.currency)
+		got, err := tt.report(transactions)
+		if err != nil {
+			t.Fatalf("%s report error = %v", tt.code, err)
+		}
+		if got != tt.expected {
+			t.Errorf("%s: expected losses %+v, got %+v", tt.code, tt.expected, got)
+		}
+
+		calculation, err := calc.Calculate(transactions, types.ProcessingOptions{TaxYear: 2024, Currency: types.Currency(tt.currency), Jurisdiction: tt.code})
+		if err != nil {
+			t.Fatalf("Calculate(%s) error = %v", tt.code, err)
+		}
+		if calculation.LossesUsed != got.used {
+			t.Errorf("%s: report used %.2f of losses, Calculate used %.2f", tt.code, got.used, calculation.LossesUsed)
+		}
+	}
+}
+
+func TestFinancialCalculator_LossCarryForward(t *testing.T) {
+	calc := NewFinancialCalculator("EUR")
+	calc.SetLossCarryForward(&LossCarryForwardRule{})
+
+	sell := func(year int, result float64) types.Transaction {
+		return types.Transaction{
+			Action:         types.TransactionTypeMarketSell,
+			Time:           time.Date(year, 5, 1, 10, 0, 0, 0, time.UTC),
+			Result:         floatPtr(result),
+			CurrencyResult: stringPtr("EUR"),
+		}
+	}
+
+	reports, err := calc.CalculateYearlyReports([]types.Transaction{sell(2023, -300), sell(2024, 200), sell(2024, 50)})
+	if err != nil {
+		t.Fatalf("CalculateYearlyReports() error = %v", err)
+	}
+
+	if reports[0].CapitalGains != -300 || reports[0].LossesCarriedForward != 300 {
+		t.Errorf("Expected a 300 loss carried forward from 2023, got %+v", reports[0])
+	}
+	if reports[1].LossesBroughtForward != 300 || reports[1].LossesUsed != 250 || reports[1].LossesCarriedForward != 50 {
+		t.Errorf("Expected 250 of 300 used in 2024, got %+v", reports[1])
+	}
+}
@@ -0,0 +1,556 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// ShareEpsilon is the tolerance used when comparing fractional share quantities
+	ShareEpsilon = 1e-9
+	// HoursPerDay is used to convert durations into whole days
+	HoursPerDay = 24
+)
+
+// MatchingMethod selects how disposals are matched against acquisitions
+type MatchingMethod string
+
+const (
+	// MatchingFIFO matches disposals against the oldest open lots first
+	MatchingFIFO MatchingMethod = "FIFO"
+	// MatchingAverageCost pools all shares of a security at their average cost
+	MatchingAverageCost MatchingMethod = "AVERAGE_COST"
+	// MatchingFourWeek matches shares bought in the four weeks before a disposal first, newest
+	// first, and the remaining shares FIFO
+	MatchingFourWeek MatchingMethod = "FOUR_WEEK"
+)
+
+// Lot is a parcel of shares acquired in a single purchase
+type Lot struct {
+	ID     string `json:"id"`
+	ISIN   string `json:"isin"`
+	Ticker string `json:"ticker"`
+	Name   string `json:"name"`
+	// AcquiredAt starts the holding period, which the wash sale rule moves back by that of the shares
+	// replaced; PurchasedAt is the date the shares were bought
+	AcquiredAt  time.Time `json:"acquired_at"`
+	PurchasedAt time.Time `json:"purchased_at"`
+	Shares      float64   `json:"shares"`
+	Cost        float64   `json:"cost"`
+	Remaining   float64   `json:"remaining"`
+	Currency    string    `json:"currency"`
+	// LocalCost is the purchase value in the instrument's price currency, excluding fees
+	LocalCost     float64 `json:"local_cost"`
+	LocalCurrency string  `json:"local_currency"`
+	// WashSaleAdjustment is the disallowed loss added to the lot's basis by the wash sale rule
+	WashSaleAdjustment float64 `json:"wash_sale_adjustment,omitempty"`
+	// Splits are the stock splits applied to the lot's shares, which are counted after them
+	Splits []StockSplit `json:"splits,omitempty"`
+
+	// washReplaced counts the shares already used as wash sale replacement shares
+	washReplaced float64
+}
+
+// CostPerShare returns the lot's cost basis per share
+func (l *Lot) CostPerShare() float64 {
+	if l.Shares <= 0 {
+		return 0
+	}
+	return l.Cost / l.Shares
+}
+
+// SplitRatio returns the number of shares after the splits applied to the lot from from and
+// before to per share before them; a zero to includes all later splits
+func (l *Lot) SplitRatio(from, to time.Time) float64 {
+	ratio := 1.0
+	for _, split := range l.Splits {
+		if !split.Date.Before(from) && (to.IsZero() || split.Date.Before(to)) {
+			ratio *= split.Ratio
+		}
+	}
+	return ratio
+}
+
+// RemainingCost returns the cost basis of the shares still held in the lot
+func (l *Lot) RemainingCost() float64 {
+	return l.Remaining * l.CostPerShare()
+}
+
+// RemainingLocalCost returns the purchase value of the remaining shares in the price currency
+func (l *Lot) RemainingLocalCost() float64 {
+	if l.Shares <= 0 {
+		return 0
+	}
+	return l.LocalCost * l.Remaining / l.Shares
+}
+
+// LotMatch records the part of a disposal matched against one lot
+type LotMatch struct {
+	LotID       string    `json:"lot_id"`
+	AcquiredAt  time.Time `json:"acquired_at"`
+	Shares      float64   `json:"shares"`
+	Cost        float64   `json:"cost"`
+	Proceeds    float64   `json:"proceeds"`
+	GainLoss    float64   `json:"gain_loss"`
+	HoldingDays int       `json:"holding_days"`
+	// LocalCost is the purchase value of the matched shares in the lot's price currency
+	LocalCost float64 `json:"local_cost,omitempty"`
+	// WashSaleDisallowed is the loss disallowed by the wash sale rule; it is included in GainLoss
+	WashSaleDisallowed float64 `json:"wash_sale_disallowed,omitempty"`
+}
+
+// Disposal is a sell transaction with its matched acquisitions
+type Disposal struct {
+	ID              string     `json:"id,omitempty"`
+	ISIN            string     `json:"isin"`
+	Ticker          string     `json:"ticker"`
+	Name            string     `json:"name"`
+	Date            time.Time  `json:"date"`
+	Shares          float64    `json:"shares"`
+	PricePerShare   float64    `json:"price_per_share"`
+	PriceCurrency   string     `json:"price_currency"`
+	GrossProceeds   float64    `json:"gross_proceeds"`
+	Fees            float64    `json:"fees"`
+	Proceeds        float64    `json:"proceeds"`
+	Cost            float64    `json:"cost"`
+	GainLoss        float64    `json:"gain_loss"`
+	UnmatchedShares float64    `json:"unmatched_shares,omitempty"`
+	BrokerResult    *float64   `json:"broker_result,omitempty"`
+	Matches         []LotMatch `json:"matches"`
+	Currency        string     `json:"currency"`
+	// WashSaleDisallowed is the loss disallowed by the wash sale rule; it is included in GainLoss
+	WashSaleDisallowed float64 `json:"wash_sale_disallowed,omitempty"`
+}
+
+// StockSplit is a split or reverse split, which Trading 212 books as a "Stock split close" row
+// for the shares held before and a "Stock split open" row for the shares held after
+type StockSplit struct {
+	ISIN         string    `json:"isin"`
+	Ticker       string    `json:"ticker"`
+	Date         time.Time `json:"date"`
+	SharesBefore float64   `json:"shares_before"`
+	SharesAfter  float64   `json:"shares_after"`
+	// Ratio is the number of shares after the split per share before
+	Ratio float64 `json:"ratio"`
+}
+
+// LotLedger is the result of replaying trades through the lot engine
+type LotLedger struct {
+	Method    MatchingMethod `json:"method"`
+	Currency  string         `json:"currency"`
+	Lots      []*Lot         `json:"lots"`
+	Disposals []Disposal     `json:"disposals"`
+	Splits    []StockSplit   `json:"splits,omitempty"`
+	Warnings  []string       `json:"warnings,omitempty"`
+}
+
+// Lot returns the lot with id, or nil if there is none
+func (l *LotLedger) Lot(id string) *Lot {
+	for _, lot := range l.Lots {
+		if lot.ID == id {
+			return lot
+		}
+	}
+	return nil
+}
+
+// DisposalsBetween returns disposals dated within [from, to)
+func (l *LotLedger) DisposalsBetween(from, to time.Time) []Disposal {
+	var disposals []Disposal
+	for _, disposal := range l.Disposals {
+		if !disposal.Date.Before(from) && disposal.Date.Before(to) {
+			disposals = append(disposals, disposal)
+		}
+	}
+	return disposals
+}
+
+// DisposalsInYear returns disposals dated within the given calendar year
+func (l *LotLedger) DisposalsInYear(year int) []Disposal {
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	return l.DisposalsBetween(from, from.AddDate(1, 0, 0))
+}
+
+// OpenLots returns lots that still hold shares
+func (l *LotLedger) OpenLots() []*Lot {
+	var open []*Lot
+	for _, lot := range l.Lots {
+		if lot.Remaining > ShareEpsilon {
+			open = append(open, lot)
+		}
+	}
+	return open
+}
+
+// LotEngine replays buy and sell transactions into lots and matched disposals
+type LotEngine struct {
+	baseCurrency string
+	method       MatchingMethod
+	rates        FXRateProvider
+	washSaleDays int
+	fourWeekDays int
+}
+
+// NewLotEngine creates a lot engine; rates may be nil to use Trading 212's exchange rates
+func NewLotEngine(baseCurrency string, method MatchingMethod, rates FXRateProvider) *LotEngine {
+	if method == "" {
+		method = MatchingFIFO
+	}
+	return &LotEngine{
+		baseCurrency: baseCurrency,
+		method:       method,
+		rates:        rates,
+	}
+}
+
+// Process replays all trades and stock splits in chronological order and returns the resulting
+// ledger. A split changes the shares of the open lots but not their cost.
+func (le *LotEngine) Process(transactions []types.Transaction) *LotLedger {
+	ledger := &LotLedger{
+		Method:   le.method,
+		Currency: le.baseCurrency,
+	}
+
+	trades := make([]types.Transaction, 0, len(transactions))
+	for _, tx := range transactions {
+		if (isTradeAction(tx.Action) || isSplitAction(tx.Action)) && tx.Shares != nil && *tx.Shares > 0 {
+			trades = append(trades, tx)
+		}
+	}
+	sort.SliceStable(trades, func(i, j int) bool {
+		return trades[i].Time.Before(trades[j].Time)
+	})
+
+	converter := newCurrencyConverter(le.baseCurrency, accountCurrency(trades), le.rates)
+	openLots := make(map[string][]*Lot)
+	lotCounts := make(map[string]int)
+	pendingWashSales := make(map[string][]*washSale)
+	pendingSplits := make(map[string]types.Transaction)
+
+	for _, tx := range trades {
+		key := SecurityKey(tx)
+		if key == "" {
+			ledger.Warnings = append(ledger.Warnings,
+				fmt.Sprintf("skipped %s on %s without ISIN or ticker", tx.Action, tx.Time.Format(rateDateLayout)))
+			continue
+		}
+
+		if isSplitAction(tx.Action) {
+			first, ok := pendingSplits[key]
+			if !ok {
+				pendingSplits[key] = tx
+				continue
+			}
+			delete(pendingSplits, key)
+			if split, ok := stockSplit(first, tx); ok {
+				applySplit(openLots[key], split)
+				ledger.Splits = append(ledger.Splits, split)
+			} else {
+				ledger.Warnings = append(ledger.Warnings,
+					fmt.Sprintf("%s stock split on %s has no matching close and open rows; ignored", key, tx.Time.Format(rateDateLayout)))
+			}
+			continue
+		}
+
+		if isBuyAction(tx.Action) {
+			lotCounts[key]++
+			openLots[key] = le.addLot(ledger, openLots[key], tx, key, lotCounts[key], converter)
+			if le.washSaleDays > 0 {
+				openLots[key] = le.replaceWashSales(ledger, openLots[key], pendingWashSales, key, tx.Time)
+			}
+			continue
+		}
+
+		disposal := le.matchDisposal(openLots[key], tx, converter)
+		if disposal.UnmatchedShares > ShareEpsilon {
+			ledger.Warnings = append(ledger.Warnings,
+				fmt.Sprintf("%s sold %.6f shares on %s without matching purchases; treated as zero cost",
+					key, disposal.UnmatchedShares, tx.Time.Format(rateDateLayout)))
+		}
+		openLots[key] = pruneClosedLots(openLots[key])
+		ledger.Disposals = append(ledger.Disposals, disposal)
+		if le.washSaleDays > 0 {
+			openLots[key] = le.recordWashSales(ledger, len(ledger.Disposals)-1, openLots[key], pendingWashSales, key)
+		}
+	}
+
+	for _, key := range sortedSplitKeys(pendingSplits) {
+		ledger.Warnings = append(ledger.Warnings,
+			fmt.Sprintf("%s stock split on %s has only one of its close and open rows; ignored",
+				key, pendingSplits[key].Time.Format(rateDateLayout)))
+	}
+	ledger.Warnings = append(ledger.Warnings, converter.warnings()...)
+	return ledger
+}
+
+// stockSplit pairs the close and open rows of a split, in either order
+func stockSplit(first, second types.Transaction) (StockSplit, bool) {
+	closeRow, openRow := first, second
+	if isSplitOpen(first.Action) {
+		closeRow, openRow = second, first
+	}
+	if isSplitOpen(closeRow.Action) || !isSplitOpen(openRow.Action) {
+		return StockSplit{}, false
+	}
+
+	split := StockSplit{
+		ISIN:         safeDeref(openRow.ISIN),
+		Ticker:       safeDeref(openRow.Ticker),
+		Date:         second.Time,
+		SharesBefore: *closeRow.Shares,
+		SharesAfter:  *openRow.Shares,
+	}
+	split.Ratio = split.SharesAfter / split.SharesBefore
+	return split, true
+}
+
+// applySplit multiplies the shares of open lots by the split ratio, keeping their cost, and records
+// the split on them
+func applySplit(open []*Lot, split StockSplit) {
+	for _, lot := range open {
+		lot.Shares *= split.Ratio
+		lot.Remaining *= split.Ratio
+		lot.washReplaced *= split.Ratio
+		lot.Splits = append(lot.Splits, split)
+	}
+}
+
+// sortedSplitKeys returns the securities with an unpaired split row in order
+func sortedSplitKeys(pending map[string]types.Transaction) []string {
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// addLot records an acquisition, merging into the pool when using average cost
+func (le *LotEngine) addLot(
+	ledger *LotLedger,
+	open []*Lot,
+	tx types.Transaction,
+	key string,
+	sequence int,
+	converter *currencyConverter,
+) []*Lot {
+	shares := *tx.Shares
+	cost := le.tradeValue(tx, converter) + le.tradeFees(tx, converter)
+	localCost := 0.0
+	if tx.PricePerShare != nil {
+		localCost = shares * *tx.PricePerShare
+	}
+
+	if le.method == MatchingAverageCost && len(open) > 0 {
+		pool := open[0]
+		pool.Cost = pool.RemainingCost() + cost
+		pool.LocalCost = pool.RemainingLocalCost() + localCost
+		pool.Shares = pool.Remaining + shares
+		pool.Remaining = pool.Shares
+		return open
+	}
+
+	lot := &Lot{
+		ID:            fmt.Sprintf("%s#%d", key, sequence),
+		ISIN:          safeDeref(tx.ISIN),
+		Ticker:        safeDeref(tx.Ticker),
+		Name:          safeDeref(tx.Name),
+		AcquiredAt:    tx.Time,
+		PurchasedAt:   tx.Time,
+		Shares:        shares,
+		Cost:          cost,
+		Remaining:     shares,
+		Currency:      le.baseCurrency,
+		LocalCost:     localCost,
+		LocalCurrency: safeDeref(tx.CurrencyPricePerShare),
+	}
+	ledger.Lots = append(ledger.Lots, lot)
+	return append(open, lot)
+}
+
+// matchDisposal matches a sell against open lots in order
+func (le *LotEngine) matchDisposal(open []*Lot, tx types.Transaction, converter *currencyConverter) Disposal {
+	shares := *tx.Shares
+	gross := le.tradeValue(tx, converter)
+	fees := le.tradeFees(tx, converter)
+
+	disposal := Disposal{
+		ID:            safeDeref(tx.ID),
+		ISIN:          safeDeref(tx.ISIN),
+		Ticker:        safeDeref(tx.Ticker),
+		Name:          safeDeref(tx.Name),
+		Date:          tx.Time,
+		Shares:        shares,
+		PriceCurrency: safeDeref(tx.CurrencyPricePerShare),
+		GrossProceeds: gross,
+		Fees:          fees,
+		Proceeds:      gross - fees,
+		Currency:      le.baseCurrency,
+	}
+	if tx.PricePerShare != nil {
+		disposal.PricePerShare = *tx.PricePerShare
+	}
+	if tx.Result != nil {
+		result := converter.convert(*tx.Result, tx.CurrencyResult, tx.Time, tx.ExchangeRate)
+		disposal.BrokerResult = &result
+	}
+
+	if le.method == MatchingFourWeek {
+		open = fourWeekOrder(open, tx.Time, le.fourWeekDays)
+	}
+
+	remaining := shares
+	for _, lot := range open {
+		if remaining <= ShareEpsilon {
+			break
+		}
+		if lot.Remaining <= ShareEpsilon {
+			continue
+		}
+
+		matched := math.Min(remaining, lot.Remaining)
+		cost := matched * lot.CostPerShare()
+		proceeds := disposal.Proceeds * matched / shares
+
+		disposal.Matches = append(disposal.Matches, LotMatch{
+			LotID:       lot.ID,
+			AcquiredAt:  lot.AcquiredAt,
+			Shares:      matched,
+			Cost:        cost,
+			Proceeds:    proceeds,
+			GainLoss:    proceeds - cost,
+			HoldingDays: HoldingDays(lot.AcquiredAt, tx.Time),
+			LocalCost:   lot.LocalCost * matched / lot.Shares,
+		})
+		disposal.Cost += cost
+
+		lot.Remaining -= matched
+		remaining -= matched
+	}
+
+	if remaining > ShareEpsilon {
+		disposal.UnmatchedShares = remaining
+	}
+	disposal.GainLoss = disposal.Proceeds - disposal.Cost
+
+	return disposal
+}
+
+// tradeValue returns shares × price converted into the base currency
+func (le *LotEngine) tradeValue(tx types.Transaction, converter *currencyConverter) float64 {
+	if tx.PricePerShare != nil && *tx.PricePerShare > 0 {
+		value := *tx.Shares * *tx.PricePerShare
+		return converter.convert(value, tx.CurrencyPricePerShare, tx.Time, tx.ExchangeRate)
+	}
+	if tx.Total != nil {
+		return converter.convert(math.Abs(*tx.Total), tx.CurrencyTotal, tx.Time, tx.ExchangeRate)
+	}
+	return 0
+}
+
+// tradeFees returns the transaction fees converted into the base currency
+func (le *LotEngine) tradeFees(tx types.Transaction, converter *currencyConverter) float64 {
+	if tx.CurrencyConversionFee == nil {
+		return 0
+	}
+	return converter.convert(math.Abs(*tx.CurrencyConversionFee), tx.CurrencyCurrencyConversionFee, tx.Time, nil)
+}
+
+// SetFourWeekDays sets the window of the four-week matching method: shares bought within days
+// before a disposal are matched first
+func (le *LotEngine) SetFourWeekDays(days int) {
+	le.fourWeekDays = days
+}
+
+// fourWeekOrder returns lots acquired within days before date, newest first, followed by the
+// older lots in acquisition order
+func fourWeekOrder(open []*Lot, date time.Time, days int) []*Lot {
+	var recent, older []*Lot
+	for _, lot := range open {
+		if HoldingDays(lot.AcquiredAt, date) <= days {
+			recent = append(recent, lot)
+		} else {
+			older = append(older, lot)
+		}
+	}
+
+	ordered := make([]*Lot, 0, len(open))
+	for i := len(recent) - 1; i >= 0; i-- {
+		ordered = append(ordered, recent[i])
+	}
+	return append(ordered, older...)
+}
+
+// pruneClosedLots drops fully disposed lots from the open list
+func pruneClosedLots(lots []*Lot) []*Lot {
+	open := lots[:0]
+	for _, lot := range lots {
+		if lot.Remaining > ShareEpsilon {
+			open = append(open, lot)
+		}
+	}
+	return open
+}
+
+// SecurityKey identifies a security by ISIN, falling back to its ticker
+func SecurityKey(tx types.Transaction) string {
+	if tx.ISIN != nil && *tx.ISIN != "" {
+		return *tx.ISIN
+	}
+	if tx.Ticker != nil {
+		return *tx.Ticker
+	}
+	return ""
+}
+
+// HoldingDays returns the number of whole days between acquisition and disposal
+func HoldingDays(acquired, disposed time.Time) int {
+	return int(truncateToDay(disposed).Sub(truncateToDay(acquired)).Hours() / HoursPerDay)
+}
+
+// isTradeAction checks if the action is a buy or sell order
+func isTradeAction(action types.TransactionType) bool {
+	return isBuyAction(action) || isSellAction(action)
+}
+
+// isBuyAction checks if the action is a buy order
+func isBuyAction(action types.TransactionType) bool {
+	switch action {
+	case types.TransactionTypeMarketBuy, types.TransactionTypeLimitBuy, types.TransactionTypeStopBuy:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSellAction checks if the action is a sell order
+func isSellAction(action types.TransactionType) bool {
+	switch action {
+	case types.TransactionTypeMarketSell, types.TransactionTypeLimitSell, types.TransactionTypeStopSell:
+		return true
+	default:
+		return false
+	}
+}
+
+// isSplitAction checks if the action is either row of a stock split
+func isSplitAction(action types.TransactionType) bool {
+	return action == types.TransactionTypeSplitOpen || action == types.TransactionTypeSplitClose
+}
+
+// isSplitOpen checks if the action is the row of a stock split holding the shares after it
+func isSplitOpen(action types.TransactionType) bool {
+	return action == types.TransactionTypeSplitOpen
+}
+
+// safeDeref safely dereferences a string pointer
+func safeDeref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,139 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func tradeTx(action types.TransactionType, date time.Time, isin string, shares, price float64) types.Transaction {
+	return types.Transaction{
+		Action:                action,
+		Time:                  date,
+		ISIN:                  stringPtr(isin),
+		Ticker:                stringPtr(isin[len(isin)-4:]),
+		Shares:                floatPtr(shares),
+		PricePerShare:         floatPtr(price),
+		CurrencyPricePerShare: stringPtr("EUR"),
+	}
+}
+
+func TestLotEngine_ProcessFIFO(t *testing.T) {
+	engine := NewLotEngine("EUR", MatchingFIFO, nil)
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 6, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 150),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 15, 200),
+	}
+
+	ledger := engine.Process(transactions)
+
+	if len(ledger.Disposals) != 1 {
+		t.Fatalf("Expected 1 disposal, got %d", len(ledger.Disposals))
+	}
+
+	disposal := ledger.Disposals[0]
+	if len(disposal.Matches) != 2 {
+		t.Fatalf("Expected 2 lot matches, got %d", len(disposal.Matches))
+	}
+
+	// 10 @ 100 + 5 @ 150 = 1750 cost, 15 @ 200 = 3000 proceeds
+	if abs(disposal.Cost-1750) > 0.001 {
+		t.Errorf("Expected cost 1750, got %.2f", disposal.Cost)
+	}
+	if abs(disposal.GainLoss-1250) > 0.001 {
+		t.Errorf("Expected gain 1250, got %.2f", disposal.GainLoss)
+	}
+	if disposal.Matches[0].HoldingDays != 387 {
+		t.Errorf("Expected first match held 387 days, got %d", disposal.Matches[0].HoldingDays)
+	}
+
+	open := ledger.OpenLots()
+	if len(open) != 1 || abs(open[0].Remaining-5) > ShareEpsilon {
+		t.Errorf("Expected one open lot with 5 shares remaining, got %+v", open)
+	}
+}
+
+func TestLotEngine_ProcessAverageCost(t *testing.T) {
+	engine := NewLotEngine("EUR", MatchingAverageCost, nil)
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 6, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 150),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 200),
+	}
+
+	ledger := engine.Process(transactions)
+	disposal := ledger.Disposals[0]
+
+	// Average cost is 125 per share
+	if abs(disposal.Cost-1250) > 0.001 {
+		t.Errorf("Expected pooled cost 1250, got %.2f", disposal.Cost)
+	}
+	if len(ledger.Lots) != 1 {
+		t.Errorf("Expected a single pooled lot, got %d", len(ledger.Lots))
+	}
+}
+
+func TestLotEngine_UnmatchedSell(t *testing.T) {
+	engine := NewLotEngine("EUR", MatchingFIFO, nil)
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 2, 50),
+	}
+
+	ledger := engine.Process(transactions)
+
+	if ledger.Disposals[0].UnmatchedShares != 2 {
+		t.Errorf("Expected 2 unmatched shares, got %.2f", ledger.Disposals[0].UnmatchedShares)
+	}
+	if len(ledger.Warnings) == 0 {
+		t.Error("Expected a warning for unmatched shares")
+	}
+}
+
+func TestCountryFromISIN(t *testing.T) {
+	tests := []struct {
+		isin string
+		want string
+	}{
+		{"US0378331005", "US"},
+		{"ie00b4l5y983", "IE"},
+		{"", UnknownCountry},
+		{"12345", UnknownCountry},
+	}
+
+	for _, tt := range tests {
+		if got := CountryFromISIN(tt.isin); got != tt.want {
+			t.Errorf("CountryFromISIN(%q) = %s, want %s", tt.isin, got, tt.want)
+		}
+	}
+}
+
+func TestLotEngine_ProcessStockSplit(t *testing.T) {
+	const isin = "US0378331005"
+	date := time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC)
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, date.AddDate(0, -6, 0), isin, 10, 400),
+		tradeTx(types.TransactionTypeSplitOpen, date, isin, 100, 40),
+		tradeTx(types.TransactionTypeSplitClose, date, isin, 10, 400),
+		tradeTx(types.TransactionTypeMarketSell, date.AddDate(0, 1, 0), isin, 50, 50),
+		tradeTx(types.TransactionTypeSplitOpen, date.AddDate(0, 2, 0), "IE00B4L5Y983", 5, 10),
+	}
+
+	ledger := NewLotEngine("EUR", MatchingFIFO, nil).Process(transactions)
+
+	if len(ledger.Splits) != 1 || ledger.Splits[0].Ratio != 10 {
+		t.Fatalf("Expected one 10:1 split, got %+v", ledger.Splits)
+	}
+	disposal := ledger.Disposals[0]
+	if disposal.UnmatchedShares != 0 || abs(disposal.Cost-2000) > 1e-9 || abs(disposal.GainLoss-500) > 1e-9 {
+		t.Errorf("Expected 50 split shares costing 2000 and a 500 gain, got %+v", disposal)
+	}
+	if len(ledger.Warnings) != 1 {
+		t.Errorf("Expected a warning for the unpaired split row, got %v", ledger.Warnings)
+	}
+}
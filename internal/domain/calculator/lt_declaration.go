@@ -0,0 +1,171 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// GPM311 income type codes for foreign investment income rows.
+// These follow the VMI income type code list; check them against the instructions for the declared year.
+const (
+	GPM311CodeSecuritiesSale = "13"
+	GPM311CodeDividends      = "17"
+)
+
+// GPM311Row is a single income row of the GPM311 foreign income annex
+type GPM311Row struct {
+	IncomeTypeCode string  `json:"income_type_code"`
+	IncomeType     string  `json:"income_type"`
+	Country        string  `json:"country"`
+	Income         float64 `json:"income"`
+	Deductions     float64 `json:"deductions"`
+	TaxPaidAbroad  float64 `json:"tax_paid_abroad"`
+	CreditableTax  float64 `json:"creditable_tax"`
+}
+
+// LTDeclaration holds the figures needed for a Lithuanian GPM311 declaration
+type LTDeclaration struct {
+	Year                  int         `json:"year"`
+	Currency              string      `json:"currency"`
+	Proceeds              float64     `json:"proceeds"`
+	AcquisitionCost       float64     `json:"acquisition_cost"`
+	Gains                 float64     `json:"gains"`
+	Losses                float64     `json:"losses"`
+	NetGain               float64     `json:"net_gain"`
+	LossesBroughtForward  float64     `json:"losses_brought_forward"`
+	LossesUsed            float64     `json:"losses_used"`
+	LossesCarriedForward  float64     `json:"losses_carried_forward"`
+	Exemption             float64     `json:"exemption"`
+	TaxableGain           float64     `json:"taxable_gain"`
+	CapitalGainsTax       float64     `json:"capital_gains_tax"`
+	GrossDividends        float64     `json:"gross_dividends"`
+	WithholdingTax        float64     `json:"withholding_tax"`
+	CreditableWithholding float64     `json:"creditable_withholding"`
+	DividendTax           float64     `json:"dividend_tax"`
+	TotalTax              float64     `json:"total_tax"`
+	Rows                  []GPM311Row `json:"rows"`
+	Disposals             []Disposal  `json:"disposals"`
+	Warnings              []string    `json:"warnings,omitempty"`
+}
+
+// GenerateLTDeclaration computes Lithuanian capital gains and dividend tax for a year, grouped
+// into GPM311 annex rows by income type and country of source. Securities losses from the five
+// previous years reduce the year's gain.
+func (c *TaxCalculator) GenerateLTDeclaration(transactions []types.Transaction, year int) (*LTDeclaration, error) {
+	jurisdiction, err := c.lookupJurisdiction("LT", year)
+	if err != nil {
+		return nil, err
+	}
+
+	declaration := &LTDeclaration{
+		Year:     year,
+		Currency: jurisdiction.Currency,
+	}
+
+	engine := c.newLotEngine(jurisdiction, jurisdiction.Currency)
+	ledger := engine.Process(transactions)
+	from, to := taxYearBounds(year)
+	declaration.Disposals = ledger.DisposalsBetween(from, to)
+	declaration.Warnings = ledger.Warnings
+
+	rows := make(map[string]*GPM311Row)
+	c.addLTDisposalRows(declaration, rows)
+	c.addLTDividendRows(declaration, rows, transactions, jurisdiction)
+
+	declaration.NetGain = declaration.Gains - declaration.Losses
+
+	// Losses from earlier years reduce the gain before the annual exemption
+	nets := make(map[int]float64)
+	for _, disposal := range ledger.Disposals {
+		nets[jurisdiction.TaxYearOf(disposal.Date)] += disposal.GainLoss
+	}
+	losses := lossesForYear(jurisdiction, nets, year)
+	declaration.LossesBroughtForward = losses.BroughtForward
+	declaration.LossesUsed = losses.Used
+	declaration.LossesCarriedForward = losses.CarriedForward
+
+	netAfterLosses := declaration.NetGain - declaration.LossesUsed
+	declaration.Exemption = math.Min(math.Max(netAfterLosses, 0), jurisdiction.Allowances.CapitalGains)
+	declaration.TaxableGain = math.Max(netAfterLosses-declaration.Exemption, 0)
+	declaration.CapitalGainsTax = declaration.TaxableGain * jurisdiction.CapitalGainsTaxRate
+	declaration.TotalTax = declaration.CapitalGainsTax + declaration.DividendTax
+
+	declaration.Rows = sortedGPM311Rows(rows)
+	return declaration, nil
+}
+
+// addLTDisposalRows accumulates securities sale income and acquisition cost per country
+func (c *TaxCalculator) addLTDisposalRows(declaration *LTDeclaration, rows map[string]*GPM311Row) {
+	for _, disposal := range declaration.Disposals {
+		declaration.Proceeds += disposal.Proceeds
+		declaration.AcquisitionCost += disposal.Cost
+		if disposal.GainLoss > 0 {
+			declaration.Gains += disposal.GainLoss
+		} else {
+			declaration.Losses += -disposal.GainLoss
+		}
+
+		row := gpm311Row(rows, GPM311CodeSecuritiesSale, "Securities sale", CountryFromISIN(disposal.ISIN))
+		row.Income += disposal.Proceeds
+		row.Deductions += disposal.Cost
+	}
+}
+
+// addLTDividendRows accumulates dividends and creditable foreign tax per country
+func (c *TaxCalculator) addLTDividendRows(
+	declaration *LTDeclaration,
+	rows map[string]*GPM311Row,
+	transactions []types.Transaction,
+	jurisdiction TaxJurisdiction,
+) {
+	for _, record := range c.dividendRecords(transactions, jurisdiction, jurisdiction.Currency) {
+		if record.Date.Year() != declaration.Year {
+			continue
+		}
+
+		credit := math.Min(record.WithholdingTax, record.Amount*jurisdiction.CreditCap())
+		tax := math.Max(record.Amount*jurisdiction.DividendTaxRate-credit, 0)
+
+		declaration.GrossDividends += record.Amount
+		declaration.WithholdingTax += record.WithholdingTax
+		declaration.CreditableWithholding += credit
+		declaration.DividendTax += tax
+
+		row := gpm311Row(rows, GPM311CodeDividends, "Dividends", CountryFromISIN(record.ISIN))
+		row.Income += record.Amount
+		row.TaxPaidAbroad += record.WithholdingTax
+		row.CreditableTax += credit
+	}
+}
+
+// gpm311Row returns the row for an income code and country, creating it if needed
+func gpm311Row(rows map[string]*GPM311Row, code, incomeType, country string) *GPM311Row {
+	key := code + "/" + country
+	row, exists := rows[key]
+	if !exists {
+		row = &GPM311Row{
+			IncomeTypeCode: code,
+			IncomeType:     incomeType,
+			Country:        country,
+		}
+		rows[key] = row
+	}
+	return row
+}
+
+// sortedGPM311Rows returns rows ordered by income code and country
+func sortedGPM311Rows(rows map[string]*GPM311Row) []GPM311Row {
+	sorted := make([]GPM311Row, 0, len(rows))
+	for _, row := range rows {
+		sorted = append(sorted, *row)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].IncomeTypeCode != sorted[j].IncomeTypeCode {
+			return sorted[i].IncomeTypeCode < sorted[j].IncomeTypeCode
+		}
+		return sorted[i].Country < sorted[j].Country
+	})
+	return sorted
+}
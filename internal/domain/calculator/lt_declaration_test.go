@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateLTDeclaration(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 5, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 250),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 5, 80),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 5, 60),
+		{
+			Action:         types.TransactionTypeDividend,
+			Time:           time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+			ISIN:           stringPtr("US0378331005"),
+			Result:         floatPtr(100),
+			CurrencyResult: stringPtr("EUR"),
+			WithholdingTax: floatPtr(30),
+		},
+	}
+
+	declaration, err := calc.GenerateLTDeclaration(transactions, 2024)
+	if err != nil {
+		t.Fatalf("GenerateLTDeclaration() error = %v", err)
+	}
+
+	// Gain 1500 on US shares, loss 100 on IE fund
+	if abs(declaration.NetGain-1400) > 0.001 {
+		t.Errorf("Expected net gain 1400, got %.2f", declaration.NetGain)
+	}
+	if declaration.Exemption != 500 {
+		t.Errorf("Expected exemption 500, got %.2f", declaration.Exemption)
+	}
+	if abs(declaration.CapitalGainsTax-135) > 0.001 {
+		t.Errorf("Expected capital gains tax 135, got %.2f", declaration.CapitalGainsTax)
+	}
+
+	// Withholding of 30% is only creditable up to 15%
+	if abs(declaration.CreditableWithholding-15) > 0.001 {
+		t.Errorf("Expected creditable withholding 15, got %.2f", declaration.CreditableWithholding)
+	}
+	if declaration.DividendTax != 0 {
+		t.Errorf("Expected no dividend tax due, got %.2f", declaration.DividendTax)
+	}
+
+	// Rows: dividends US, securities IE, securities US
+	if len(declaration.Rows) != 3 {
+		t.Fatalf("Expected 3 GPM311 rows, got %d", len(declaration.Rows))
+	}
+	for _, row := range declaration.Rows {
+		if row.IncomeTypeCode == GPM311CodeSecuritiesSale && row.Country == "US" {
+			if row.Income != 2500 || row.Deductions != 1000 {
+				t.Errorf("Unexpected US securities row: %+v", row)
+			}
+		}
+	}
+}
+
+func TestTaxCalculator_CalculateUnsupportedJurisdiction(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	_, err := calc.Calculate(nil, types.ProcessingOptions{Jurisdiction: "XX"})
+	if err == nil {
+		t.Error("Expected error for unsupported jurisdiction")
+	}
+}
@@ -0,0 +1,221 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// NLBox3Year holds the Box 3 parameters published for one tax year: the deemed-return percentages,
+// heffingsvrij vermogen, debt threshold and tax rate. Savings percentages are set after the year
+// ends; check the latest values in the jurisdiction definition.
+type NLBox3Year struct {
+	SavingsReturn    float64 `yaml:"savings_return" json:"savings_return"`
+	InvestmentReturn float64 `yaml:"investment_return" json:"investment_return"`
+	DebtReturn       float64 `yaml:"debt_return" json:"debt_return"`
+	TaxFreeAllowance float64 `yaml:"tax_free_allowance" json:"tax_free_allowance"`
+	DebtThreshold    float64 `yaml:"debt_threshold" json:"debt_threshold"`
+	TaxRate          float64 `yaml:"tax_rate" json:"tax_rate"`
+}
+
+// NLOptions holds the balances outside the Trading 212 portfolio that count towards Box 3
+type NLOptions struct {
+	// Cash is the total of bank cash balances outside Trading 212 on 1 January
+	Cash float64 `json:"cash"`
+	// Debts is the total of Box 3 debts on 1 January
+	Debts float64 `json:"debts"`
+	// FiscalPartner doubles the heffingsvrij vermogen and debt threshold
+	FiscalPartner bool `json:"fiscal_partner"`
+}
+
+// NLWorksheetLine is one line of the Box 3 worksheet
+type NLWorksheetLine struct {
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// NLReport holds the Dutch Box 3 computation for a year
+type NLReport struct {
+	Year                int               `json:"year"`
+	Currency            string            `json:"currency"`
+	Parameters          NLBox3Year        `json:"parameters"`
+	Options             NLOptions         `json:"options"`
+	Investments         float64           `json:"investments"`
+	BrokerCash          float64           `json:"broker_cash"`
+	Savings             float64           `json:"savings"`
+	Debts               float64           `json:"debts"`
+	DeemedReturn        float64           `json:"deemed_return"`
+	ReturnBase          float64           `json:"return_base"`
+	TaxFreeAllowance    float64           `json:"tax_free_allowance"`
+	TaxableBase         float64           `json:"taxable_base"`
+	TaxableIncome       float64           `json:"taxable_income"`
+	DeemedTax           float64           `json:"deemed_tax"`
+	EndValue            float64           `json:"end_value"`
+	NetPurchases        float64           `json:"net_purchases"`
+	Dividends           float64           `json:"dividends"`
+	Interest            float64           `json:"interest"`
+	ActualReturn        float64           `json:"actual_return"`
+	CounterproofTax     float64           `json:"counterproof_tax"`
+	CounterproofApplies bool              `json:"counterproof_applies"`
+	Tax                 float64           `json:"tax"`
+	ForeignTaxPaid      float64           `json:"foreign_tax_paid"`
+	Worksheet           []NLWorksheetLine `json:"worksheet"`
+	Warnings            []string          `json:"warnings,omitempty"`
+}
+
+// GenerateNLReport computes Box 3 tax on the portfolio value at 1 January of year, together with
+// the actual-return counterproof
+func (c *TaxCalculator) GenerateNLReport(transactions []types.Transaction, year int, options NLOptions) (*NLReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("NL", year)
+	if err != nil {
+		return nil, err
+	}
+
+	parameters := jurisdiction.Years[year].Box3
+	if parameters == nil {
+		return nil, fmt.Errorf("no Box 3 parameters for %d", year)
+	}
+
+	report := &NLReport{
+		Year:       year,
+		Currency:   jurisdiction.Currency,
+		Parameters: *parameters,
+		Options:    options,
+	}
+
+	// The peildatum is 1 January, so the holdings at the end of the previous year count
+	portfolioCalc := NewPortfolioCalculator(jurisdiction.Currency)
+	portfolioCalc.SetPriceProvider(c.prices)
+	portfolioCalc.SetFXRateProvider(c.ratesFor(jurisdiction, jurisdiction.Currency))
+	startPortfolio := portfolioCalc.CalculateEndOfYearPortfolio(transactions, year-1)
+	endPortfolio := portfolioCalc.CalculateEndOfYearPortfolio(transactions, year)
+	report.Investments = startPortfolio.TotalMarketValue
+	report.EndValue = endPortfolio.TotalMarketValue
+	c.addNLBrokerCash(report, transactions, jurisdiction)
+	report.Savings = options.Cash + report.BrokerCash
+
+	threshold := parameters.DebtThreshold
+	allowance := parameters.TaxFreeAllowance
+	if options.FiscalPartner {
+		threshold *= 2
+		allowance *= 2
+	}
+	report.Debts = math.Max(options.Debts-threshold, 0)
+
+	report.DeemedReturn = report.Savings*parameters.SavingsReturn +
+		report.Investments*parameters.InvestmentReturn -
+		report.Debts*parameters.DebtReturn
+	report.ReturnBase = math.Max(report.Savings+report.Investments-report.Debts, 0)
+	report.TaxFreeAllowance = allowance
+	report.TaxableBase = math.Max(report.ReturnBase-allowance, 0)
+	if report.ReturnBase > 0 {
+		report.TaxableIncome = math.Max(report.DeemedReturn*report.TaxableBase/report.ReturnBase, 0)
+	}
+	report.DeemedTax = report.TaxableIncome * parameters.TaxRate
+
+	c.addNLActualReturn(report, transactions, jurisdiction)
+	report.CounterproofTax = math.Max(report.ActualReturn, 0) * parameters.TaxRate
+	report.CounterproofApplies = report.CounterproofTax < report.DeemedTax
+	report.Tax = report.DeemedTax
+	if report.CounterproofApplies {
+		report.Tax = report.CounterproofTax
+	}
+
+	report.Worksheet = report.worksheet()
+	if c.prices == nil {
+		report.Warnings = append(report.Warnings,
+			"portfolio values use the last trade price before the valuation date; use the broker's 1 January statement where it differs")
+	}
+	for _, portfolio := range []*types.PortfolioSummary{startPortfolio, endPortfolio} {
+		if c.prices != nil && portfolio.StalePrices > 0 {
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"%d position(s) on %s are valued at prices more than %d days old",
+				portfolio.StalePrices, portfolio.AsOfDate.Format(rateDateLayout), StalePriceDays))
+		}
+	}
+	return report, nil
+}
+
+// addNLBrokerCash values the Trading 212 cash held at the end of the previous year. Negative
+// balances, which mean part of the history is missing, are left out.
+func (c *TaxCalculator) addNLBrokerCash(report *NLReport, transactions []types.Transaction, jurisdiction TaxJurisdiction) {
+	currency := jurisdiction.Currency
+	cash := NewCashCalculator(currency)
+	cash.SetFXRateProvider(c.ratesFor(jurisdiction, currency))
+	yearEnd := cash.YearEndCash(cash.BuildLedger(transactions), report.Year-1)
+
+	for _, balance := range yearEnd.Balances {
+		switch {
+		case balance.Balance < -CashTolerance:
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"Trading 212 %s cash on 1 January is negative (%.2f) and left out; an export may be missing",
+				balance.Currency, balance.Balance))
+		case !balance.Converted:
+			report.Warnings = append(report.Warnings, fmt.Sprintf(
+				"no exchange rate for the Trading 212 %s cash on 1 January; add it to --cash", balance.Currency))
+		case balance.BaseValue > 0:
+			report.BrokerCash += balance.BaseValue
+		}
+	}
+}
+
+// addNLActualReturn computes the actual return for the counterproof: income received plus the change
+// in portfolio value that is not explained by purchases and sales
+func (c *TaxCalculator) addNLActualReturn(report *NLReport, transactions []types.Transaction, jurisdiction TaxJurisdiction) {
+	currency := jurisdiction.Currency
+	converter := newCurrencyConverter(currency, accountCurrency(transactions), c.ratesFor(jurisdiction, currency))
+	for _, tx := range transactions {
+		if tx.Time.Year() != report.Year || !isTradeAction(tx.Action) || tx.Shares == nil {
+			continue
+		}
+		value := 0.0
+		if tx.PricePerShare != nil {
+			value = converter.convert(*tx.Shares**tx.PricePerShare, tx.CurrencyPricePerShare, tx.Time, tx.ExchangeRate)
+		}
+		if isBuyAction(tx.Action) {
+			report.NetPurchases += value
+		} else {
+			report.NetPurchases -= value
+		}
+	}
+
+	for _, record := range c.dividendRecords(transactions, jurisdiction, currency) {
+		if record.Date.Year() == report.Year {
+			report.Dividends += record.Amount
+			report.ForeignTaxPaid += record.WithholdingTax
+		}
+	}
+	for _, record := range NewIncomeCalculator(currency).extractInterestRecords(transactions) {
+		if record.Date.Year() == report.Year {
+			report.Interest += record.Amount
+		}
+	}
+
+	report.ActualReturn = report.Dividends + report.Interest + report.EndValue - report.Investments - report.NetPurchases
+}
+
+// worksheet lays out the Box 3 computation line by line
+func (r *NLReport) worksheet() []NLWorksheetLine {
+	return []NLWorksheetLine{
+		{fmt.Sprintf("Bank cash on 1 January %d", r.Year), r.Options.Cash},
+		{fmt.Sprintf("Trading 212 cash on 1 January %d", r.Year), r.BrokerCash},
+		{fmt.Sprintf("Investments on 1 January %d", r.Year), r.Investments},
+		{"Debts above the threshold", r.Debts},
+		{fmt.Sprintf("Deemed return on savings (%.2f%%)", r.Parameters.SavingsReturn*PercentMultiplier), r.Savings * r.Parameters.SavingsReturn},
+		{fmt.Sprintf("Deemed return on investments (%.2f%%)", r.Parameters.InvestmentReturn*PercentMultiplier), r.Investments * r.Parameters.InvestmentReturn},
+		{fmt.Sprintf("Deemed return on debts (%.2f%%)", r.Parameters.DebtReturn*PercentMultiplier), -r.Debts * r.Parameters.DebtReturn},
+		{"Total deemed return", r.DeemedReturn},
+		{"Rendementsgrondslag", r.ReturnBase},
+		{"Heffingsvrij vermogen", r.TaxFreeAllowance},
+		{"Grondslag sparen en beleggen", r.TaxableBase},
+		{"Voordeel uit sparen en beleggen", r.TaxableIncome},
+		{fmt.Sprintf("Box 3 tax at %.0f%%", r.Parameters.TaxRate*PercentMultiplier), r.DeemedTax},
+		{"Counterproof: dividends and interest", r.Dividends + r.Interest},
+		{"Counterproof: value change excluding net purchases", r.EndValue - r.Investments - r.NetPurchases},
+		{"Counterproof: actual return", r.ActualReturn},
+		{"Counterproof: tax on actual return", r.CounterproofTax},
+		{"Box 3 tax due", r.Tax},
+		{"Foreign dividend tax to credit", r.ForeignTaxPaid},
+	}
+}
@@ -0,0 +1,85 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateNLReport(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	buy := func(date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(types.TransactionTypeMarketBuy, date, "IE00BK5BQT80", shares, price)
+		tx.Ticker = stringPtr("VWCE")
+		tx.Total = floatPtr(shares * price)
+		tx.CurrencyTotal = stringPtr("EUR")
+		return tx
+	}
+
+	transactions := []types.Transaction{
+		buy(time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC), 100, 600),
+		buy(time.Date(2024, 12, 1, 10, 0, 0, 0, time.UTC), 10, 650),
+		{
+			Action:         types.TransactionTypeDividend,
+			Time:           time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			ISIN:           stringPtr("IE00BK5BQT80"),
+			Result:         floatPtr(1000),
+			CurrencyResult: stringPtr("EUR"),
+		},
+	}
+
+	report, err := calc.GenerateNLReport(transactions, 2024, NLOptions{Cash: 20000})
+	if err != nil {
+		t.Fatalf("GenerateNLReport() error = %v", err)
+	}
+
+	if report.Investments != 60000 || report.EndValue != 71500 {
+		t.Errorf("Expected 60000 on 1 January and 71500 at year end, got %.2f and %.2f", report.Investments, report.EndValue)
+	}
+	if abs(report.DeemedReturn-3912) > 0.001 {
+		t.Errorf("Expected deemed return 3912, got %.2f", report.DeemedReturn)
+	}
+	if abs(report.TaxableIncome-1124.7) > 0.001 || abs(report.DeemedTax-1124.7*0.36) > 0.001 {
+		t.Errorf("Expected taxable income 1124.70 and tax %.2f, got %.2f and %.2f", 1124.7*0.36, report.TaxableIncome, report.DeemedTax)
+	}
+	if abs(report.ActualReturn-6000) > 0.001 || report.CounterproofApplies {
+		t.Errorf("Expected actual return 6000 without counterproof, got %.2f (applies: %v)", report.ActualReturn, report.CounterproofApplies)
+	}
+	if report.Tax != report.DeemedTax || len(report.Worksheet) == 0 {
+		t.Errorf("Expected deemed tax to be due with a worksheet")
+	}
+
+	partner, err := calc.GenerateNLReport(transactions, 2024, NLOptions{Cash: 20000, FiscalPartner: true})
+	if err != nil {
+		t.Fatalf("GenerateNLReport() error = %v", err)
+	}
+	if partner.Tax != 0 {
+		t.Errorf("Expected no tax below the doubled allowance, got %.2f", partner.Tax)
+	}
+
+	if _, err := calc.GenerateNLReport(transactions, 2015, NLOptions{}); err == nil {
+		t.Error("Expected error for a year without Box 3 parameters")
+	}
+}
+
+func TestTaxCalculator_GenerateNLReportBrokerCash(t *testing.T) {
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2023, 6, 1, 10, 0, 0, 0, time.UTC), "IE00BK5BQT80", 10, 600)
+	buy.Total, buy.CurrencyTotal = floatPtr(6000), stringPtr("EUR")
+	transactions := []types.Transaction{
+		{Action: types.TransactionTypeDeposit, Time: time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC), Total: floatPtr(10000), CurrencyTotal: stringPtr("EUR")},
+		buy,
+		{Action: types.TransactionTypeWithdrawal, Time: time.Date(2024, 2, 1, 10, 0, 0, 0, time.UTC), Total: floatPtr(-4000), CurrencyTotal: stringPtr("EUR")},
+	}
+
+	report, err := NewTaxCalculator().GenerateNLReport(transactions, 2024, NLOptions{Cash: 1000})
+	if err != nil {
+		t.Fatalf("GenerateNLReport() error = %v", err)
+	}
+
+	// The cash left in the account at the end of 2023 counts as savings on 1 January 2024
+	if report.BrokerCash != 4000 || report.Savings != 5000 {
+		t.Errorf("Expected 4000 broker cash and 5000 savings, got %.2f and %.2f", report.BrokerCash, report.Savings)
+	}
+}
@@ -0,0 +1,193 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// PIT-38 fields used for foreign broker income. The numbering follows PIT-38(17);
+// check it against the form version for the declared year.
+const (
+	PIT38FieldOtherIncome   = 22
+	PIT38FieldOtherCosts    = 23
+	PIT38FieldTotalIncome   = 24
+	PIT38FieldTotalCosts    = 25
+	PIT38FieldProfit        = 26
+	PIT38FieldLoss          = 27
+	PIT38FieldPriorLosses   = 28
+	PIT38FieldTaxBase       = 29
+	PIT38FieldTax           = 31
+	PIT38FieldTaxDue        = 33
+	PIT38FieldDividendTax   = 45
+	PIT38FieldForeignTax    = 46
+	PIT38FieldDividendTopUp = 47
+)
+
+// PIT38Field is a single field value of the PIT-38 form
+type PIT38Field struct {
+	Section     string  `json:"section"`
+	Field       int     `json:"field"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
+
+// PITZGRow is the PIT/ZG attachment for income from one country. Share sales are not taxed at
+// source, so no tax paid abroad is claimed on them.
+type PITZGRow struct {
+	Country string  `json:"country"`
+	Income  float64 `json:"income"`
+	Costs   float64 `json:"costs"`
+	Profit  float64 `json:"profit"`
+}
+
+// PLDividendRow is the dividend tax computation for one payment
+type PLDividendRow struct {
+	Date           string  `json:"date"`
+	ISIN           string  `json:"isin,omitempty"`
+	Name           string  `json:"name,omitempty"`
+	Country        string  `json:"country"`
+	Gross          float64 `json:"gross"`
+	WithholdingTax float64 `json:"withholding_tax"`
+	Tax            float64 `json:"tax"`
+	Credit         float64 `json:"credit"`
+	TopUp          float64 `json:"top_up"`
+}
+
+// PLReport holds the Polish PIT-38 and PIT/ZG figures for a year
+type PLReport struct {
+	Year                int             `json:"year"`
+	Currency            string          `json:"currency"`
+	Income              float64         `json:"income"`
+	Costs               float64         `json:"costs"`
+	Profit              float64         `json:"profit"`
+	Loss                float64         `json:"loss"`
+	PriorLosses         float64         `json:"prior_losses"`
+	LossCarriedForward  float64         `json:"loss_carried_forward"`
+	TaxBase             float64         `json:"tax_base"`
+	CapitalGainsTaxRate float64         `json:"capital_gains_tax_rate"`
+	CapitalGainsTax     float64         `json:"capital_gains_tax"`
+	DividendTax         float64         `json:"dividend_tax"`
+	DividendCredit      float64         `json:"dividend_credit"`
+	DividendTopUp       float64         `json:"dividend_top_up"`
+	Fields              []PIT38Field    `json:"fields"`
+	PITZG               []PITZGRow      `json:"pit_zg"`
+	Dividends           []PLDividendRow `json:"dividends"`
+	Disposals           []Disposal      `json:"disposals"`
+	Warnings            []string        `json:"warnings,omitempty"`
+}
+
+// GeneratePLReport computes Polish tax on a year's share sales and foreign dividends,
+// converted at the NBP rate of the business day before each transaction. Losses from the five
+// previous years reduce the profit, using at most half of each year's loss.
+func (c *TaxCalculator) GeneratePLReport(transactions []types.Transaction, year int) (*PLReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("PL", year)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &PLReport{
+		Year:     year,
+		Currency: jurisdiction.Currency,
+	}
+
+	engine := c.newLotEngine(jurisdiction, jurisdiction.Currency)
+	ledger := engine.Process(transactions)
+	report.Disposals = ledger.DisposalsInYear(year)
+	report.Warnings = ledger.Warnings
+
+	// Sale fees are deductible costs alongside the acquisition cost
+	countries := make(map[string]*PITZGRow)
+	for _, disposal := range report.Disposals {
+		costs := disposal.Cost + disposal.Fees
+		report.Income += disposal.GrossProceeds
+		report.Costs += costs
+
+		country := CountryFromISIN(disposal.ISIN)
+		row, exists := countries[country]
+		if !exists {
+			row = &PITZGRow{Country: country}
+			countries[country] = row
+		}
+		row.Income += disposal.GrossProceeds
+		row.Costs += costs
+	}
+
+	net := report.Income - report.Costs
+	report.Profit = math.Max(net, 0)
+	report.Loss = math.Max(-net, 0)
+	nets := make(map[int]float64)
+	for _, disposal := range ledger.Disposals {
+		nets[jurisdiction.TaxYearOf(disposal.Date)] += disposal.GrossProceeds - disposal.Cost - disposal.Fees
+	}
+	losses := lossesForYear(jurisdiction, nets, year)
+	report.PriorLosses = losses.Used
+	report.LossCarriedForward = losses.CarriedForward
+	report.TaxBase = math.Round(report.Profit - report.PriorLosses)
+	report.CapitalGainsTaxRate = jurisdiction.CapitalGainsTaxRate
+	report.CapitalGainsTax = math.Round(report.TaxBase * report.CapitalGainsTaxRate)
+
+	report.PITZG = make([]PITZGRow, 0, len(countries))
+	for _, row := range countries {
+		row.Profit = math.Max(row.Income-row.Costs, 0)
+		report.PITZG = append(report.PITZG, *row)
+	}
+	sort.Slice(report.PITZG, func(i, j int) bool {
+		return report.PITZG[i].Country < report.PITZG[j].Country
+	})
+
+	for _, record := range c.dividendRecords(transactions, jurisdiction, jurisdiction.Currency) {
+		if record.Date.Year() != year {
+			continue
+		}
+
+		tax := record.Amount * jurisdiction.DividendTaxRate
+		credit := math.Min(record.WithholdingTax, record.Amount*jurisdiction.CreditCap())
+		report.Dividends = append(report.Dividends, PLDividendRow{
+			Date:           record.Date.Format(rateDateLayout),
+			ISIN:           record.ISIN,
+			Name:           record.Name,
+			Country:        CountryFromISIN(record.ISIN),
+			Gross:          record.Amount,
+			WithholdingTax: record.WithholdingTax,
+			Tax:            tax,
+			Credit:         credit,
+			TopUp:          tax - credit,
+		})
+
+		report.DividendTax += tax
+		report.DividendCredit += credit
+	}
+	report.DividendTax = roundToGrosz(report.DividendTax)
+	report.DividendCredit = roundToGrosz(report.DividendCredit)
+	report.DividendTopUp = math.Round(math.Max(report.DividendTax-report.DividendCredit, 0))
+
+	report.Fields = report.pit38Fields()
+	return report, nil
+}
+
+// pit38Fields maps the report onto PIT-38 section C, D and G fields
+func (r *PLReport) pit38Fields() []PIT38Field {
+	return []PIT38Field{
+		{Section: "C", Field: PIT38FieldOtherIncome, Description: "Other income (przychód)", Amount: roundToGrosz(r.Income)},
+		{Section: "C", Field: PIT38FieldOtherCosts, Description: "Other costs (koszty uzyskania przychodu)", Amount: roundToGrosz(r.Costs)},
+		{Section: "C", Field: PIT38FieldTotalIncome, Description: "Total income", Amount: roundToGrosz(r.Income)},
+		{Section: "C", Field: PIT38FieldTotalCosts, Description: "Total costs", Amount: roundToGrosz(r.Costs)},
+		{Section: "C", Field: PIT38FieldProfit, Description: "Profit (dochód)", Amount: roundToGrosz(r.Profit)},
+		{Section: "C", Field: PIT38FieldLoss, Description: "Loss (strata)", Amount: roundToGrosz(r.Loss)},
+		{Section: "D", Field: PIT38FieldPriorLosses, Description: "Prior-year losses (straty z lat ubiegłych)", Amount: roundToGrosz(r.PriorLosses)},
+		{Section: "D", Field: PIT38FieldTaxBase, Description: "Tax base", Amount: r.TaxBase},
+		{Section: "D", Field: PIT38FieldTax, Description: fmt.Sprintf("Tax at %.4g%%", r.CapitalGainsTaxRate*PercentMultiplier), Amount: r.CapitalGainsTax},
+		{Section: "D", Field: PIT38FieldTaxDue, Description: "Tax due", Amount: r.CapitalGainsTax},
+		{Section: "G", Field: PIT38FieldDividendTax, Description: "Flat tax on foreign dividends", Amount: r.DividendTax},
+		{Section: "G", Field: PIT38FieldForeignTax, Description: "Tax paid abroad", Amount: r.DividendCredit},
+		{Section: "G", Field: PIT38FieldDividendTopUp, Description: "Difference to pay", Amount: r.DividendTopUp},
+	}
+}
+
+// roundToGrosz rounds an amount to two decimal places
+func roundToGrosz(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
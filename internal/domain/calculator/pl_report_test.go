@@ -0,0 +1,118 @@
+package calculator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GeneratePLReport(t *testing.T) {
+	calc := NewTaxCalculator()
+	jurisdiction, _ := calc.GetJurisdiction("PL")
+
+	// NBP table A quotes PLN per unit of foreign currency
+	rates, err := ParseRateTable(strings.NewReader("date,currency,rate\n2024-03-01,USD,4.0\n2024-03-04,USD,4.1\n2024-06-03,USD,4.2\n2024-06-04,USD,4.3\n"),
+		"PLN", jurisdiction.FXSource)
+	if err != nil {
+		t.Fatalf("ParseRateTable() error = %v", err)
+	}
+	calc.SetFXRateProvider(rates)
+
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 3, 4, 15, 0, 0, 0, time.UTC), "US0378331005", 10, 100)
+	buy.CurrencyPricePerShare = stringPtr("USD")
+	buy.CurrencyConversionFee = floatPtr(1)
+	buy.CurrencyCurrencyConversionFee = stringPtr("USD")
+	sell := tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 4, 15, 0, 0, 0, time.UTC), "US0378331005", 10, 120)
+	sell.CurrencyPricePerShare = stringPtr("USD")
+
+	transactions := []types.Transaction{
+		buy,
+		sell,
+		{
+			Action:         types.TransactionTypeDividend,
+			Time:           time.Date(2024, 6, 4, 9, 0, 0, 0, time.UTC),
+			ISIN:           stringPtr("US0378331005"),
+			Result:         floatPtr(10),
+			CurrencyResult: stringPtr("USD"),
+			WithholdingTax: floatPtr(1.5),
+		},
+	}
+
+	report, err := calc.GeneratePLReport(transactions, 2024)
+	if err != nil {
+		t.Fatalf("GeneratePLReport() error = %v", err)
+	}
+
+	// Rates from the business day before: 4.0 for the buy, 4.2 for the sell
+	if abs(report.Income-5040) > 0.001 || abs(report.Costs-4004) > 0.001 {
+		t.Errorf("Expected income 5040 and costs 4004, got %.2f and %.2f", report.Income, report.Costs)
+	}
+	if report.TaxBase != 1036 || report.CapitalGainsTax != 197 {
+		t.Errorf("Expected tax base 1036 and tax 197, got %.2f and %.2f", report.TaxBase, report.CapitalGainsTax)
+	}
+
+	if len(report.PITZG) != 1 || report.PITZG[0].Country != "US" || abs(report.PITZG[0].Profit-1036) > 0.001 {
+		t.Errorf("Unexpected PIT/ZG rows: %+v", report.PITZG)
+	}
+
+	if report.DividendTax != 7.98 || report.DividendCredit != 6.3 || report.DividendTopUp != 2 {
+		t.Errorf("Expected dividend tax 7.98, credit 6.30, top-up 2, got %.2f, %.2f, %.2f",
+			report.DividendTax, report.DividendCredit, report.DividendTopUp)
+	}
+
+	for _, field := range report.Fields {
+		if field.Field == PIT38FieldDividendTopUp && field.Amount != 2 {
+			t.Errorf("Expected field %d to be 2, got %.2f", PIT38FieldDividendTopUp, field.Amount)
+		}
+		if field.Field == PIT38FieldTax && field.Description != "Tax at 19%" {
+			t.Errorf("Expected field %d to be labelled with the 19%% rate, got %q", PIT38FieldTax, field.Description)
+		}
+	}
+}
+
+func TestTaxCalculator_GeneratePLReportPriorLosses(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	trade := func(action types.TransactionType, year int, shares, price float64) types.Transaction {
+		tx := tradeTx(action, time.Date(year, 3, 1, 10, 0, 0, 0, time.UTC), "US0378331005", shares, price)
+		tx.CurrencyPricePerShare = stringPtr("PLN")
+		return tx
+	}
+
+	transactions := []types.Transaction{
+		// A 1000 loss in 2022, of which at most 500 is usable in any later year
+		trade(types.TransactionTypeMarketBuy, 2022, 10, 300),
+		trade(types.TransactionTypeMarketSell, 2022, 10, 200),
+		trade(types.TransactionTypeMarketBuy, 2022, 30, 100),
+		trade(types.TransactionTypeMarketSell, 2023, 10, 180),
+		trade(types.TransactionTypeMarketSell, 2024, 10, 300),
+	}
+
+	tests := []struct {
+		year        int
+		priorLosses float64
+		carried     float64
+		taxBase     float64
+	}{
+		{2023, 500, 500, 300},
+		{2024, 500, 0, 1500},
+	}
+
+	for _, tt := range tests {
+		report, err := calc.GeneratePLReport(transactions, tt.year)
+		if err != nil {
+			t.Fatalf("GeneratePLReport(%d) error = %v", tt.year, err)
+		}
+		if abs(report.PriorLosses-tt.priorLosses) > 0.001 || abs(report.LossCarriedForward-tt.carried) > 0.001 || report.TaxBase != tt.taxBase {
+			t.Errorf("%d: expected prior losses %.2f, carried %.2f and tax base %.2f, got %.2f, %.2f and %.2f",
+				tt.year, tt.priorLosses, tt.carried, tt.taxBase, report.PriorLosses, report.LossCarriedForward, report.TaxBase)
+		}
+		for _, field := range report.Fields {
+			if field.Field == PIT38FieldPriorLosses && field.Amount != tt.priorLosses {
+				t.Errorf("%d: expected field %d to be %.2f, got %.2f", tt.year, PIT38FieldPriorLosses, tt.priorLosses, field.Amount)
+			}
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package calculator
 
 import (
+	"math"
 	"sort"
 	"strings"
 	"time"
@@ -16,6 +17,8 @@ const (
 // PortfolioCalculator calculates portfolio positions and summaries
 type PortfolioCalculator struct {
 	baseCurrency string
+	prices       PriceProvider
+	rates        FXRateProvider
 }
 
 // NewPortfolioCalculator creates a new portfolio calculator
@@ -25,6 +28,16 @@ func NewPortfolioCalculator(baseCurrency string) *PortfolioCalculator {
 	}
 }
 
+// SetPriceProvider sets the closing prices used to value positions instead of the last trade price
+func (pc *PortfolioCalculator) SetPriceProvider(prices PriceProvider) {
+	pc.prices = prices
+}
+
+// SetFXRateProvider sets official exchange rates used to convert closing prices
+func (pc *PortfolioCalculator) SetFXRateProvider(rates FXRateProvider) {
+	pc.rates = rates
+}
+
 // CalculatePortfolioValuation generates portfolio valuations across multiple years
 func (pc *PortfolioCalculator) CalculatePortfolioValuation(transactions []types.Transaction) *types.PortfolioValuationReport {
 	// Get all unique years from transactions
@@ -42,8 +55,17 @@ func (pc *PortfolioCalculator) CalculatePortfolioValuation(transactions []types.
 		Currency:         pc.baseCurrency,
 		GeneratedAt:      time.Now(),
 		DataSource:       "Trading 212 CSV Export",
-		PriceNote:        "Portfolio values based on last transaction price for each security",
+		PriceNote:        pc.priceNote(),
+	}
+}
+
+// priceNote describes the prices positions are valued at
+func (pc *PortfolioCalculator) priceNote() string {
+	if pc.prices != nil {
+		return "Portfolio values based on closing prices on the valuation date, " +
+			"falling back to the last transaction price for securities without one"
 	}
+	return "Portfolio values based on last transaction price for each security"
 }
 
 // extractYears gets all unique years from transactions
@@ -68,20 +90,32 @@ func (pc *PortfolioCalculator) extractYears(transactions []types.Transaction) []
 
 // CalculateEndOfYearPortfolio calculates the portfolio state at the end of a given year
 func (pc *PortfolioCalculator) CalculateEndOfYearPortfolio(transactions []types.Transaction, year int) *types.PortfolioSummary {
-	endOfYear := time.Date(year, 12, 31, 23, 59, 59, 0, time.UTC)
-	relevantTransactions := pc.filterTransactionsUpToDate(transactions, endOfYear)
+	return pc.PortfolioAt(transactions, time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC))
+}
+
+// PortfolioAt calculates the portfolio state at the close of date, including every transaction made
+// that day. The yearly activity covers date's calendar year up to date.
+func (pc *PortfolioCalculator) PortfolioAt(transactions []types.Transaction, date time.Time) *types.PortfolioSummary {
+	asOf := endOfDay(date)
+	year := asOf.Year()
+	relevantTransactions := pc.filterTransactionsUpToDate(transactions, asOf)
 
 	// Process transactions to build positions and calculate metrics
 	positions := make(map[string]*types.PortfolioPosition)
 	lastPrices := make(map[string]*PriceInfo)
 	yearlyMetrics := pc.calculateYearlyMetrics(relevantTransactions, year)
 
-	pc.processTransactionsForPositions(relevantTransactions, positions, lastPrices)
-	finalPositions, totals := pc.buildFinalPositions(positions, lastPrices)
+	pc.processTransactionsForPositions(sortedByTime(relevantTransactions), positions, lastPrices, make(map[string]types.Transaction))
+	finalPositions, totals := pc.buildFinalPositions(positions, lastPrices, asOf)
+
+	cash := 0.0
+	for _, tx := range relevantTransactions {
+		cash += pc.cashFlow(tx)
+	}
 
 	return &types.PortfolioSummary{
 		Year:                           year,
-		AsOfDate:                       endOfYear,
+		AsOfDate:                       asOf,
 		Positions:                      finalPositions,
 		TotalPositions:                 len(finalPositions),
 		TotalShares:                    totals.TotalShares,
@@ -93,9 +127,16 @@ func (pc *PortfolioCalculator) CalculateEndOfYearPortfolio(transactions []types.
 		YearlyDeposits:                 yearlyMetrics.Deposits,
 		YearlyDividends:                yearlyMetrics.Dividends,
 		YearlyInterest:                 yearlyMetrics.Interest,
+		Cash:                           cash,
+		StalePrices:                    totals.StalePrices,
 	}
 }
 
+// endOfDay returns the last second of date's day
+func endOfDay(date time.Time) time.Time {
+	return truncateToDay(date).AddDate(0, 0, 1).Add(-time.Second)
+}
+
 // filterTransactionsUpToDate filters transactions up to the specified date
 func (pc *PortfolioCalculator) filterTransactionsUpToDate(transactions []types.Transaction, endDate time.Time) []types.Transaction {
 	var filtered []types.Transaction
@@ -141,14 +182,23 @@ func (pc *PortfolioCalculator) calculateYearlyMetrics(transactions []types.Trans
 	return metrics
 }
 
-// processTransactionsForPositions processes trade transactions to build positions and track prices
+// processTransactionsForPositions processes trade transactions and stock splits, in time order, to
+// build positions and track prices. The first row of a split waits in pendingSplits for the other.
 func (pc *PortfolioCalculator) processTransactionsForPositions(
 	transactions []types.Transaction,
 	positions map[string]*types.PortfolioPosition,
 	lastPrices map[string]*PriceInfo,
+	pendingSplits map[string]types.Transaction,
 ) {
 	for _, tx := range transactions {
-		if !pc.isTradeTransaction(tx) || tx.Ticker == nil || tx.ISIN == nil {
+		if tx.Ticker == nil || tx.ISIN == nil {
+			continue
+		}
+		if isSplitAction(tx.Action) && tx.Shares != nil && *tx.Shares > 0 {
+			pc.handleSplit(positions, lastPrices, pendingSplits, tx)
+			continue
+		}
+		if !pc.isTradeTransaction(tx) {
 			continue
 		}
 
@@ -167,6 +217,35 @@ func (pc *PortfolioCalculator) processTransactionsForPositions(
 	}
 }
 
+// handleSplit pairs the close and open rows of a stock split, as the lot engine does, and
+// multiplies the shares held by its ratio and divides the last trade price by it. The cost is kept.
+func (pc *PortfolioCalculator) handleSplit(
+	positions map[string]*types.PortfolioPosition,
+	lastPrices map[string]*PriceInfo,
+	pendingSplits map[string]types.Transaction,
+	tx types.Transaction,
+) {
+	ticker := *tx.Ticker
+	first, ok := pendingSplits[ticker]
+	if !ok {
+		pendingSplits[ticker] = tx
+		return
+	}
+	delete(pendingSplits, ticker)
+
+	split, ok := stockSplit(first, tx)
+	if !ok || split.Ratio <= 0 {
+		return
+	}
+	if position, exists := positions[ticker]; exists {
+		position.Shares *= split.Ratio
+	}
+	if price, exists := lastPrices[ticker]; exists {
+		price.Price /= split.Ratio
+		price.OriginalPrice /= split.Ratio
+	}
+}
+
 // getOrCreatePosition gets existing position or creates a new one
 func (pc *PortfolioCalculator) getOrCreatePosition(
 	positions map[string]*types.PortfolioPosition,
@@ -204,6 +283,9 @@ func (pc *PortfolioCalculator) updateLastPrice(
 			Currency:         pc.baseCurrency,
 			OriginalPrice:    *tx.PricePerShare,
 			OriginalCurrency: pc.safeString(tx.CurrencyPricePerShare),
+			ExchangeRate:     tx.ExchangeRate,
+			AccountCurrency:  pc.safeString(tx.CurrencyTotal),
+			Source:           PriceSourceLastTrade,
 		}
 	}
 }
@@ -213,12 +295,14 @@ type PositionTotals struct {
 	TotalShares      float64
 	TotalInvested    float64
 	TotalMarketValue float64
+	StalePrices      int
 }
 
 // buildFinalPositions converts positions map to sorted slice and calculates totals
 func (pc *PortfolioCalculator) buildFinalPositions(
 	positions map[string]*types.PortfolioPosition,
 	lastPrices map[string]*PriceInfo,
+	valuationDate time.Time,
 ) ([]types.PortfolioPosition, *PositionTotals) {
 	finalPositions := make([]types.PortfolioPosition, 0, len(positions))
 	totals := &PositionTotals{}
@@ -228,12 +312,15 @@ func (pc *PortfolioCalculator) buildFinalPositions(
 			continue
 		}
 
-		pc.finalizePosition(position, lastPrices)
+		pc.finalizePosition(position, lastPrices, valuationDate)
 
 		finalPositions = append(finalPositions, *position)
 		totals.TotalShares += position.Shares
 		totals.TotalInvested += position.TotalCost
 		totals.TotalMarketValue += position.MarketValue
+		if position.PriceStale {
+			totals.StalePrices++
+		}
 	}
 
 	// Sort by market value in descending order
@@ -244,17 +331,29 @@ func (pc *PortfolioCalculator) buildFinalPositions(
 	return finalPositions, totals
 }
 
-// finalizePosition calculates final position metrics including market value and P&L
-func (pc *PortfolioCalculator) finalizePosition(position *types.PortfolioPosition, lastPrices map[string]*PriceInfo) {
+// finalizePosition calculates final position metrics including market value and P&L, valuing the
+// position at its closing price on the valuation date where the price provider has one
+func (pc *PortfolioCalculator) finalizePosition(
+	position *types.PortfolioPosition,
+	lastPrices map[string]*PriceInfo,
+	valuationDate time.Time,
+) {
 	if position.Shares > 0 {
 		position.AverageCost = position.TotalCost / position.Shares
 	}
 
+	priceInfo, hasPriceInfo := lastPrices[position.Ticker]
+	if quote, found := closingPrice(pc.prices, position.ISIN, position.Ticker, valuationDate); found {
+		priceInfo = pc.closingPriceInfo(quote, priceInfo, valuationDate)
+		hasPriceInfo = true
+	}
+
 	// Add market pricing information
-	if priceInfo, hasPriceInfo := lastPrices[position.Ticker]; hasPriceInfo {
+	if hasPriceInfo {
 		position.LastPrice = priceInfo.Price
 		position.LastPriceDate = priceInfo.Date
 		position.LastPriceCurrency = priceInfo.Currency
+		position.PriceSource = priceInfo.Source
 		position.MarketValue = position.Shares * priceInfo.Price
 		position.UnrealizedGainLoss = position.MarketValue - position.TotalCost
 
@@ -266,10 +365,41 @@ func (pc *PortfolioCalculator) finalizePosition(position *types.PortfolioPositio
 		position.LastPrice = position.AverageCost
 		position.LastPriceDate = position.LastPurchase
 		position.LastPriceCurrency = position.Currency
+		position.PriceSource = PriceSourceCost
 		position.MarketValue = position.TotalCost
 		position.UnrealizedGainLoss = 0
 		position.UnrealizedGainLossPercent = 0
 	}
+
+	position.PriceAgeDays = priceAgeDays(position.LastPriceDate, valuationDate)
+	position.PriceStale = position.PriceAgeDays > StalePriceDays
+}
+
+// closingPriceInfo converts a closing price into the base currency; prices without a currency are
+// taken to be in the currency of the security's last trade, whose exchange rate is the fallback
+func (pc *PortfolioCalculator) closingPriceInfo(quote PriceQuote, lastTrade *PriceInfo, valuationDate time.Time) *PriceInfo {
+	currency := quote.Currency
+	var brokerRate *float64
+	account := ""
+	if lastTrade != nil {
+		account = lastTrade.AccountCurrency
+		if currency == "" {
+			currency = lastTrade.OriginalCurrency
+		}
+		if currency == lastTrade.OriginalCurrency {
+			brokerRate = lastTrade.ExchangeRate
+		}
+	}
+
+	converter := newCurrencyConverter(pc.baseCurrency, account, pc.rates)
+	return &PriceInfo{
+		Price:            converter.convert(quote.Price, &currency, valuationDate, brokerRate),
+		Date:             quote.Date,
+		Currency:         pc.baseCurrency,
+		OriginalPrice:    quote.Price,
+		OriginalCurrency: currency,
+		Source:           quote.Source,
+	}
 }
 
 // calculatePercentage calculates percentage safely, avoiding division by zero
@@ -287,6 +417,10 @@ type PriceInfo struct {
 	Currency         string
 	OriginalPrice    float64
 	OriginalCurrency string
+	ExchangeRate     *float64
+	// AccountCurrency is the currency ExchangeRate converts into
+	AccountCurrency string
+	Source          string
 }
 
 // safeString safely dereferences a string pointer
@@ -386,6 +520,25 @@ func (pc *PortfolioCalculator) isSellTransaction(tx types.Transaction) bool {
 	}
 }
 
+// cashFlow returns the change in cash balance caused by a transaction, in the base currency.
+// Currency conversions are taken to leave the value of cash unchanged.
+func (pc *PortfolioCalculator) cashFlow(tx types.Transaction) float64 {
+	switch kind := classifyCash(tx); kind {
+	case CashDeposit, CashSell, CashCardCredit, CashWithdrawal, CashBuy, CashFee, CashCardDebit:
+		if tx.Total == nil {
+			return 0
+		}
+		amount := math.Abs(pc.convertToBaseCurrency(*tx.Total, tx.CurrencyTotal, tx.ExchangeRate))
+		if kind == CashWithdrawal || kind == CashBuy || kind == CashFee || kind == CashCardDebit {
+			return -amount
+		}
+		return amount
+	case CashIncome:
+		return pc.extractTransactionAmount(tx)
+	}
+	return 0
+}
+
 // convertToBaseCurrency converts amount to base currency
 // extractTransactionAmount extracts and converts transaction amount from Result or Total fields
 func (pc *PortfolioCalculator) extractTransactionAmount(tx types.Transaction) float64 {
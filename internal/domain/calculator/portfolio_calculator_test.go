@@ -143,3 +143,45 @@ func TestPortfolioCalculator_EmptyTransactions(t *testing.T) {
 		t.Errorf("Expected no yearly portfolios for empty transactions, got %d", len(report.YearlyPortfolios))
 	}
 }
+
+func TestPortfolioCalculator_StockSplitMatchesLotLedger(t *testing.T) {
+	const isin = "US0378331005"
+	date := time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC)
+	buy := tradeTx(types.TransactionTypeMarketBuy, date.AddDate(0, -6, 0), isin, 10, 400)
+	buy.Total = floatPtr(4000)
+	transactions := []types.Transaction{
+		buy,
+		tradeTx(types.TransactionTypeSplitOpen, date, isin, 100, 40),
+		tradeTx(types.TransactionTypeSplitClose, date, isin, 10, 400),
+	}
+
+	calc := NewPortfolioCalculator("EUR")
+	ledger := NewLotEngine("EUR", MatchingFIFO, nil).Process(transactions)
+	held := 0.0
+	for _, lot := range ledger.Lots {
+		held += lot.Remaining
+	}
+
+	// The pre-split trade price is divided by the ratio, so the value is unchanged by the split
+	portfolio := calc.CalculateEndOfYearPortfolio(transactions, 2024)
+	if len(portfolio.Positions) != 1 {
+		t.Fatalf("Expected one position, got %+v", portfolio.Positions)
+	}
+	position := portfolio.Positions[0]
+	if abs(position.Shares-held) > 1e-9 || abs(position.Shares-100) > 1e-9 {
+		t.Errorf("Expected 100 shares as in the lot ledger (%.2f), got %.2f", held, position.Shares)
+	}
+	if abs(position.LastPrice-40) > 1e-9 || abs(position.MarketValue-4000) > 1e-9 || abs(position.TotalCost-4000) > 1e-9 {
+		t.Errorf("Expected 100 shares at 40 worth their 4000 cost, got %+v", position)
+	}
+
+	// The daily replay agrees
+	series, err := calc.CalculateTimeSeries(transactions, date.AddDate(0, 0, -1), date.AddDate(0, 0, 1), SeriesDaily)
+	if err != nil {
+		t.Fatalf("CalculateTimeSeries() error = %v", err)
+	}
+	last := series.Snapshots[len(series.Snapshots)-1]
+	if abs(last.MarketValue-4000) > 1e-9 {
+		t.Errorf("Expected the series to value the split position at 4000, got %+v", last)
+	}
+}
@@ -0,0 +1,152 @@
+package calculator
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// SeriesInterval is the spacing between snapshots in a portfolio time series
+type SeriesInterval string
+
+const (
+	// SeriesDaily takes a snapshot at the close of every day
+	SeriesDaily SeriesInterval = "daily"
+	// SeriesMonthly takes a snapshot at the close of every month, and of the last day
+	SeriesMonthly SeriesInterval = "monthly"
+)
+
+// CalculateTimeSeries replays transactions to value the portfolio at the close of each day, or each
+// month end, between from and to. A zero from starts at the first transaction and a zero to ends at
+// the last one. Positions are valued as in PortfolioAt.
+func (pc *PortfolioCalculator) CalculateTimeSeries(
+	transactions []types.Transaction,
+	from, to time.Time,
+	interval SeriesInterval,
+) (*types.PortfolioTimeSeries, error) {
+	if interval == "" {
+		interval = SeriesMonthly
+	}
+	if interval != SeriesDaily && interval != SeriesMonthly {
+		return nil, fmt.Errorf("unsupported series interval %q (supported: %s, %s)", interval, SeriesDaily, SeriesMonthly)
+	}
+
+	sorted := sortedByTime(transactions)
+
+	series := &types.PortfolioTimeSeries{
+		Interval:  string(interval),
+		Currency:  pc.baseCurrency,
+		PriceNote: pc.priceNote(),
+	}
+	if len(sorted) == 0 && (from.IsZero() || to.IsZero()) {
+		return series, nil
+	}
+	if from.IsZero() {
+		from = sorted[0].Time
+	}
+	if to.IsZero() {
+		to = sorted[len(sorted)-1].Time
+	}
+	from, to = truncateToDay(from), truncateToDay(to)
+	if to.Before(from) {
+		return nil, fmt.Errorf("series end %s is before its start %s", to.Format(rateDateLayout), from.Format(rateDateLayout))
+	}
+	series.From, series.To = from, to
+
+	cash, netDeposits := 0.0, 0.0
+	pc.replay(sorted, seriesDates(from, to, interval), func(date time.Time, replayed []types.Transaction, held []types.PortfolioPosition, totals *PositionTotals) {
+		for _, tx := range replayed {
+			flow := pc.cashFlow(tx)
+			cash += flow
+			if isExternalFlow(tx) {
+				netDeposits += flow
+			}
+		}
+
+		series.Snapshots = append(series.Snapshots, types.PortfolioSnapshot{
+			Date:               date,
+			Positions:          len(held),
+			CostBasis:          totals.TotalInvested,
+			MarketValue:        totals.TotalMarketValue,
+			UnrealizedGainLoss: totals.TotalMarketValue - totals.TotalInvested,
+			Cash:               cash,
+			TotalValue:         totals.TotalMarketValue + cash,
+			NetDeposits:        netDeposits,
+			StalePrices:        totals.StalePrices,
+		})
+	})
+
+	return series, nil
+}
+
+// replay applies transactions sorted by time in order and calls visit at the close of each of
+// dates, which must be ascending, with the transactions applied since the previous date and the
+// positions held and their totals
+func (pc *PortfolioCalculator) replay(
+	sorted []types.Transaction,
+	dates []time.Time,
+	visit func(date time.Time, replayed []types.Transaction, held []types.PortfolioPosition, totals *PositionTotals),
+) {
+	positions := make(map[string]*types.PortfolioPosition)
+	lastPrices := make(map[string]*PriceInfo)
+	pendingSplits := make(map[string]types.Transaction)
+	next := 0
+
+	for _, date := range dates {
+		asOf := endOfDay(date)
+		end := next
+		for end < len(sorted) && !sorted[end].Time.After(asOf) {
+			end++
+		}
+
+		replayed := sorted[next:end]
+		pc.processTransactionsForPositions(replayed, positions, lastPrices, pendingSplits)
+		next = end
+
+		held, totals := pc.buildFinalPositions(positions, lastPrices, asOf)
+		visit(date, replayed, held, totals)
+	}
+}
+
+// isExternalFlow reports whether a transaction moves money into or out of the account, counting
+// card spending and refunds as withdrawals and deposits
+func isExternalFlow(tx types.Transaction) bool {
+	switch classifyCash(tx) {
+	case CashDeposit, CashWithdrawal, CashCardDebit, CashCardCredit:
+		return true
+	}
+	return false
+}
+
+// seriesDates returns the snapshot days from from to to: every day, or every month end and to itself
+func seriesDates(from, to time.Time, interval SeriesInterval) []time.Time {
+	var dates []time.Time
+	if interval == SeriesDaily {
+		for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+			dates = append(dates, date)
+		}
+		return dates
+	}
+
+	for monthEnd := lastDayOfMonth(from); monthEnd.Before(to); monthEnd = lastDayOfMonth(monthEnd.AddDate(0, 0, 1)) {
+		dates = append(dates, monthEnd)
+	}
+	return append(dates, to)
+}
+
+// sortedByTime returns a copy of transactions in time order
+func sortedByTime(transactions []types.Transaction) []types.Transaction {
+	sorted := make([]types.Transaction, len(transactions))
+	copy(sorted, transactions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+	return sorted
+}
+
+// lastDayOfMonth returns the last day of date's month
+func lastDayOfMonth(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month()+1, 0, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,106 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func seriesTransactions() []types.Transaction {
+	trade := func(action types.TransactionType, date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(action, date, "US5949181045", shares, price)
+		tx.Total = floatPtr(shares * price)
+		tx.CurrencyTotal = stringPtr("EUR")
+		return tx
+	}
+	cash := func(action types.TransactionType, date time.Time, total float64) types.Transaction {
+		return types.Transaction{Action: action, Time: date, Total: floatPtr(total), CurrencyTotal: stringPtr("EUR")}
+	}
+
+	return []types.Transaction{
+		cash(types.TransactionTypeDeposit, time.Date(2024, 1, 5, 9, 0, 0, 0, time.UTC), 2000),
+		trade(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 15, 0, 0, 0, time.UTC), 10, 100),
+		trade(types.TransactionTypeMarketBuy, time.Date(2024, 2, 12, 15, 0, 0, 0, time.UTC), 5, 120),
+		trade(types.TransactionTypeMarketSell, time.Date(2024, 3, 20, 15, 0, 0, 0, time.UTC), 6, 130),
+		cash(types.TransactionTypeWithdrawal, time.Date(2024, 3, 25, 9, 0, 0, 0, time.UTC), -300),
+	}
+}
+
+func TestPortfolioCalculator_PortfolioAt(t *testing.T) {
+	calculator := NewPortfolioCalculator("EUR")
+	transactions := seriesTransactions()
+
+	// The close of 12 February includes the trade made that afternoon
+	portfolio := calculator.PortfolioAt(transactions, time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC))
+	if portfolio.TotalShares != 15 || portfolio.TotalInvested != 1600 || portfolio.TotalMarketValue != 1800 {
+		t.Errorf("Expected 15 shares costing 1600 worth 1800, got %+v", portfolio)
+	}
+	if portfolio.Cash != 400 || portfolio.YearlyDeposits != 2000 {
+		t.Errorf("Expected 400 cash from a 2000 deposit, got %.2f and %.2f", portfolio.Cash, portfolio.YearlyDeposits)
+	}
+	if !portfolio.AsOfDate.Equal(time.Date(2024, 2, 12, 23, 59, 59, 0, time.UTC)) {
+		t.Errorf("Expected the close of 12 February, got %v", portfolio.AsOfDate)
+	}
+
+	// The year-end portfolio is the portfolio at 31 December
+	endOfYear := calculator.CalculateEndOfYearPortfolio(transactions, 2024)
+	at := calculator.PortfolioAt(transactions, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC))
+	if endOfYear.TotalMarketValue != at.TotalMarketValue || endOfYear.Cash != at.Cash || endOfYear.Cash != 880 {
+		t.Errorf("Expected matching year-end portfolios with 880 cash, got %+v and %+v", endOfYear, at)
+	}
+}
+
+func TestPortfolioCalculator_CalculateTimeSeries(t *testing.T) {
+	calculator := NewPortfolioCalculator("EUR")
+	transactions := seriesTransactions()
+
+	series, err := calculator.CalculateTimeSeries(transactions, time.Time{}, time.Time{}, SeriesMonthly)
+	if err != nil {
+		t.Fatalf("CalculateTimeSeries() error = %v", err)
+	}
+
+	// Month ends from January, then the last transaction day
+	wantDates := []time.Time{
+		time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 25, 0, 0, 0, 0, time.UTC),
+	}
+	if len(series.Snapshots) != len(wantDates) {
+		t.Fatalf("Expected %d snapshots, got %+v", len(wantDates), series.Snapshots)
+	}
+	for i, date := range wantDates {
+		if !series.Snapshots[i].Date.Equal(date) {
+			t.Errorf("Snapshot %d date = %v, want %v", i, series.Snapshots[i].Date, date)
+		}
+	}
+
+	february := series.Snapshots[1]
+	if february.MarketValue != 1800 || february.Cash != 400 || february.TotalValue != 2200 || february.NetDeposits != 2000 {
+		t.Errorf("Unexpected February snapshot %+v", february)
+	}
+	march := series.Snapshots[2]
+	if abs(march.CostBasis-960) > 1e-9 || march.MarketValue != 1170 || march.Cash != 880 || march.NetDeposits != 1700 {
+		t.Errorf("Unexpected March snapshot %+v", march)
+	}
+
+	// Each daily snapshot matches the portfolio valued on that day
+	daily, err := calculator.CalculateTimeSeries(transactions,
+		time.Date(2024, 2, 10, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 14, 0, 0, 0, 0, time.UTC), SeriesDaily)
+	if err != nil {
+		t.Fatalf("CalculateTimeSeries() error = %v", err)
+	}
+	if len(daily.Snapshots) != 5 {
+		t.Fatalf("Expected 5 daily snapshots, got %d", len(daily.Snapshots))
+	}
+	for _, snapshot := range daily.Snapshots {
+		portfolio := calculator.PortfolioAt(transactions, snapshot.Date)
+		if snapshot.MarketValue != portfolio.TotalMarketValue || snapshot.Cash != portfolio.Cash {
+			t.Errorf("Snapshot %v = %+v, want the portfolio at that date %+v", snapshot.Date, snapshot, portfolio)
+		}
+	}
+
+	if _, err := calculator.CalculateTimeSeries(transactions, time.Time{}, time.Time{}, "weekly"); err == nil {
+		t.Error("Expected an error for an unsupported interval")
+	}
+}
@@ -0,0 +1,246 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// PositionEventKind is the kind of a row in a position's history
+type PositionEventKind string
+
+const (
+	// PositionBuy is a purchase of shares
+	PositionBuy PositionEventKind = "buy"
+	// PositionSell is a sale of shares, with its realised gain or loss
+	PositionSell PositionEventKind = "sell"
+	// PositionDividend is a dividend paid on the shares held
+	PositionDividend PositionEventKind = "dividend"
+	// PositionSplit is a stock split or reverse split
+	PositionSplit PositionEventKind = "split"
+	// PositionFee is a charge booked against the security outside a trade
+	PositionFee PositionEventKind = "fee"
+)
+
+// PositionEvent is one event in a position's history with the running totals after it. Amounts
+// are in the base currency.
+type PositionEvent struct {
+	Date   time.Time         `json:"date"`
+	Kind   PositionEventKind `json:"kind"`
+	Action string            `json:"action"`
+	ID     string            `json:"id,omitempty"`
+	// Shares is the number bought or sold, the shares a dividend was paid on, or the shares a split
+	// added, which are negative for a reverse split
+	Shares        float64 `json:"shares"`
+	PricePerShare float64 `json:"price_per_share,omitempty"`
+	PriceCurrency string  `json:"price_currency,omitempty"`
+	// Amount is the cost of a buy including fees, the proceeds of a sell after fees, the dividend
+	// received or the fee charged
+	Amount         float64 `json:"amount"`
+	Fees           float64 `json:"fees,omitempty"`
+	WithholdingTax float64 `json:"withholding_tax,omitempty"`
+	// GainLoss is the gain or loss realised by a sell under the matching method
+	GainLoss   float64 `json:"gain_loss,omitempty"`
+	SplitRatio float64 `json:"split_ratio,omitempty"`
+
+	RunningShares    float64 `json:"running_shares"`
+	RunningCostBasis float64 `json:"running_cost_basis"`
+	AverageCost      float64 `json:"average_cost"`
+	RealisedGainLoss float64 `json:"realised_gain_loss"`
+	Dividends        float64 `json:"dividends"`
+}
+
+// PositionHistory is every buy, sell, dividend, split and fee of one security in date order
+type PositionHistory struct {
+	ISIN     string          `json:"isin"`
+	Ticker   string          `json:"ticker"`
+	Name     string          `json:"name"`
+	Currency string          `json:"currency"`
+	Method   MatchingMethod  `json:"method"`
+	Events   []PositionEvent `json:"events"`
+	// Shares, CostBasis and AverageCost describe the position after the last event
+	Shares           float64   `json:"shares"`
+	CostBasis        float64   `json:"cost_basis"`
+	AverageCost      float64   `json:"average_cost"`
+	RealisedGainLoss float64   `json:"realised_gain_loss"`
+	Dividends        float64   `json:"dividends"`
+	WithholdingTax   float64   `json:"withholding_tax"`
+	Fees             float64   `json:"fees"`
+	FirstDate        time.Time `json:"first_date"`
+	LastDate         time.Time `json:"last_date"`
+	Warnings         []string  `json:"warnings,omitempty"`
+}
+
+// PositionHistory replays the transactions of one security, given by ISIN or ticker, into its
+// history. Sells are matched against purchases with the calculator's matching method, so the
+// realised gains agree with the yearly reports.
+func (fc *FinancialCalculator) PositionHistory(transactions []types.Transaction, security string) (*PositionHistory, error) {
+	rows, err := securityTransactions(transactions, security)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := fc.newLotEngine()
+	ledger := engine.Process(rows)
+	splits := make(map[time.Time]StockSplit, len(ledger.Splits))
+	for _, split := range ledger.Splits {
+		splits[split.Date] = split
+	}
+
+	var dividendRows []types.Transaction
+	for _, tx := range rows {
+		if isDividendAction(tx.Action) {
+			dividendRows = append(dividendRows, tx)
+		}
+	}
+	dividends := NewIncomeCalculator(fc.baseCurrency).extractDividendRecords(dividendRows)
+
+	first := rows[0]
+	history := &PositionHistory{
+		ISIN:      safeDeref(first.ISIN),
+		Ticker:    safeDeref(first.Ticker),
+		Name:      safeDeref(first.Name),
+		Currency:  fc.baseCurrency,
+		Method:    ledger.Method,
+		FirstDate: first.Time,
+		LastDate:  rows[len(rows)-1].Time,
+		Warnings:  ledger.Warnings,
+	}
+
+	converter := newCurrencyConverter(fc.baseCurrency, accountCurrency(rows), nil)
+	sells, dividendIndex := 0, 0
+	for _, tx := range rows {
+		event := PositionEvent{Date: tx.Time, Action: string(tx.Action), ID: safeDeref(tx.ID)}
+		if tx.PricePerShare != nil {
+			event.PricePerShare = *tx.PricePerShare
+			event.PriceCurrency = safeDeref(tx.CurrencyPricePerShare)
+		}
+		hasShares := tx.Shares != nil && *tx.Shares > 0
+
+		switch {
+		case isBuyAction(tx.Action) && hasShares:
+			event.Kind = PositionBuy
+			event.Shares = *tx.Shares
+			event.Fees = engine.tradeFees(tx, converter)
+			event.Amount = engine.tradeValue(tx, converter) + event.Fees
+			history.Shares += event.Shares
+			history.CostBasis += event.Amount
+		case isSellAction(tx.Action) && hasShares:
+			disposal := ledger.Disposals[sells]
+			sells++
+			event.Kind = PositionSell
+			event.Shares = disposal.Shares
+			event.Fees = disposal.Fees
+			event.Amount = disposal.Proceeds
+			event.GainLoss = disposal.GainLoss
+			history.Shares -= disposal.Shares
+			history.CostBasis -= disposal.Cost
+			history.RealisedGainLoss += disposal.GainLoss
+		case isSplitAction(tx.Action):
+			split, ok := splits[tx.Time]
+			if !ok {
+				continue // The other row of the split, or a split the lot engine ignored
+			}
+			delete(splits, tx.Time)
+			event.Kind = PositionSplit
+			event.SplitRatio = split.Ratio
+			event.Shares = history.Shares * (split.Ratio - 1)
+			history.Shares *= split.Ratio
+		case isDividendAction(tx.Action):
+			record := dividends[dividendIndex]
+			dividendIndex++
+			event.Kind = PositionDividend
+			event.Shares = record.Shares
+			event.Amount = record.Amount
+			event.WithholdingTax = record.WithholdingTax
+			history.Dividends += record.Amount
+			history.WithholdingTax += record.WithholdingTax
+		case classifyCash(tx) == CashFee && tx.Total != nil:
+			event.Kind = PositionFee
+			event.Amount = converter.convert(math.Abs(*tx.Total), tx.CurrencyTotal, tx.Time, tx.ExchangeRate)
+			event.Fees = event.Amount
+		default:
+			continue
+		}
+		history.Fees += event.Fees
+
+		if math.Abs(history.Shares) <= ShareEpsilon {
+			history.Shares, history.CostBasis = 0, 0
+		}
+		history.AverageCost = 0
+		if history.Shares > 0 {
+			history.AverageCost = history.CostBasis / history.Shares
+		}
+		event.RunningShares = history.Shares
+		event.RunningCostBasis = history.CostBasis
+		event.AverageCost = history.AverageCost
+		event.RealisedGainLoss = history.RealisedGainLoss
+		event.Dividends = history.Dividends
+		history.Events = append(history.Events, event)
+	}
+
+	return history, nil
+}
+
+// securityTransactions returns the transactions of the security with the ISIN or ticker given,
+// in date order. A ticker is resolved to its ISIN, so rows without a ticker are included; a
+// ticker used by more than one ISIN must be given as an ISIN.
+func securityTransactions(transactions []types.Transaction, security string) ([]types.Transaction, error) {
+	security = strings.TrimSpace(security)
+	if security == "" {
+		return nil, fmt.Errorf("no security given")
+	}
+
+	isin, ticker := "", ""
+	isins := make(map[string]bool)
+	for _, tx := range transactions {
+		if strings.EqualFold(safeDeref(tx.ISIN), security) {
+			isin = safeDeref(tx.ISIN)
+			break
+		}
+		if strings.EqualFold(safeDeref(tx.Ticker), security) {
+			ticker = safeDeref(tx.Ticker)
+			if safeDeref(tx.ISIN) != "" {
+				isins[safeDeref(tx.ISIN)] = true
+			}
+		}
+	}
+	if isin == "" {
+		matches := make([]string, 0, len(isins))
+		for match := range isins {
+			matches = append(matches, match)
+		}
+		sort.Strings(matches)
+		if len(matches) > 1 {
+			return nil, fmt.Errorf("ticker %s matches more than one ISIN (%s); give the ISIN", security, strings.Join(matches, ", "))
+		}
+		if len(matches) == 1 {
+			isin = matches[0]
+		}
+	}
+
+	var rows []types.Transaction
+	for _, tx := range transactions {
+		rowISIN := safeDeref(tx.ISIN)
+		if (isin != "" && rowISIN == isin) || (ticker != "" && rowISIN == "" && safeDeref(tx.Ticker) == ticker) {
+			rows = append(rows, tx)
+		}
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no transactions found for %s", security)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].Time.Before(rows[j].Time)
+	})
+	return rows, nil
+}
+
+// isDividendAction checks if the action is a dividend of any kind
+func isDividendAction(action types.TransactionType) bool {
+	return strings.Contains(strings.ToLower(string(action)), "dividend")
+}
@@ -0,0 +1,87 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestFinancialCalculator_PositionHistory(t *testing.T) {
+	const isin = "US0378331005"
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+	splitClose := tradeTx(types.TransactionTypeSplitClose, day(2023, 8, 1), isin, 20, 130)
+	splitOpen := tradeTx(types.TransactionTypeSplitOpen, day(2023, 8, 1), isin, 80, 32.5)
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 2, 1), isin, 60, 50),
+		tradeTx(types.TransactionTypeMarketBuy, day(2023, 1, 10), isin, 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, day(2023, 6, 10), isin, 10, 150),
+		splitOpen,
+		splitClose,
+		dividendTx(day(2023, 11, 16), isin, 8, 1.2),
+		{Action: "Stamp duty fee", Time: day(2024, 3, 1), ISIN: stringPtr(isin), Total: floatPtr(-2), CurrencyTotal: stringPtr("EUR")},
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 1, 5), "IE00B4L5Y983", 5, 80),
+	}
+
+	history, err := NewFinancialCalculator("EUR").PositionHistory(transactions, "1005")
+	if err != nil {
+		t.Fatalf("PositionHistory() error = %v", err)
+	}
+
+	kinds := []PositionEventKind{PositionBuy, PositionBuy, PositionSplit, PositionDividend, PositionSell, PositionFee}
+	if len(history.Events) != len(kinds) {
+		t.Fatalf("Expected %d events, got %+v", len(kinds), history.Events)
+	}
+	for i, kind := range kinds {
+		if history.Events[i].Kind != kind {
+			t.Errorf("Event %d: expected %s, got %s", i, kind, history.Events[i].Kind)
+		}
+	}
+
+	split := history.Events[2]
+	if split.SplitRatio != 4 || abs(split.Shares-60) > 1e-9 || abs(split.RunningShares-80) > 1e-9 {
+		t.Errorf("Expected a 4:1 split adding 60 shares, got %+v", split)
+	}
+	if abs(split.RunningCostBasis-2500) > 1e-9 || abs(split.AverageCost-31.25) > 1e-9 {
+		t.Errorf("Expected the split to keep a 2500 cost basis at 31.25 a share, got %+v", split)
+	}
+
+	// FIFO: 40 post-split shares costing 1000 and 20 of the 40 costing 1500
+	sell := history.Events[4]
+	if abs(sell.GainLoss-1250) > 1e-9 || abs(sell.RunningShares-20) > 1e-9 || abs(sell.RunningCostBasis-750) > 1e-9 {
+		t.Errorf("Expected a 1250 gain leaving 20 shares costing 750, got %+v", sell)
+	}
+	if abs(sell.Dividends-8) > 1e-9 {
+		t.Errorf("Expected 8 of dividends by the sell, got %.2f", sell.Dividends)
+	}
+
+	if abs(history.Shares-20) > 1e-9 || abs(history.AverageCost-37.5) > 1e-9 || abs(history.RealisedGainLoss-1250) > 1e-9 {
+		t.Errorf("Expected 20 shares at 37.50 and 1250 realised, got %+v", history)
+	}
+	if abs(history.WithholdingTax-1.2) > 1e-9 || abs(history.Fees-2) > 1e-9 {
+		t.Errorf("Expected 1.20 withheld and 2.00 of fees, got %.2f and %.2f", history.WithholdingTax, history.Fees)
+	}
+	if history.ISIN != isin || len(history.Warnings) != 0 {
+		t.Errorf("Expected %s without warnings, got %s and %v", isin, history.ISIN, history.Warnings)
+	}
+}
+
+func TestFinancialCalculator_PositionHistoryErrors(t *testing.T) {
+	date := time.Date(2024, 1, 5, 10, 0, 0, 0, time.UTC)
+	first := tradeTx(types.TransactionTypeMarketBuy, date, "US0000000ABC", 1, 10)
+	second := tradeTx(types.TransactionTypeMarketBuy, date, "GB0000000ABC", 1, 10)
+	calc := NewFinancialCalculator("EUR")
+
+	if _, err := calc.PositionHistory([]types.Transaction{first, second}, "0ABC"); err == nil {
+		t.Error("Expected an error for a ticker with two ISINs")
+	}
+	if _, err := calc.PositionHistory([]types.Transaction{first, second}, "gb0000000abc"); err != nil {
+		t.Errorf("Expected the ISIN to pick one security, got %v", err)
+	}
+	if _, err := calc.PositionHistory([]types.Transaction{first}, "AAPL"); err == nil {
+		t.Error("Expected an error for a security without transactions")
+	}
+}
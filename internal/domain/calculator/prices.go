@@ -0,0 +1,213 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StalePriceDays is how old a price can be on the valuation date before it is reported as stale
+const StalePriceDays = 7
+
+// Price sources reported alongside valuations
+const (
+	// PriceSourceGiven marks a price supplied by the caller
+	PriceSourceGiven = "given"
+	// PriceSourceLastTrade marks the price of the security's latest trade
+	PriceSourceLastTrade = "last trade"
+	// PriceSourceCost marks a position valued at its cost basis for lack of any price
+	PriceSourceCost = "cost basis"
+)
+
+// PriceQuote is a closing price per share
+type PriceQuote struct {
+	Date     time.Time `json:"date"`
+	Price    float64   `json:"price"`
+	Currency string    `json:"currency,omitempty"`
+	Source   string    `json:"source"`
+}
+
+// PriceProvider supplies historical closing prices for valuing holdings
+type PriceProvider interface {
+	// Close returns the closing price of a security, by ISIN or ticker, on date or the last one before it
+	Close(security string, date time.Time) (PriceQuote, bool)
+}
+
+// PriceStore is a PriceProvider backed by a table of daily closing prices
+type PriceStore struct {
+	source string
+	prices map[string][]PriceQuote
+}
+
+// NewPriceStore creates an empty price store whose quotes are attributed to source
+func NewPriceStore(source string) *PriceStore {
+	return &PriceStore{
+		source: source,
+		prices: make(map[string][]PriceQuote),
+	}
+}
+
+// Add records the closing price of security, by ISIN or ticker, on date; currency may be empty when
+// the price is in the security's trading currency
+func (ps *PriceStore) Add(security string, date time.Time, price float64, currency string) {
+	security = strings.ToUpper(security)
+	day := truncateToDay(date)
+	quote := PriceQuote{Date: day, Price: price, Currency: strings.ToUpper(currency), Source: ps.source}
+	quotes := ps.prices[security]
+
+	index := sort.Search(len(quotes), func(i int) bool {
+		return !quotes[i].Date.Before(day)
+	})
+	if index < len(quotes) && quotes[index].Date.Equal(day) {
+		quotes[index] = quote
+		return
+	}
+
+	quotes = append(quotes, PriceQuote{})
+	copy(quotes[index+1:], quotes[index:])
+	quotes[index] = quote
+	ps.prices[security] = quotes
+}
+
+// Close returns the closing price of security on date or the last one before it
+func (ps *PriceStore) Close(security string, date time.Time) (PriceQuote, bool) {
+	quotes := ps.prices[strings.ToUpper(security)]
+	cutoff := truncateToDay(date).AddDate(0, 0, 1)
+
+	index := sort.Search(len(quotes), func(i int) bool {
+		return !quotes[i].Date.Before(cutoff)
+	}) - 1
+	if index < 0 {
+		return PriceQuote{}, false
+	}
+	return quotes[index], true
+}
+
+// Securities returns the ISINs and tickers present in the store
+func (ps *PriceStore) Securities() []string {
+	securities := make([]string, 0, len(ps.prices))
+	for security := range ps.prices {
+		securities = append(securities, security)
+	}
+	sort.Strings(securities)
+	return securities
+}
+
+// priceRecord is a closing price as written in a JSON price file
+type priceRecord struct {
+	Date     string  `json:"date"`
+	Security string  `json:"security"`
+	Price    float64 `json:"price"`
+	Currency string  `json:"currency"`
+}
+
+// LoadPriceStore loads closing prices from a CSV file with date,security,price[,currency] columns
+// or a JSON array of {date, security, price, currency} objects, chosen by the file extension
+func LoadPriceStore(filename string) (*PriceStore, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open price file %s: %w", filename, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	source := filepath.Base(filename)
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return ParsePriceJSON(file, source)
+	}
+	return ParsePriceCSV(file, source)
+}
+
+// ParsePriceCSV reads date,security,price[,currency] rows; the security is an ISIN or ticker
+func ParsePriceCSV(reader io.Reader, source string) (*PriceStore, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read price file: %w", err)
+	}
+
+	store := NewPriceStore(source)
+
+	for i, record := range records {
+		if len(record) < 3 {
+			return nil, fmt.Errorf("price file line %d: expected date,security,price", i+1)
+		}
+
+		date, err := time.Parse(rateDateLayout, strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				continue // Header row
+			}
+			return nil, fmt.Errorf("price file line %d: invalid date %q", i+1, record[0])
+		}
+
+		price, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+		if err != nil || price <= 0 {
+			return nil, fmt.Errorf("price file line %d: invalid price %q", i+1, record[2])
+		}
+
+		currency := ""
+		if len(record) > 3 {
+			currency = strings.TrimSpace(record[3])
+		}
+		store.Add(strings.TrimSpace(record[1]), date, price, currency)
+	}
+
+	return store, nil
+}
+
+// ParsePriceJSON reads a JSON array of {date, security, price, currency} objects
+func ParsePriceJSON(reader io.Reader, source string) (*PriceStore, error) {
+	var records []priceRecord
+	if err := json.NewDecoder(reader).Decode(&records); err != nil {
+		return nil, fmt.Errorf("failed to read price file: %w", err)
+	}
+
+	store := NewPriceStore(source)
+
+	for i, record := range records {
+		date, err := time.Parse(rateDateLayout, record.Date)
+		if err != nil {
+			return nil, fmt.Errorf("price file entry %d: invalid date %q", i+1, record.Date)
+		}
+		if record.Security == "" || record.Price <= 0 {
+			return nil, fmt.Errorf("price file entry %d: expected a security and a positive price", i+1)
+		}
+		store.Add(record.Security, date, record.Price, record.Currency)
+	}
+
+	return store, nil
+}
+
+// closingPrice returns the provider's close for a security by ISIN, then ticker; provider may be nil
+func closingPrice(provider PriceProvider, isin, ticker string, date time.Time) (PriceQuote, bool) {
+	if provider == nil {
+		return PriceQuote{}, false
+	}
+	for _, security := range []string{isin, ticker} {
+		if security == "" {
+			continue
+		}
+		if quote, ok := provider.Close(security, date); ok {
+			return quote, true
+		}
+	}
+	return PriceQuote{}, false
+}
+
+// priceAgeDays returns the whole days between a price's date and the valuation date
+func priceAgeDays(priced, valuation time.Time) int {
+	if days := HoldingDays(priced, valuation); days > 0 {
+		return days
+	}
+	return 0
+}
@@ -0,0 +1,120 @@
+package calculator
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestParsePriceCSV(t *testing.T) {
+	data := `date,security,price,currency
+2024-12-27,US5949181045,420.50,USD
+2024-12-31,us5949181045,421.00,USD
+2024-12-30,VUSA,95.10
+`
+	store, err := ParsePriceCSV(strings.NewReader(data), "prices.csv")
+	if err != nil {
+		t.Fatalf("ParsePriceCSV() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		security string
+		date     time.Time
+		want     float64
+		wantDate time.Time
+		wantOK   bool
+	}{
+		{"exact date", "US5949181045", time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC), 421, time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), true},
+		{"last close before", "US5949181045", time.Date(2024, 12, 29, 12, 0, 0, 0, time.UTC), 420.5, time.Date(2024, 12, 27, 0, 0, 0, 0, time.UTC), true},
+		{"ticker is case-insensitive", "vusa", time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC), 95.1, time.Date(2024, 12, 30, 0, 0, 0, 0, time.UTC), true},
+		{"before first close", "VUSA", time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC), 0, time.Time{}, false},
+		{"unknown security", "AAPL", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 0, time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, ok := store.Close(tt.security, tt.date)
+			if ok != tt.wantOK || abs(quote.Price-tt.want) > 1e-9 || !quote.Date.Equal(tt.wantDate) {
+				t.Errorf("Close() = %+v, %v, want %v on %v, %v", quote, ok, tt.want, tt.wantDate, tt.wantOK)
+			}
+			if ok && quote.Source != "prices.csv" {
+				t.Errorf("Expected the store name as source, got %q", quote.Source)
+			}
+		})
+	}
+
+	if _, err := ParsePriceCSV(strings.NewReader("2024-12-31,VUSA,abc\n"), "bad.csv"); err == nil {
+		t.Error("Expected an error for an invalid price")
+	}
+}
+
+func TestParsePriceJSON(t *testing.T) {
+	data := `[
+  {"date": "2024-12-31", "security": "IE00B3XXRP09", "price": 95.2, "currency": "eur"},
+  {"date": "2024-12-30", "security": "IE00B3XXRP09", "price": 94.8}
+]`
+	store, err := ParsePriceJSON(strings.NewReader(data), "prices.json")
+	if err != nil {
+		t.Fatalf("ParsePriceJSON() error = %v", err)
+	}
+
+	quote, ok := store.Close("IE00B3XXRP09", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if !ok || quote.Price != 95.2 || quote.Currency != "EUR" {
+		t.Errorf("Expected the 31 December close in EUR, got %+v, %v", quote, ok)
+	}
+
+	if _, err := ParsePriceJSON(strings.NewReader(`[{"date": "2024-12-31", "price": 1}]`), "bad.json"); err == nil {
+		t.Error("Expected an error for an entry without a security")
+	}
+}
+
+func TestPortfolioCalculator_PriceProvider(t *testing.T) {
+	buy := func(date time.Time, isin string, shares, price float64) types.Transaction {
+		tx := tradeTx(types.TransactionTypeMarketBuy, date, isin, shares, price)
+		tx.Total = floatPtr(shares * price)
+		tx.CurrencyTotal = stringPtr("EUR")
+		return tx
+	}
+	transactions := []types.Transaction{
+		buy(time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), "US5949181045", 10, 100),
+		buy(time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 5, 80),
+	}
+
+	store := NewPriceStore("prices.csv")
+	store.Add("US5949181045", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 130, "")
+	store.Add("Y983", time.Date(2024, 12, 15, 0, 0, 0, 0, time.UTC), 90, "EUR")
+
+	calculator := NewPortfolioCalculator("EUR")
+	calculator.SetPriceProvider(store)
+	portfolio := calculator.CalculateEndOfYearPortfolio(transactions, 2024)
+
+	positions := make(map[string]types.PortfolioPosition)
+	for _, position := range portfolio.Positions {
+		positions[position.ISIN] = position
+	}
+
+	msft := positions["US5949181045"]
+	if msft.LastPrice != 130 || msft.MarketValue != 1300 || msft.PriceSource != "prices.csv" || msft.PriceAgeDays != 0 || msft.PriceStale {
+		t.Errorf("Expected the 31 December close by ISIN, got %+v", msft)
+	}
+
+	// Looked up by ticker, and more than a week old on the valuation date
+	iwda := positions["IE00B4L5Y983"]
+	if iwda.LastPrice != 90 || iwda.PriceAgeDays != 16 || !iwda.PriceStale {
+		t.Errorf("Expected a stale close by ticker, got %+v", iwda)
+	}
+	if portfolio.StalePrices != 1 || portfolio.TotalMarketValue != 1750 {
+		t.Errorf("Expected 1 stale price and a value of 1750, got %d and %.2f", portfolio.StalePrices, portfolio.TotalMarketValue)
+	}
+
+	// Without a provider the last trade price is used
+	fallback := NewPortfolioCalculator("EUR").CalculateEndOfYearPortfolio(transactions, 2024)
+	for _, position := range fallback.Positions {
+		if position.PriceSource != PriceSourceLastTrade || !position.PriceStale {
+			t.Errorf("Expected a stale last trade price, got %+v", position)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// ReclaimMinimum is the smallest over-withheld amount reported as a claim
+	ReclaimMinimum = 0.01
+)
+
+// ReclaimStatus describes whether a reclaim can still be filed
+type ReclaimStatus string
+
+// Reclaim statuses
+const (
+	ReclaimOpen    ReclaimStatus = "open"    // The deadline has not passed
+	ReclaimExpired ReclaimStatus = "expired" // The deadline has passed
+	ReclaimUnknown ReclaimStatus = "unknown" // No reclaim period is known for the source country
+)
+
+// ReclaimClaim is a dividend withheld above the treaty rate
+type ReclaimClaim struct {
+	Date            time.Time `json:"date"`
+	Ticker          string    `json:"ticker,omitempty"`
+	ISIN            string    `json:"isin"`
+	Name            string    `json:"name,omitempty"`
+	SourceCountry   string    `json:"source_country"`
+	Shares          float64   `json:"shares,omitempty"`
+	GrossAmount     float64   `json:"gross_amount"`
+	Withheld        float64   `json:"withheld"`
+	WithholdingRate float64   `json:"withholding_rate"`
+	TreatyRate      float64   `json:"treaty_rate"`
+	Reclaimable     float64   `json:"reclaimable"`
+	// PaymentCurrency and the Payment amounts are in the currency the dividend was declared in,
+	// as reclaim forms ask for them; they are empty when the export has no per-share amount
+	PaymentCurrency    string        `json:"payment_currency,omitempty"`
+	PaymentGross       float64       `json:"payment_gross,omitempty"`
+	PaymentWithheld    float64       `json:"payment_withheld,omitempty"`
+	PaymentReclaimable float64       `json:"payment_reclaimable,omitempty"`
+	Deadline           time.Time     `json:"deadline,omitempty"`
+	DaysLeft           int           `json:"days_left,omitempty"`
+	Status             ReclaimStatus `json:"status"`
+}
+
+// ReclaimSummary totals the reclaimable tax of one source country and year of payment
+type ReclaimSummary struct {
+	Country     string        `json:"country"`
+	Year        int           `json:"year"`
+	Claims      int           `json:"claims"`
+	Withheld    float64       `json:"withheld"`
+	Reclaimable float64       `json:"reclaimable"`
+	Deadline    time.Time     `json:"deadline,omitempty"`
+	Status      ReclaimStatus `json:"status"`
+}
+
+// ReclaimReport lists over-withheld dividends and the tax that can be reclaimed from source countries
+type ReclaimReport struct {
+	ResidenceCountry string           `json:"residence_country"`
+	Currency         string           `json:"currency"`
+	AsOf             time.Time        `json:"as_of"`
+	Claims           []ReclaimClaim   `json:"claims"`
+	Summaries        []ReclaimSummary `json:"summaries"`
+	Open             float64          `json:"open"`
+	Expired          float64          `json:"expired"`
+	Unknown          float64          `json:"unknown"`
+}
+
+// GenerateReclaimReport finds dividends withheld above the treaty rate between the source country
+// and the jurisdiction's country and tracks the reclaim deadlines as of asOf. options.TaxYear
+// limits the report to dividends paid in that calendar year; 0 includes every year.
+func (c *TaxCalculator) GenerateReclaimReport(
+	transactions []types.Transaction,
+	options types.ProcessingOptions,
+	asOf time.Time,
+) (*ReclaimReport, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReclaimReport{
+		ResidenceCountry: jurisdiction.Country,
+		Currency:         c.reportingCurrency(jurisdiction, options),
+		AsOf:             truncateToDay(asOf),
+	}
+
+	summaries := make(map[string]*ReclaimSummary)
+	for _, record := range c.dividendRecords(transactions, jurisdiction, report.Currency) {
+		if options.TaxYear != 0 && record.Date.Year() != options.TaxYear {
+			continue
+		}
+		source := CountryFromISIN(record.ISIN)
+		if source == jurisdiction.Country {
+			continue
+		}
+
+		credit := c.foreignTaxCredit(jurisdiction, source, record)
+		if credit.Reclaimable < ReclaimMinimum {
+			continue
+		}
+
+		claim := c.reclaimClaim(credit, record, report.AsOf)
+		report.Claims = append(report.Claims, claim)
+
+		switch claim.Status {
+		case ReclaimOpen:
+			report.Open += claim.Reclaimable
+		case ReclaimExpired:
+			report.Expired += claim.Reclaimable
+		default:
+			report.Unknown += claim.Reclaimable
+		}
+
+		key := fmt.Sprintf("%s/%d", source, claim.Date.Year())
+		summary, exists := summaries[key]
+		if !exists {
+			summary = &ReclaimSummary{Country: source, Year: claim.Date.Year(), Deadline: claim.Deadline, Status: claim.Status}
+			summaries[key] = summary
+		}
+		summary.Claims++
+		summary.Withheld += claim.Withheld
+		summary.Reclaimable += claim.Reclaimable
+		// The summary shows the earliest deadline still open
+		if claim.Status == ReclaimOpen && (summary.Status != ReclaimOpen || claim.Deadline.Before(summary.Deadline)) {
+			summary.Deadline = claim.Deadline
+			summary.Status = ReclaimOpen
+		}
+	}
+
+	sort.Slice(report.Claims, func(i, j int) bool {
+		return report.Claims[i].Date.Before(report.Claims[j].Date)
+	})
+	for _, summary := range summaries {
+		report.Summaries = append(report.Summaries, *summary)
+	}
+	sort.Slice(report.Summaries, func(i, j int) bool {
+		if report.Summaries[i].Country != report.Summaries[j].Country {
+			return report.Summaries[i].Country < report.Summaries[j].Country
+		}
+		return report.Summaries[i].Year < report.Summaries[j].Year
+	})
+
+	return report, nil
+}
+
+// reclaimClaim builds the claim for an over-withheld dividend
+func (c *TaxCalculator) reclaimClaim(credit ForeignTaxCredit, record types.DividendRecord, asOf time.Time) ReclaimClaim {
+	claim := ReclaimClaim{
+		Date:            credit.Date,
+		Ticker:          credit.Ticker,
+		ISIN:            credit.ISIN,
+		Name:            credit.Name,
+		SourceCountry:   credit.SourceCountry,
+		Shares:          record.Shares,
+		GrossAmount:     credit.GrossAmount,
+		Withheld:        credit.Withheld,
+		WithholdingRate: credit.WithholdingRate,
+		TreatyRate:      credit.TreatyRate,
+		Reclaimable:     credit.Reclaimable,
+		Status:          ReclaimUnknown,
+	}
+
+	if record.Shares > 0 && record.PricePerShare > 0 {
+		claim.PaymentCurrency = record.PriceCurrency
+		claim.PaymentGross = record.Shares * record.PricePerShare
+		claim.PaymentWithheld = claim.PaymentGross * credit.WithholdingRate
+		claim.PaymentReclaimable = claim.PaymentGross * math.Max(credit.WithholdingRate-credit.TreatyRate, 0)
+	}
+
+	if c.treaties == nil {
+		return claim
+	}
+	if period, known := c.treaties.ReclaimPeriod(credit.SourceCountry); known {
+		claim.Deadline = period.Deadline(credit.Date)
+		claim.DaysLeft = int(claim.Deadline.Sub(asOf).Hours() / HoursPerDay)
+		claim.Status = ReclaimOpen
+		if claim.Deadline.Before(asOf) {
+			claim.Status = ReclaimExpired
+		}
+	}
+
+	return claim
+}
+
+// WriteCSV writes one row per over-withheld payment, with the amounts in the payment currency
+// reclaim forms ask for alongside the reporting currency amounts
+func (r *ReclaimReport) WriteCSV(w io.Writer) error {
+	records := [][]string{{"Payment date", "Country", "ISIN", "Ticker", "Name", "Shares",
+		"Payment currency", "Gross (payment)", "Withheld (payment)", "Reclaimable (payment)",
+		"Withholding rate", "Treaty rate", "Gross", "Withheld", "Reclaimable", "Currency", "Deadline", "Status"}}
+	for _, claim := range r.Claims {
+		deadline := ""
+		if !claim.Deadline.IsZero() {
+			deadline = claim.Deadline.Format(rateDateLayout)
+		}
+		records = append(records, []string{
+			claim.Date.Format(rateDateLayout), claim.SourceCountry, claim.ISIN, claim.Ticker, claim.Name,
+			formatShares(claim.Shares), claim.PaymentCurrency, formatAmount(claim.PaymentGross),
+			formatAmount(claim.PaymentWithheld), formatAmount(claim.PaymentReclaimable),
+			fmt.Sprintf("%.4f", claim.WithholdingRate), fmt.Sprintf("%.4f", claim.TreatyRate),
+			formatAmount(claim.GrossAmount), formatAmount(claim.Withheld), formatAmount(claim.Reclaimable),
+			r.Currency, deadline, string(claim.Status),
+		})
+	}
+
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write reclaim CSV: %w", err)
+	}
+	return nil
+}
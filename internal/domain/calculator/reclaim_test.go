@@ -0,0 +1,93 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateReclaimReport(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	nestle := dividendTx(time.Date(2024, 5, 2, 9, 0, 0, 0, time.UTC), "CH0038863350", 100, 35)
+	nestle.Shares = floatPtr(10)
+	nestle.PricePerShare = floatPtr(3)
+	nestle.CurrencyPricePerShare = stringPtr("CHF")
+
+	transactions := []types.Transaction{
+		nestle,
+		dividendTx(time.Date(2021, 5, 1, 9, 0, 0, 0, time.UTC), "DE0007164600", 100, 26.375),
+		dividendTx(time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 15),
+		dividendTx(time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC), "JP3633400001", 100, 20),
+	}
+
+	asOf := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	report, err := calc.GenerateReclaimReport(transactions, types.ProcessingOptions{Jurisdiction: "LT"}, asOf)
+	if err != nil {
+		t.Fatalf("GenerateReclaimReport() error = %v", err)
+	}
+
+	// The US dividend was withheld at the treaty rate
+	if len(report.Claims) != 3 {
+		t.Fatalf("Expected 3 claims, got %+v", report.Claims)
+	}
+
+	germany := report.Claims[0]
+	if germany.SourceCountry != "DE" || abs(germany.Reclaimable-11.375) > 0.001 || germany.Status != ReclaimExpired ||
+		!germany.Deadline.Equal(time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected an expired German claim of 11.375 due by the end of 2025, got %+v", germany)
+	}
+
+	swiss := report.Claims[1]
+	if swiss.SourceCountry != "CH" || swiss.Reclaimable != 20 || swiss.Status != ReclaimOpen ||
+		!swiss.Deadline.Equal(time.Date(2027, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected an open Swiss claim of 20 due by the end of 2027, got %+v", swiss)
+	}
+	if swiss.PaymentCurrency != "CHF" || swiss.PaymentGross != 30 || abs(swiss.PaymentReclaimable-6) > 0.001 {
+		t.Errorf("Expected 6 of 30 CHF reclaimable, got %+v", swiss)
+	}
+
+	if japan := report.Claims[2]; japan.Status != ReclaimUnknown || japan.Reclaimable != 5 {
+		t.Errorf("Expected a Japanese claim of 5 with no known deadline, got %+v", japan)
+	}
+
+	if report.Open != 20 || abs(report.Expired-11.375) > 0.001 || report.Unknown != 5 || len(report.Summaries) != 3 {
+		t.Errorf("Expected 20 open, 11.375 expired and 5 unknown in 3 summaries, got %+v", report)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(records) != 4 || records[2][1] != "CH" || records[2][9] != "6.00" || records[2][16] != "2027-12-31" {
+		t.Errorf("Unexpected reclaim CSV: %v", records)
+	}
+
+	// A tax year limits the report to dividends paid in it
+	report, _ = calc.GenerateReclaimReport(transactions, types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2021}, asOf)
+	if len(report.Claims) != 1 {
+		t.Errorf("Expected 1 claim in 2021, got %d", len(report.Claims))
+	}
+}
+
+func TestReclaimPeriod_Deadline(t *testing.T) {
+	paid := time.Date(2024, 3, 15, 14, 0, 0, 0, time.UTC)
+
+	if deadline := (ReclaimPeriod{Years: 4, From: ReclaimFromPayment}).Deadline(paid); !deadline.Equal(time.Date(2028, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected four years from payment, got %v", deadline)
+	}
+	if deadline := (ReclaimPeriod{Years: 2, From: ReclaimFromYearEnd}).Deadline(paid); !deadline.Equal(time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected two years from the end of 2024, got %v", deadline)
+	}
+
+	if _, err := ParseTreatyTable([]byte("version: 1\nreclaim_periods:\n  DE: {years: 4, from: filing}\n")); err == nil {
+		t.Error("Expected error for an unknown reclaim period start")
+	}
+}
@@ -0,0 +1,230 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// ReconcileRow compares the computed gain on one sell with Trading 212's Result. The difference
+// is split into the effect of the matching method, of fees, of exchange rates and a remainder:
+//
+//	GainLoss - BrokerResult = MethodEffect + FeeEffect + FXEffect + Unexplained
+type ReconcileRow struct {
+	Date      time.Time `json:"date"`
+	ID        string    `json:"id,omitempty"`
+	Ticker    string    `json:"ticker,omitempty"`
+	ISIN      string    `json:"isin,omitempty"`
+	Shares    float64   `json:"shares"`
+	Proceeds  float64   `json:"proceeds"`
+	Cost      float64   `json:"cost"`
+	GainLoss  float64   `json:"gain_loss"`
+	HasResult bool      `json:"has_result"`
+	// BrokerResult is Trading 212's Result, using average cost in the instrument currency
+	BrokerResult float64 `json:"broker_result"`
+	Difference   float64 `json:"difference"`
+	// MethodEffect is the difference between the configured matching method and average cost
+	MethodEffect float64 `json:"method_effect"`
+	// FeeEffect is the effect of including conversion fees in cost and proceeds
+	FeeEffect float64 `json:"fee_effect"`
+	// FXEffect is the difference between converting purchases and sales at their own dates' rates
+	// from the configured source, and converting the instrument-currency gain at the sale rate
+	// Trading 212 applied
+	FXEffect    float64 `json:"fx_effect"`
+	Unexplained float64 `json:"unexplained"`
+	Flagged     bool    `json:"flagged"`
+	Explanation string  `json:"explanation"`
+}
+
+// ReconciliationReport compares computed gains with Trading 212's Result column for every sell
+type ReconciliationReport struct {
+	Jurisdiction  string         `json:"jurisdiction"`
+	Year          int            `json:"year,omitempty"`
+	Method        MatchingMethod `json:"method"`
+	Currency      string         `json:"currency"`
+	Tolerance     float64        `json:"tolerance"`
+	Rows          []ReconcileRow `json:"rows"`
+	GainLoss      float64        `json:"gain_loss"`
+	BrokerResult  float64        `json:"broker_result"`
+	Difference    float64        `json:"difference"`
+	MethodEffect  float64        `json:"method_effect"`
+	FeeEffect     float64        `json:"fee_effect"`
+	FXEffect      float64        `json:"fx_effect"`
+	Unexplained   float64        `json:"unexplained"`
+	Flagged       int            `json:"flagged"`
+	WithoutResult int            `json:"without_result"`
+}
+
+// Reconcile compares the gain on every sell in options.TaxYear, computed with the jurisdiction's
+// matching method and exchange rates, with Trading 212's Result and explains the difference.
+// Rows whose difference exceeds tolerance are flagged; a tolerance of 0 uses
+// GainDiscrepancyTolerance. A tax year of 0 includes every sell.
+func (c *TaxCalculator) Reconcile(
+	transactions []types.Transaction,
+	options types.ProcessingOptions,
+	tolerance float64,
+) (*ReconciliationReport, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return nil, err
+	}
+	if tolerance <= 0 {
+		tolerance = GainDiscrepancyTolerance
+	}
+	currency := c.reportingCurrency(jurisdiction, options)
+
+	configured := c.newLotEngine(jurisdiction, currency)
+	computed := configured.Process(transactions).Disposals
+
+	// Each step moves one assumption towards Trading 212's: average cost, then no fees, then the
+	// broker's exchange rates
+	noFees := withoutFees(transactions)
+	averaged := NewLotEngine(currency, MatchingAverageCost, c.ratesFor(jurisdiction, currency)).Process(transactions).Disposals
+	averagedNoFees := NewLotEngine(currency, MatchingAverageCost, c.ratesFor(jurisdiction, currency)).Process(noFees).Disposals
+	brokerRates := NewLotEngine(currency, MatchingAverageCost, nil).Process(noFees).Disposals
+
+	report := &ReconciliationReport{
+		Jurisdiction: jurisdiction.Code,
+		Year:         options.TaxYear,
+		Method:       jurisdiction.MatchingMethod,
+		Currency:     currency,
+		Tolerance:    tolerance,
+	}
+
+	for i, disposal := range computed {
+		if !inTaxYear(jurisdiction, options.TaxYear, disposal.Date) {
+			continue
+		}
+
+		row := ReconcileRow{
+			Date:     disposal.Date,
+			ID:       disposal.ID,
+			Ticker:   disposal.Ticker,
+			ISIN:     disposal.ISIN,
+			Shares:   disposal.Shares,
+			Proceeds: disposal.Proceeds,
+			Cost:     disposal.Cost,
+			GainLoss: disposal.GainLoss,
+		}
+
+		if disposal.BrokerResult == nil {
+			row.Explanation = "no Result in export"
+			report.WithoutResult++
+			report.Rows = append(report.Rows, row)
+			report.GainLoss += row.GainLoss
+			continue
+		}
+
+		localGain := localCurrencyGain(brokerRates[i], averagedNoFees[i].GainLoss)
+
+		row.HasResult = true
+		row.BrokerResult = *disposal.BrokerResult
+		row.Difference = row.GainLoss - row.BrokerResult
+		row.MethodEffect = disposal.GainLoss - averaged[i].GainLoss
+		row.FeeEffect = averaged[i].GainLoss - averagedNoFees[i].GainLoss
+		row.FXEffect = averagedNoFees[i].GainLoss - localGain
+		row.Unexplained = localGain - row.BrokerResult
+		row.Flagged = math.Abs(row.Difference) > tolerance
+		row.Explanation = explainDifference(row, tolerance)
+
+		report.Rows = append(report.Rows, row)
+		report.GainLoss += row.GainLoss
+		report.BrokerResult += row.BrokerResult
+		report.Difference += row.Difference
+		report.MethodEffect += row.MethodEffect
+		report.FeeEffect += row.FeeEffect
+		report.FXEffect += row.FXEffect
+		report.Unexplained += row.Unexplained
+		if row.Flagged {
+			report.Flagged++
+		}
+	}
+
+	return report, nil
+}
+
+// localCurrencyGain returns an average cost disposal's gain in the instrument currency converted
+// at the rate Trading 212 applied to the sale, or fallback when the disposal has no price
+func localCurrencyGain(disposal Disposal, fallback float64) float64 {
+	localProceeds := disposal.Shares * disposal.PricePerShare
+	if localProceeds <= 0 {
+		return fallback
+	}
+
+	localCost := 0.0
+	for _, match := range disposal.Matches {
+		localCost += match.LocalCost
+	}
+	return (localProceeds - localCost) * disposal.GrossProceeds / localProceeds
+}
+
+// withoutFees returns a copy of transactions with conversion fees removed
+func withoutFees(transactions []types.Transaction) []types.Transaction {
+	stripped := make([]types.Transaction, len(transactions))
+	for i, tx := range transactions {
+		tx.CurrencyConversionFee = nil
+		stripped[i] = tx
+	}
+	return stripped
+}
+
+// explainDifference names the effects that exceed tolerance, largest first
+func explainDifference(row ReconcileRow, tolerance float64) string {
+	if !row.Flagged {
+		return "within tolerance"
+	}
+
+	effects := []struct {
+		name   string
+		amount float64
+	}{
+		{"method", row.MethodEffect},
+		{"fees", row.FeeEffect},
+		{"FX", row.FXEffect},
+		{"unexplained", row.Unexplained},
+	}
+	sort.SliceStable(effects, func(i, j int) bool {
+		return math.Abs(effects[i].amount) > math.Abs(effects[j].amount)
+	})
+
+	var parts []string
+	for _, effect := range effects {
+		if math.Abs(effect.amount) > tolerance {
+			parts = append(parts, fmt.Sprintf("%s %+.2f", effect.name, effect.amount))
+		}
+	}
+	if len(parts) == 0 {
+		return "several small effects"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// WriteCSV writes one row per sell with the computed gain, Trading 212's Result and the
+// breakdown of the difference
+func (r *ReconciliationReport) WriteCSV(w io.Writer) error {
+	records := [][]string{{"Date", "ID", "Ticker", "ISIN", "Shares", "Proceeds", "Cost", "Gain/Loss",
+		"T212 Result", "Difference", "Method", "Fees", "FX", "Unexplained", "Flagged", "Explanation", "Currency"}}
+	for _, row := range r.Rows {
+		result, difference := "", ""
+		if row.HasResult {
+			result, difference = formatAmount(row.BrokerResult), formatAmount(row.Difference)
+		}
+		records = append(records, []string{
+			row.Date.Format(rateDateLayout), row.ID, row.Ticker, row.ISIN, formatShares(row.Shares),
+			formatAmount(row.Proceeds), formatAmount(row.Cost), formatAmount(row.GainLoss), result, difference,
+			formatAmount(row.MethodEffect), formatAmount(row.FeeEffect), formatAmount(row.FXEffect),
+			formatAmount(row.Unexplained), fmt.Sprintf("%t", row.Flagged), row.Explanation, r.Currency,
+		})
+	}
+
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write reconciliation CSV: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package calculator
+
+import (
+	"bytes"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_Reconcile(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	usdTrade := func(action types.TransactionType, date time.Time, shares, price, rate float64) types.Transaction {
+		tx := tradeTx(action, date, "US0378331005", shares, price)
+		tx.CurrencyPricePerShare = stringPtr("USD")
+		tx.ExchangeRate = floatPtr(rate)
+		return tx
+	}
+
+	sell := usdTrade(types.TransactionTypeMarketSell, time.Date(2024, 6, 1, 10, 0, 0, 0, time.UTC), 10, 250, 1.25)
+	sell.CurrencyConversionFee = floatPtr(3)
+	sell.CurrencyCurrencyConversionFee = stringPtr("EUR")
+	sell.Result = floatPtr(800)
+	sell.CurrencyResult = stringPtr("EUR")
+
+	matching := tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 5, 12)
+	matching.Result = floatPtr(10)
+	matching.CurrencyResult = stringPtr("EUR")
+
+	transactions := []types.Transaction{
+		usdTrade(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), 10, 100, 1.0),
+		usdTrade(types.TransactionTypeMarketBuy, time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), 10, 200, 1.25),
+		sell,
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 6, 10, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 10),
+		matching,
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 8, 1, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 5, 11),
+	}
+
+	report, err := calc.Reconcile(transactions, types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2024}, 0)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(report.Rows) != 3 || report.Flagged != 1 || report.WithoutResult != 1 {
+		t.Fatalf("Expected 3 rows with 1 flagged and 1 without a Result, got %+v", report)
+	}
+
+	// FIFO gain 1997 against T212's 800: 300 from matching the cheaper lot, -3 of fees and -100
+	// from converting each trade at its own rate rather than the gain at the sale rate
+	row := report.Rows[0]
+	if abs(row.GainLoss-997) > 0.001 || abs(row.Difference-197) > 0.001 {
+		t.Errorf("Expected gain 997 differing by 197, got %+v", row)
+	}
+	if abs(row.MethodEffect-300) > 0.001 || abs(row.FeeEffect+3) > 0.001 || abs(row.FXEffect+100) > 0.001 || abs(row.Unexplained) > 0.001 {
+		t.Errorf("Expected method 300, fees -3, FX -100 and nothing unexplained, got %+v", row)
+	}
+	if !row.Flagged || row.Explanation != "method +300.00; FX -100.00; fees -3.00" {
+		t.Errorf("Unexpected flag or explanation: %v %q", row.Flagged, row.Explanation)
+	}
+
+	if row := report.Rows[1]; row.Flagged || row.Explanation != "within tolerance" {
+		t.Errorf("Expected the matching sell within tolerance, got %+v", row)
+	}
+	if row := report.Rows[2]; row.HasResult || row.Flagged {
+		t.Errorf("Expected the last sell without a Result, got %+v", row)
+	}
+
+	// A wider tolerance clears the flag
+	report, _ = calc.Reconcile(transactions, types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2024}, 500)
+	if report.Flagged != 0 {
+		t.Errorf("Expected no flagged rows with a 500 tolerance, got %d", report.Flagged)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV() error = %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to read CSV: %v", err)
+	}
+	if len(records) != 4 || records[1][8] != "800.00" || records[1][10] != "300.00" || records[3][8] != "" {
+		t.Errorf("Unexpected reconciliation CSV: %v", records)
+	}
+}
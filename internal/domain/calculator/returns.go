@@ -0,0 +1,320 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// DaysPerYear annualises money-weighted returns
+	DaysPerYear = 365.0
+	// xirrIterations bounds the bisection used to solve for the money-weighted return
+	xirrIterations = 200
+	// xirrTolerance is the precision the money-weighted return is solved to
+	xirrTolerance = 1e-9
+	// minimumBase is the smallest value a sub-period return is measured against
+	minimumBase = 0.01
+)
+
+// PeriodReturn is the performance of the portfolio or a position over a period. Returns are percentages.
+type PeriodReturn struct {
+	// Year is the calendar year of the period, or 0 for the whole history
+	Year       int       `json:"year,omitempty"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	StartValue float64   `json:"start_value"`
+	EndValue   float64   `json:"end_value"`
+	// NetFlows is the money put in less the money taken out during the period
+	NetFlows float64 `json:"net_flows"`
+	// TimeWeighted chains the returns of the sub-periods between cash flows, so it is not affected
+	// by the timing or size of deposits and withdrawals
+	TimeWeighted float64 `json:"time_weighted"`
+	// MoneyWeighted is the annualised internal rate of return (XIRR) of the period's cash flows, or
+	// nil when it has no solution
+	MoneyWeighted *float64 `json:"money_weighted,omitempty"`
+}
+
+// PositionReturns is the performance of a single security, treating buys as money put in and
+// sells and dividends as money taken out
+type PositionReturns struct {
+	Ticker  string         `json:"ticker"`
+	ISIN    string         `json:"isin"`
+	Name    string         `json:"name"`
+	Overall PeriodReturn   `json:"overall"`
+	Years   []PeriodReturn `json:"years"`
+}
+
+// ReturnsReport holds time-weighted and money-weighted returns per year, overall and per position.
+// The portfolio's value includes cash, and its cash flows are deposits and withdrawals.
+type ReturnsReport struct {
+	Currency  string            `json:"currency"`
+	From      time.Time         `json:"from"`
+	To        time.Time         `json:"to"`
+	Years     []PeriodReturn    `json:"years"`
+	Overall   PeriodReturn      `json:"overall"`
+	Positions []PositionReturns `json:"positions"`
+	PriceNote string            `json:"price_note"`
+}
+
+// dailyValue is a value at the close of a day and the cash flows of that day
+type dailyValue struct {
+	value float64
+	// in is the money put in during the day and out the money taken out
+	in  float64
+	out float64
+}
+
+// dated is a cash flow on a date, positive when received by the investor
+type dated struct {
+	date   time.Time
+	amount float64
+}
+
+// CalculateReturns replays transactions day by day from the first transaction to the close of to,
+// or of the last transaction when to is zero, and measures the time-weighted and money-weighted
+// returns of the portfolio and of each position, for each calendar year and for the whole history
+func (pc *PortfolioCalculator) CalculateReturns(transactions []types.Transaction, to time.Time) *ReturnsReport {
+	report := &ReturnsReport{Currency: pc.baseCurrency, PriceNote: pc.priceNote()}
+	sorted := sortedByTime(transactions)
+	from, to, ok := returnsPeriod(sorted, to)
+	if !ok {
+		return report
+	}
+	report.From, report.To = from, to
+
+	days := pc.replayDays(sorted, seriesDates(from, to, SeriesDaily))
+	report.Overall, report.Years = periodReturns(days.dates, days.portfolio)
+	for ticker, values := range days.positions {
+		security, held := days.securities[ticker]
+		if !held {
+			// Dividends alone do not make a position
+			continue
+		}
+		overall, years := periodReturns(days.dates, values)
+		report.Positions = append(report.Positions, PositionReturns{
+			Ticker:  ticker,
+			ISIN:    security.ISIN,
+			Name:    security.Name,
+			Overall: overall,
+			Years:   years,
+		})
+	}
+	sort.Slice(report.Positions, func(i, j int) bool {
+		return report.Positions[i].Ticker < report.Positions[j].Ticker
+	})
+
+	return report
+}
+
+// returnsPeriod returns the days from the first of the sorted transactions to to, or to the last
+// transaction when to is zero, and whether there are any
+func returnsPeriod(sorted []types.Transaction, to time.Time) (time.Time, time.Time, bool) {
+	if len(sorted) == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	from := truncateToDay(sorted[0].Time)
+	if to.IsZero() {
+		to = sorted[len(sorted)-1].Time
+	}
+	to = truncateToDay(to)
+	return from, to, !to.Before(from)
+}
+
+// replayedDays holds the values and cash flows of the portfolio and of each position at the close of each day
+type replayedDays struct {
+	dates      []time.Time
+	portfolio  []dailyValue
+	positions  map[string][]dailyValue
+	securities map[string]types.PortfolioPosition
+}
+
+// replayDays replays transactions sorted by time to the close of each of dates, recording the
+// portfolio's value with cash and its deposits and withdrawals, and each position's value, buys,
+// sells and dividends
+func (pc *PortfolioCalculator) replayDays(sorted []types.Transaction, dates []time.Time) *replayedDays {
+	days := &replayedDays{
+		dates:      dates,
+		portfolio:  make([]dailyValue, 0, len(dates)),
+		positions:  make(map[string][]dailyValue),
+		securities: make(map[string]types.PortfolioPosition),
+	}
+	cash := 0.0
+
+	pc.replay(sorted, dates, func(date time.Time, replayed []types.Transaction, held []types.PortfolioPosition, totals *PositionTotals) {
+		day := dailyValue{}
+		dayPositions := make(map[string]dailyValue)
+		for _, tx := range replayed {
+			flow := pc.cashFlow(tx)
+			cash += flow
+			if isExternalFlow(tx) {
+				if flow > 0 {
+					day.in += flow
+				} else {
+					day.out -= flow
+				}
+			}
+			if tx.Ticker != nil && *tx.Ticker != "" {
+				position := dayPositions[*tx.Ticker]
+				switch {
+				case pc.isBuyTransaction(tx):
+					position.in -= flow
+				case pc.isSellTransaction(tx):
+					position.out += flow
+				default:
+					position.out += pc.positionIncome(tx)
+				}
+				dayPositions[*tx.Ticker] = position
+			}
+		}
+		day.value = totals.TotalMarketValue + cash
+		days.portfolio = append(days.portfolio, day)
+
+		for _, position := range held {
+			entry := dayPositions[position.Ticker]
+			entry.value = position.MarketValue
+			dayPositions[position.Ticker] = entry
+			days.securities[position.Ticker] = position
+		}
+		index := len(days.portfolio) - 1
+		for ticker, entry := range dayPositions {
+			if days.positions[ticker] == nil {
+				days.positions[ticker] = make([]dailyValue, len(dates))
+			}
+			days.positions[ticker][index] = entry
+		}
+	})
+
+	return days
+}
+
+// positionIncome returns the dividend a transaction pays on a position, or 0 for other transactions
+func (pc *PortfolioCalculator) positionIncome(tx types.Transaction) float64 {
+	if !strings.Contains(strings.ToLower(string(tx.Action)), "dividend") {
+		return 0
+	}
+	return pc.extractTransactionAmount(tx)
+}
+
+// periodReturns measures returns over all days and over each calendar year of days
+func periodReturns(dates []time.Time, values []dailyValue) (PeriodReturn, []PeriodReturn) {
+	overall := measureReturn(dates, values, 0, len(dates))
+	var years []PeriodReturn
+	for _, days := range yearRanges(dates) {
+		period := measureReturn(dates, values, days[0], days[1])
+		period.Year = dates[days[0]].Year()
+		if !period.empty() {
+			years = append(years, period)
+		}
+	}
+	return overall, years
+}
+
+// yearRanges splits ascending dates into the start and end indexes of each calendar year
+func yearRanges(dates []time.Time) [][2]int {
+	var ranges [][2]int
+	for start := 0; start < len(dates); {
+		end := start
+		for end < len(dates) && dates[end].Year() == dates[start].Year() {
+			end++
+		}
+		ranges = append(ranges, [2]int{start, end})
+		start = end
+	}
+	return ranges
+}
+
+// empty reports whether nothing was invested during the period
+func (p PeriodReturn) empty() bool {
+	return p.StartValue == 0 && p.EndValue == 0 && p.NetFlows == 0
+}
+
+// measureReturn measures the returns over days start to end-1, starting from the value at the
+// close of the day before start
+func measureReturn(dates []time.Time, values []dailyValue, start, end int) PeriodReturn {
+	period := PeriodReturn{From: dates[start], To: dates[end-1]}
+	if start > 0 {
+		period.StartValue = values[start-1].value
+	}
+	period.EndValue = values[end-1].value
+
+	// Money is taken to be put in at the start of a day and taken out at its close
+	flows := []dated{{date: dates[start], amount: -period.StartValue}}
+	growth := 1.0
+	previous := period.StartValue
+	for i := start; i < end; i++ {
+		day := values[i]
+		period.NetFlows += day.in - day.out
+		if daily, ok := dailyReturn(previous, day); ok {
+			growth *= 1 + daily
+		}
+		previous = day.value
+		if day.in != 0 || day.out != 0 {
+			flows = append(flows, dated{date: dates[i], amount: day.out - day.in})
+		}
+	}
+	flows = append(flows, dated{date: dates[end-1], amount: period.EndValue})
+
+	period.TimeWeighted = (growth - 1) * PercentMultiplier
+	if rate, ok := xirr(flows); ok {
+		rate *= PercentMultiplier
+		period.MoneyWeighted = &rate
+	}
+	return period
+}
+
+// dailyReturn returns a day's return on the previous close, with money put in at the start of the
+// day and taken out at its close; it is not defined when there was next to nothing invested
+func dailyReturn(previous float64, day dailyValue) (float64, bool) {
+	base := previous + day.in
+	if base <= minimumBase {
+		return 0, false
+	}
+	return (day.value+day.out)/base - 1, true
+}
+
+// xirr solves for the annual rate at which the flows' net present value is zero. It needs money
+// both paid and received, and assumes the present value falls as the rate rises.
+func xirr(flows []dated) (float64, bool) {
+	paid, received := false, false
+	for _, flow := range flows {
+		paid = paid || flow.amount < 0
+		received = received || flow.amount > 0
+	}
+	if !paid || !received {
+		return 0, false
+	}
+
+	presentValue := func(rate float64) float64 {
+		total := 0.0
+		for _, flow := range flows {
+			years := flow.date.Sub(flows[0].date).Hours() / HoursPerDay / DaysPerYear
+			total += flow.amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	low, high := -0.9999, 1.0
+	for presentValue(high) > 0 {
+		if high > 1e6 {
+			return 0, false
+		}
+		high *= 2
+	}
+	if presentValue(low) < 0 {
+		return 0, false
+	}
+
+	for i := 0; i < xirrIterations && high-low > xirrTolerance; i++ {
+		middle := (low + high) / 2
+		if presentValue(middle) > 0 {
+			low = middle
+		} else {
+			high = middle
+		}
+	}
+	return (low + high) / 2, true
+}
@@ -0,0 +1,117 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestPortfolioCalculator_CalculateReturns(t *testing.T) {
+	buy := func(date time.Time, shares, price float64) types.Transaction {
+		tx := tradeTx(types.TransactionTypeMarketBuy, date, "IE00B4L5Y983", shares, price)
+		tx.Total = floatPtr(shares * price)
+		tx.CurrencyTotal = stringPtr("EUR")
+		return tx
+	}
+	deposit := func(date time.Time, total float64) types.Transaction {
+		return types.Transaction{Action: types.TransactionTypeDeposit, Time: date, Total: floatPtr(total), CurrencyTotal: stringPtr("EUR")}
+	}
+	transactions := []types.Transaction{
+		deposit(time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), 1000),
+		buy(time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), 10, 100),
+		deposit(time.Date(2024, 7, 1, 9, 0, 0, 0, time.UTC), 2000),
+		buy(time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC), 10, 200),
+	}
+
+	// The price doubles before the second deposit, then falls 10% by the year end and rises 10% after
+	store := NewPriceStore("prices.csv")
+	store.Add("IE00B4L5Y983", time.Date(2024, 6, 28, 0, 0, 0, 0, time.UTC), 200, "")
+	store.Add("IE00B4L5Y983", time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC), 180, "")
+	store.Add("IE00B4L5Y983", time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC), 198, "")
+
+	calculator := NewPortfolioCalculator("EUR")
+	calculator.SetPriceProvider(store)
+	report := calculator.CalculateReturns(transactions, time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC))
+
+	if len(report.Years) != 2 {
+		t.Fatalf("Expected 2 years, got %+v", report.Years)
+	}
+	year2024, year2025 := report.Years[0], report.Years[1]
+
+	// Time-weighted: +100% then -10% in 2024 regardless of the deposit
+	if abs(year2024.TimeWeighted-80) > 1e-6 || year2024.EndValue != 3600 || year2024.NetFlows != 3000 {
+		t.Errorf("Unexpected 2024 return %+v", year2024)
+	}
+	if abs(year2025.TimeWeighted-10) > 1e-6 || year2025.StartValue != 3600 {
+		t.Errorf("Unexpected 2025 return %+v", year2025)
+	}
+	if abs(report.Overall.TimeWeighted-98) > 1e-6 {
+		t.Errorf("Expected an overall time-weighted return of 98%%, got %.4f", report.Overall.TimeWeighted)
+	}
+
+	// Money-weighted: most of the money went in before the fall, so it trails the time-weighted return
+	if year2024.MoneyWeighted == nil || *year2024.MoneyWeighted >= year2024.TimeWeighted || *year2024.MoneyWeighted <= 0 {
+		t.Errorf("Expected a positive money-weighted return below 80%%, got %v", year2024.MoneyWeighted)
+	}
+
+	// With no cash, the position's returns are the portfolio's
+	if len(report.Positions) != 1 {
+		t.Fatalf("Expected 1 position, got %+v", report.Positions)
+	}
+	position := report.Positions[0]
+	if position.ISIN != "IE00B4L5Y983" || abs(position.Overall.TimeWeighted-98) > 1e-6 || len(position.Years) != 2 {
+		t.Errorf("Unexpected position returns %+v", position)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	rate, ok := xirr([]dated{{date: start, amount: -1000}, {date: start.AddDate(0, 0, 365), amount: 1100}})
+	if !ok || abs(rate-0.1) > 1e-6 {
+		t.Errorf("xirr() = %v, %v, want 0.1", rate, ok)
+	}
+
+	rate, ok = xirr([]dated{
+		{date: start, amount: -1000},
+		{date: start.AddDate(0, 0, 365), amount: -1000},
+		{date: start.AddDate(0, 0, 730), amount: 2000},
+	})
+	if !ok || abs(rate) > 1e-6 {
+		t.Errorf("xirr() = %v, %v, want 0 for money returned unchanged", rate, ok)
+	}
+
+	if _, ok := xirr([]dated{{date: start, amount: 1000}}); ok {
+		t.Error("Expected no solution without money paid in")
+	}
+}
+
+func TestFinancialCalculator_YearlyReturns(t *testing.T) {
+	buy := tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 100)
+	buy.Total, buy.CurrencyTotal = floatPtr(1000), stringPtr("EUR")
+	sell := tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 6, 3, 10, 0, 0, 0, time.UTC), "IE00B4L5Y983", 10, 150)
+	sell.Total, sell.CurrencyTotal = floatPtr(1500), stringPtr("EUR")
+	transactions := []types.Transaction{
+		{Action: types.TransactionTypeDeposit, Time: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC), Total: floatPtr(1000), CurrencyTotal: stringPtr("EUR")},
+		buy,
+		sell,
+		{Action: types.TransactionTypeDeposit, Time: time.Date(2025, 2, 3, 9, 0, 0, 0, time.UTC), Total: floatPtr(500), CurrencyTotal: stringPtr("EUR")},
+	}
+
+	returns := NewFinancialCalculator("EUR").CalculateReturns(transactions, time.Time{})
+	if len(returns.Years) != 2 {
+		t.Fatalf("Expected 2 years of returns, got %d", len(returns.Years))
+	}
+
+	// The 2025 deposit sits in cash, so it does not change the time-weighted return
+	if abs(returns.Years[0].TimeWeighted-50) > 1e-6 || returns.Years[0].MoneyWeighted == nil {
+		t.Errorf("Expected a 50%% time-weighted return and a money-weighted return for 2024, got %+v", returns.Years[0])
+	}
+	if abs(returns.Years[1].TimeWeighted) > 1e-6 {
+		t.Errorf("Expected a flat 2025, got %.4f", returns.Years[1].TimeWeighted)
+	}
+	if abs(returns.Overall.TimeWeighted-50) > 1e-6 {
+		t.Errorf("Expected an overall time-weighted return of 50%%, got %.4f", returns.Overall.TimeWeighted)
+	}
+}
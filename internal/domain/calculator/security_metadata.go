@@ -0,0 +1,183 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SecurityMetadataSchemaVersion is the version of the YAML security metadata format this build reads
+const SecurityMetadataSchemaVersion = 1
+
+// SecurityMetadata describes a security for allocation reports. Country is where its business or
+// index exposure is, Domicile where the security itself is registered.
+type SecurityMetadata struct {
+	ISIN       string `yaml:"-" json:"isin"`
+	Name       string `yaml:"name" json:"name,omitempty"`
+	Sector     string `yaml:"sector" json:"sector,omitempty"`
+	Country    string `yaml:"country" json:"country,omitempty"`
+	AssetClass string `yaml:"asset_class" json:"asset_class,omitempty"`
+	// Currency is the currency the security trades in; the currency of its trades when empty
+	Currency string `yaml:"currency" json:"currency,omitempty"`
+	// TER is the total expense ratio in percent per year
+	TER      *float64 `yaml:"ter" json:"ter,omitempty"`
+	Domicile string   `yaml:"domicile" json:"domicile,omitempty"`
+}
+
+// securityMetadataFile is the YAML layout of a security metadata file
+type securityMetadataFile struct {
+	Version    int                         `yaml:"version"`
+	Securities map[string]SecurityMetadata `yaml:"securities"`
+}
+
+// SecurityMetadataStore holds security metadata keyed by ISIN
+type SecurityMetadataStore struct {
+	securities map[string]SecurityMetadata
+}
+
+// NewSecurityMetadataStore creates an empty metadata store
+func NewSecurityMetadataStore() *SecurityMetadataStore {
+	return &SecurityMetadataStore{securities: make(map[string]SecurityMetadata)}
+}
+
+// Add records the metadata of a security, replacing any held for its ISIN
+func (s *SecurityMetadataStore) Add(metadata SecurityMetadata) error {
+	metadata.ISIN = strings.ToUpper(strings.TrimSpace(metadata.ISIN))
+	if metadata.ISIN == "" {
+		return fmt.Errorf("security metadata without ISIN")
+	}
+	if metadata.TER != nil && (*metadata.TER < 0 || *metadata.TER > PercentMultiplier) {
+		return fmt.Errorf("invalid TER %.4f for %s", *metadata.TER, metadata.ISIN)
+	}
+	metadata.Country = strings.ToUpper(strings.TrimSpace(metadata.Country))
+	metadata.Domicile = strings.ToUpper(strings.TrimSpace(metadata.Domicile))
+	metadata.Currency = strings.ToUpper(strings.TrimSpace(metadata.Currency))
+	s.securities[metadata.ISIN] = metadata
+	return nil
+}
+
+// Lookup returns the metadata of the security with isin
+func (s *SecurityMetadataStore) Lookup(isin string) (SecurityMetadata, bool) {
+	if s == nil {
+		return SecurityMetadata{}, false
+	}
+	metadata, ok := s.securities[strings.ToUpper(isin)]
+	return metadata, ok
+}
+
+// ISINs returns the securities in the store in order
+func (s *SecurityMetadataStore) ISINs() []string {
+	isins := make([]string, 0, len(s.securities))
+	for isin := range s.securities {
+		isins = append(isins, isin)
+	}
+	sort.Strings(isins)
+	return isins
+}
+
+// LoadSecurityMetadata loads security metadata from a YAML or CSV file, chosen by the file extension
+func LoadSecurityMetadata(filename string) (*SecurityMetadataStore, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open security metadata file %s: %w", filename, err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	var store *SecurityMetadataStore
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		data, readErr := io.ReadAll(file)
+		if readErr != nil {
+			return nil, fmt.Errorf("failed to read security metadata file %s: %w", filename, readErr)
+		}
+		store, err = ParseSecurityMetadataYAML(data)
+	default:
+		store, err = ParseSecurityMetadataCSV(file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return store, nil
+}
+
+// ParseSecurityMetadataYAML reads a versioned YAML file with securities keyed by ISIN
+func ParseSecurityMetadataYAML(data []byte) (*SecurityMetadataStore, error) {
+	var file securityMetadataFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse security metadata: %w", err)
+	}
+	if file.Version != SecurityMetadataSchemaVersion {
+		return nil, fmt.Errorf("unsupported security metadata version %d", file.Version)
+	}
+
+	store := NewSecurityMetadataStore()
+	for isin, metadata := range file.Securities {
+		metadata.ISIN = isin
+		if err := store.Add(metadata); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// ParseSecurityMetadataCSV reads rows under a header naming the columns: isin and any of name,
+// sector, country, asset_class, currency, ter and domicile
+func ParseSecurityMetadataCSV(reader io.Reader) (*SecurityMetadataStore, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.TrimLeadingSpace = true
+	csvReader.FieldsPerRecord = -1
+
+	records, err := csvReader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read security metadata: %w", err)
+	}
+	if len(records) == 0 {
+		return NewSecurityMetadataStore(), nil
+	}
+
+	columns := make(map[string]int, len(records[0]))
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	if _, ok := columns["isin"]; !ok {
+		return nil, fmt.Errorf("security metadata header has no isin column")
+	}
+
+	store := NewSecurityMetadataStore()
+	for line, record := range records[1:] {
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		metadata := SecurityMetadata{
+			ISIN:       field("isin"),
+			Name:       field("name"),
+			Sector:     field("sector"),
+			Country:    field("country"),
+			AssetClass: field("asset_class"),
+			Currency:   field("currency"),
+			Domicile:   field("domicile"),
+		}
+		if ter := strings.TrimSuffix(field("ter"), "%"); ter != "" {
+			value, err := strconv.ParseFloat(ter, 64)
+			if err != nil {
+				return nil, fmt.Errorf("security metadata line %d: invalid TER %q", line+2, field("ter"))
+			}
+			metadata.TER = &value
+		}
+		if err := store.Add(metadata); err != nil {
+			return nil, fmt.Errorf("security metadata line %d: %w", line+2, err)
+		}
+	}
+	return store, nil
+}
@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSecurityMetadataYAML(t *testing.T) {
+	data := []byte(`version: 1
+securities:
+  ie00b4l5y983:
+    name: iShares Core MSCI World
+    sector: Diversified
+    country: global
+    asset_class: Equity ETF
+    ter: 0.20
+    domicile: ie
+  US0378331005:
+    sector: Technology
+`)
+
+	store, err := ParseSecurityMetadataYAML(data)
+	if err != nil {
+		t.Fatalf("ParseSecurityMetadataYAML() error = %v", err)
+	}
+	if got := store.ISINs(); len(got) != 2 || got[0] != "IE00B4L5Y983" {
+		t.Fatalf("Expected two ISINs in upper case, got %v", got)
+	}
+
+	world, ok := store.Lookup("IE00B4L5Y983")
+	if !ok || world.Country != "GLOBAL" || world.Domicile != "IE" || world.TER == nil || *world.TER != 0.2 {
+		t.Errorf("Unexpected metadata %+v", world)
+	}
+	if apple, _ := store.Lookup("us0378331005"); apple.Sector != "Technology" || apple.TER != nil {
+		t.Errorf("Unexpected metadata %+v", apple)
+	}
+
+	if _, err := ParseSecurityMetadataYAML([]byte("version: 2\n")); err == nil {
+		t.Error("Expected an error for an unsupported version")
+	}
+}
+
+func TestParseSecurityMetadataCSV(t *testing.T) {
+	input := `ISIN,Sector,Country,Asset_Class,TER,Domicile
+IE00B4L5Y983,Diversified,Global,Equity ETF,0.20%,IE
+US0378331005,Technology,US,Equity,,
+`
+	store, err := ParseSecurityMetadataCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseSecurityMetadataCSV() error = %v", err)
+	}
+
+	world, _ := store.Lookup("IE00B4L5Y983")
+	if world.AssetClass != "Equity ETF" || world.TER == nil || *world.TER != 0.2 {
+		t.Errorf("Unexpected metadata %+v", world)
+	}
+	if apple, ok := store.Lookup("US0378331005"); !ok || apple.Country != "US" || apple.TER != nil {
+		t.Errorf("Unexpected metadata %+v", apple)
+	}
+
+	tests := []string{
+		"sector,country\nTech,US\n",
+		"isin,ter\nUS0378331005,cheap\n",
+		"isin,ter\nUS0378331005,150\n",
+		"isin,sector\n,Tech\n",
+	}
+	for _, input := range tests {
+		if _, err := ParseSecurityMetadataCSV(strings.NewReader(input)); err == nil {
+			t.Errorf("Expected an error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package calculator
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// simulatedIDPrefix marks the transaction IDs of hypothetical sells
+const simulatedIDPrefix = "simulated-"
+
+// HypotheticalSell is a sell that has not happened, used to preview its tax impact
+type HypotheticalSell struct {
+	// Security is the ticker or ISIN of a security in the transaction history
+	Security string    `json:"security"`
+	Shares   float64   `json:"shares"`
+	Price    float64   `json:"price"`
+	Date     time.Time `json:"date"`
+	// ExchangeRate is units of the price currency per unit of the reporting currency, as in
+	// Trading 212 exports. 0 uses the official rate, falling back to the last rate Trading 212
+	// applied to the security.
+	ExchangeRate float64 `json:"exchange_rate,omitempty"`
+}
+
+// SimulatedSell is a hypothetical sell matched against the open lots it would dispose of
+type SimulatedSell struct {
+	Sell           HypotheticalSell `json:"sell"`
+	TaxYear        int              `json:"tax_year"`
+	Disposal       Disposal         `json:"disposal"`
+	MinHoldingDays int              `json:"min_holding_days"`
+	MaxHoldingDays int              `json:"max_holding_days"`
+}
+
+// SimulationYear compares a tax year's capital gains and tax with and without the hypothetical sells
+type SimulationYear struct {
+	Year int `json:"year"`
+	// NetGainsBefore and NetGainsAfter are net gains after losses brought forward
+	NetGainsBefore  float64 `json:"net_gains_before"`
+	NetGainsAfter   float64 `json:"net_gains_after"`
+	Allowance       float64 `json:"allowance"`
+	AllowanceBefore float64 `json:"allowance_before"`
+	AllowanceAfter  float64 `json:"allowance_after"`
+	TaxBefore       float64 `json:"tax_before"`
+	TaxAfter        float64 `json:"tax_after"`
+	MarginalTax     float64 `json:"marginal_tax"`
+	MarginalRate    float64 `json:"marginal_rate"`
+}
+
+// SimulationReport is the tax impact of a set of hypothetical sells
+type SimulationReport struct {
+	Jurisdiction string           `json:"jurisdiction"`
+	Method       MatchingMethod   `json:"method"`
+	Currency     string           `json:"currency"`
+	Sells        []SimulatedSell  `json:"sells"`
+	Years        []SimulationYear `json:"years"`
+	GainLoss     float64          `json:"gain_loss"`
+	MarginalTax  float64          `json:"marginal_tax"`
+	Warnings     []string         `json:"warnings,omitempty"`
+}
+
+// Simulate previews the tax impact of hypothetical sells. The sells are matched against the lots
+// open on their dates with the jurisdiction's matching method, and each affected tax year's tax is
+// calculated with and without them. The transactions are not modified.
+func (c *TaxCalculator) Simulate(
+	transactions []types.Transaction,
+	sells []HypotheticalSell,
+	options types.ProcessingOptions,
+) (*SimulationReport, error) {
+	if len(sells) == 0 {
+		return nil, errors.New("no sells to simulate")
+	}
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, 0)
+	if err != nil {
+		return nil, err
+	}
+	currency := c.reportingCurrency(jurisdiction, options)
+
+	simulated := make([]types.Transaction, len(transactions), len(transactions)+len(sells))
+	copy(simulated, transactions)
+	for i, sell := range sells {
+		tx, err := hypotheticalTransaction(transactions, sell, i, currency)
+		if err != nil {
+			return nil, err
+		}
+		simulated = append(simulated, tx)
+	}
+
+	engine := c.newLotEngine(jurisdiction, currency)
+	ledger := engine.Process(simulated)
+
+	report := &SimulationReport{
+		Jurisdiction: jurisdiction.Code,
+		Method:       jurisdiction.MatchingMethod,
+		Currency:     currency,
+	}
+
+	years := make(map[int]bool)
+	for _, disposal := range ledger.Disposals {
+		if !strings.HasPrefix(disposal.ID, simulatedIDPrefix) {
+			continue
+		}
+		var index int
+		if _, err := fmt.Sscanf(disposal.ID, simulatedIDPrefix+"%d", &index); err != nil {
+			continue
+		}
+
+		sell := SimulatedSell{
+			Sell:     sells[index],
+			TaxYear:  jurisdiction.TaxYearOf(disposal.Date),
+			Disposal: disposal,
+		}
+		for i, match := range disposal.Matches {
+			if i == 0 || match.HoldingDays < sell.MinHoldingDays {
+				sell.MinHoldingDays = match.HoldingDays
+			}
+			if match.HoldingDays > sell.MaxHoldingDays {
+				sell.MaxHoldingDays = match.HoldingDays
+			}
+		}
+		if disposal.UnmatchedShares > ShareEpsilon {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("%s: selling %.6f more shares than held on %s",
+				sells[index].Security, disposal.UnmatchedShares, disposal.Date.Format(rateDateLayout)))
+		}
+
+		years[sell.TaxYear] = true
+		report.Sells = append(report.Sells, sell)
+		report.GainLoss += disposal.GainLoss
+	}
+
+	sortedYears := make([]int, 0, len(years))
+	for year := range years {
+		sortedYears = append(sortedYears, year)
+	}
+	sort.Ints(sortedYears)
+
+	for _, year := range sortedYears {
+		yearOptions := options
+		yearOptions.TaxYear = year
+
+		simulationYear, err := c.simulateYear(transactions, simulated, yearOptions)
+		if err != nil {
+			return nil, err
+		}
+		report.Years = append(report.Years, simulationYear)
+		report.MarginalTax += simulationYear.MarginalTax
+	}
+
+	return report, nil
+}
+
+// simulateYear calculates options.TaxYear's tax from the actual and the simulated transactions
+func (c *TaxCalculator) simulateYear(
+	actual, simulated []types.Transaction,
+	options types.ProcessingOptions,
+) (SimulationYear, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return SimulationYear{}, err
+	}
+
+	before, err := c.Calculate(actual, options)
+	if err != nil {
+		return SimulationYear{}, err
+	}
+	after, err := c.Calculate(simulated, options)
+	if err != nil {
+		return SimulationYear{}, err
+	}
+
+	allowance := jurisdiction.Allowances.CapitalGains
+	netBefore := before.NetGainLoss - before.LossesUsed
+	netAfter := after.NetGainLoss - after.LossesUsed
+
+	return SimulationYear{
+		Year:            options.TaxYear,
+		NetGainsBefore:  netBefore,
+		NetGainsAfter:   netAfter,
+		Allowance:       allowance,
+		AllowanceBefore: math.Max(allowance-math.Max(netBefore, 0), 0),
+		AllowanceAfter:  math.Max(allowance-math.Max(netAfter, 0), 0),
+		TaxBefore:       before.EstimatedTax,
+		TaxAfter:        after.EstimatedTax,
+		MarginalTax:     after.EstimatedTax - before.EstimatedTax,
+		MarginalRate:    after.MarginalRate,
+	}, nil
+}
+
+// hypotheticalTransaction builds a market sell for a hypothetical sell, taking the security's
+// details from its latest trade in the history
+func hypotheticalTransaction(
+	transactions []types.Transaction,
+	sell HypotheticalSell,
+	index int,
+	currency string,
+) (types.Transaction, error) {
+	if sell.Shares <= 0 {
+		return types.Transaction{}, fmt.Errorf("simulated sell of %s must have a positive number of shares", sell.Security)
+	}
+	if sell.Price <= 0 {
+		return types.Transaction{}, fmt.Errorf("simulated sell of %s must have a positive price", sell.Security)
+	}
+
+	var latest *types.Transaction
+	for i := range transactions {
+		tx := &transactions[i]
+		if !isTradeAction(tx.Action) {
+			continue
+		}
+		if !strings.EqualFold(safeDeref(tx.Ticker), sell.Security) && !strings.EqualFold(safeDeref(tx.ISIN), sell.Security) {
+			continue
+		}
+		if latest == nil || !tx.Time.Before(latest.Time) {
+			latest = tx
+		}
+	}
+	if latest == nil {
+		return types.Transaction{}, fmt.Errorf("no trades found for %s", sell.Security)
+	}
+
+	id := fmt.Sprintf("%s%d", simulatedIDPrefix, index)
+	shares := sell.Shares
+	price := sell.Price
+	tx := types.Transaction{
+		Action:                types.TransactionTypeMarketSell,
+		Time:                  sell.Date,
+		ISIN:                  latest.ISIN,
+		Ticker:                latest.Ticker,
+		Name:                  latest.Name,
+		ID:                    &id,
+		Shares:                &shares,
+		PricePerShare:         &price,
+		CurrencyPricePerShare: latest.CurrencyPricePerShare,
+		ExchangeRate:          latest.ExchangeRate,
+	}
+	if sell.ExchangeRate > 0 {
+		rate := sell.ExchangeRate
+		tx.ExchangeRate = &rate
+	}
+	if priceCurrency := safeDeref(tx.CurrencyPricePerShare); priceCurrency == "" || priceCurrency == currency {
+		tx.ExchangeRate = nil
+	}
+	return tx, nil
+}
@@ -0,0 +1,72 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_Simulate(t *testing.T) {
+	calc := NewTaxCalculator()
+	options := types.ProcessingOptions{Jurisdiction: "LT"}
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 1, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketBuy, time.Date(2024, 3, 10, 10, 0, 0, 0, time.UTC), "US0378331005", 10, 150),
+		tradeTx(types.TransactionTypeMarketSell, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), "US0378331005", 2, 200),
+	}
+
+	sells := []HypotheticalSell{{Security: "1005", Shares: 10, Price: 200, Date: time.Date(2024, 9, 1, 0, 0, 0, 0, time.UTC)}}
+	report, err := calc.Simulate(transactions, sells, options)
+	if err != nil {
+		t.Fatalf("Simulate() error = %v", err)
+	}
+	if len(report.Sells) != 1 || len(report.Years) != 1 {
+		t.Fatalf("Expected 1 sell in 1 tax year, got %+v", report)
+	}
+
+	// FIFO takes the remaining 8 shares of the first lot and 2 of the second
+	sell := report.Sells[0]
+	if len(sell.Disposal.Matches) != 2 || abs(sell.Disposal.GainLoss-900) > 0.001 {
+		t.Errorf("Expected a gain of 900 over 2 lots, got %+v", sell.Disposal)
+	}
+	if sell.TaxYear != 2024 || sell.MinHoldingDays != 175 || sell.MaxHoldingDays != 235 {
+		t.Errorf("Expected holding periods of 175 to 235 days in 2024, got %+v", sell)
+	}
+
+	// 200 already realised leaves 300 of the 500 allowance; 1100 is taxed at 15% above it
+	year := report.Years[0]
+	if year.NetGainsBefore != 200 || abs(year.NetGainsAfter-1100) > 0.001 {
+		t.Errorf("Expected net gains of 200 before and 1100 after, got %+v", year)
+	}
+	if year.AllowanceBefore != 300 || year.AllowanceAfter != 0 {
+		t.Errorf("Expected 300 of the allowance left before and none after, got %+v", year)
+	}
+	if year.TaxBefore != 0 || abs(year.MarginalTax-90) > 0.001 || abs(report.MarginalTax-90) > 0.001 {
+		t.Errorf("Expected 90 of marginal tax, got %+v", year)
+	}
+
+	// The history is left as it was
+	if len(transactions) != 3 {
+		t.Errorf("Expected the history to keep 3 transactions, got %d", len(transactions))
+	}
+	gains, _, _ := calc.CalculateCapitalGains(transactions, types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2024})
+	if gains != 200 {
+		t.Errorf("Expected the actual gains to remain 200, got %.2f", gains)
+	}
+
+	// Selling more than is held is reported
+	sells[0].Shares = 20
+	report, _ = calc.Simulate(transactions, sells, options)
+	if len(report.Warnings) != 1 {
+		t.Errorf("Expected a warning for selling more than held, got %v", report.Warnings)
+	}
+
+	if _, err := calc.Simulate(transactions, []HypotheticalSell{{Security: "MSFT", Shares: 1, Price: 1}}, options); err == nil {
+		t.Error("Expected error for a security without trades")
+	}
+	if _, err := calc.Simulate(transactions, []HypotheticalSell{{Security: "1005", Price: 1}}, options); err == nil {
+		t.Error("Expected error for a sell without shares")
+	}
+}
@@ -0,0 +1,119 @@
+package calculator
+
+import (
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// tradePrice is a per-share price observed on a trade
+type tradePrice struct {
+	date  time.Time
+	price float64
+}
+
+// tradeSecurity identifies a traded security and the currency of its latest trade
+type tradeSecurity struct {
+	date         time.Time
+	isin         string
+	ticker       string
+	currency     string
+	exchangeRate *float64
+}
+
+// tradePriceIndex holds trade prices per security converted into a base currency, preferring
+// closing prices from a price provider where one is set
+type tradePriceIndex struct {
+	prices     map[string][]tradePrice
+	securities map[string]tradeSecurity
+	closes     PriceProvider
+	converter  *currencyConverter
+}
+
+// newTradePriceIndex builds a price index from all trades, converting prices with converter;
+// closes may be nil to value at trade prices only
+func newTradePriceIndex(transactions []types.Transaction, converter *currencyConverter, closes PriceProvider) *tradePriceIndex {
+	index := &tradePriceIndex{
+		prices:     make(map[string][]tradePrice),
+		securities: make(map[string]tradeSecurity),
+		closes:     closes,
+		converter:  converter,
+	}
+
+	for _, tx := range transactions {
+		if !isTradeAction(tx.Action) || tx.PricePerShare == nil || *tx.PricePerShare <= 0 {
+			continue
+		}
+		key := SecurityKey(tx)
+		if key == "" {
+			continue
+		}
+		price := converter.convert(*tx.PricePerShare, tx.CurrencyPricePerShare, tx.Time, tx.ExchangeRate)
+		index.prices[key] = append(index.prices[key], tradePrice{date: tx.Time, price: price})
+		if latest, seen := index.securities[key]; !seen || !tx.Time.Before(latest.date) {
+			index.securities[key] = tradeSecurity{
+				date:         tx.Time,
+				isin:         safeDeref(tx.ISIN),
+				ticker:       safeDeref(tx.Ticker),
+				currency:     safeDeref(tx.CurrencyPricePerShare),
+				exchangeRate: tx.ExchangeRate,
+			}
+		}
+	}
+
+	for key := range index.prices {
+		prices := index.prices[key]
+		sort.SliceStable(prices, func(i, j int) bool {
+			return prices[i].date.Before(prices[j].date)
+		})
+	}
+
+	return index
+}
+
+// priceAt returns the last price for key before date: the closing price of the previous day where
+// the provider has one no older than the last trade, otherwise the last trade price
+func (ti *tradePriceIndex) priceAt(key string, date time.Time) (float64, time.Time, bool) {
+	prices := ti.prices[key]
+	i := sort.Search(len(prices), func(i int) bool {
+		return !prices[i].date.Before(date)
+	}) - 1
+
+	security := ti.securities[key]
+	if quote, ok := closingPrice(ti.closes, security.isin, security.ticker, truncateToDay(date).AddDate(0, 0, -1)); ok {
+		if i < 0 || !quote.Date.Before(truncateToDay(prices[i].date)) {
+			currency := quote.Currency
+			if currency == "" {
+				currency = security.currency
+			}
+			var brokerRate *float64
+			if currency == security.currency {
+				brokerRate = security.exchangeRate
+			}
+			return ti.converter.convert(quote.Price, &currency, quote.Date, brokerRate), quote.Date, true
+		}
+	}
+
+	if i < 0 {
+		return 0, time.Time{}, false
+	}
+	return prices[i].price, prices[i].date, true
+}
+
+// hasCloses reports whether the index values securities at closing prices
+func (ti *tradePriceIndex) hasCloses() bool {
+	return ti.closes != nil
+}
+
+// firstPriceFrom returns the first trade price for key on or after date
+func (ti *tradePriceIndex) firstPriceFrom(key string, date time.Time) (float64, time.Time, bool) {
+	prices := ti.prices[key]
+	i := sort.Search(len(prices), func(i int) bool {
+		return !prices[i].date.Before(date)
+	})
+	if i >= len(prices) {
+		return 0, time.Time{}, false
+	}
+	return prices[i].price, prices[i].date, true
+}
@@ -0,0 +1,164 @@
+package calculator
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TreatySchemaVersion is the version of the treaty-rate table format this build reads
+const TreatySchemaVersion = 1
+
+// treatyDefaultKey is the key of the rate applying to source countries without their own entry
+const treatyDefaultKey = "default"
+
+// ReclaimPeriodStart is the date a reclaim period is counted from
+type ReclaimPeriodStart string
+
+// Reclaim period starts
+const (
+	ReclaimFromPayment ReclaimPeriodStart = "payment"  // The dividend payment date
+	ReclaimFromYearEnd ReclaimPeriodStart = "year_end" // The end of the calendar year of payment
+)
+
+// ReclaimPeriod is how long excess withholding tax can be reclaimed from a source country
+type ReclaimPeriod struct {
+	Years int                `yaml:"years" json:"years"`
+	From  ReclaimPeriodStart `yaml:"from" json:"from"`
+}
+
+// Deadline returns the last day a reclaim for a dividend paid on date can be filed
+func (p ReclaimPeriod) Deadline(date time.Time) time.Time {
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+	if p.From == ReclaimFromYearEnd {
+		start = time.Date(date.Year(), time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return start.AddDate(p.Years, 0, 0)
+}
+
+// defaultTreatyRates holds the built-in treaty-rate table
+//
+//go:embed treaty_rates.yaml
+var defaultTreatyRates []byte
+
+// treatyFile is the YAML layout of a treaty-rate table
+type treatyFile struct {
+	Version        int                           `yaml:"version"`
+	StatutoryRates map[string]float64            `yaml:"statutory_rates"`
+	Treaties       map[string]map[string]float64 `yaml:"treaties"`
+	ReclaimPeriods map[string]ReclaimPeriod      `yaml:"reclaim_periods"`
+}
+
+// TreatyTable holds dividend withholding rates by source country and the treaty rates between
+// residence and source countries
+type TreatyTable struct {
+	statutory map[string]float64
+	treaties  map[string]map[string]float64
+	reclaims  map[string]ReclaimPeriod
+}
+
+// ParseTreatyTable reads a treaty-rate table from YAML
+func ParseTreatyTable(data []byte) (*TreatyTable, error) {
+	var file treatyFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse treaty rates: %w", err)
+	}
+	if file.Version != TreatySchemaVersion {
+		return nil, fmt.Errorf("unsupported treaty rates version %d", file.Version)
+	}
+
+	table := &TreatyTable{
+		statutory: make(map[string]float64, len(file.StatutoryRates)),
+		treaties:  make(map[string]map[string]float64, len(file.Treaties)),
+		reclaims:  make(map[string]ReclaimPeriod, len(file.ReclaimPeriods)),
+	}
+	for source, rate := range file.StatutoryRates {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid statutory rate %.4f for %s", rate, source)
+		}
+		table.statutory[strings.ToUpper(source)] = rate
+	}
+	for residence, rates := range file.Treaties {
+		treaty := make(map[string]float64, len(rates))
+		for source, rate := range rates {
+			if rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("invalid treaty rate %.4f for %s from %s", rate, residence, source)
+			}
+			if source != treatyDefaultKey {
+				source = strings.ToUpper(source)
+			}
+			treaty[source] = rate
+		}
+		table.treaties[strings.ToUpper(residence)] = treaty
+	}
+	for source, period := range file.ReclaimPeriods {
+		switch period.From {
+		case ReclaimFromPayment, ReclaimFromYearEnd:
+		default:
+			return nil, fmt.Errorf("unknown reclaim period start %q for %s", period.From, source)
+		}
+		if period.Years <= 0 {
+			return nil, fmt.Errorf("invalid reclaim period of %d years for %s", period.Years, source)
+		}
+		table.reclaims[strings.ToUpper(source)] = period
+	}
+	return table, nil
+}
+
+// LoadTreatyTable reads a treaty-rate table from a YAML file
+func LoadTreatyTable(path string) (*TreatyTable, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read treaty rates file: %w", err)
+	}
+	table, err := ParseTreatyTable(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return table, nil
+}
+
+// DefaultTreatyTable returns the built-in treaty-rate table
+func DefaultTreatyTable() (*TreatyTable, error) {
+	table, err := ParseTreatyTable(defaultTreatyRates)
+	if err != nil {
+		return nil, fmt.Errorf("built-in treaty rates: %w", err)
+	}
+	return table, nil
+}
+
+// Rate returns the treaty rate on dividends paid from source to a resident of residence, and
+// whether the table lists a treaty rate for the pair. The default rate is not a listed treaty.
+func (t *TreatyTable) Rate(residence, source string) (float64, bool) {
+	treaty, exists := t.treaties[strings.ToUpper(residence)]
+	if !exists || strings.EqualFold(source, treatyDefaultKey) {
+		return 0, false
+	}
+	rate, exists := treaty[strings.ToUpper(source)]
+	return rate, exists
+}
+
+// DefaultRate returns the rate assumed for residents of residence on dividends from source
+// countries without a listed treaty, and whether the table has one
+func (t *TreatyTable) DefaultRate(residence string) (float64, bool) {
+	rate, exists := t.treaties[strings.ToUpper(residence)][treatyDefaultKey]
+	return rate, exists
+}
+
+// StatutoryRate returns the rate source withholds from non-residents without treaty relief, and
+// whether the table knows it
+func (t *TreatyTable) StatutoryRate(source string) (float64, bool) {
+	rate, exists := t.statutory[strings.ToUpper(source)]
+	return rate, exists
+}
+
+// ReclaimPeriod returns how long excess tax withheld by source can be reclaimed, and whether the
+// table knows it
+func (t *TreatyTable) ReclaimPeriod(source string) (ReclaimPeriod, bool) {
+	period, exists := t.reclaims[strings.ToUpper(source)]
+	return period, exists
+}
@@ -0,0 +1,188 @@
+package calculator
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+const (
+	// USLongTermHoldingYears is the holding period beyond which gains are long-term
+	USLongTermHoldingYears = 1
+	// Form8949CodeWashSale is the column (f) adjustment code for a nondeductible wash sale loss
+	Form8949CodeWashSale = "W"
+	// form8949DateLayout is the date format used on Form 8949
+	form8949DateLayout = "01/02/2006"
+)
+
+// Form8949Box is the checkbox a Form 8949 row is reported under
+type Form8949Box string
+
+// Form 8949 boxes for transactions not reported to the IRS on Form 1099-B
+const (
+	Form8949BoxC Form8949Box = "C" // Short-term, no Form 1099-B
+	Form8949BoxF Form8949Box = "F" // Long-term, no Form 1099-B
+)
+
+// Form8949Row is one row of Form 8949, columns (a) to (h)
+type Form8949Row struct {
+	Box          Form8949Box `json:"box"`
+	Description  string      `json:"description"`
+	DateAcquired time.Time   `json:"date_acquired"`
+	DateSold     time.Time   `json:"date_sold"`
+	Proceeds     float64     `json:"proceeds"`
+	Cost         float64     `json:"cost"`
+	Code         string      `json:"code,omitempty"`
+	Adjustment   float64     `json:"adjustment,omitempty"`
+	GainLoss     float64     `json:"gain_loss"`
+}
+
+// ScheduleDLine holds the totals carried from Form 8949 to a Schedule D line
+type ScheduleDLine struct {
+	Line        string  `json:"line"`
+	Description string  `json:"description"`
+	Proceeds    float64 `json:"proceeds"`
+	Cost        float64 `json:"cost"`
+	Adjustments float64 `json:"adjustments"`
+	GainLoss    float64 `json:"gain_loss"`
+}
+
+// USReport holds the Form 8949 listing and Schedule D totals for a year
+type USReport struct {
+	Year                 int           `json:"year"`
+	Currency             string        `json:"currency"`
+	ShortTerm            []Form8949Row `json:"short_term"`
+	LongTerm             []Form8949Row `json:"long_term"`
+	ShortTermTotal       ScheduleDLine `json:"short_term_total"`
+	LongTermTotal        ScheduleDLine `json:"long_term_total"`
+	NetShortTerm         float64       `json:"net_short_term"`
+	NetLongTerm          float64       `json:"net_long_term"`
+	NetGainLoss          float64       `json:"net_gain_loss"`
+	LossesBroughtForward float64       `json:"losses_brought_forward"`
+	LossesUsed           float64       `json:"losses_used"`
+	CapitalLossDeduction float64       `json:"capital_loss_deduction"`
+	LossCarryover        float64       `json:"loss_carryover"`
+	WashSaleDisallowed   float64       `json:"wash_sale_disallowed"`
+	Dividends            float64       `json:"dividends"`
+	WithholdingTax       float64       `json:"withholding_tax"`
+	Disposals            []Disposal    `json:"disposals"`
+	Warnings             []string      `json:"warnings,omitempty"`
+}
+
+// GenerateUSReport lists a year's disposals on Form 8949, split into short-term and long-term
+// with wash sale adjustments, and totals them for Schedule D
+func (c *TaxCalculator) GenerateUSReport(transactions []types.Transaction, year int) (*USReport, error) {
+	jurisdiction, err := c.lookupJurisdiction("US", year)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &USReport{
+		Year:           year,
+		Currency:       jurisdiction.Currency,
+		ShortTermTotal: ScheduleDLine{Line: "3", Description: "Short-term totals from Form 8949 box C"},
+		LongTermTotal:  ScheduleDLine{Line: "10", Description: "Long-term totals from Form 8949 box F"},
+	}
+
+	engine := c.newLotEngine(jurisdiction, jurisdiction.Currency)
+	ledger := engine.Process(transactions)
+	report.Disposals = ledger.DisposalsInYear(year)
+	report.Warnings = ledger.Warnings
+
+	for _, disposal := range report.Disposals {
+		report.WashSaleDisallowed += disposal.WashSaleDisallowed
+		for _, match := range disposal.Matches {
+			row := Form8949Row{
+				Description:  form8949Description(disposal, match.Shares),
+				DateAcquired: match.AcquiredAt,
+				DateSold:     disposal.Date,
+				Proceeds:     match.Proceeds,
+				Cost:         match.Cost,
+				GainLoss:     match.GainLoss,
+			}
+			if match.WashSaleDisallowed > 0 {
+				row.Code = Form8949CodeWashSale
+				row.Adjustment = match.WashSaleDisallowed
+			}
+
+			if IsLongTerm(match.AcquiredAt, disposal.Date) {
+				row.Box = Form8949BoxF
+				report.LongTerm = append(report.LongTerm, row)
+				report.LongTermTotal.add(row)
+			} else {
+				row.Box = Form8949BoxC
+				report.ShortTerm = append(report.ShortTerm, row)
+				report.ShortTermTotal.add(row)
+			}
+		}
+	}
+
+	report.NetShortTerm = report.ShortTermTotal.GainLoss
+	report.NetLongTerm = report.LongTermTotal.GainLoss
+	report.NetGainLoss = report.NetShortTerm + report.NetLongTerm
+
+	// Carryovers from earlier years reduce the gain; a net loss is deductible against other income
+	// up to the yearly limit and the rest carries over
+	nets := make(map[int]float64)
+	for _, disposal := range ledger.Disposals {
+		nets[jurisdiction.TaxYearOf(disposal.Date)] += disposal.GainLoss
+	}
+	losses := lossesForYear(jurisdiction, nets, year)
+	report.LossesBroughtForward = losses.BroughtForward
+	report.LossesUsed = losses.Used
+	report.CapitalLossDeduction = losses.OffsetAgainstIncome
+	report.LossCarryover = losses.CarriedForward
+
+	for _, record := range c.dividendRecords(transactions, jurisdiction, jurisdiction.Currency) {
+		if record.Date.Year() == year {
+			report.Dividends += record.Amount
+			report.WithholdingTax += record.WithholdingTax
+		}
+	}
+
+	return report, nil
+}
+
+// WriteForm8949CSV writes the Form 8949 rows, short-term first, as CSV
+func (r *USReport) WriteForm8949CSV(w io.Writer) error {
+	records := [][]string{{"Box", "(a) Description", "(b) Date acquired", "(c) Date sold", "(d) Proceeds",
+		"(e) Cost or other basis", "(f) Code", "(g) Adjustment", "(h) Gain or (loss)"}}
+	for _, row := range append(append([]Form8949Row{}, r.ShortTerm...), r.LongTerm...) {
+		records = append(records, []string{
+			string(row.Box), row.Description, row.DateAcquired.Format(form8949DateLayout), row.DateSold.Format(form8949DateLayout),
+			formatAmount(row.Proceeds), formatAmount(row.Cost), row.Code, formatAmount(row.Adjustment), formatAmount(row.GainLoss),
+		})
+	}
+
+	if err := csv.NewWriter(w).WriteAll(records); err != nil {
+		return fmt.Errorf("failed to write Form 8949 CSV: %w", err)
+	}
+	return nil
+}
+
+// add accumulates a Form 8949 row into the Schedule D line
+func (l *ScheduleDLine) add(row Form8949Row) {
+	l.Proceeds += row.Proceeds
+	l.Cost += row.Cost
+	l.Adjustments += row.Adjustment
+	l.GainLoss += row.GainLoss
+}
+
+// IsLongTerm reports whether shares held from acquired to disposed were held for more than one year
+func IsLongTerm(acquired, disposed time.Time) bool {
+	return truncateToDay(disposed).After(truncateToDay(acquired).AddDate(USLongTermHoldingYears, 0, 0))
+}
+
+// form8949Description returns the column (a) description of the property sold
+func form8949Description(disposal Disposal, shares float64) string {
+	name := disposal.Ticker
+	if name == "" {
+		name = disposal.Name
+	}
+	quantity := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.6f", shares), "0"), ".")
+	return fmt.Sprintf("%s sh. %s", quantity, name)
+}
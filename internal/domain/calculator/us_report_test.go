@@ -0,0 +1,68 @@
+package calculator
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestTaxCalculator_GenerateUSReport(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 10, 0, 0, 0, time.UTC)
+	}
+
+	transactions := []types.Transaction{
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 1, 2), "US0378331005", 10, 100),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 3, 1), "US0378331005", 10, 80),
+		tradeTx(types.TransactionTypeMarketBuy, day(2024, 3, 15), "US0378331005", 5, 85),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 6, 1), "US0378331005", 5, 90),
+		tradeTx(types.TransactionTypeMarketBuy, day(2023, 1, 10), "US5949181045", 2, 100),
+		tradeTx(types.TransactionTypeMarketSell, day(2024, 6, 1), "US5949181045", 2, 300),
+	}
+
+	report, err := calc.GenerateUSReport(transactions, 2024)
+	if err != nil {
+		t.Fatalf("GenerateUSReport() error = %v", err)
+	}
+
+	if len(report.ShortTerm) != 2 || len(report.LongTerm) != 1 {
+		t.Fatalf("Expected 2 short-term and 1 long-term rows, got %d and %d", len(report.ShortTerm), len(report.LongTerm))
+	}
+
+	washRow := report.ShortTerm[0]
+	if washRow.Code != Form8949CodeWashSale || washRow.Adjustment != 100 || washRow.GainLoss != -100 {
+		t.Errorf("Unexpected wash sale row: %+v", washRow)
+	}
+
+	short := report.ShortTermTotal
+	if short.Proceeds != 1250 || short.Cost != 1525 || short.Adjustments != 100 || short.GainLoss != -175 {
+		t.Errorf("Unexpected Schedule D line 3 totals: %+v", short)
+	}
+	if report.NetLongTerm != 400 || report.NetGainLoss != 225 {
+		t.Errorf("Expected long-term 400 and net 225, got %.2f and %.2f", report.NetLongTerm, report.NetGainLoss)
+	}
+
+	var buf bytes.Buffer
+	if err := report.WriteForm8949CSV(&buf); err != nil {
+		t.Fatalf("WriteForm8949CSV() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "C,10 sh. 1005,01/02/2024,03/01/2024,800.00,1000.00,W,100.00,-100.00") {
+		t.Errorf("Unexpected Form 8949 CSV:\n%s", buf.String())
+	}
+}
+
+func TestIsLongTerm(t *testing.T) {
+	acquired := time.Date(2023, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	if IsLongTerm(acquired, time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected exactly one year to be short-term")
+	}
+	if !IsLongTerm(acquired, time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC)) {
+		t.Error("Expected more than one year to be long-term")
+	}
+}
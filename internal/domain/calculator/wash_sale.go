@@ -0,0 +1,160 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// washSale is the part of a loss match still waiting for replacement shares
+type washSale struct {
+	disposal     int
+	match        int
+	date         time.Time
+	shares       float64
+	lossPerShare float64
+	holding      time.Duration
+}
+
+// SetWashSaleDays enables the wash sale rule: losses are disallowed to the extent the same
+// security is bought within days before or after the sale, and the disallowed loss is added to the
+// basis of the replacement shares, whose holding period includes that of the shares sold
+func (le *LotEngine) SetWashSaleDays(days int) {
+	le.washSaleDays = days
+}
+
+// recordWashSales applies the wash sale rule to a loss disposal, first against shares bought in the
+// window before the sale and then queueing the rest for purchases after it
+func (le *LotEngine) recordWashSales(
+	ledger *LotLedger,
+	disposalIndex int,
+	open []*Lot,
+	pending map[string][]*washSale,
+	key string,
+) []*Lot {
+	disposal := &ledger.Disposals[disposalIndex]
+
+	// Shares from the lots sold are not replacement shares
+	sold := make(map[string]bool, len(disposal.Matches))
+	for _, match := range disposal.Matches {
+		sold[match.LotID] = true
+	}
+
+	for i, match := range disposal.Matches {
+		if match.GainLoss >= 0 || match.Shares <= ShareEpsilon {
+			continue
+		}
+
+		sale := &washSale{
+			disposal:     disposalIndex,
+			match:        i,
+			date:         disposal.Date,
+			shares:       match.Shares,
+			lossPerShare: -match.GainLoss / match.Shares,
+			holding:      disposal.Date.Sub(match.AcquiredAt),
+		}
+
+		for j := 0; j < len(open) && sale.shares > ShareEpsilon; j++ {
+			lot := open[j]
+			if sold[lot.ID] || HoldingDays(lot.PurchasedAt, disposal.Date) > le.washSaleDays {
+				continue
+			}
+			open = le.applyWashSale(ledger, open, j, sale)
+		}
+
+		if sale.shares > ShareEpsilon {
+			pending[key] = append(pending[key], sale)
+		}
+	}
+
+	return open
+}
+
+// replaceWashSales uses a new purchase, the last open lot, as replacement shares for earlier loss
+// sales within the window
+func (le *LotEngine) replaceWashSales(
+	ledger *LotLedger,
+	open []*Lot,
+	pending map[string][]*washSale,
+	key string,
+	date time.Time,
+) []*Lot {
+	waiting := pending[key][:0]
+	for _, sale := range pending[key] {
+		if HoldingDays(sale.date, date) > le.washSaleDays {
+			continue
+		}
+		open = le.applyWashSale(ledger, open, len(open)-1, sale)
+		if sale.shares > ShareEpsilon {
+			waiting = append(waiting, sale)
+		}
+	}
+	pending[key] = waiting
+
+	return open
+}
+
+// applyWashSale disallows the loss on as many shares as the lot at index can replace, splitting
+// the lot when only part of it is needed
+func (le *LotEngine) applyWashSale(ledger *LotLedger, open []*Lot, index int, sale *washSale) []*Lot {
+	lot := open[index]
+	available := lot.Remaining - lot.washReplaced
+	if available <= ShareEpsilon {
+		return open
+	}
+
+	shares := math.Min(available, sale.shares)
+	replacement := lot
+	if shares < lot.Remaining-ShareEpsilon {
+		replacement = splitLot(ledger, lot, shares)
+		open = append(open[:index+1], append([]*Lot{replacement}, open[index+1:]...)...)
+	}
+
+	disallowed := shares * sale.lossPerShare
+	replacement.Cost += disallowed
+	replacement.WashSaleAdjustment += disallowed
+	replacement.AcquiredAt = replacement.AcquiredAt.Add(-sale.holding)
+	replacement.washReplaced = replacement.Remaining
+
+	disposal := &ledger.Disposals[sale.disposal]
+	disposal.Matches[sale.match].WashSaleDisallowed += disallowed
+	disposal.Matches[sale.match].GainLoss += disallowed
+	disposal.WashSaleDisallowed += disallowed
+	disposal.GainLoss += disallowed
+
+	sale.shares -= shares
+	return open
+}
+
+// splitLot moves shares of a lot's remaining shares into a new lot with the same cost per share
+func splitLot(ledger *LotLedger, lot *Lot, shares float64) *Lot {
+	costPerShare := lot.CostPerShare()
+	localPerShare := 0.0
+	if lot.Shares > 0 {
+		localPerShare = lot.LocalCost / lot.Shares
+	}
+
+	split := &Lot{
+		ID:            fmt.Sprintf("%s-%d", lot.ID, len(ledger.Lots)),
+		ISIN:          lot.ISIN,
+		Ticker:        lot.Ticker,
+		Name:          lot.Name,
+		AcquiredAt:    lot.AcquiredAt,
+		PurchasedAt:   lot.PurchasedAt,
+		Shares:        shares,
+		Cost:          shares * costPerShare,
+		Remaining:     shares,
+		Currency:      lot.Currency,
+		LocalCost:     shares * localPerShare,
+		LocalCurrency: lot.LocalCurrency,
+		Splits:        lot.Splits,
+	}
+
+	lot.Shares -= shares
+	lot.Remaining -= shares
+	lot.Cost -= split.Cost
+	lot.LocalCost -= split.LocalCost
+
+	ledger.Lots = append(ledger.Lots, split)
+	return split
+}
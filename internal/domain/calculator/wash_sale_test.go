@@ -0,0 +1,116 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func TestLotEngine_WashSales(t *testing.T) {
+	day := func(month time.Month, d int) time.Time {
+		return time.Date(2024, month, d, 10, 0, 0, 0, time.UTC)
+	}
+
+	t.Run("replacement bought after the sale", func(t *testing.T) {
+		engine := NewLotEngine("EUR", MatchingFIFO, nil)
+		engine.SetWashSaleDays(30)
+
+		ledger := engine.Process([]types.Transaction{
+			tradeTx(types.TransactionTypeMarketBuy, day(1, 2), "US0378331005", 10, 100),
+			tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US0378331005", 10, 80),
+			tradeTx(types.TransactionTypeMarketBuy, day(3, 15), "US0378331005", 5, 85),
+		})
+
+		disposal := ledger.Disposals[0]
+		if disposal.WashSaleDisallowed != 100 || disposal.GainLoss != -100 {
+			t.Errorf("Expected 100 disallowed and -100 allowed loss, got %.2f and %.2f", disposal.WashSaleDisallowed, disposal.GainLoss)
+		}
+
+		open := ledger.OpenLots()
+		if len(open) != 1 {
+			t.Fatalf("Expected 1 open lot, got %d", len(open))
+		}
+		if open[0].Cost != 525 || open[0].WashSaleAdjustment != 100 {
+			t.Errorf("Expected replacement basis 525 with 100 adjustment, got %.2f and %.2f", open[0].Cost, open[0].WashSaleAdjustment)
+		}
+		// The 59 days the sold shares were held carry over to the replacement shares
+		if !open[0].AcquiredAt.Equal(day(1, 16)) {
+			t.Errorf("Expected holding period to start 2024-01-16, got %s", open[0].AcquiredAt)
+		}
+		if !open[0].PurchasedAt.Equal(day(3, 15)) {
+			t.Errorf("Expected the purchase date to stay 2024-03-15, got %s", open[0].PurchasedAt)
+		}
+	})
+
+	t.Run("replacement bought before the sale is split", func(t *testing.T) {
+		engine := NewLotEngine("EUR", MatchingFIFO, nil)
+		engine.SetWashSaleDays(30)
+
+		ledger := engine.Process([]types.Transaction{
+			tradeTx(types.TransactionTypeMarketBuy, day(1, 2), "US0378331005", 4, 100),
+			tradeTx(types.TransactionTypeMarketBuy, day(2, 20), "US0378331005", 10, 70),
+			tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US0378331005", 4, 70),
+		})
+
+		if ledger.Disposals[0].WashSaleDisallowed != 120 {
+			t.Errorf("Expected 120 disallowed, got %.2f", ledger.Disposals[0].WashSaleDisallowed)
+		}
+
+		open := ledger.OpenLots()
+		if len(open) != 2 {
+			t.Fatalf("Expected the replacement lot to be split in two, got %d lots", len(open))
+		}
+		if open[0].Cost != 420 || open[1].Cost != 400 || open[1].Remaining != 4 {
+			t.Errorf("Unexpected lots after split: %+v, %+v", *open[0], *open[1])
+		}
+	})
+
+	t.Run("replacement shares sold at a loss and replaced again", func(t *testing.T) {
+		engine := NewLotEngine("EUR", MatchingFIFO, nil)
+		engine.SetWashSaleDays(30)
+
+		ledger := engine.Process([]types.Transaction{
+			tradeTx(types.TransactionTypeMarketBuy, day(1, 2), "US0378331005", 10, 100),
+			tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US0378331005", 10, 80),
+			tradeTx(types.TransactionTypeMarketBuy, day(3, 15), "US0378331005", 10, 85),
+			// Selling the adjusted lot at a loss washes again against the shares bought on 1 April
+			tradeTx(types.TransactionTypeMarketBuy, day(4, 1), "US0378331005", 10, 70),
+			tradeTx(types.TransactionTypeMarketSell, day(4, 10), "US0378331005", 10, 70),
+		})
+
+		// The second sale's loss is the 150 of price fall plus the 200 disallowed on the first
+		second := ledger.Disposals[1]
+		if second.WashSaleDisallowed != 350 || second.GainLoss != 0 {
+			t.Errorf("Expected the 350 loss on the adjusted lot to be disallowed, got %.2f disallowed and %.2f allowed",
+				second.WashSaleDisallowed, second.GainLoss)
+		}
+
+		open := ledger.OpenLots()
+		if len(open) != 1 || open[0].Cost != 1050 || open[0].WashSaleAdjustment != 350 {
+			t.Fatalf("Expected one replacement lot with basis 1050, got %+v", open)
+		}
+		if !open[0].PurchasedAt.Equal(day(4, 1)) {
+			t.Errorf("Expected the replacement to keep its purchase date, got %s", open[0].PurchasedAt)
+		}
+		// The 59 days of the first lot and the 85 of the adjusted lot carry over
+		if !open[0].AcquiredAt.Equal(day(4, 1).AddDate(0, 0, -85)) {
+			t.Errorf("Expected holding period to start 85 days before the purchase, got %s", open[0].AcquiredAt)
+		}
+	})
+
+	t.Run("gains are not affected", func(t *testing.T) {
+		engine := NewLotEngine("EUR", MatchingFIFO, nil)
+		engine.SetWashSaleDays(30)
+
+		ledger := engine.Process([]types.Transaction{
+			tradeTx(types.TransactionTypeMarketBuy, day(1, 2), "US0378331005", 10, 100),
+			tradeTx(types.TransactionTypeMarketSell, day(3, 1), "US0378331005", 10, 120),
+			tradeTx(types.TransactionTypeMarketBuy, day(3, 15), "US0378331005", 10, 110),
+		})
+
+		if ledger.Disposals[0].WashSaleDisallowed != 0 || ledger.OpenLots()[0].Cost != 1100 {
+			t.Errorf("Expected no wash sale adjustment for a gain")
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package calculator
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+// ForeignTaxCredit shows how foreign tax withheld from one dividend is credited
+type ForeignTaxCredit struct {
+	Date          time.Time `json:"date"`
+	Ticker        string    `json:"ticker,omitempty"`
+	ISIN          string    `json:"isin,omitempty"`
+	Name          string    `json:"name,omitempty"`
+	SourceCountry string    `json:"source_country"`
+	GrossAmount   float64   `json:"gross_amount"`
+	Withheld      float64   `json:"withheld"`
+	// WithholdingRate is the rate actually withheld
+	WithholdingRate float64 `json:"withholding_rate"`
+	// TreatyRate is the rate creditable under the treaty, or when no treaty is listed for the source
+	// country the table's default rate or else the jurisdiction's credit cap
+	TreatyRate float64 `json:"treaty_rate"`
+	// TreatyKnown is true when the table lists a treaty between the two countries
+	TreatyKnown bool    `json:"treaty_known"`
+	DomesticTax float64 `json:"domestic_tax"`
+	Creditable  float64 `json:"creditable"`
+	// NonCreditable is the withholding tax that cannot be credited
+	NonCreditable float64 `json:"non_creditable"`
+	// Reclaimable is the part of NonCreditable withheld above the treaty rate, which may be
+	// reclaimed from the source country
+	Reclaimable float64 `json:"reclaimable"`
+	ResidualTax float64 `json:"residual_tax"`
+}
+
+// ForeignTaxCountrySummary totals foreign tax credits for one source country
+type ForeignTaxCountrySummary struct {
+	Country       string  `json:"country"`
+	Dividends     int     `json:"dividends"`
+	TreatyRate    float64 `json:"treaty_rate"`
+	TreatyKnown   bool    `json:"treaty_known"`
+	GrossAmount   float64 `json:"gross_amount"`
+	Withheld      float64 `json:"withheld"`
+	DomesticTax   float64 `json:"domestic_tax"`
+	Creditable    float64 `json:"creditable"`
+	NonCreditable float64 `json:"non_creditable"`
+	Reclaimable   float64 `json:"reclaimable"`
+	ResidualTax   float64 `json:"residual_tax"`
+}
+
+// ForeignTaxCreditReport is the foreign tax credit calculation for a tax year
+type ForeignTaxCreditReport struct {
+	Jurisdiction     string                     `json:"jurisdiction"`
+	ResidenceCountry string                     `json:"residence_country"`
+	Year             int                        `json:"year"`
+	Currency         string                     `json:"currency"`
+	DomesticRate     float64                    `json:"domestic_rate"`
+	Dividends        []ForeignTaxCredit         `json:"dividends"`
+	Countries        []ForeignTaxCountrySummary `json:"countries"`
+	GrossAmount      float64                    `json:"gross_amount"`
+	Withheld         float64                    `json:"withheld"`
+	DomesticTax      float64                    `json:"domestic_tax"`
+	Creditable       float64                    `json:"creditable"`
+	NonCreditable    float64                    `json:"non_creditable"`
+	Reclaimable      float64                    `json:"reclaimable"`
+	ResidualTax      float64                    `json:"residual_tax"`
+}
+
+// CalculateForeignTaxCredits works out, for each foreign dividend in options.TaxYear, how much of
+// the tax withheld at source is creditable against domestic dividend tax. The credit is capped at
+// the treaty rate between the source country, taken from the ISIN prefix, and the jurisdiction's
+// country, and at the domestic tax on the dividend. Tax withheld above the treaty rate is
+// reported as reclaimable. Dividends from the residence country itself are left out.
+func (c *TaxCalculator) CalculateForeignTaxCredits(
+	transactions []types.Transaction,
+	options types.ProcessingOptions,
+) (*ForeignTaxCreditReport, error) {
+	jurisdiction, err := c.lookupJurisdiction(options.Jurisdiction, options.TaxYear)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ForeignTaxCreditReport{
+		Jurisdiction:     jurisdiction.Code,
+		ResidenceCountry: jurisdiction.Country,
+		Year:             options.TaxYear,
+		Currency:         c.reportingCurrency(jurisdiction, options),
+		DomesticRate:     jurisdiction.DividendTaxRate,
+	}
+
+	countries := make(map[string]*ForeignTaxCountrySummary)
+	for _, record := range c.dividendRecords(transactions, jurisdiction, report.Currency) {
+		if !inTaxYear(jurisdiction, options.TaxYear, record.Date) {
+			continue
+		}
+		source := CountryFromISIN(record.ISIN)
+		if source == jurisdiction.Country {
+			continue
+		}
+
+		credit := c.foreignTaxCredit(jurisdiction, source, record)
+		report.Dividends = append(report.Dividends, credit)
+
+		summary, exists := countries[source]
+		if !exists {
+			summary = &ForeignTaxCountrySummary{
+				Country:     source,
+				TreatyRate:  credit.TreatyRate,
+				TreatyKnown: credit.TreatyKnown,
+			}
+			countries[source] = summary
+		}
+		summary.Dividends++
+		summary.GrossAmount += credit.GrossAmount
+		summary.Withheld += credit.Withheld
+		summary.DomesticTax += credit.DomesticTax
+		summary.Creditable += credit.Creditable
+		summary.NonCreditable += credit.NonCreditable
+		summary.Reclaimable += credit.Reclaimable
+		summary.ResidualTax += credit.ResidualTax
+
+		report.GrossAmount += credit.GrossAmount
+		report.Withheld += credit.Withheld
+		report.DomesticTax += credit.DomesticTax
+		report.Creditable += credit.Creditable
+		report.NonCreditable += credit.NonCreditable
+		report.Reclaimable += credit.Reclaimable
+		report.ResidualTax += credit.ResidualTax
+	}
+
+	for _, summary := range countries {
+		report.Countries = append(report.Countries, *summary)
+	}
+	sort.Slice(report.Countries, func(i, j int) bool {
+		return report.Countries[i].Country < report.Countries[j].Country
+	})
+
+	return report, nil
+}
+
+// foreignTaxCredit applies the treaty rate between the jurisdiction and source to one dividend
+func (c *TaxCalculator) foreignTaxCredit(jurisdiction TaxJurisdiction, source string, record types.DividendRecord) ForeignTaxCredit {
+	treatyRate, known, listed := 0.0, false, false
+	if c.treaties != nil {
+		treatyRate, known = c.treaties.Rate(jurisdiction.Country, source)
+		listed = known
+		if !known {
+			treatyRate, listed = c.treaties.DefaultRate(jurisdiction.Country)
+		}
+	}
+	if !listed {
+		treatyRate = jurisdiction.CreditCap()
+	}
+
+	credit := ForeignTaxCredit{
+		Date:          record.Date,
+		Ticker:        record.Ticker,
+		ISIN:          record.ISIN,
+		Name:          record.Name,
+		SourceCountry: source,
+		GrossAmount:   record.Amount,
+		Withheld:      record.WithholdingTax,
+		TreatyRate:    treatyRate,
+		TreatyKnown:   known,
+		DomesticTax:   record.Amount * jurisdiction.DividendTaxRate,
+	}
+	if record.Amount > 0 {
+		credit.WithholdingRate = record.WithholdingTax / record.Amount
+	}
+
+	credit.Creditable = math.Max(math.Min(credit.Withheld, math.Min(record.Amount*treatyRate, credit.DomesticTax)), 0)
+	credit.NonCreditable = credit.Withheld - credit.Creditable
+	if listed {
+		credit.Reclaimable = math.Max(credit.Withheld-record.Amount*treatyRate, 0)
+	}
+	credit.ResidualTax = math.Max(credit.DomesticTax-credit.Creditable, 0)
+	return credit
+}
@@ -0,0 +1,161 @@
+package calculator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Lizzergas/go-t212-taxes/internal/domain/types"
+)
+
+func dividendTx(date time.Time, isin string, gross, withheld float64) types.Transaction {
+	return types.Transaction{
+		Action:         types.TransactionTypeDividend,
+		Time:           date,
+		ISIN:           stringPtr(isin),
+		Result:         floatPtr(gross),
+		CurrencyResult: stringPtr("EUR"),
+		WithholdingTax: floatPtr(withheld),
+	}
+}
+
+func TestTreatyTable_Rate(t *testing.T) {
+	table, err := DefaultTreatyTable()
+	if err != nil {
+		t.Fatalf("DefaultTreatyTable() error = %v", err)
+	}
+
+	tests := []struct {
+		residence string
+		source    string
+		rate      float64
+		known     bool
+	}{
+		{"BG", "US", 0.10, true},
+		{"BG", "FR", 0, false},
+		{"BG", "default", 0, false},
+		{"gb", "jp", 0.10, true},
+		{"XX", "US", 0, false},
+	}
+
+	for _, tt := range tests {
+		rate, known := table.Rate(tt.residence, tt.source)
+		if rate != tt.rate || known != tt.known {
+			t.Errorf("Rate(%s, %s) = %.2f, %v; expected %.2f, %v", tt.residence, tt.source, rate, known, tt.rate, tt.known)
+		}
+	}
+
+	if rate, ok := table.DefaultRate("BG"); !ok || rate != 0.15 {
+		t.Errorf("DefaultRate(BG) = %.2f, %v; expected 0.15, true", rate, ok)
+	}
+
+	if rate, _ := table.StatutoryRate("CH"); rate != 0.35 {
+		t.Errorf("Expected a 35%% Swiss statutory rate, got %.2f", rate)
+	}
+
+	if _, err := ParseTreatyTable([]byte("version: 1\ntreaties:\n  LT:\n    US: 1.5\n")); err == nil {
+		t.Error("Expected error for a rate above 100%")
+	}
+}
+
+func TestTaxCalculator_CalculateForeignTaxCredits(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	transactions := []types.Transaction{
+		dividendTx(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), "CH0038863350", 100, 35),
+		dividendTx(time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 0),
+		dividendTx(time.Date(2024, 9, 1, 9, 0, 0, 0, time.UTC), "LT0000128266", 100, 15),
+		dividendTx(time.Date(2023, 9, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 15),
+	}
+
+	report, err := calc.CalculateForeignTaxCredits(transactions, types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2024})
+	if err != nil {
+		t.Fatalf("CalculateForeignTaxCredits() error = %v", err)
+	}
+
+	// Domestic Lithuanian dividends and the 2023 dividend are left out
+	if len(report.Dividends) != 2 || len(report.Countries) != 2 {
+		t.Fatalf("Expected 2 foreign dividends from 2 countries, got %+v", report)
+	}
+
+	ch := report.Countries[0]
+	if ch.Country != "CH" || ch.Creditable != 15 || abs(ch.NonCreditable-20) > 0.001 ||
+		abs(ch.Reclaimable-20) > 0.001 || ch.ResidualTax != 0 {
+		t.Errorf("Expected 15 of 35 Swiss tax creditable and 20 reclaimable, got %+v", ch)
+	}
+
+	us := report.Countries[1]
+	if us.Country != "US" || us.Creditable != 0 || us.ResidualTax != 15 {
+		t.Errorf("Expected 15 residual tax on the US dividend, got %+v", us)
+	}
+
+	if report.Withheld != 35 || report.Creditable != 15 || report.ResidualTax != 15 {
+		t.Errorf("Expected totals of 35 withheld, 15 creditable and 15 residual, got %+v", report)
+	}
+}
+
+func TestTaxCalculator_CalculateForeignTaxCreditsCappedByDomesticTax(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	dividend := dividendTx(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 15)
+	dividend.CurrencyResult = stringPtr("BGN")
+	transactions := []types.Transaction{dividend}
+
+	// Bulgaria taxes dividends at 5% and has a 10% treaty rate with the US
+	report, err := calc.CalculateForeignTaxCredits(transactions, types.ProcessingOptions{Jurisdiction: "BG", TaxYear: 2024})
+	if err != nil {
+		t.Fatalf("CalculateForeignTaxCredits() error = %v", err)
+	}
+
+	credit := report.Dividends[0]
+	if credit.TreatyRate != 0.10 || abs(credit.Creditable-5) > 0.001 || abs(credit.NonCreditable-10) > 0.001 ||
+		abs(credit.Reclaimable-5) > 0.001 || credit.ResidualTax != 0 {
+		t.Errorf("Expected 5 creditable, 10 not creditable of which 5 reclaimable, got %+v", credit)
+	}
+
+	// Without a treaty rate the jurisdiction's credit cap applies and nothing is reclaimable
+	table, err := ParseTreatyTable([]byte("version: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	calc.SetTreatyTable(table)
+
+	report, err = calc.CalculateForeignTaxCredits(transactions, types.ProcessingOptions{Jurisdiction: "BG", TaxYear: 2024})
+	if err != nil {
+		t.Fatalf("CalculateForeignTaxCredits() error = %v", err)
+	}
+	if credit := report.Dividends[0]; credit.TreatyKnown || credit.Reclaimable != 0 || abs(credit.Creditable-5) > 0.001 {
+		t.Errorf("Expected the credit cap without a treaty, got %+v", credit)
+	}
+}
+
+func TestTaxCalculator_CalculateCreditsPerDividend(t *testing.T) {
+	calc := NewTaxCalculator()
+
+	// 30 withheld on one dividend cannot cover the tax on another dividend that had none withheld
+	transactions := []types.Transaction{
+		dividendTx(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 0),
+		dividendTx(time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC), "US0378331005", 100, 30),
+	}
+	options := types.ProcessingOptions{Jurisdiction: "LT", TaxYear: 2024, IncludeWithholdingTax: true}
+
+	result, err := calc.Calculate(transactions, options)
+	if err != nil {
+		t.Fatalf("Calculate() error = %v", err)
+	}
+	credits, err := calc.CalculateForeignTaxCredits(transactions, options)
+	if err != nil {
+		t.Fatalf("CalculateForeignTaxCredits() error = %v", err)
+	}
+
+	if credits.Creditable != 15 {
+		t.Errorf("Expected 15 creditable, got %.2f", credits.Creditable)
+	}
+	if abs(result.DividendTax-(30-credits.Creditable)) > 0.001 {
+		t.Errorf("Expected dividend tax of 30 less the 15 credit, got %.2f", result.DividendTax)
+	}
+
+	// The treaty table's default rate is not a listed treaty
+	if credits.Countries[0].TreatyKnown || credits.Countries[0].TreatyRate != 0.15 {
+		t.Errorf("Expected the default 15%% rate without a listed treaty, got %+v", credits.Countries[0])
+	}
+}
@@ -19,6 +19,8 @@ const (
 	TransactionTypeInterest   TransactionType = "Interest"
 	TransactionTypeDeposit    TransactionType = "Deposit"
 	TransactionTypeWithdrawal TransactionType = "Withdrawal"
+	TransactionTypeSplitOpen  TransactionType = "Stock split open"
+	TransactionTypeSplitClose TransactionType = "Stock split close"
 )
 
 // Currency represents supported currencies
@@ -64,21 +66,37 @@ type Transaction struct {
 
 // TaxCalculation represents the result of tax calculations
 type TaxCalculation struct {
-	TotalGains         float64 `json:"total_gains"`
-	TotalLosses        float64 `json:"total_losses"`
-	NetGainLoss        float64 `json:"net_gain_loss"`
-	DividendIncome     float64 `json:"dividend_income"`
-	WithholdingTaxPaid float64 `json:"withholding_tax_paid"`
-	TaxableIncome      float64 `json:"taxable_income"`
-	EstimatedTax       float64 `json:"estimated_tax"`
+	TotalGains           float64 `json:"total_gains"`
+	TotalLosses          float64 `json:"total_losses"`
+	NetGainLoss          float64 `json:"net_gain_loss"`
+	DividendIncome       float64 `json:"dividend_income"`
+	WithholdingTaxPaid   float64 `json:"withholding_tax_paid"`
+	LossesBroughtForward float64 `json:"losses_brought_forward"`
+	LossesUsed           float64 `json:"losses_used"`
+	LossesCarriedForward float64 `json:"losses_carried_forward"`
+	TaxableIncome        float64 `json:"taxable_income"`
+	CapitalGainsTax      float64 `json:"capital_gains_tax"`
+	DividendTax          float64 `json:"dividend_tax"`
+	MarginalRate         float64 `json:"marginal_rate"`
+	EstimatedTax         float64 `json:"estimated_tax"`
+}
+
+// TaxProfile holds the taxpayer details that income-dependent rates depend on
+type TaxProfile struct {
+	// OtherIncome is taxable income other than investment income, after allowances and deductions
+	OtherIncome float64 `json:"other_income"`
+	// AggregateIncome opts in to taxing investment income at the progressive income rates
+	// where a jurisdiction allows it
+	AggregateIncome bool `json:"aggregate_income"`
 }
 
 // ProcessingOptions holds configuration for processing
 type ProcessingOptions struct {
-	TaxYear               int      `json:"tax_year"`
-	Currency              Currency `json:"currency"`
-	Jurisdiction          string   `json:"jurisdiction"`
-	IncludeWithholdingTax bool     `json:"include_withholding_tax"`
+	TaxYear               int        `json:"tax_year"`
+	Currency              Currency   `json:"currency"`
+	Jurisdiction          string     `json:"jurisdiction"`
+	IncludeWithholdingTax bool       `json:"include_withholding_tax"`
+	Profile               TaxProfile `json:"profile"`
 }
 
 // ProcessingResult represents the complete result of CSV processing
@@ -99,15 +117,36 @@ type ProcessingSummary struct {
 
 // YearlyReport represents financial report for a specific year
 type YearlyReport struct {
-	Year               int     `json:"year"`
-	TotalDeposits      float64 `json:"total_deposits"`
-	TotalTransactions  int     `json:"total_transactions"`
-	CapitalGains       float64 `json:"capital_gains"`
-	Dividends          float64 `json:"dividends"`
-	Interest           float64 `json:"interest"`
-	TotalGains         float64 `json:"total_gains"`
-	PercentageIncrease float64 `json:"percentage_increase"`
-	Currency           string  `json:"currency"`
+	Year              int     `json:"year"`
+	TotalDeposits     float64 `json:"total_deposits"`
+	TotalTransactions int     `json:"total_transactions"`
+	// CapitalGains is the net realised gain or loss from matching sells against purchased lots
+	CapitalGains float64 `json:"capital_gains"`
+	// BrokerCapitalGains is the sum of Trading 212's Result column on sells, kept as a cross-check
+	BrokerCapitalGains float64 `json:"broker_capital_gains"`
+	// CapitalGainsDiscrepancy is the matched gain less the broker result over sells that have one
+	CapitalGainsDiscrepancy float64           `json:"capital_gains_discrepancy"`
+	SellsWithoutResult      int               `json:"sells_without_result,omitempty"`
+	GainDiscrepancies       []GainDiscrepancy `json:"gain_discrepancies,omitempty"`
+	LossesBroughtForward    float64           `json:"losses_brought_forward"`
+	LossesUsed              float64           `json:"losses_used"`
+	LossesCarriedForward    float64           `json:"losses_carried_forward"`
+	Dividends               float64           `json:"dividends"`
+	Interest                float64           `json:"interest"`
+	TotalGains              float64           `json:"total_gains"`
+	PercentageIncrease      float64           `json:"percentage_increase"`
+	Currency                string            `json:"currency"`
+}
+
+// GainDiscrepancy is a sell whose matched gain differs from Trading 212's Result
+type GainDiscrepancy struct {
+	Date         time.Time `json:"date"`
+	Ticker       string    `json:"ticker,omitempty"`
+	ISIN         string    `json:"isin,omitempty"`
+	Shares       float64   `json:"shares"`
+	GainLoss     float64   `json:"gain_loss"`
+	BrokerResult float64   `json:"broker_result"`
+	Difference   float64   `json:"difference"`
 }
 
 // OverallReport represents total investment summary across all years
@@ -162,6 +201,7 @@ type DividendRecord struct {
 	DividendYield  float64   `json:"dividend_yield,omitempty"`
 	Shares         float64   `json:"shares,omitempty"`
 	PricePerShare  float64   `json:"price_per_share,omitempty"`
+	PriceCurrency  string    `json:"price_currency,omitempty"`
 }
 
 // InterestRecord represents a detailed interest transaction
@@ -220,6 +260,9 @@ type PortfolioPosition struct {
 	LastPrice                 float64   `json:"last_price"`
 	LastPriceDate             time.Time `json:"last_price_date"`
 	LastPriceCurrency         string    `json:"last_price_currency"`
+	PriceSource               string    `json:"price_source"`
+	PriceAgeDays              int       `json:"price_age_days"`
+	PriceStale                bool      `json:"price_stale"`
 	MarketValue               float64   `json:"market_value"`
 	UnrealizedGainLoss        float64   `json:"unrealized_gain_loss"`
 	UnrealizedGainLossPercent float64   `json:"unrealized_gain_loss_percent"`
@@ -244,6 +287,31 @@ type PortfolioSummary struct {
 	YearlyDeposits                 float64             `json:"yearly_deposits"`
 	YearlyDividends                float64             `json:"yearly_dividends"`
 	YearlyInterest                 float64             `json:"yearly_interest"`
+	Cash                           float64             `json:"cash"`
+	StalePrices                    int                 `json:"stale_prices"`
+}
+
+// PortfolioSnapshot represents the portfolio totals at the close of a day
+type PortfolioSnapshot struct {
+	Date               time.Time `json:"date"`
+	Positions          int       `json:"positions"`
+	CostBasis          float64   `json:"cost_basis"`
+	MarketValue        float64   `json:"market_value"`
+	UnrealizedGainLoss float64   `json:"unrealized_gain_loss"`
+	Cash               float64   `json:"cash"`
+	TotalValue         float64   `json:"total_value"`
+	NetDeposits        float64   `json:"net_deposits"`
+	StalePrices        int       `json:"stale_prices"`
+}
+
+// PortfolioTimeSeries represents portfolio snapshots at regular intervals
+type PortfolioTimeSeries struct {
+	Interval  string              `json:"interval"`
+	From      time.Time           `json:"from"`
+	To        time.Time           `json:"to"`
+	Currency  string              `json:"currency"`
+	Snapshots []PortfolioSnapshot `json:"snapshots"`
+	PriceNote string              `json:"price_note"`
 }
 
 // PortfolioValuationReport represents portfolio valuations across multiple years